@@ -0,0 +1,342 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Keys of the fields rendered by the access log encoder. Fields with other
+// keys are dropped unless the encoder was constructed with AccessLogExtras.
+const (
+	AccessRemoteAddrKey = "remoteAddr"
+	AccessUserKey       = "user"
+	AccessMethodKey     = "method"
+	AccessPathKey       = "path"
+	AccessProtoKey      = "proto"
+	AccessStatusKey     = "status"
+	AccessBytesKey      = "bytes"
+	AccessRefererKey    = "referer"
+	AccessUserAgentKey  = "userAgent"
+)
+
+// The Apache log time layout, %t in LogFormat directives.
+const _accessTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+const (
+	accessRemoteAddr = iota
+	accessUser
+	accessMethod
+	accessPath
+	accessProto
+	accessStatus
+	accessBytes
+	accessReferer
+	accessUserAgent
+	numAccessFields
+)
+
+var accessPool = sync.Pool{New: func() interface{} {
+	return &accessLogEncoder{}
+}}
+
+type accessLogEncoder struct {
+	values [numAccessFields]string
+	set    [numAccessFields]bool
+	// Fields with unrecognized keys (or added inside a namespace) are
+	// accumulated in a text encoder, which also handles nesting.
+	extras     *textEncoder
+	keepExtras bool
+	namespaced bool
+}
+
+// An AccessLogOption is used to set options for an access log encoder.
+type AccessLogOption interface {
+	apply(*accessLogEncoder)
+}
+
+type accessLogOptionFunc func(*accessLogEncoder)
+
+func (opt accessLogOptionFunc) apply(enc *accessLogEncoder) {
+	opt(enc)
+}
+
+// AccessLogExtras appends fields with unrecognized keys to each line, after
+// the standard fields, as a space-separated list of key=value pairs (using the
+// same representation as the text encoder).
+func AccessLogExtras() AccessLogOption {
+	return accessLogOptionFunc(func(enc *accessLogEncoder) {
+		enc.keepExtras = true
+	})
+}
+
+// NewAccessLogEncoder creates an encoder that writes entries as lines in the
+// Apache combined log format:
+//
+//	remoteAddr - user [time] "method path proto" status bytes "referer" "userAgent"
+//
+// The values come from fields with the Access*Key keys, and the time is the
+// entry's timestamp; the message and level aren't written. Missing fields
+// (and a zero byte count) are rendered as "-", and quoted values have any
+// embedded quotes, backslashes, and control characters escaped.
+func NewAccessLogEncoder(options ...AccessLogOption) Encoder {
+	enc := accessPool.Get().(*accessLogEncoder)
+	enc.reset()
+	enc.extras = NewTextEncoder().(*textEncoder)
+	enc.keepExtras = false
+	for _, opt := range options {
+		opt.apply(enc)
+	}
+	return enc
+}
+
+func (enc *accessLogEncoder) reset() {
+	enc.values = [numAccessFields]string{}
+	enc.set = [numAccessFields]bool{}
+	enc.namespaced = false
+}
+
+func (enc *accessLogEncoder) Free() {
+	enc.extras.Free()
+	enc.extras = nil
+	enc.reset()
+	accessPool.Put(enc)
+}
+
+func (enc *accessLogEncoder) Clone() Encoder {
+	clone := accessPool.Get().(*accessLogEncoder)
+	clone.values = enc.values
+	clone.set = enc.set
+	clone.namespaced = enc.namespaced
+	clone.keepExtras = enc.keepExtras
+	clone.extras = enc.extras.Clone().(*textEncoder)
+	return clone
+}
+
+// field returns the index of a recognized key.
+func (enc *accessLogEncoder) field(key string) (int, bool) {
+	if enc.namespaced {
+		return 0, false
+	}
+	switch key {
+	case AccessRemoteAddrKey:
+		return accessRemoteAddr, true
+	case AccessUserKey:
+		return accessUser, true
+	case AccessMethodKey:
+		return accessMethod, true
+	case AccessPathKey:
+		return accessPath, true
+	case AccessProtoKey:
+		return accessProto, true
+	case AccessStatusKey:
+		return accessStatus, true
+	case AccessBytesKey:
+		return accessBytes, true
+	case AccessRefererKey:
+		return accessReferer, true
+	case AccessUserAgentKey:
+		return accessUserAgent, true
+	}
+	return 0, false
+}
+
+func (enc *accessLogEncoder) setValue(key, val string) bool {
+	i, ok := enc.field(key)
+	if ok {
+		enc.values[i], enc.set[i] = val, true
+	}
+	return ok
+}
+
+func (enc *accessLogEncoder) AddString(key, val string) {
+	if !enc.setValue(key, val) {
+		enc.extras.AddString(key, val)
+	}
+}
+
+func (enc *accessLogEncoder) AddByteString(key string, val []byte) {
+	if !enc.setValue(key, string(val)) {
+		enc.extras.AddByteString(key, val)
+	}
+}
+
+func (enc *accessLogEncoder) AddInt(key string, val int) {
+	enc.AddInt64(key, int64(val))
+}
+
+func (enc *accessLogEncoder) AddInt64(key string, val int64) {
+	if !enc.setValue(key, strconv.FormatInt(val, 10)) {
+		enc.extras.AddInt64(key, val)
+	}
+}
+
+func (enc *accessLogEncoder) AddUint(key string, val uint) {
+	enc.AddUint64(key, uint64(val))
+}
+
+func (enc *accessLogEncoder) AddUint64(key string, val uint64) {
+	if !enc.setValue(key, strconv.FormatUint(val, 10)) {
+		enc.extras.AddUint64(key, val)
+	}
+}
+
+func (enc *accessLogEncoder) AddUintptr(key string, val uintptr) {
+	enc.extras.AddUintptr(key, val)
+}
+
+func (enc *accessLogEncoder) AddBinary(key string, val []byte) {
+	enc.extras.AddBinary(key, val)
+}
+
+func (enc *accessLogEncoder) AddBool(key string, val bool) {
+	enc.extras.AddBool(key, val)
+}
+
+func (enc *accessLogEncoder) AddFloat32(key string, val float32) {
+	enc.extras.AddFloat32(key, val)
+}
+
+func (enc *accessLogEncoder) AddFloat64(key string, val float64) {
+	enc.extras.AddFloat64(key, val)
+}
+
+func (enc *accessLogEncoder) AddMarshaler(key string, obj LogMarshaler) error {
+	return enc.extras.AddMarshaler(key, obj)
+}
+
+func (enc *accessLogEncoder) AddArray(key string, arr ArrayMarshaler) error {
+	return enc.extras.AddArray(key, arr)
+}
+
+func (enc *accessLogEncoder) AddRawJSON(key string, val []byte) error {
+	return enc.extras.AddRawJSON(key, val)
+}
+
+// AddObject records Stringers and other objects with recognized keys using
+// their text representation.
+func (enc *accessLogEncoder) AddObject(key string, obj interface{}) error {
+	if _, ok := enc.field(key); ok && obj != nil {
+		s, err := textString(obj)
+		if err != nil {
+			return err
+		}
+		enc.setValue(key, s)
+		return nil
+	}
+	return enc.extras.AddObject(key, obj)
+}
+
+func (enc *accessLogEncoder) OpenNamespace(key string) {
+	enc.namespaced = true
+	enc.extras.OpenNamespace(key)
+}
+
+func (enc *accessLogEncoder) WriteEntry(sink io.Writer, ent Entry) error {
+	if sink == nil {
+		return errNilSink
+	}
+
+	final := textPool.Get().(*textEncoder)
+	final.truncate()
+	buf := final.bytes
+	buf = enc.appendBare(buf, accessRemoteAddr)
+	buf = append(buf, " - "...)
+	buf = enc.appendBare(buf, accessUser)
+	buf = append(buf, " ["...)
+	buf = ent.Time.AppendFormat(buf, _accessTimeLayout)
+	buf = append(buf, `] "`...)
+	if enc.set[accessMethod] || enc.set[accessPath] || enc.set[accessProto] {
+		buf = enc.appendEscaped(buf, accessMethod)
+		buf = append(buf, ' ')
+		buf = enc.appendEscaped(buf, accessPath)
+		buf = append(buf, ' ')
+		buf = enc.appendEscaped(buf, accessProto)
+	} else {
+		buf = append(buf, '-')
+	}
+	buf = append(buf, `" `...)
+	buf = enc.appendBare(buf, accessStatus)
+	buf = append(buf, ' ')
+	if enc.values[accessBytes] == "0" {
+		buf = append(buf, '-')
+	} else {
+		buf = enc.appendBare(buf, accessBytes)
+	}
+	buf = append(buf, ` "`...)
+	buf = enc.appendEscaped(buf, accessReferer)
+	buf = append(buf, `" "`...)
+	buf = enc.appendEscaped(buf, accessUserAgent)
+	buf = append(buf, '"')
+	if enc.keepExtras && (len(enc.extras.bytes) > 0 || enc.extras.prefix != nil) {
+		buf = append(buf, ' ')
+		buf = enc.extras.appendFields(buf)
+	}
+	buf = append(buf, '\n')
+	final.bytes = buf
+
+	expectedBytes := len(final.bytes)
+	n, err := sink.Write(final.bytes)
+	final.Free()
+	if err != nil {
+		return err
+	}
+	if n != expectedBytes {
+		return fmt.Errorf("incomplete write: only wrote %v of %v bytes", n, expectedBytes)
+	}
+	return nil
+}
+
+// appendBare appends an unquoted field, which may not contain spaces or
+// quotes; any that it does contain are escaped.
+func (enc *accessLogEncoder) appendBare(dst []byte, i int) []byte {
+	if enc.values[i] == "" {
+		return append(dst, '-')
+	}
+	return appendAccessEscaped(dst, enc.values[i], true)
+}
+
+func (enc *accessLogEncoder) appendEscaped(dst []byte, i int) []byte {
+	if enc.values[i] == "" {
+		return append(dst, '-')
+	}
+	return appendAccessEscaped(dst, enc.values[i], false)
+}
+
+// appendAccessEscaped escapes values the way Apache's mod_log_config does:
+// quotes and backslashes are backslash-escaped, and control characters (and,
+// for unquoted values, spaces) are written as \xhh.
+func appendAccessEscaped(dst []byte, s string, bare bool) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c < 0x20 || c == 0x7f || (bare && c == ' '):
+			dst = append(dst, '\\', 'x', _hex[c>>4], _hex[c&0xF])
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
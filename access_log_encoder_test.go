@@ -0,0 +1,150 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uber-go/zap/spywrite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _accessTime = time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+
+func writeAccessEntry(t testing.TB, enc Encoder) string {
+	buf := &testBuffer{}
+	require.NoError(t, enc.WriteEntry(buf, Entry{Message: "ignored", Level: InfoLevel, Time: _accessTime}), "Unexpected error writing entry.")
+	return buf.String()
+}
+
+func TestAccessLogEncoder(t *testing.T) {
+	full := []Field{
+		String(AccessRemoteAddrKey, "127.0.0.1"),
+		String(AccessUserKey, "frank"),
+		String(AccessMethodKey, "GET"),
+		String(AccessPathKey, "/apache_pb.gif"),
+		String(AccessProtoKey, "HTTP/1.0"),
+		Int(AccessStatusKey, 200),
+		Int64(AccessBytesKey, 2326),
+		String(AccessRefererKey, "http://www.example.com/start.html"),
+		String(AccessUserAgentKey, "Mozilla/4.08 [en] (Win98; I ;Nav)"),
+	}
+
+	tests := []struct {
+		desc     string
+		opts     []AccessLogOption
+		fields   []Field
+		expected string
+	}{
+		{
+			"complete",
+			nil,
+			full,
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+		},
+		{
+			"no fields",
+			nil,
+			nil,
+			`- - - [10/Oct/2000:13:55:36 -0700] "-" - - "-" "-"`,
+		},
+		{
+			"partial request line and zero bytes",
+			nil,
+			[]Field{String(AccessMethodKey, "HEAD"), Uint(AccessStatusKey, 304), Uint64(AccessBytesKey, 0)},
+			`- - - [10/Oct/2000:13:55:36 -0700] "HEAD - -" 304 - "-" "-"`,
+		},
+		{
+			"escaping",
+			nil,
+			[]Field{
+				String(AccessRemoteAddrKey, "::1"),
+				String(AccessUserKey, "john doe"),
+				ByteString(AccessPathKey, []byte(`/a"b\c`)),
+				String(AccessUserAgentKey, `Mozilla/5.0 "quoted" agent`+"\n"),
+			},
+			`::1 - john\x20doe [10/Oct/2000:13:55:36 -0700] "- /a\"b\\c -" - - "-" "Mozilla/5.0 \"quoted\" agent\x0a"`,
+		},
+		{
+			"stringers and unknown fields",
+			nil,
+			[]Field{Stringer(AccessProtoKey, stringer{}), String("requestID", "abc"), Bool("cached", true)},
+			`- - - [10/Oct/2000:13:55:36 -0700] "- - stringer" - - "-" "-"`,
+		},
+		{
+			"extras",
+			[]AccessLogOption{AccessLogExtras()},
+			append([]Field{
+				String("requestID", "abc"),
+				Duration("latency", time.Millisecond),
+				Error(errors.New("fail")),
+			}, full...),
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)" requestID=abc latency=1000000 error=fail`,
+		},
+		{
+			"namespaced keys are extras",
+			[]AccessLogOption{AccessLogExtras()},
+			[]Field{Int(AccessStatusKey, 500), Namespace("upstream"), Int(AccessStatusKey, 502)},
+			`- - - [10/Oct/2000:13:55:36 -0700] "-" 500 - "-" "-" upstream.status=502`,
+		},
+	}
+
+	for _, tt := range tests {
+		enc := NewAccessLogEncoder(tt.opts...)
+		addFields(enc, tt.fields)
+		assert.Equal(t, tt.expected+"\n", writeAccessEntry(t, enc), "Unexpected access log line for %s.", tt.desc)
+		enc.Free()
+	}
+}
+
+func TestAccessLogEncoderClone(t *testing.T) {
+	parent := NewAccessLogEncoder(AccessLogExtras())
+	defer parent.Free()
+	parent.AddString(AccessRemoteAddrKey, "10.0.0.1")
+	parent.AddString("service", "edge")
+
+	clone := parent.Clone()
+	defer clone.Free()
+	clone.AddInt(AccessStatusKey, 404)
+	clone.AddString("route", "/missing")
+
+	assert.Equal(t, `10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "-" - - "-" "-" service=edge`+"\n", writeAccessEntry(t, parent), "Expected the parent to be unaffected by the clone.")
+	assert.Equal(t, `10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "-" 404 - "-" "-" service=edge route=/missing`+"\n", writeAccessEntry(t, clone), "Unexpected output from the clone.")
+}
+
+func TestAccessLogEncoderLogger(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(NewAccessLogEncoder(), Output(buf)).With(String(AccessRemoteAddrKey, "192.0.2.1"))
+	logger.Info("request", String(AccessMethodKey, "POST"), String(AccessPathKey, "/login"), String(AccessProtoKey, "HTTP/1.1"), Int(AccessStatusKey, 302))
+	assert.Regexp(t, `^192\.0\.2\.1 - - \[[^\]]+\] "POST /login HTTP/1\.1" 302 - "-" "-"$`, buf.Stripped(), "Unexpected access log line from a logger.")
+}
+
+func TestAccessLogEncoderWriteEntryFailure(t *testing.T) {
+	enc := NewAccessLogEncoder()
+	defer enc.Free()
+	assert.Equal(t, errNilSink, enc.WriteEntry(nil, Entry{}), "Expected an error writing to a nil sink.")
+	assert.Error(t, enc.WriteEntry(spywrite.FailWriter{}, Entry{}), "Expected an error when writing to sink fails.")
+	assert.Error(t, enc.WriteEntry(spywrite.ShortWriter{}, Entry{}), "Expected an error on partial writes to sink.")
+}
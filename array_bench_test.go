@@ -0,0 +1,87 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	_benchInts      = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	_benchStrings   = []string{"bar 1", "bar 2", "bar 3", "bar 4", "bar 5", "bar 6", "bar 7", "bar 8", "bar 9", "bar 10"}
+	_benchDurations = []time.Duration{time.Second, time.Minute, time.Hour}
+)
+
+func BenchmarkIntsArrayField(b *testing.B) {
+	b.ReportAllocs()
+	enc := newJSONEncoder()
+	defer enc.Free()
+	field := Ints("ints", _benchInts)
+	for i := 0; i < b.N; i++ {
+		enc.truncate()
+		field.AddTo(enc)
+	}
+}
+
+func BenchmarkIntsReflectField(b *testing.B) {
+	b.ReportAllocs()
+	enc := newJSONEncoder()
+	defer enc.Free()
+	field := Object("ints", _benchInts)
+	for i := 0; i < b.N; i++ {
+		enc.truncate()
+		field.AddTo(enc)
+	}
+}
+
+func BenchmarkStringsArrayField(b *testing.B) {
+	b.ReportAllocs()
+	enc := newJSONEncoder()
+	defer enc.Free()
+	field := Strings("strings", _benchStrings)
+	for i := 0; i < b.N; i++ {
+		enc.truncate()
+		field.AddTo(enc)
+	}
+}
+
+func BenchmarkStringsReflectField(b *testing.B) {
+	b.ReportAllocs()
+	enc := newJSONEncoder()
+	defer enc.Free()
+	field := Object("strings", _benchStrings)
+	for i := 0; i < b.N; i++ {
+		enc.truncate()
+		field.AddTo(enc)
+	}
+}
+
+func BenchmarkDurationsArrayField(b *testing.B) {
+	b.ReportAllocs()
+	enc := newJSONEncoder()
+	defer enc.Free()
+	field := Durations("durations", _benchDurations)
+	for i := 0; i < b.N; i++ {
+		enc.truncate()
+		field.AddTo(enc)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrayFields(t *testing.T) {
+	tests := []struct {
+		field Field
+		json  string
+		text  string
+	}{
+		{Bools("k", []bool{true, false}), `"k":[true,false]`, "k=[true,false]"},
+		{Bools("k", nil), `"k":[]`, "k=[]"},
+		{ByteStrings("k", [][]byte{[]byte("foo"), []byte("bar baz")}), `"k":["foo","bar baz"]`, `k=[foo,"bar baz"]`},
+		{ByteStrings("k", [][]byte{[]byte("\xed\xa0\x80")}), `"k":["\ufffd\ufffd\ufffd"]`, "k=[\xed\xa0\x80]"},
+		{ByteStrings("k", nil), `"k":[]`, "k=[]"},
+		{Durations("k", []time.Duration{time.Nanosecond, time.Microsecond}), `"k":[1,1000]`, "k=[1,1000]"},
+		{Durations("k", nil), `"k":[]`, "k=[]"},
+		{Float64s("k", []float64{1.5, -2, math.NaN(), math.Inf(1)}), `"k":[1.5,-2,"NaN","+Inf"]`, "k=[1.5,-2,NaN,+Inf]"},
+		{Float64s("k", nil), `"k":[]`, "k=[]"},
+		{Ints("k", []int{1, -2, 3}), `"k":[1,-2,3]`, "k=[1,-2,3]"},
+		{Ints("k", nil), `"k":[]`, "k=[]"},
+		{Int64s("k", []int64{math.MaxInt64, math.MinInt64}), `"k":[9223372036854775807,-9223372036854775808]`, "k=[9223372036854775807,-9223372036854775808]"},
+		{Int64s("k", nil), `"k":[]`, "k=[]"},
+		{Strings("k", []string{"foo", "", `"quoted"`, "a,b"}), `"k":["foo","","\"quoted\"","a,b"]`, `k=[foo,"","\"quoted\"","a,b"]`},
+		{Strings("k", nil), `"k":[]`, "k=[]"},
+		{Times("k", []time.Time{time.Unix(0, 0), time.Unix(1, int64(500*time.Millisecond))}), `"k":[0,1.5]`, "k=[0,1.5]"},
+		{Times("k", nil), `"k":[]`, "k=[]"},
+		{Uint64s("k", []uint64{1, math.MaxUint64}), `"k":[1,18446744073709551615]`, "k=[1,18446744073709551615]"},
+		{Uint64s("k", nil), `"k":[]`, "k=[]"},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.json, tt.field)
+		assertCanBeReused(t, tt.field)
+		withTextEncoder(func(enc *textEncoder) {
+			tt.field.AddTo(enc)
+			assert.Equal(t, tt.text, string(enc.bytes), "Unexpected text output for field %+v.", tt.field)
+		})
+	}
+}
+
+func TestArrayFieldsInContext(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		logger.With(Ints("ints", []int{1, 2})).Info("", Strings("strings", []string{"a"}), Int("n", 3))
+		assert.Equal(t, `{"level":"info","msg":"","ints":[1,2],"strings":["a"],"n":3}`, buf.Stripped(), "Unexpected output with array fields.")
+	})
+}
+
+// nested builds an ArrayMarshaler that nests arrays and objects depth levels
+// deep, alternating between the two.
+func nested(depth int) ArrayMarshaler {
+	return ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+		arr.AppendInt64(int64(depth))
+		if depth == 0 {
+			return nil
+		}
+		if depth%2 == 0 {
+			return arr.AppendArray(nested(depth - 1))
+		}
+		return arr.AppendMarshaler(LogMarshalerFunc(func(kv KeyValue) error {
+			return kv.AddArray("inner", nested(depth-1))
+		}))
+	})
+}
+
+func TestArrayMarshaler(t *testing.T) {
+	failing := LogMarshalerFunc(func(kv KeyValue) error {
+		kv.OpenNamespace("ns")
+		kv.AddString("partial", "yes")
+		return errors.New("fail")
+	})
+	tests := []struct {
+		desc  string
+		field Field
+		json  string
+		text  string
+	}{
+		{
+			"empty",
+			Array("k", ArrayMarshalerFunc(func(ArrayEncoder) error { return nil })),
+			`"k":[]`,
+			"k=[]",
+		},
+		{
+			"deeply nested",
+			Array("k", nested(4)),
+			`"k":[4,[3,{"inner":[2,[1,{"inner":[0]}]]}]]`,
+			"k=[4,[3,{inner=[2,[1,{inner=[0]}]]}]]",
+		},
+		{
+			"erroring element",
+			Array("k", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+				arr.AppendString("ok")
+				return arr.AppendMarshaler(failing)
+			})),
+			`"k":["ok",{"ns":{"partial":"yes"}}],"kError":"fail"`,
+			"k=[ok,{ns.partial=yes}] kError=fail",
+		},
+		{
+			"erroring array",
+			Array("k", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+				arr.AppendBool(true)
+				arr.AppendArray(ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+					arr.AppendFloat64(1.5)
+					return errors.New("fail")
+				}))
+				return errors.New("outer fail")
+			})),
+			`"k":[true,[1.5]],"kError":"outer fail"`,
+			"k=[true,[1.5]] kError=outer fail",
+		},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.json, tt.field)
+		assertCanBeReused(t, tt.field)
+		withTextEncoder(func(enc *textEncoder) {
+			tt.field.AddTo(enc)
+			assert.Equal(t, tt.text, string(enc.bytes), "Unexpected text output for %s array.", tt.desc)
+		})
+	}
+}
+
+func TestArrayMarshalerEncoderState(t *testing.T) {
+	// After an error, the encoder should still produce well-formed entries.
+	failing := Array("k", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+		return arr.AppendMarshaler(LogMarshalerFunc(func(KeyValue) error {
+			return errors.New("fail")
+		}))
+	}))
+
+	enc := NewJSONEncoder(NoTime())
+	defer enc.Free()
+	failing.AddTo(enc)
+	enc.AddString("after", "error")
+
+	buf := &testBuffer{}
+	require.NoError(t, enc.WriteEntry(buf, Entry{Message: "state", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.Equal(t, `{"level":"info","msg":"state","k":[{}],"kError":"fail","after":"error"}`, buf.Stripped(), "Unexpected output after a marshaling error.")
+}
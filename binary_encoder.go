@@ -0,0 +1,495 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// The binary encoder writes each entry as a Protocol Buffers message, so any
+// protobuf library can decode it using the following (proto3) schema. Fields
+// are written in the order they were added; nested objects, arrays, and
+// namespaces carry their members in the nested fields slot, and array elements
+// have empty keys.
+//
+//	message Entry {
+//	  sint64 time_unix_nano = 1;
+//	  sint32 level = 2;
+//	  reserved 3; // logger name
+//	  string message = 4;
+//	  Caller caller = 5;
+//	  string stack = 6;
+//	  repeated Field fields = 7;
+//	}
+//
+//	message Caller {
+//	  string file = 1;
+//	  int64 line = 2;
+//	  uint64 pc = 3;
+//	}
+//
+//	message Field {
+//	  string key = 1;
+//	  Type type = 2;
+//	  bool bool_value = 3;
+//	  sint64 int_value = 4;
+//	  uint64 uint_value = 5;
+//	  double float_value = 6;
+//	  repeated Field fields = 7;
+//	  bytes bytes_value = 8;
+//	}
+//
+// The Type enumeration is exported by the zapdecode package.
+const (
+	_binEntryTime    = 1
+	_binEntryLevel   = 2
+	_binEntryMessage = 4
+	_binEntryCaller  = 5
+	_binEntryStack   = 6
+	_binEntryFields  = 7
+
+	_binCallerFile = 1
+	_binCallerLine = 2
+	_binCallerPC   = 3
+
+	_binFieldKey    = 1
+	_binFieldType   = 2
+	_binFieldBool   = 3
+	_binFieldInt    = 4
+	_binFieldUint   = 5
+	_binFieldFloat  = 6
+	_binFieldFields = 7 // must match _binEntryFields
+	_binFieldBytes  = 8
+)
+
+// Protobuf wire types.
+const (
+	_binVarint  = 0
+	_binFixed64 = 1
+	_binBytes   = 2
+)
+
+// Field types, as written in the Field message's type slot. These must match
+// the zapdecode package's Type constants.
+const (
+	_binTypeBool       = 1
+	_binTypeInt64      = 2
+	_binTypeUint64     = 3
+	_binTypeUintptr    = 4
+	_binTypeFloat32    = 5
+	_binTypeFloat64    = 6
+	_binTypeString     = 7
+	_binTypeByteString = 8
+	_binTypeBinary     = 9
+	_binTypeRawJSON    = 10
+	_binTypeObject     = 11
+	_binTypeArray      = 12
+	_binTypeNamespace  = 13
+)
+
+var binaryPool = sync.Pool{New: func() interface{} {
+	return &binaryEncoder{
+		bytes: make([]byte, 0, _initialBufSize),
+	}
+}}
+
+type binaryEncoder struct {
+	bytes []byte
+	// The offsets of the length prefixes of the open namespaces, innermost
+	// last. Namespaces stay open until the entry is written, so their lengths
+	// aren't known until then.
+	namespaces []int
+}
+
+// NewBinaryEncoder creates an encoder that writes each entry as a compact,
+// length-prefixed Protocol Buffers message: the encoded entry is preceded by
+// its size in bytes as an unsigned varint, so streams of entries are
+// self-delimiting. It's meant for shipping logs between machines rather than
+// for reading them; the zapdecode package reads entries back. Since the
+// encoder writes each entry's Caller and Stack as structured data, use it with
+// RecordCaller and RecordStacks rather than AddCaller and AddStacks.
+//
+// Unlike the JSON and text encoders, the binary encoder preserves the types
+// of fields. Objects added with AddObject are serialized with encoding/json
+// and kept as RawJSON values, unless they implement LogMarshaler, error, or
+// fmt.Stringer.
+func NewBinaryEncoder() Encoder {
+	enc := binaryPool.Get().(*binaryEncoder)
+	enc.truncate()
+	return enc
+}
+
+func (enc *binaryEncoder) Free() {
+	if cap(enc.bytes) > _maxPooledBufSize {
+		return
+	}
+	binaryPool.Put(enc)
+}
+
+func (enc *binaryEncoder) truncate() {
+	enc.bytes = enc.bytes[:0]
+	enc.namespaces = enc.namespaces[:0]
+}
+
+func (enc *binaryEncoder) AddString(key, val string) {
+	start := enc.openField(key, _binTypeString)
+	enc.bytes = appendBinaryString(enc.bytes, _binFieldBytes, val)
+	enc.closeMessage(start)
+}
+
+func (enc *binaryEncoder) AddByteString(key string, val []byte) {
+	start := enc.openField(key, _binTypeByteString)
+	enc.bytes = appendBinaryBytes(enc.bytes, _binFieldBytes, val)
+	enc.closeMessage(start)
+}
+
+func (enc *binaryEncoder) AddBinary(key string, val []byte) {
+	start := enc.openField(key, _binTypeBinary)
+	enc.bytes = appendBinaryBytes(enc.bytes, _binFieldBytes, val)
+	enc.closeMessage(start)
+}
+
+// AddRawJSON adds a pre-encoded JSON value. Invalid values are added as byte
+// strings instead, and AddRawJSON returns an error.
+func (enc *binaryEncoder) AddRawJSON(key string, val []byte) error {
+	var raw json.RawMessage
+	if err := json.Unmarshal(val, &raw); err != nil {
+		enc.AddByteString(key, val)
+		return errInvalidRawJSON
+	}
+	enc.addRawJSON(key, val)
+	return nil
+}
+
+func (enc *binaryEncoder) addRawJSON(key string, val []byte) {
+	start := enc.openField(key, _binTypeRawJSON)
+	enc.bytes = appendBinaryBytes(enc.bytes, _binFieldBytes, val)
+	enc.closeMessage(start)
+}
+
+func (enc *binaryEncoder) AddBool(key string, val bool) {
+	start := enc.openField(key, _binTypeBool)
+	enc.bytes = appendBinaryBool(enc.bytes, val)
+	enc.closeMessage(start)
+}
+
+func (enc *binaryEncoder) AddInt(key string, val int) {
+	enc.AddInt64(key, int64(val))
+}
+
+func (enc *binaryEncoder) AddInt64(key string, val int64) {
+	start := enc.openField(key, _binTypeInt64)
+	enc.bytes = appendBinarySint(enc.bytes, _binFieldInt, val)
+	enc.closeMessage(start)
+}
+
+func (enc *binaryEncoder) AddUint(key string, val uint) {
+	enc.AddUint64(key, uint64(val))
+}
+
+func (enc *binaryEncoder) AddUint64(key string, val uint64) {
+	enc.addUint(key, _binTypeUint64, val)
+}
+
+func (enc *binaryEncoder) AddUintptr(key string, val uintptr) {
+	enc.addUint(key, _binTypeUintptr, uint64(val))
+}
+
+func (enc *binaryEncoder) addUint(key string, typ uint64, val uint64) {
+	start := enc.openField(key, typ)
+	enc.bytes = appendBinaryUint(enc.bytes, _binFieldUint, val)
+	enc.closeMessage(start)
+}
+
+func (enc *binaryEncoder) AddFloat64(key string, val float64) {
+	enc.addFloat(key, _binTypeFloat64, val)
+}
+
+// AddFloat32 widens the value to a float64, which represents it exactly, but
+// records that it was a float32.
+func (enc *binaryEncoder) AddFloat32(key string, val float32) {
+	enc.addFloat(key, _binTypeFloat32, float64(val))
+}
+
+func (enc *binaryEncoder) addFloat(key string, typ uint64, val float64) {
+	start := enc.openField(key, typ)
+	enc.bytes = appendBinaryDouble(enc.bytes, val)
+	enc.closeMessage(start)
+}
+
+func (enc *binaryEncoder) AddMarshaler(key string, obj LogMarshaler) error {
+	start := enc.openField(key, _binTypeObject)
+	err := enc.marshal(obj)
+	enc.closeMessage(start)
+	return err
+}
+
+// marshal adds the object's members to the current message, closing any
+// namespaces the object opens.
+func (enc *binaryEncoder) marshal(obj LogMarshaler) error {
+	depth := len(enc.namespaces)
+	err := obj.MarshalLog(enc)
+	enc.closeNamespaces(depth)
+	return err
+}
+
+func (enc *binaryEncoder) AddArray(key string, arr ArrayMarshaler) error {
+	start := enc.openField(key, _binTypeArray)
+	err := arr.MarshalLogArray(enc)
+	enc.closeMessage(start)
+	return err
+}
+
+func (enc *binaryEncoder) AddObject(key string, obj interface{}) error {
+	if m, ok := obj.(LogMarshaler); ok && !isNilPointer(m) {
+		return enc.AddMarshaler(key, m)
+	}
+	if m, ok := lookupTypeEncoder(obj); ok {
+		return enc.AddMarshaler(key, m)
+	}
+	if s, ok, err := canonicalString(obj); ok {
+		enc.AddString(key, s)
+		return err
+	}
+	// As in the JSON encoder, handle top-level floats ourselves, since the
+	// standard library refuses to serialize NaN and infinite values.
+	switch f := obj.(type) {
+	case float64:
+		enc.AddFloat64(key, f)
+		return nil
+	case float32:
+		enc.AddFloat32(key, f)
+		return nil
+	case marshaledObject:
+		enc.addRawJSON(key, f.json)
+		return nil
+	}
+	marshaled, err := json.Marshal(obj)
+	if isNonFiniteError(err) {
+		return addSanitized(enc, key, reflect.ValueOf(obj), DefaultMapDepth)
+	}
+	if err != nil {
+		return err
+	}
+	enc.addRawJSON(key, marshaled)
+	return nil
+}
+
+// OpenNamespace adds a namespace field; all subsequent fields are its
+// members until the enclosing object (or the log entry) is closed.
+func (enc *binaryEncoder) OpenNamespace(key string) {
+	enc.namespaces = append(enc.namespaces, enc.openField(key, _binTypeNamespace))
+}
+
+func (enc *binaryEncoder) AppendMarshaler(obj LogMarshaler) error {
+	return enc.AddMarshaler("", obj)
+}
+
+func (enc *binaryEncoder) AppendArray(arr ArrayMarshaler) error {
+	return enc.AddArray("", arr)
+}
+
+func (enc *binaryEncoder) AppendObject(obj interface{}) error {
+	return enc.AddObject("", obj)
+}
+
+func (enc *binaryEncoder) AppendBool(val bool) {
+	enc.AddBool("", val)
+}
+
+func (enc *binaryEncoder) AppendByteString(val []byte) {
+	enc.AddByteString("", val)
+}
+
+func (enc *binaryEncoder) AppendFloat32(val float32) {
+	enc.AddFloat32("", val)
+}
+
+func (enc *binaryEncoder) AppendFloat64(val float64) {
+	enc.AddFloat64("", val)
+}
+
+func (enc *binaryEncoder) AppendInt64(val int64) {
+	enc.AddInt64("", val)
+}
+
+func (enc *binaryEncoder) AppendUint64(val uint64) {
+	enc.AddUint64("", val)
+}
+
+func (enc *binaryEncoder) AppendString(val string) {
+	enc.AddString("", val)
+}
+
+func (enc *binaryEncoder) Clone() Encoder {
+	clone := binaryPool.Get().(*binaryEncoder)
+	clone.truncate()
+	clone.bytes = append(clone.bytes, enc.bytes...)
+	clone.namespaces = append(clone.namespaces, enc.namespaces...)
+	return clone
+}
+
+func (enc *binaryEncoder) WriteEntry(sink io.Writer, ent Entry) error {
+	if sink == nil {
+		return errNilSink
+	}
+
+	final := binaryPool.Get().(*binaryEncoder)
+	final.truncate()
+	// Leave room for the length prefix, which is written once the size of
+	// the entry is known.
+	final.bytes = append(final.bytes, make([]byte, binary.MaxVarintLen64)...)
+	if !ent.Time.IsZero() {
+		final.bytes = appendBinarySint(final.bytes, _binEntryTime, ent.Time.UnixNano())
+	}
+	final.bytes = appendBinarySint(final.bytes, _binEntryLevel, int64(ent.Level))
+	final.bytes = appendBinaryString(final.bytes, _binEntryMessage, ent.Message)
+	if ent.Caller.Defined {
+		start := final.openMessage(_binEntryCaller)
+		final.bytes = appendBinaryString(final.bytes, _binCallerFile, ent.Caller.File)
+		final.bytes = appendBinaryInt(final.bytes, _binCallerLine, int64(ent.Caller.Line))
+		final.bytes = appendBinaryUint(final.bytes, _binCallerPC, uint64(ent.Caller.PC))
+		final.closeMessage(start)
+	}
+	if ent.Stack != "" {
+		final.bytes = appendBinaryString(final.bytes, _binEntryStack, ent.Stack)
+	}
+	offset := len(final.bytes)
+	final.bytes = append(final.bytes, enc.bytes...)
+	for _, start := range enc.namespaces {
+		final.namespaces = append(final.namespaces, start+offset)
+	}
+	final.closeNamespaces(0)
+
+	var prefix [binary.MaxVarintLen64]byte
+	size := binary.PutUvarint(prefix[:], uint64(len(final.bytes)-binary.MaxVarintLen64))
+	begin := binary.MaxVarintLen64 - size
+	copy(final.bytes[begin:], prefix[:size])
+	framed := final.bytes[begin:]
+
+	expectedBytes := len(framed)
+	n, err := sink.Write(framed)
+	final.Free()
+	if err != nil {
+		return err
+	}
+	if n != expectedBytes {
+		return fmt.Errorf("incomplete write: only wrote %v of %v bytes", n, expectedBytes)
+	}
+	return nil
+}
+
+// openField starts a Field message with the supplied key and type, returning
+// the offset to pass to closeMessage. Entries and Fields use the same number
+// for their nested fields, so fields are encoded identically at every depth.
+func (enc *binaryEncoder) openField(key string, typ uint64) int {
+	start := enc.openMessage(_binEntryFields)
+	if key != "" {
+		enc.bytes = appendBinaryString(enc.bytes, _binFieldKey, key)
+	}
+	enc.bytes = appendBinaryUint(enc.bytes, _binFieldType, typ)
+	return start
+}
+
+// openMessage starts a length-delimited message. Since its length isn't
+// known yet, it's inserted by closeMessage.
+func (enc *binaryEncoder) openMessage(num uint64) int {
+	enc.bytes = appendBinaryTag(enc.bytes, num, _binBytes)
+	return len(enc.bytes)
+}
+
+// closeMessage inserts the length prefix of the message whose contents begin
+// at start.
+func (enc *binaryEncoder) closeMessage(start int) {
+	var prefix [binary.MaxVarintLen64]byte
+	size := binary.PutUvarint(prefix[:], uint64(len(enc.bytes)-start))
+	enc.bytes = append(enc.bytes, prefix[:size]...)
+	copy(enc.bytes[start+size:], enc.bytes[start:len(enc.bytes)-size])
+	copy(enc.bytes[start:], prefix[:size])
+}
+
+// closeNamespaces closes open namespaces, innermost first, until only depth
+// remain open.
+func (enc *binaryEncoder) closeNamespaces(depth int) {
+	for i := len(enc.namespaces) - 1; i >= depth; i-- {
+		enc.closeMessage(enc.namespaces[i])
+	}
+	enc.namespaces = enc.namespaces[:depth]
+}
+
+func appendBinaryTag(dst []byte, num, wireType uint64) []byte {
+	return appendUvarint(dst, num<<3|wireType)
+}
+
+func appendBinaryUint(dst []byte, num, val uint64) []byte {
+	dst = appendBinaryTag(dst, num, _binVarint)
+	return appendUvarint(dst, val)
+}
+
+// appendBinarySint zigzag-encodes a signed value, as protobuf's sint types do.
+func appendBinarySint(dst []byte, num uint64, val int64) []byte {
+	return appendBinaryUint(dst, num, uint64(val<<1)^uint64(val>>63))
+}
+
+// appendBinaryInt encodes a signed value as protobuf's int64 type does.
+func appendBinaryInt(dst []byte, num uint64, val int64) []byte {
+	return appendBinaryUint(dst, num, uint64(val))
+}
+
+func appendBinaryBool(dst []byte, val bool) []byte {
+	if val {
+		return appendBinaryUint(dst, _binFieldBool, 1)
+	}
+	return appendBinaryUint(dst, _binFieldBool, 0)
+}
+
+func appendBinaryDouble(dst []byte, val float64) []byte {
+	dst = appendBinaryTag(dst, _binFieldFloat, _binFixed64)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(val))
+	return append(dst, b[:]...)
+}
+
+func appendBinaryString(dst []byte, num uint64, val string) []byte {
+	dst = appendBinaryTag(dst, num, _binBytes)
+	dst = appendUvarint(dst, uint64(len(val)))
+	return append(dst, val...)
+}
+
+func appendBinaryBytes(dst []byte, num uint64, val []byte) []byte {
+	dst = appendBinaryTag(dst, num, _binBytes)
+	dst = appendUvarint(dst, uint64(len(val)))
+	return append(dst, val...)
+}
+
+func appendUvarint(dst []byte, val uint64) []byte {
+	for val >= 0x80 {
+		dst = append(dst, byte(val)|0x80)
+		val >>= 7
+	}
+	return append(dst, byte(val))
+}
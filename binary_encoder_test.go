@@ -0,0 +1,160 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber-go/zap/spywrite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The zapdecode package tests round trips through the binary encoder in
+// detail; these tests check the wire format itself.
+
+func writeBinaryEntry(t testing.TB, enc Encoder, ent Entry) []byte {
+	buf := &bytes.Buffer{}
+	require.NoError(t, enc.WriteEntry(buf, ent), "Unexpected error writing a binary entry.")
+	return buf.Bytes()
+}
+
+func TestBinaryEncoderWireFormat(t *testing.T) {
+	enc := NewBinaryEncoder()
+	defer enc.Free()
+	enc.AddString("k", "v")
+
+	ent := Entry{Level: InfoLevel, Time: time.Unix(0, 1), Message: "hi"}
+	assert.Equal(t, []byte{
+		18,          // length prefix
+		1<<3 | 0, 2, // time, zigzag-encoded
+		2<<3 | 0, 0, // level
+		4<<3 | 2, 2, 'h', 'i', // message
+		7<<3 | 2, 8, // field
+		1<<3 | 2, 1, 'k', // key
+		2<<3 | 0, _binTypeString, // type
+		8<<3 | 2, 1, 'v', // value
+	}, writeBinaryEntry(t, enc, ent), "Unexpected encoding.")
+
+	ent = Entry{Level: DebugLevel, Caller: EntryCaller{Defined: true, PC: 1, File: "f", Line: 2}, Stack: "s"}
+	assert.Equal(t, []byte{
+		26,
+		2<<3 | 0, 1, // level -1, zigzag-encoded
+		4<<3 | 2, 0, // empty message
+		5<<3 | 2, 7, // caller
+		1<<3 | 2, 1, 'f', // file
+		2<<3 | 0, 2, // line
+		3<<3 | 0, 1, // pc
+		6<<3 | 2, 1, 's', // stack
+		7<<3 | 2, 8, 1<<3 | 2, 1, 'k', 2<<3 | 0, _binTypeString, 8<<3 | 2, 1, 'v',
+	}, writeBinaryEntry(t, enc, ent), "Unexpected encoding of an entry with a caller and stack.")
+}
+
+func TestBinaryEncoderLongValues(t *testing.T) {
+	// Values longer than 127 bytes need multi-byte length prefixes, which
+	// shift the message contents as they're inserted.
+	long := strings.Repeat("x", 300)
+	enc := NewBinaryEncoder()
+	defer enc.Free()
+	enc.AddMarshaler("obj", LogMarshalerFunc(func(kv KeyValue) error {
+		kv.OpenNamespace("ns")
+		kv.AddString("long", long)
+		return nil
+	}))
+
+	out := writeBinaryEntry(t, enc, Entry{Message: long})
+	size, n := binary.Uvarint(out)
+	require.True(t, n > 1, "Expected a multi-byte length prefix.")
+	assert.Equal(t, len(out)-n, int(size), "Length prefix doesn't match the entry's size.")
+
+	field := []byte{1<<3 | 2, 4, 'l', 'o', 'n', 'g', 2<<3 | 0, _binTypeString, 8<<3 | 2, 0xac, 0x02}
+	field = append(field, long...)
+	ns := append([]byte{1<<3 | 2, 2, 'n', 's', 2<<3 | 0, _binTypeNamespace, 7<<3 | 2, 0xb7, 0x02}, field...)
+	obj := append([]byte{1<<3 | 2, 3, 'o', 'b', 'j', 2<<3 | 0, _binTypeObject, 7<<3 | 2, 0xc0, 0x02}, ns...)
+	assert.True(t, bytes.HasSuffix(out, append([]byte{7<<3 | 2, 0xca, 0x02}, obj...)), "Unexpected encoding of nested fields.")
+}
+
+func TestBinaryEncoderClone(t *testing.T) {
+	parent := NewBinaryEncoder()
+	defer parent.Free()
+	parent.OpenNamespace("ns")
+	parent.AddInt("parent", 1)
+	before := writeBinaryEntry(t, parent, Entry{})
+
+	clone := parent.Clone()
+	defer clone.Free()
+	clone.AddInt("clone", 2)
+	assert.Equal(t, before, writeBinaryEntry(t, parent, Entry{}), "Expected cloning not to affect the parent.")
+	assert.True(t, len(writeBinaryEntry(t, clone, Entry{})) > len(before), "Expected the clone to have another field.")
+	assert.Equal(t, before, writeBinaryEntry(t, parent, Entry{}), "Expected writing an entry not to close the parent's namespace.")
+}
+
+func TestBinaryEncoderRawJSON(t *testing.T) {
+	valid := NewBinaryEncoder()
+	defer valid.Free()
+	assert.NoError(t, valid.AddRawJSON("k", []byte(`{"a":1}`)), "Unexpected error adding valid raw JSON.")
+
+	invalid := NewBinaryEncoder()
+	defer invalid.Free()
+	assert.Equal(t, errInvalidRawJSON, invalid.AddRawJSON("k", []byte(`{"a":`)), "Expected an error adding invalid raw JSON.")
+	fallback := NewBinaryEncoder()
+	defer fallback.Free()
+	fallback.AddByteString("k", []byte(`{"a":`))
+	assert.Equal(t, writeBinaryEntry(t, fallback, Entry{}), writeBinaryEntry(t, invalid, Entry{}), "Expected invalid raw JSON to be added as a byte string.")
+}
+
+func TestBinaryEncoderNestedNonFiniteFloats(t *testing.T) {
+	enc := NewBinaryEncoder()
+	defer enc.Free()
+	assert.NoError(t, enc.AddObject("k", struct{ F float64 }{math.NaN()}), "Unexpected error adding a nested NaN.")
+
+	expected := NewBinaryEncoder()
+	defer expected.Free()
+	expected.AddMarshaler("k", LogMarshalerFunc(func(kv KeyValue) error {
+		kv.AddFloat64("F", math.NaN())
+		return nil
+	}))
+	assert.Equal(t, writeBinaryEntry(t, expected, Entry{}), writeBinaryEntry(t, enc, Entry{}), "Expected nested NaNs to be encoded as floats.")
+}
+
+func TestBinaryWriteEntryFailure(t *testing.T) {
+	enc := NewBinaryEncoder()
+	defer enc.Free()
+	tests := []struct {
+		sink io.Writer
+		msg  string
+	}{
+		{nil, "Expected an error when writing to a nil sink."},
+		{spywrite.FailWriter{}, "Expected an error when writing to sink fails."},
+		{spywrite.ShortWriter{}, "Expected an error on partial writes to sink."},
+	}
+	for _, tt := range tests {
+		err := enc.WriteEntry(tt.sink, Entry{Message: "hello", Level: InfoLevel, Time: time.Unix(0, 0)})
+		assert.Error(t, err, tt.msg)
+	}
+}
@@ -20,7 +20,14 @@
 
 package zap
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
+
+// _maxPooledFields caps the size of the field buffers retained by pooled
+// CheckedMessages, so that an occasional huge message doesn't pin memory.
+const _maxPooledFields = 64
 
 var _cmPool = sync.Pool{
 	New: func() interface{} {
@@ -38,6 +45,9 @@ type CheckedMessage struct {
 	safeToWrite bool
 	lvl         Level
 	msg         string
+	// Write copies its fields here, which lets callers' variadic slices stay
+	// on the stack.
+	fields []Field
 
 	// singly linked list built by Chain
 	next *CheckedMessage // carried by each part of Chain-ed list
@@ -55,35 +65,56 @@ func NewCheckedMessage(logger Logger, lvl Level, msg string) *CheckedMessage {
 }
 
 // Write logs the pre-checked message with the supplied fields. It will call
-// the underlying level method (Debug, Info, Warn, Error, DPanic, Panic, and
-// Fatal) for the defined levels; the Log method is only called for unknown
+// the underlying level method (Trace, Debug, Info, Warn, Error, DPanic, Panic,
+// and Fatal) for the defined levels; the Log method is only called for unknown
 // logging levels.
 //
 // It MUST be called at most once, since Write will return the *CheckedMessage
 // to an internal pool for potentially immediate re-use; re-using a
 // *CheckedMessage after calling Write() will result in data races or other
 // undefined behavior. An attempt is made to detect and DPanic log any re-use,
-// but such detection is not guaranteed due to race conditions.
+// but such detection is not guaranteed due to race conditions. Re-use is
+// reported to the logger's ErrorOutput if it has one (as Loggers built on a
+// Meta do), and DPanic logged otherwise; either way, it panics in development.
+//
+// Writing a nil (not OK) message is a no-op, so the results of Check may be
+// written unconditionally. Writing a Chain-ed message writes each of its
+// constituents; see Chain for the details.
 func (m *CheckedMessage) Write(fields ...Field) {
-	if m == nil {
+	if !m.claim() {
 		return
 	}
+	m.fields = append(m.fields[:0], fields...)
+	if m.next != nil {
+		m.writeChain()
+		return
+	}
+	m.write(m.fields)
+	m.free()
+}
 
+// claim marks the message as written, reporting whether it was safe to do so.
+func (m *CheckedMessage) claim() bool {
+	if m == nil {
+		return false
+	}
 	if !m.safeToWrite {
 		// we're living in racy times, so copy what we can out of the pointer
 		// that we have, and at least tell the user something
 		if logger := m.logger; logger != nil {
-			lvl, msg := m.lvl, m.msg
-			logger.DPanic(
-				"Must not call zap.(*CheckedMessage).Write() more than once",
-				Nest("prior", Stringer("level", lvl), String("msg", msg)),
-			)
+			m.reportUnsafeWrite(logger, m.lvl, m.msg)
 		}
-		return
+		return false
 	}
 	m.safeToWrite = false
+	return true
+}
 
+// write calls the level method of the message's logger.
+func (m *CheckedMessage) write(fields []Field) {
 	switch m.lvl {
+	case TraceLevel:
+		m.logger.Trace(m.msg, fields...)
 	case DebugLevel:
 		m.logger.Debug(m.msg, fields...)
 	case InfoLevel:
@@ -92,6 +123,8 @@ func (m *CheckedMessage) Write(fields ...Field) {
 		m.logger.Warn(m.msg, fields...)
 	case ErrorLevel:
 		m.logger.Error(m.msg, fields...)
+	case DPanicLevel:
+		m.logger.DPanic(m.msg, fields...)
 	case PanicLevel:
 		m.logger.Panic(m.msg, fields...)
 	case FatalLevel:
@@ -99,17 +132,159 @@ func (m *CheckedMessage) Write(fields ...Field) {
 	default:
 		m.logger.Log(m.lvl, m.msg, fields...)
 	}
+}
+
+// writeChain writes every message in a chain with the head's fields, putting
+// off any panics and exits until all of them are written. The head must
+// already be claimed.
+func (m *CheckedMessage) writeChain() {
+	var (
+		exit      bool
+		panicked  bool
+		recovered interface{}
+	)
+	for prev, cm := m, m; cm != nil; prev, cm = cm, cm.next {
+		if cm != m && !cm.claim() {
+			// It's already been written (and freed), so it isn't ours to
+			// free again.
+			prev.next = cm.next
+			cm = prev
+			continue
+		}
+		switch cm.lvl {
+		case FatalLevel:
+			// Writing the entry never exits, so exit (at most once) after
+			// writing and flushing the whole chain. Build the entry here, so
+			// it has the caller's stack; only the write may outlive the chain
+			// (see writeTerminal).
+			writeTerminal(encodeTerminal(cm.logger, FatalLevel, cm.msg, m.fields))
+			exit = exit || terminates(cm.logger, FatalLevel)
+		case DPanicLevel, PanicLevel:
+			if r, ok := cm.writeRecovering(m.fields); ok && !panicked {
+				panicked, recovered = true, r
+			}
+		default:
+			cm.write(m.fields)
+		}
+	}
+	// Freeing a message clears its next pointer, so free the chain last.
+	for cm := m.next; cm != nil; {
+		next := cm.next
+		cm.free()
+		cm = next
+	}
+	m.free()
+
+	if exit {
+		_exit(1)
+	}
+	if panicked {
+		panic(recovered)
+	}
+}
+
+// writeRecovering writes the message, recovering from any panic. It reports
+// whether the write panicked, and with what value.
+func (m *CheckedMessage) writeRecovering(fields []Field) (recovered interface{}, panicked bool) {
+	panicked = true
+	defer func() {
+		if panicked {
+			recovered = recover()
+		}
+	}()
+	m.write(fields)
+	return nil, false
+}
+
+// A Terminator is a Logger whose Panic and Fatal methods don't necessarily
+// panic or exit, like the wrapper returned by CapLevel and the spy Logger.
+// Terminates reports whether the level method for lvl panics or exits; Chain
+// uses it to decide whether to exit after writing Fatal-level messages.
+// Loggers that don't implement it are assumed to terminate.
+type Terminator interface {
+	Terminates(lvl Level) bool
+}
+
+// terminates reports whether the logger's level method for lvl (Panic or
+// Fatal) panics or exits.
+func terminates(log Logger, lvl Level) bool {
+	if t, ok := log.(Terminator); ok {
+		return t.Terminates(lvl)
+	}
+	return true
+}
+
+// discard returns a message that won't be written (and any messages chained
+// to it) to the pool.
+func (m *CheckedMessage) discard() {
+	for m != nil {
+		next := m.next
+		if m.claim() {
+			m.free()
+		}
+		m = next
+	}
+}
 
-	m.next.Write(fields...)
+func (m *CheckedMessage) free() {
+	// Don't keep the fields' values alive while the message sits in the pool.
+	for i := range m.fields {
+		m.fields[i] = Field{}
+	}
+	if cap(m.fields) > _maxPooledFields {
+		m.fields = nil
+	}
+	m.fields = m.fields[:0]
 	m.next, m.tail = nil, nil
 	_cmPool.Put(m)
 }
 
+// A messageWrapper is the Logger of a CheckedMessage that writes another,
+// wrapped CheckedMessage (like the ones returned by Filter, CapLevel, and
+// DynamicFields). Its level methods write the wrapped message, so once that's
+// been written, re-use is reported to the logger it wraps instead.
+type messageWrapper interface {
+	wrappedLogger() Logger
+}
+
+// An internalErrorReporter can report problems outside of its usual output.
+// Loggers that embed a Meta implement it.
+type internalErrorReporter interface {
+	InternalError(cause string, err error)
+	development() bool
+}
+
+func (m *CheckedMessage) reportUnsafeWrite(logger Logger, lvl Level, msg string) {
+	const diagnostic = "Must not call zap.(*CheckedMessage).Write() more than once"
+	r, ok := logger.(internalErrorReporter)
+	if !ok {
+		if w, ok := logger.(messageWrapper); ok {
+			logger = w.wrappedLogger()
+		}
+		logger.DPanic(diagnostic, Nest("prior", Stringer("level", lvl), String("msg", msg)))
+		return
+	}
+	r.InternalError("CheckedMessage", fmt.Errorf("%s (prior level %v, msg %q)", diagnostic, lvl, msg))
+	if r.development() {
+		panic(diagnostic)
+	}
+}
+
 // Chain combines two or more CheckedMessages. If the receiver message is not
 // OK(), the passed message is returned. Otherwise if the passed message is
 // OK(), then it is retained such that its Write() will be called after the
 // receiver's Write(), and any previously Chain()'ed messages already so
-// retained.
+// retained. Nil receivers and arguments are skipped, so the results of Check
+// may be chained unconditionally; the result is OK if any of them are.
+//
+// Writing the chain writes each constituent exactly once, in order, with the
+// same fields. Panics and exits are put off until every constituent has been
+// written: a DPanic- or Panic-level constituent that panics is recovered, and
+// the first such panic is re-raised at the end, while Fatal-level
+// constituents are written with Log and the process exits after the last
+// one. (Constituents whose logger doesn't terminate at that level, like those
+// wrapped by CapLevel, still don't; see Terminator.) If any constituent was already written,
+// it's reported as a re-use and skipped.
 func (m *CheckedMessage) Chain(ms ...*CheckedMessage) *CheckedMessage {
 	for _, m2 := range ms {
 		if !m2.OK() {
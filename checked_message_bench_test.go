@@ -97,3 +97,27 @@ func BenchmarkCheckedMessage_Chain_sliceLoggers(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkCheckedMessage_Write(b *testing.B) {
+	log := benchmarkLoggers([]Level{InfoLevel}, DiscardOutput)[0]
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if cm := log.Check(InfoLevel, "fwiw"); cm.OK() {
+				cm.Write(Int("i", 42), String("s", "str"))
+			}
+		}
+	})
+}
+
+func BenchmarkCheckedMessage_Write_tee(b *testing.B) {
+	log := Tee(benchmarkLoggers([]Level{InfoLevel, InfoLevel}, DiscardOutput)...)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if cm := log.Check(InfoLevel, "fwiw"); cm.OK() {
+				cm.Write(Int("i", 42), String("s", "str"))
+			}
+		}
+	})
+}
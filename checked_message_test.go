@@ -21,6 +21,8 @@
 package zap
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,15 +50,210 @@ func TestJSONLoggerCheck(t *testing.T) {
 }
 
 func TestCheckedMessageUnsafeWrite(t *testing.T) {
+	buf, errBuf := &testBuffer{}, &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(errBuf))
+	cm := logger.Check(InfoLevel, "bob lob law blog")
+	cm.Write()
+	cm.Write()
+	assert.Equal(t, []string{`{"level":"info","msg":"bob lob law blog"}`}, buf.Lines(), "Expected one lob log.")
+	assert.Contains(
+		t,
+		errBuf.String(),
+		`CheckedMessage error: Must not call zap.(*CheckedMessage).Write() more than once (prior level info, msg "bob lob law blog")`,
+		"Expected re-use to be reported to the error output.",
+	)
+}
+
+func TestCheckedMessageWriteLevels(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), DebugLevel, Output(buf), ErrorOutput(Discard))
+	for _, lvl := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, DPanicLevel, Level(42)} {
+		buf.Reset()
+		cm := logger.Check(lvl, "checked")
+		require.True(t, cm.OK(), "Expected CheckedMessage to be OK at level %v.", lvl)
+		cm.Write(Int("magic", 42), String("lvl", lvl.String()))
+		expected := fmt.Sprintf(`{"level":"%v","msg":"checked","magic":42,"lvl":"%v"}`, lvl, lvl)
+		assert.Equal(t, expected, buf.Stripped(), "Unexpected output writing a CheckedMessage at level %v.", lvl)
+	}
+}
+
+func TestCheckedMessageTerminates(t *testing.T) {
+	buf := &testBuffer{}
+	// Panic and Fatal messages terminate even if the logger disables them.
+	logger := New(newJSONEncoder(NoTime()), DPanicLevel+1, Output(buf), ErrorOutput(Discard))
+
+	prod := New(newJSONEncoder(NoTime()), Output(Discard), ErrorOutput(Discard))
+	dev := New(newJSONEncoder(NoTime()), Development(), Output(Discard), ErrorOutput(Discard))
+	assert.NotPanics(t, func() { prod.Check(DPanicLevel, "dpanic").Write() }, "Unexpected panic outside development.")
+	assert.Panics(t, func() { dev.Check(DPanicLevel, "dpanic").Write() }, "Expected DPanic to panic in development.")
+	assert.Panics(t, func() { logger.Check(PanicLevel, "panic").Write() }, "Expected a panic.")
+
+	stub := stubExit()
+	defer stub.Unstub()
+	logger.Check(FatalLevel, "fatal").Write()
+	stub.AssertStatus(t, 1)
+	assert.Equal(t, []string{
+		`{"level":"panic","msg":"panic"}`,
+		`{"level":"fatal","msg":"fatal"}`,
+	}, buf.Lines(), "Unexpected output from terminating levels.")
+}
+
+func TestCheckedMessageNilWrite(t *testing.T) {
 	withJSONLogger(t, opts(InfoLevel), func(logger Logger, buf *testBuffer) {
-		cm := logger.Check(InfoLevel, "bob lob law blog")
+		cm := logger.Check(DebugLevel, "disabled")
+		require.False(t, cm.OK(), "Expected CheckedMessage to be not OK at disabled levels.")
+		assert.NotPanics(t, func() { cm.Write(Int("magic", 42)) }, "Expected writing a nil CheckedMessage to be a no-op.")
+		assert.Empty(t, buf.String(), "Expected no output writing a nil CheckedMessage.")
+	})
+}
+
+func TestCheckedMessageUnsafeWriteDevelopment(t *testing.T) {
+	buf, errBuf := &testBuffer{}, &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Development(), Output(buf), ErrorOutput(errBuf))
+	cm := logger.Check(InfoLevel, "bob lob law blog")
+	cm.Write()
+	assert.Panics(t, func() { cm.Write() }, "Expected re-use to panic in development.")
+	assert.Equal(t, []string{`{"level":"info","msg":"bob lob law blog"}`}, buf.Lines(), "Expected one lob log.")
+	assert.Contains(t, errBuf.String(), "Must not call zap.(*CheckedMessage).Write() more than once", "Expected re-use to be reported.")
+}
+
+func TestCheckedMessageTee(t *testing.T) {
+	infoBuf, warnBuf, errBuf := &testBuffer{}, &testBuffer{}, &testBuffer{}
+	logger := Tee(
+		New(newJSONEncoder(NoTime()), InfoLevel, Output(infoBuf), ErrorOutput(errBuf)),
+		New(newJSONEncoder(NoTime()), WarnLevel, Output(warnBuf), ErrorOutput(Discard)),
+	)
+
+	assert.False(t, logger.Check(DebugLevel, "").OK(), "Expected a tee to be disabled if all its loggers are.")
+	logger.Check(InfoLevel, "info").Write(Int("n", 1))
+	cm := logger.Check(WarnLevel, "warn")
+	cm.Write(Int("n", 2))
+	cm.Write(Int("n", 3))
+	assert.Contains(t, errBuf.String(), "Must not call zap.(*CheckedMessage).Write() more than once", "Expected re-use to be reported.")
+
+	stub := stubExit()
+	defer stub.Unstub()
+	logger.Check(FatalLevel, "fatal").Write(Int("n", 4))
+	stub.AssertStatus(t, 1)
+
+	assert.Equal(t, []string{
+		`{"level":"info","msg":"info","n":1}`,
+		`{"level":"warn","msg":"warn","n":2}`,
+		`{"level":"fatal","msg":"fatal","n":4}`,
+	}, infoBuf.Lines(), "Unexpected output from the Info logger.")
+	assert.Equal(t, []string{
+		`{"level":"warn","msg":"warn","n":2}`,
+		`{"level":"fatal","msg":"fatal","n":4}`,
+	}, warnBuf.Lines(), "Unexpected output from the Warn logger.")
+}
+
+func TestCheckedMessageUnsafeWriteWithoutMeta(t *testing.T) {
+	// Loggers that don't expose an error output get a DPanic instead.
+	buf := &testBuffer{}
+	logger := Filter(New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard)), func(Level, string, []Field) bool {
+		return true
+	})
+	cm := logger.Check(InfoLevel, "bob lob law blog")
+	cm.Write()
+	cm.Write()
+	assert.Equal(t, []string{
+		`{"level":"info","msg":"bob lob law blog"}`,
+		`{"level":"dpanic","msg":"Must not call zap.(*CheckedMessage).Write() more than once","prior":{"level":"info","msg":"bob lob law blog"}}`,
+	}, buf.Lines(), "Expected one lob log, and a DPanic.")
+}
+
+func TestCheckedMessageUnsafeWriteWrapped(t *testing.T) {
+	// Re-use of a wrapped message is reported to the logger it wraps, not
+	// written through the already-written message.
+	const prior = `"prior":{"level":"warn","msg":"bob lob law blog"}`
+	tests := []struct {
+		desc     string
+		wrap     func(Logger) Logger
+		expected []string
+	}{
+		{
+			"DynamicFields",
+			func(l Logger) Logger { return DynamicFields(l, func() []Field { return []Field{Int("n", 1)} }) },
+			[]string{
+				`{"level":"warn","msg":"bob lob law blog","n":1}`,
+				`{"level":"dpanic","msg":"Must not call zap.(*CheckedMessage).Write() more than once",` + prior + `}`,
+			},
+		},
+		{
+			"CapLevel",
+			func(l Logger) Logger { return CapLevel(l, InfoLevel) },
+			[]string{
+				`{"level":"info","msg":"bob lob law blog","original_level":"warn"}`,
+				`{"level":"info","msg":"Must not call zap.(*CheckedMessage).Write() more than once",` + prior + `,"original_level":"dpanic"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		buf := &testBuffer{}
+		// Filter hides the error output, so re-use is DPanic logged.
+		logger := tt.wrap(Filter(New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard)), func(Level, string, []Field) bool {
+			return true
+		}))
+		cm := logger.Check(WarnLevel, "bob lob law blog")
 		cm.Write()
 		cm.Write()
-		assert.Equal(t, []string{
-			`{"level":"info","msg":"bob lob law blog"}`,
-			`{"level":"dpanic","msg":"Must not call zap.(*CheckedMessage).Write() more than once","prior":{"level":"info","msg":"bob lob law blog"}}`,
-		}, buf.Lines(), "Expected one lob log, and a fatal")
-	})
+		assert.Equal(t, tt.expected, buf.Lines(), "Unexpected output re-using a %s message.", tt.desc)
+	}
+}
+
+func TestCheckedMessageDoesntRetainFields(t *testing.T) {
+	logger := New(NullEncoder(), DiscardOutput)
+	cm := logger.Check(InfoLevel, "")
+	require.True(t, cm.OK(), "Expected CheckedMessage to be OK at enabled levels.")
+	cm.Write(String("foo", "bar"), Object("obj", map[string]int{"a": 1}))
+	for i, f := range cm.fields[:cap(cm.fields)] {
+		assert.Equal(t, Field{}, f, "Expected pooled message not to retain field %d.", i)
+	}
+}
+
+func TestCheckedMessageConcurrentWrites(t *testing.T) {
+	buf := &testBuffer{}
+	infoLog := New(newJSONEncoder(NoTime()), Output(buf), Fields(String("name", "A")))
+	teeLog := Tee(infoLog.With(String("tee", "1")), infoLog.With(String("tee", "2")))
+	logs := []Logger{infoLog, teeLog}
+
+	const goroutines, writes = 10, 100
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				log := logs[i%len(logs)]
+				if cm := log.Check(DebugLevel, "disabled"); cm.OK() {
+					t.Errorf("Expected DebugLevel to be disabled.")
+				}
+				if cm := log.Check(InfoLevel, "enabled"); cm.OK() {
+					cm.Write(Int("g", g), Int("i", i))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	counts := make(map[string]int)
+	for _, line := range buf.Lines() {
+		counts[line]++
+	}
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < writes; i++ {
+			fields := fmt.Sprintf(`"g":%d,"i":%d}`, g, i)
+			if i%2 == 0 {
+				assert.Equal(t, 1, counts[`{"level":"info","msg":"enabled","name":"A",`+fields], "Unexpected output for write %d from goroutine %d.", i, g)
+				continue
+			}
+			for _, tee := range []string{"1", "2"} {
+				line := fmt.Sprintf(`{"level":"info","msg":"enabled","name":"A","tee":"%s",%s`, tee, fields)
+				assert.Equal(t, 1, counts[line], "Unexpected teed output for write %d from goroutine %d.", i, g)
+			}
+		}
+	}
+	assert.Equal(t, goroutines*writes*3/2, len(buf.Lines()), "Unexpected number of entries.")
 }
 
 func TestCheckedMessage_Chain(t *testing.T) {
@@ -198,3 +395,108 @@ func TestCheckedMessage_Chain(t *testing.T) {
 		}
 	})
 }
+
+func TestCheckedMessageChainFatal(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard))
+	loga := logger.With(String("name", "A"))
+	logb := logger.With(String("name", "B"))
+
+	// Record how many entries were written at each exit.
+	stub := stubExit()
+	defer stub.Unstub()
+	var exits []int
+	_exit = func(int) { exits = append(exits, len(buf.Lines())) }
+
+	loga.Check(FatalLevel, "fatal").Chain(
+		logb.Check(InfoLevel, "fatal"),
+		logb.Check(FatalLevel, "fatal"),
+		CapLevel(loga, ErrorLevel).Check(FatalLevel, "fatal"),
+	).Write(Int("i", 1))
+	assert.Equal(t, []int{4}, exits, "Expected to exit exactly once, after writing every message.")
+	assert.Equal(t, []string{
+		`{"level":"fatal","msg":"fatal","name":"A","i":1}`,
+		`{"level":"info","msg":"fatal","name":"B","i":1}`,
+		`{"level":"fatal","msg":"fatal","name":"B","i":1}`,
+		`{"level":"error","msg":"fatal","name":"A","i":1,"original_level":"fatal"}`,
+	}, buf.Lines(), "Unexpected output from a chain with Fatal-level messages.")
+
+	exits = nil
+	buf.Reset()
+	CapLevel(loga, ErrorLevel).Check(FatalLevel, "capped").Chain(logb.Check(InfoLevel, "capped")).Write()
+	assert.Empty(t, exits, "Expected capped Fatal-level messages not to exit.")
+	assert.Equal(t, 2, len(buf.Lines()), "Expected both messages to be written.")
+}
+
+func TestCheckedMessageChainFatalStack(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard), AddStacks(FatalLevel))
+	stub := stubExit()
+	defer stub.Unstub()
+
+	logger.Check(FatalLevel, "fatal").Chain(logger.Check(FatalLevel, "fatal")).Write()
+	stub.AssertStatus(t, 1)
+	lines := buf.Lines()
+	require.Equal(t, 2, len(lines), "Expected both chained messages to be written.")
+	for _, line := range lines {
+		assert.Contains(t, line, "zap.TestCheckedMessageChainFatalStack", "Expected the stacktrace to include the test function.")
+		assert.NotContains(t, line, "zap.writeTerminal", "Expected the entry to be built on the calling goroutine.")
+	}
+}
+
+func TestCheckedMessageChainPanic(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard))
+	dev := New(newJSONEncoder(NoTime()), Development(), Output(buf), ErrorOutput(Discard))
+
+	cm := logger.Check(PanicLevel, "first").Chain(
+		dev.Check(DPanicLevel, "second"),
+		logger.Check(InfoLevel, "third"),
+	)
+	assert.Panics(t, func() { cm.Write() }, "Expected a chain with a Panic-level message to panic.")
+	assert.Equal(t, []string{
+		`{"level":"panic","msg":"first"}`,
+		`{"level":"dpanic","msg":"second"}`,
+		`{"level":"info","msg":"third"}`,
+	}, buf.Lines(), "Expected every message to be written before panicking.")
+
+	buf.Reset()
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		dev.Check(DPanicLevel, "dpanic").Chain(logger.Check(PanicLevel, "panic")).Write()
+	}()
+	assert.Equal(t, "dpanic", recovered, "Expected the first panic to be re-raised.")
+	assert.Equal(t, 2, len(buf.Lines()), "Expected both messages to be written.")
+}
+
+func TestCheckedMessageChainUnsafeWrite(t *testing.T) {
+	buf, errBuf := &testBuffer{}, &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(errBuf))
+
+	written := logger.Check(InfoLevel, "written")
+	cm := logger.Check(InfoLevel, "head").Chain(logger.Check(InfoLevel, "tail"), written)
+	written.Write()
+	cm.Write()
+	assert.Equal(t, []string{
+		`{"level":"info","msg":"written"}`,
+		`{"level":"info","msg":"head"}`,
+		`{"level":"info","msg":"tail"}`,
+	}, buf.Lines(), "Expected already-written messages in a chain to be skipped.")
+	assert.Contains(t, errBuf.String(), `prior level info, msg "written"`, "Expected re-use to be reported.")
+
+	buf.Reset()
+	errBuf.Reset()
+	cm.Write()
+	assert.Empty(t, buf.Lines(), "Expected no output writing a chain twice.")
+	assert.Contains(t, errBuf.String(), `prior level info, msg "head"`, "Expected re-use to be reported.")
+
+	// The skipped message was already returned to the pool when it was
+	// written, so writing the chain mustn't return it again.
+	seen := make(map[*CheckedMessage]bool)
+	for i := 0; i < 4; i++ {
+		m := NewCheckedMessage(logger, InfoLevel, "")
+		assert.False(t, seen[m], "Expected skipped messages to be returned to the pool only once.")
+		seen[m] = true
+	}
+}
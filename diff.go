@@ -0,0 +1,332 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// _maxDiffElements is the largest number of elements in a slice, array, or
+// map that Diff compares element by element. Larger containers are compared
+// as a whole.
+const _maxDiffElements = 100
+
+// Diff constructs a field that describes how a value changed, for example
+// when a config or model object is updated, without encoding both versions
+// in full. It's encoded as a nested object whose members are the fields that
+// differ, each holding {"from": x, "to": y}; unchanged fields are omitted, so
+// identical values produce an empty object.
+//
+// Structs are compared field by field using the same rules as Struct: only
+// exported fields are compared, keys come from zap and json tags, and
+// embedded structs are flattened. Nested structs, slices, arrays, and maps
+// are compared member by member, up to DefaultMapDepth levels deep, and
+// differing members are nested in the same way; slice elements are keyed by
+// index, and map entries by their formatted keys. Elements and entries that
+// exist on only one side have only a "from" or a "to". Slices, arrays, and
+// maps with more than 100 elements, byte slices, values nested too deeply,
+// and types like time.Time and errors that Any encodes specially are compared
+// as a whole, using reflect.DeepEqual (or Time.Equal). If the values' types
+// differ, both are encoded whole, along with a "note" naming the types.
+//
+// Comparing values with reflection is expensive, so the field is lazy (see
+// Lazy): the diff is only computed if the entry is written.
+func Diff(key string, old, new interface{}) Field {
+	return DiffDepth(key, old, new, DefaultMapDepth)
+}
+
+// DiffDepth is like Diff, but it compares the members of at most maxDepth
+// levels of nested structs, slices, arrays, and maps below the top-level
+// values. With a maxDepth of zero, the comparison is shallow: the top-level
+// values' fields or elements are compared, and those that differ are encoded
+// whole.
+func DiffDepth(key string, old, new interface{}, maxDepth int) Field {
+	return Lazy(key, func() Field {
+		d := diffValues(key, reflect.ValueOf(old), reflect.ValueOf(new), maxDepth+1)
+		return Marshaler(key, d)
+	})
+}
+
+// A diff describes how a value changed. Values whose members were compared
+// have a child diff for each member that changed; other values are
+// described by their old and new versions.
+type diff struct {
+	key      string
+	children []diff
+	// For values compared as a whole. A value that's missing on one side
+	// (for example, an element appended to a slice) has only the other.
+	whole          bool
+	from, to       reflect.Value
+	hasFrom, hasTo bool
+	note           string
+}
+
+func (d diff) MarshalLog(kv KeyValue) error {
+	if !d.whole {
+		var first error
+		for _, c := range d.children {
+			if err := kv.AddMarshaler(c.key, c); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+	if d.hasFrom {
+		addDiffValue(kv, "from", d.from)
+	}
+	if d.hasTo {
+		addDiffValue(kv, "to", d.to)
+	}
+	if d.note != "" {
+		kv.AddString("note", d.note)
+	}
+	return nil
+}
+
+func addDiffValue(kv KeyValue, key string, v reflect.Value) {
+	if !v.IsValid() {
+		kv.AddObject(key, nil)
+		return
+	}
+	addStructValue(kv, key, v, DefaultMapDepth)
+}
+
+func wholeDiff(key string, from, to reflect.Value) diff {
+	return diff{key: key, whole: true, from: from, to: to, hasFrom: true, hasTo: true}
+}
+
+// changed reports whether the diff describes a change.
+func (d diff) changed() bool {
+	return d.whole || len(d.children) > 0
+}
+
+// diffValues compares two values, comparing their members if depth is
+// positive.
+func diffValues(key string, a, b reflect.Value, depth int) diff {
+	a, b = diffIndirect(a), diffIndirect(b)
+	aNil, bNil := diffIsNil(a), diffIsNil(b)
+	switch {
+	case aNil && bNil:
+		return diff{key: key}
+	case aNil || bNil:
+		return wholeDiff(key, a, b)
+	case a.Type() != b.Type():
+		d := wholeDiff(key, a, b)
+		d.note = fmt.Sprintf("type changed from %v to %v", a.Type(), b.Type())
+		return d
+	}
+
+	if usesAny(a.Type()) {
+		return diffWhole(key, a, b)
+	}
+	switch kind := a.Kind(); {
+	case isPrimitive(kind):
+		if !primitivesEqual(a, b) {
+			return wholeDiff(key, a, b)
+		}
+		return diff{key: key}
+	case kind == reflect.Struct:
+		if depth <= 0 {
+			return diffWhole(key, a, b)
+		}
+		return diff{key: key, children: diffStruct(planFor(a.Type()), a, b, depth-1)}
+	case kind == reflect.Slice && a.Type().Elem().Kind() == reflect.Uint8:
+		if !bytes.Equal(a.Bytes(), b.Bytes()) {
+			return wholeDiff(key, a, b)
+		}
+		return diff{key: key}
+	case kind == reflect.Slice || kind == reflect.Array:
+		if depth <= 0 || a.Len() > _maxDiffElements || b.Len() > _maxDiffElements {
+			return diffWhole(key, a, b)
+		}
+		return diff{key: key, children: diffElements(a, b, depth-1)}
+	case kind == reflect.Map:
+		if depth <= 0 || a.Len() > _maxDiffElements || b.Len() > _maxDiffElements {
+			return diffWhole(key, a, b)
+		}
+		return diff{key: key, children: diffEntries(a, b, depth-1)}
+	case kind == reflect.Func || kind == reflect.Chan || kind == reflect.UnsafePointer:
+		if a.Pointer() != b.Pointer() {
+			return wholeDiff(key, a, b)
+		}
+		return diff{key: key}
+	default:
+		return diffWhole(key, a, b)
+	}
+}
+
+// diffWhole compares two values of the same type as a whole.
+func diffWhole(key string, a, b reflect.Value) diff {
+	av, bv := a.Interface(), b.Interface()
+	if t, ok := av.(time.Time); ok && t.Equal(bv.(time.Time)) {
+		return diff{key: key}
+	}
+	if reflect.DeepEqual(av, bv) {
+		return diff{key: key}
+	}
+	return wholeDiff(key, a, b)
+}
+
+// diffIndirect follows pointers and interfaces, like addStructValue does,
+// stopping at nil values and at types that Any encodes specially.
+func diffIndirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() || usesAny(v.Type()) {
+			break
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func diffIsNil(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func primitivesEqual(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		// Treat NaNs as equal to each other, so that unchanged NaNs aren't
+		// reported on every update.
+		return x == y || (x != x && y != y)
+	default:
+		return a.String() == b.String()
+	}
+}
+
+func diffStruct(plan *structPlan, a, b reflect.Value, depth int) []diff {
+	var children []diff
+	for _, f := range plan.fields {
+		fa, fb := a.Field(f.index), b.Field(f.index)
+		if f.embedded == nil {
+			if d := diffValues(f.name, fa, fb, depth); d.changed() {
+				children = append(children, d)
+			}
+			continue
+		}
+		if fa.Kind() == reflect.Ptr {
+			if fa.IsNil() && fb.IsNil() {
+				continue
+			}
+			// Compare a missing embedded struct as if it were empty.
+			fa, fb = diffElem(fa), diffElem(fb)
+		}
+		children = append(children, diffStruct(f.embedded, fa, fb, depth)...)
+	}
+	return children
+}
+
+// diffElem dereferences a pointer, substituting the zero value for nil.
+func diffElem(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
+
+func diffElements(a, b reflect.Value, depth int) []diff {
+	var children []diff
+	n := a.Len()
+	if b.Len() > n {
+		n = b.Len()
+	}
+	for i := 0; i < n; i++ {
+		key := strconv.Itoa(i)
+		switch {
+		case i >= b.Len():
+			children = append(children, diff{key: key, whole: true, from: a.Index(i), hasFrom: true})
+		case i >= a.Len():
+			children = append(children, diff{key: key, whole: true, to: b.Index(i), hasTo: true})
+		default:
+			if d := diffValues(key, a.Index(i), b.Index(i), depth); d.changed() {
+				children = append(children, d)
+			}
+		}
+	}
+	return children
+}
+
+// A diffEntry is a map key and its values in the old and new maps.
+type diffEntry struct {
+	key  string
+	a, b reflect.Value
+}
+
+type diffEntriesByKey []*diffEntry
+
+func (es diffEntriesByKey) Len() int           { return len(es) }
+func (es diffEntriesByKey) Less(i, j int) bool { return es[i].key < es[j].key }
+func (es diffEntriesByKey) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+
+func diffEntries(a, b reflect.Value, depth int) []diff {
+	byKey := make(map[interface{}]*diffEntry, a.Len())
+	entries := make(diffEntriesByKey, 0, a.Len())
+	lookup := func(k reflect.Value) *diffEntry {
+		e, ok := byKey[k.Interface()]
+		if !ok {
+			e = &diffEntry{key: fmt.Sprint(k.Interface())}
+			byKey[k.Interface()] = e
+			entries = append(entries, e)
+		}
+		return e
+	}
+	for _, k := range a.MapKeys() {
+		lookup(k).a = a.MapIndex(k)
+	}
+	for _, k := range b.MapKeys() {
+		lookup(k).b = b.MapIndex(k)
+	}
+	sort.Stable(entries)
+
+	var children []diff
+	for _, e := range entries {
+		switch {
+		case !e.b.IsValid():
+			children = append(children, diff{key: e.key, whole: true, from: e.a, hasFrom: true})
+		case !e.a.IsValid():
+			children = append(children, diff{key: e.key, whole: true, to: e.b, hasTo: true})
+		default:
+			if d := diffValues(e.key, e.a, e.b, depth); d.changed() {
+				children = append(children, d)
+			}
+		}
+	}
+	return children
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type diffAddress struct {
+	City string `json:"city"`
+	Zip  string
+}
+
+type diffConfig struct {
+	StructExportedEmbed
+	Name     string `json:"name"`
+	Port     int
+	Ratio    float64
+	Tags     []string
+	Limits   map[string]int
+	Address  diffAddress
+	Backup   *diffAddress
+	Timeout  time.Duration
+	Started  time.Time
+	Extra    interface{}
+	Secret   string `zap:"-"`
+	internal int
+}
+
+type diffEmbeds struct {
+	structEmbedded
+	Own string
+}
+
+func baseDiffConfig() diffConfig {
+	return diffConfig{
+		StructExportedEmbed: StructExportedEmbed{"flat"},
+		Name:                "api",
+		Port:                80,
+		Ratio:               0.5,
+		Tags:                []string{"a", "b", "c"},
+		Limits:              map[string]int{"a": 1, "b": 2},
+		Address:             diffAddress{City: "Paris", Zip: "75001"},
+		Timeout:             time.Second,
+		Started:             time.Unix(0, 0),
+		Extra:               1,
+		Secret:              "hunter2",
+		internal:            1,
+	}
+}
+
+func TestDiffStructs(t *testing.T) {
+	tests := []struct {
+		desc     string
+		update   func(*diffConfig)
+		expected string
+	}{
+		{"identical", func(*diffConfig) {}, `"k":{}`},
+		{
+			"scalars",
+			func(c *diffConfig) { c.Name, c.Port, c.Ratio = "web", 8080, 0.75 },
+			`"k":{"name":{"from":"api","to":"web"},"Port":{"from":80,"to":8080},"Ratio":{"from":0.5,"to":0.75}}`,
+		},
+		{
+			"unexported and skipped fields",
+			func(c *diffConfig) { c.internal, c.Secret = 2, "changed" },
+			`"k":{}`,
+		},
+		{
+			"embedded struct",
+			func(c *diffConfig) { c.Flattened = "changed" },
+			`"k":{"Flattened":{"from":"flat","to":"changed"}}`,
+		},
+		{
+			"nested struct",
+			func(c *diffConfig) { c.Address.City = "Lyon" },
+			`"k":{"Address":{"city":{"from":"Paris","to":"Lyon"}}}`,
+		},
+		{
+			"nil pointer to struct",
+			func(c *diffConfig) { c.Backup = &diffAddress{City: "Nice"} },
+			`"k":{"Backup":{"from":null,"to":{"city":"Nice","Zip":""}}}`,
+		},
+		{
+			"slice",
+			func(c *diffConfig) { c.Tags = []string{"a", "x"} },
+			`"k":{"Tags":{"1":{"from":"b","to":"x"},"2":{"from":"c"}}}`,
+		},
+		{
+			"appended elements",
+			func(c *diffConfig) { c.Tags = append(c.Tags, "d") },
+			`"k":{"Tags":{"3":{"to":"d"}}}`,
+		},
+		{
+			"map",
+			func(c *diffConfig) { c.Limits = map[string]int{"b": 3, "c": 4} },
+			`"k":{"Limits":{"a":{"from":1},"b":{"from":2,"to":3},"c":{"to":4}}}`,
+		},
+		{
+			"duration and time",
+			func(c *diffConfig) { c.Timeout, c.Started = 2*time.Second, time.Unix(1, 0) },
+			`"k":{"Timeout":{"from":1000000000,"to":2000000000},"Started":{"from":0,"to":1}}`,
+		},
+		{
+			"equal times in different locations",
+			func(c *diffConfig) { c.Started = c.Started.In(time.FixedZone("EST", -5*60*60)) },
+			`"k":{}`,
+		},
+		{
+			"interface with a different type",
+			func(c *diffConfig) { c.Extra = "one" },
+			`"k":{"Extra":{"from":1,"to":"one","note":"type changed from int to string"}}`,
+		},
+		{
+			"interface set to nil",
+			func(c *diffConfig) { c.Extra = nil },
+			`"k":{"Extra":{"from":1,"to":null}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		old, updated := baseDiffConfig(), baseDiffConfig()
+		tt.update(&updated)
+		assertFieldJSON(t, tt.expected, Diff("k", old, updated))
+		assertFieldJSON(t, tt.expected, Diff("k", &old, &updated))
+		assertCanBeReused(t, Diff("k", old, updated))
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		desc     string
+		old, new interface{}
+		expected string
+	}{
+		{"both nil", nil, nil, `"k":{}`},
+		{"nil to value", nil, 1, `"k":{"from":null,"to":1}`},
+		{"value to nil pointer", &diffAddress{}, (*diffAddress)(nil), `"k":{"from":{"city":"","Zip":""},"to":null}`},
+		{"equal scalars", 1, 1, `"k":{}`},
+		{"scalars", 1, 2, `"k":{"from":1,"to":2}`},
+		{"NaNs", nan(), nan(), `"k":{}`},
+		{"type mismatch", 1, "one", `"k":{"from":1,"to":"one","note":"type changed from int to string"}`},
+		{
+			"struct type mismatch",
+			diffAddress{City: "Paris"},
+			structInner{Name: "Paris"},
+			`"k":{"from":{"city":"Paris","Zip":""},"to":{"name":"Paris"},"note":"type changed from zap.diffAddress to zap.structInner"}`,
+		},
+		{
+			"unexported embedded struct",
+			diffEmbeds{structEmbedded{"a"}, "own"},
+			diffEmbeds{structEmbedded{"b"}, "own"},
+			`"k":{"Flattened":{"from":"a","to":"b"}}`,
+		},
+		{"byte slices", []byte("foo"), []byte("bar"), `"k":{"from":"Zm9v","to":"YmFy"}`},
+		{"nil and empty slices", []int(nil), []int{}, `"k":{}`},
+		{"arrays", [2]int{1, 2}, [2]int{1, 3}, `"k":{"1":{"from":2,"to":3}}`},
+		{
+			"nested containers",
+			map[string][]int{"a": {1}},
+			map[string][]int{"a": {2}},
+			`"k":{"a":{"0":{"from":1,"to":2}}}`,
+		},
+		{
+			"non-string map keys",
+			map[int]string{2: "b", 10: "j"},
+			map[int]string{2: "c", 10: "k"},
+			`"k":{"10":{"from":"j","to":"k"},"2":{"from":"b","to":"c"}}`,
+		},
+		{
+			"slices of structs",
+			[]diffAddress{{City: "Paris"}},
+			[]diffAddress{{City: "Lyon"}},
+			`"k":{"0":{"city":{"from":"Paris","to":"Lyon"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, Diff("k", tt.old, tt.new))
+	}
+}
+
+func nan() float64 {
+	zero := 0.0
+	return zero / zero
+}
+
+func TestDiffDepth(t *testing.T) {
+	old, updated := baseDiffConfig(), baseDiffConfig()
+	updated.Address.City = "Lyon"
+	updated.Tags = []string{"a"}
+
+	assertFieldJSON(t, `"k":{"Tags":{"from":["a","b","c"],"to":["a"]},"Address":{"from":{"city":"Paris","Zip":"75001"},"to":{"city":"Lyon","Zip":"75001"}}}`, DiffDepth("k", old, updated, 0))
+	assertFieldJSON(t, `"k":{"Tags":{"1":{"from":"b"},"2":{"from":"c"}},"Address":{"city":{"from":"Paris","to":"Lyon"}}}`, DiffDepth("k", old, updated, 1))
+	assertFieldJSON(t, `"k":{"from":1,"to":2}`, DiffDepth("k", 1, 2, -1))
+	assertFieldJSON(t, `"k":{}`, DiffDepth("k", old, old, -1))
+
+	// Self-referential values are cut off by the depth limit.
+	a := &structNode{Val: 1}
+	a.Next = a
+	b := &structNode{Val: 2}
+	b.Next = b
+	enc := newJSONEncoder()
+	defer enc.Free()
+	assert.NotPanics(t, func() { DiffDepth("k", a, b, 2).AddTo(enc) }, "Unexpected panic diffing cyclic values.")
+	assert.True(t, strings.HasPrefix(string(enc.bytes), `"k":{"Val":{"from":1,"to":2},"Next":{"Val":{"from":1,"to":2},"Next":{"Val":{"from":1,"to":2},"Next":{"from":{"Val":1,"Next":`), "Unexpected output diffing cyclic values: %s", enc.bytes)
+}
+
+func TestDiffLargeContainers(t *testing.T) {
+	old := make([]int, _maxDiffElements+1)
+	updated := make([]int, _maxDiffElements+1)
+	updated[0] = 1
+
+	enc := newJSONEncoder()
+	defer enc.Free()
+	Diff("k", old, updated).AddTo(enc)
+	assert.True(t, strings.HasPrefix(string(enc.bytes), `"k":{"from":[0,0,`), "Expected large slices to be compared whole: %s", enc.bytes)
+	assert.Contains(t, string(enc.bytes), `],"to":[1,0,`, "Expected large slices to be compared whole.")
+
+	assertFieldJSON(t, `"k":{}`, Diff("k", old, make([]int, _maxDiffElements+1)))
+}
+
+func TestDiffIsLazy(t *testing.T) {
+	old, updated := baseDiffConfig(), baseDiffConfig()
+	assert.Equal(t, lazyType, Diff("k", old, updated).fieldType, "Expected Diff to return a lazy field.")
+
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		updated.Port = 443
+		logger.Info("updated", Diff("config", old, updated))
+		assert.Equal(t, `{"level":"info","msg":"updated","config":{"Port":{"from":80,"to":443}}}`, buf.Stripped(), "Unexpected output logging a diff.")
+	})
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "fmt"
+
+// DynamicFields wraps a Logger, adding the fields returned by fn to each
+// entry after the fields supplied at the log site. It's useful for context
+// that changes from entry to entry, like trace IDs kept in a per-request
+// registry, without deriving a new logger for each request.
+//
+// The function is called once for each entry that's written, after the level
+// check, so it's never called for disabled levels (or for CheckedMessages
+// that aren't OK). The exceptions are DPanic, Panic, and Fatal, which may
+// terminate even if their levels are disabled; they always call it. It should
+// be cheap, and it may return nil to add nothing.
+// If it panics, the panic is recovered and reported to the logger's
+// ErrorOutput, if it has one, and the entry is written without the dynamic
+// fields.
+//
+// The dynamic fields are encoded just like fields supplied at the log site,
+// so they're added to any open namespace and take part in DeduplicateKeys.
+// To call the function once per entry for several loggers, wrap a Tee of
+// them rather than each one.
+func DynamicFields(log Logger, fn func() []Field) Logger {
+	return &dynamicLogger{log: log, fn: fn}
+}
+
+type dynamicLogger struct {
+	log Logger
+	fn  func() []Field
+}
+
+func (dl *dynamicLogger) With(fields ...Field) Logger {
+	return &dynamicLogger{log: dl.log.With(fields...), fn: dl.fn}
+}
+
+func (dl *dynamicLogger) Sync() error {
+	return Sync(dl.log)
+}
+
+func (dl *dynamicLogger) Context() []Field {
+	return Context(dl.log)
+}
+
+func (dl *dynamicLogger) Audit() Logger {
+	return &dynamicLogger{log: Audit(dl.log), fn: dl.fn}
+}
+
+func (dl *dynamicLogger) IsAudit() bool {
+	return IsAudit(dl.log)
+}
+
+func (dl *dynamicLogger) Terminates(lvl Level) bool {
+	return terminates(dl.log, lvl)
+}
+
+func (dl *dynamicLogger) Check(lvl Level, msg string) *CheckedMessage {
+	switch lvl {
+	case PanicLevel, FatalLevel:
+		// Like Tees, write these with our own Panic and Fatal methods, so
+		// that chained messages can Log them without terminating.
+		return NewCheckedMessage(dl, lvl, msg)
+	}
+	cm := dl.log.Check(lvl, msg)
+	if !cm.OK() {
+		return nil
+	}
+	return NewCheckedMessage(&dynamicMessage{Logger: dl.log, cm: cm, dl: dl}, lvl, msg)
+}
+
+func (dl *dynamicLogger) Log(lvl Level, msg string, fields ...Field) {
+	switch lvl {
+	case PanicLevel, FatalLevel:
+		dl.log.Log(lvl, msg, dl.resolve(fields)...)
+	case DPanicLevel:
+		// Writing a DPanic-level CheckedMessage may panic in development, but
+		// Log never does.
+		if cm := dl.log.Check(lvl, msg); cm.OK() {
+			cm.discard()
+			dl.log.Log(lvl, msg, dl.resolve(fields)...)
+		}
+	default:
+		dl.write(lvl, msg, fields)
+	}
+}
+
+func (dl *dynamicLogger) Trace(msg string, fields ...Field) {
+	dl.write(TraceLevel, msg, fields)
+}
+
+func (dl *dynamicLogger) Debug(msg string, fields ...Field) {
+	dl.write(DebugLevel, msg, fields)
+}
+
+func (dl *dynamicLogger) Info(msg string, fields ...Field) {
+	dl.write(InfoLevel, msg, fields)
+}
+
+func (dl *dynamicLogger) Warn(msg string, fields ...Field) {
+	dl.write(WarnLevel, msg, fields)
+}
+
+func (dl *dynamicLogger) Error(msg string, fields ...Field) {
+	dl.write(ErrorLevel, msg, fields)
+}
+
+func (dl *dynamicLogger) DPanic(msg string, fields ...Field) {
+	// DPanic may panic in development even if the level is disabled.
+	dl.log.DPanic(msg, dl.resolve(fields)...)
+}
+
+func (dl *dynamicLogger) Panic(msg string, fields ...Field) {
+	dl.log.Panic(msg, dl.resolve(fields)...)
+}
+
+func (dl *dynamicLogger) Fatal(msg string, fields ...Field) {
+	dl.log.Fatal(msg, dl.resolve(fields)...)
+}
+
+func (dl *dynamicLogger) encodeTerminal(lvl Level, msg string, fields []Field) func() {
+	return encodeTerminal(dl.log, lvl, msg, dl.resolve(fields))
+}
+
+// write checks the wrapped logger and writes the entry with the dynamic
+// fields, if the level is enabled.
+func (dl *dynamicLogger) write(lvl Level, msg string, fields []Field) {
+	if cm := dl.log.Check(lvl, msg); cm.OK() {
+		cm.Write(dl.resolve(fields)...)
+	}
+}
+
+// resolve appends the dynamic fields to the supplied fields, without
+// modifying the caller's slice.
+func (dl *dynamicLogger) resolve(fields []Field) []Field {
+	dynamic := dl.dynamic()
+	if len(dynamic) == 0 {
+		return fields
+	}
+	return append(fields[:len(fields):len(fields)], dynamic...)
+}
+
+func (dl *dynamicLogger) dynamic() (fields []Field) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportInternalError(dl.log, "dynamic fields", fmt.Errorf("panic resolving dynamic fields: %v", r))
+			fields = nil
+		}
+	}()
+	return dl.fn()
+}
+
+// A dynamicMessage writes a CheckedMessage from the wrapped logger, adding the
+// dynamic fields. Like a filteredMessage, it only needs the level methods that
+// CheckedMessages call below Panic (and Log, for other levels).
+type dynamicMessage struct {
+	Logger
+
+	cm *CheckedMessage
+	dl *dynamicLogger
+}
+
+func (dm *dynamicMessage) write(fields []Field) {
+	dm.cm.Write(dm.dl.resolve(fields)...)
+}
+
+func (dm *dynamicMessage) wrappedLogger() Logger {
+	return dm.Logger
+}
+
+func (dm *dynamicMessage) Log(_ Level, _ string, fields ...Field) {
+	dm.write(fields)
+}
+
+func (dm *dynamicMessage) Trace(_ string, fields ...Field) {
+	dm.write(fields)
+}
+
+func (dm *dynamicMessage) Debug(_ string, fields ...Field) {
+	dm.write(fields)
+}
+
+func (dm *dynamicMessage) Info(_ string, fields ...Field) {
+	dm.write(fields)
+}
+
+func (dm *dynamicMessage) Warn(_ string, fields ...Field) {
+	dm.write(fields)
+}
+
+func (dm *dynamicMessage) Error(_ string, fields ...Field) {
+	dm.write(fields)
+}
+
+func (dm *dynamicMessage) DPanic(_ string, fields ...Field) {
+	dm.write(fields)
+}
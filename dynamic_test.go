@@ -0,0 +1,163 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/atomic"
+)
+
+// countingFields returns a resolver that adds a "call" field with the number
+// of times it's been called.
+func countingFields() (func() []Field, *atomic.Int64) {
+	calls := atomic.NewInt64(0)
+	return func() []Field {
+		return []Field{Int64("call", calls.Inc())}
+	}, calls
+}
+
+func TestDynamicFields(t *testing.T) {
+	withJSONLogger(t, opts(InfoLevel), func(logger Logger, buf *testBuffer) {
+		fn, calls := countingFields()
+		log := DynamicFields(logger.With(String("user", "alice")), fn)
+
+		log.Debug("disabled")
+		log.Log(DebugLevel, "disabled")
+		assert.False(t, log.Check(DebugLevel, "disabled").OK(), "Expected CheckedMessages at disabled levels to be non-OK.")
+		assert.Equal(t, int64(0), calls.Load(), "Expected no calls at disabled levels.")
+
+		log.Info("info", Int("n", 1))
+		log.Log(InfoLevel, "log")
+		cm := log.Check(InfoLevel, "checked")
+		require.True(t, cm.OK(), "Expected CheckedMessages at enabled levels to be OK.")
+		assert.Equal(t, int64(2), calls.Load(), "Expected Check not to call the resolver.")
+		cm.Write(Int("n", 2))
+		log.With(Int("child", 1)).Info("child")
+		assert.Equal(t, int64(4), calls.Load(), "Expected one call per written entry.")
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"info","user":"alice","n":1,"call":1}`,
+			`{"level":"info","msg":"log","user":"alice","call":2}`,
+			`{"level":"info","msg":"checked","user":"alice","n":2,"call":3}`,
+			`{"level":"info","msg":"child","user":"alice","child":1,"call":4}`,
+		}, buf.Lines(), "Unexpected output with dynamic fields.")
+	})
+}
+
+func TestDynamicFieldsDontModifyCallerFields(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		fn, _ := countingFields()
+		fields := make([]Field, 1, 2)
+		fields[0] = Int("n", 1)
+		DynamicFields(logger, fn).Info("foo", fields...)
+		assert.Equal(t, []Field{Int("n", 1)}, fields[:cap(fields)][:1], "Expected the caller's fields to be unchanged.")
+		assert.Equal(t, Field{}, fields[:cap(fields)][1], "Expected the caller's backing array to be unchanged.")
+	})
+}
+
+func TestDynamicFieldsNil(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		log := DynamicFields(logger, func() []Field { return nil })
+		log.Info("foo", Int("n", 1))
+		log.Check(InfoLevel, "bar").Write()
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"foo","n":1}`,
+			`{"level":"info","msg":"bar"}`,
+		}, buf.Lines(), "Expected nil dynamic fields to add nothing.")
+	})
+}
+
+func TestDynamicFieldsPanic(t *testing.T) {
+	buf, errBuf := &testBuffer{}, &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(errBuf))
+	log := DynamicFields(logger, func() []Field { panic("no trace ID") })
+	assert.NotPanics(t, func() { log.Info("foo", Int("n", 1)) }, "Expected panics in the resolver to be recovered.")
+	assert.Equal(t, `{"level":"info","msg":"foo","n":1}`, buf.Stripped(), "Expected the entry to be written without dynamic fields.")
+	assert.Contains(t, errBuf.String(), "dynamic fields error: panic resolving dynamic fields: no trace ID", "Expected the panic to be reported.")
+}
+
+func TestDynamicFieldsEncoding(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime(), DeduplicateKeys()), Output(buf), Fields(String("trace", "stale")))
+	log := DynamicFields(logger, func() []Field { return []Field{String("trace", "abc")} })
+	log.Info("dedupe")
+	log.Info("namespace", Namespace("request"), Int("n", 1))
+	assert.Equal(t, []string{
+		`{"level":"info","msg":"dedupe","trace":"abc"}`,
+		`{"level":"info","msg":"namespace","trace":"stale","request":{"n":1,"trace":"abc"}}`,
+	}, buf.Lines(), "Expected dynamic fields to be encoded like any others.")
+}
+
+func TestDynamicFieldsTee(t *testing.T) {
+	buf1, buf2 := &testBuffer{}, &testBuffer{}
+	tee := Tee(
+		New(newJSONEncoder(NoTime()), Output(buf1), ErrorOutput(Discard)),
+		New(newJSONEncoder(NoTime()), WarnLevel, Output(buf2), ErrorOutput(Discard)),
+	)
+	fn, calls := countingFields()
+	log := DynamicFields(tee, fn)
+	log.Info("info")
+	log.Error("error")
+	log.Check(WarnLevel, "checked").Write()
+	assert.Equal(t, int64(3), calls.Load(), "Expected one call per entry, not per child.")
+	assert.Equal(t, []string{
+		`{"level":"info","msg":"info","call":1}`,
+		`{"level":"error","msg":"error","call":2}`,
+		`{"level":"warn","msg":"checked","call":3}`,
+	}, buf1.Lines(), "Unexpected output from the first child.")
+	assert.Equal(t, []string{
+		`{"level":"error","msg":"error","call":2}`,
+		`{"level":"warn","msg":"checked","call":3}`,
+	}, buf2.Lines(), "Unexpected output from the second child.")
+}
+
+func TestDynamicFieldsTerminalLevels(t *testing.T) {
+	buf := &testBuffer{}
+	fn, calls := countingFields()
+	log := DynamicFields(New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard)), fn)
+
+	stub := stubExit()
+	defer stub.Unstub()
+	log.Log(FatalLevel, "log fatal")
+	stub.AssertNoExit(t)
+	assert.Panics(t, func() { log.Panic("panic") }, "Expected Panic to panic.")
+	assert.Panics(t, func() { log.Check(PanicLevel, "checked panic").Write() }, "Expected checked Panic to panic.")
+	log.Check(FatalLevel, "checked fatal").Chain(log.Check(InfoLevel, "after")).Write()
+	stub.AssertStatus(t, 1)
+	assert.Equal(t, int64(5), calls.Load(), "Unexpected number of calls.")
+	assert.Equal(t, []string{
+		`{"level":"fatal","msg":"log fatal","call":1}`,
+		`{"level":"panic","msg":"panic","call":2}`,
+		`{"level":"panic","msg":"checked panic","call":3}`,
+		`{"level":"fatal","msg":"checked fatal","call":4}`,
+		`{"level":"info","msg":"after","call":5}`,
+	}, buf.Lines(), "Unexpected output at terminal levels.")
+}
+
+func TestDynamicFieldsLogDPanic(t *testing.T) {
+	buf := &testBuffer{}
+	fn, _ := countingFields()
+	log := DynamicFields(New(newJSONEncoder(NoTime()), Development(), Output(buf), ErrorOutput(Discard)), fn)
+	assert.NotPanics(t, func() { log.Log(DPanicLevel, "dpanic") }, "Expected Log not to panic in development.")
+	assert.Equal(t, `{"level":"dpanic","msg":"dpanic","call":1}`, buf.Stripped(), "Unexpected output from Log at DPanicLevel.")
+}
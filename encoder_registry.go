@@ -0,0 +1,160 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoEncoderConstructor = errors.New("encoder constructor must not be nil")
+
+// EncoderConfig is a data-driven description of an encoder, suitable for
+// loading from a configuration file. Each encoder interprets the fields it
+// supports and ignores the rest; zero values select the encoder's defaults.
+type EncoderConfig struct {
+	// MessageKey, LevelKey, and TimeKey set the keys used for the entry's
+	// message, level, and timestamp. The text encoder doesn't use keys.
+	MessageKey string `json:"messageKey"`
+	LevelKey   string `json:"levelKey"`
+	TimeKey    string `json:"timeKey"`
+	// TimeFormat is a layout string, as understood by time.Parse. By default,
+	// the JSON encoder writes floating-point seconds since epoch and the text
+	// encoder writes RFC3339 timestamps.
+	TimeFormat string `json:"timeFormat"`
+	// NoTime omits timestamps altogether.
+	NoTime bool `json:"noTime"`
+}
+
+// An EncoderConstructor builds an Encoder from an EncoderConfig.
+type EncoderConstructor func(EncoderConfig) (Encoder, error)
+
+var _encoders = struct {
+	sync.RWMutex
+	byName map[string]EncoderConstructor
+}{byName: map[string]EncoderConstructor{
+	"json":        newJSONEncoderFromConfig,
+	"text":        newTextEncoderFromConfig,
+	"console":     newTextEncoderFromConfig,
+	"null":        newNullEncoderFromConfig,
+	"access":      newAccessLogEncoderFromConfig,
+	"stackdriver": newStackdriverEncoderFromConfig,
+	"binary":      newBinaryEncoderFromConfig,
+}}
+
+// RegisterEncoder makes an encoder available to NewEncoderByName under the
+// supplied name. The "json", "text", "console" (an alias for "text"), "null",
+// "access" (see NewAccessLogEncoder), "stackdriver" (see
+// NewStackdriverEncoder), and "binary" (see NewBinaryEncoder) encoders are
+// registered by default. Registering a
+// name twice returns an error.
+//
+// RegisterEncoder is safe for concurrent use, so packages providing encoders
+// may call it from an init function.
+func RegisterEncoder(name string, constructor EncoderConstructor) error {
+	if constructor == nil {
+		return errNoEncoderConstructor
+	}
+	_encoders.Lock()
+	defer _encoders.Unlock()
+	if _, ok := _encoders.byName[name]; ok {
+		return fmt.Errorf("encoder %q is already registered", name)
+	}
+	_encoders.byName[name] = constructor
+	return nil
+}
+
+// NewEncoderByName builds an encoder using the constructor registered under
+// the supplied name. Unknown names return an error listing the registered
+// encoders.
+func NewEncoderByName(name string, cfg EncoderConfig) (Encoder, error) {
+	_encoders.RLock()
+	constructor, ok := _encoders.byName[name]
+	_encoders.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no encoder registered for name %q (registered encoders: %s)", name, registeredEncoders())
+	}
+	return constructor(cfg)
+}
+
+func registeredEncoders() string {
+	_encoders.RLock()
+	names := make([]string, 0, len(_encoders.byName))
+	for name := range _encoders.byName {
+		names = append(names, name)
+	}
+	_encoders.RUnlock()
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func newJSONEncoderFromConfig(cfg EncoderConfig) (Encoder, error) {
+	var opts []JSONOption
+	if cfg.MessageKey != "" {
+		opts = append(opts, MessageKey(cfg.MessageKey))
+	}
+	if cfg.LevelKey != "" {
+		opts = append(opts, LevelString(cfg.LevelKey))
+	}
+	timeKey := cfg.TimeKey
+	if timeKey == "" {
+		timeKey = "ts"
+	}
+	switch {
+	case cfg.NoTime:
+		opts = append(opts, NoTime())
+	case cfg.TimeFormat != "":
+		layout := cfg.TimeFormat
+		opts = append(opts, TimeFormatter(func(t time.Time) Field {
+			return String(timeKey, t.Format(layout))
+		}))
+	case cfg.TimeKey != "":
+		opts = append(opts, EpochFormatter(timeKey))
+	}
+	return NewJSONEncoder(opts...), nil
+}
+
+func newTextEncoderFromConfig(cfg EncoderConfig) (Encoder, error) {
+	var opts []TextOption
+	switch {
+	case cfg.NoTime:
+		opts = append(opts, TextNoTime())
+	case cfg.TimeFormat != "":
+		opts = append(opts, TextTimeFormat(cfg.TimeFormat))
+	}
+	return NewTextEncoder(opts...), nil
+}
+
+func newNullEncoderFromConfig(EncoderConfig) (Encoder, error) {
+	return NullEncoder(), nil
+}
+
+func newAccessLogEncoderFromConfig(EncoderConfig) (Encoder, error) {
+	return NewAccessLogEncoder(), nil
+}
+
+func newBinaryEncoderFromConfig(EncoderConfig) (Encoder, error) {
+	return NewBinaryEncoder(), nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEncoder writes each entry's level and message, prefixed with the
+// configured message key.
+type fakeEncoder struct {
+	nullEncoder
+	key string
+}
+
+func (enc fakeEncoder) Clone() Encoder { return enc }
+
+func (enc fakeEncoder) WriteEntry(w io.Writer, ent Entry) error {
+	_, err := fmt.Fprintf(w, "fake %s %s=%s\n", ent.Level, enc.key, ent.Message)
+	return err
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	var got EncoderConfig
+	require.NoError(t, RegisterEncoder("fake", func(cfg EncoderConfig) (Encoder, error) {
+		got = cfg
+		return fakeEncoder{key: cfg.MessageKey}, nil
+	}), "Unexpected error registering an encoder.")
+
+	cfg := EncoderConfig{MessageKey: "message"}
+	enc, err := NewEncoderByName("fake", cfg)
+	require.NoError(t, err, "Unexpected error building a registered encoder.")
+	assert.Equal(t, cfg, got, "Expected the constructor to receive the config.")
+
+	buf := &testBuffer{}
+	logger := New(enc, Output(buf))
+	logger.Info("hello")
+	logger.With(String("ignored", "field")).Warn("world")
+	assert.Equal(t, []string{"fake info message=hello", "fake warn message=world"}, buf.Lines(), "Expected entries to go through the registered encoder.")
+
+	err = RegisterEncoder("fake", func(EncoderConfig) (Encoder, error) { return NullEncoder(), nil })
+	assert.Error(t, err, "Expected an error registering a duplicate name.")
+	assert.Contains(t, err.Error(), `"fake"`, "Expected the error to name the duplicate encoder.")
+	assert.Equal(t, errNoEncoderConstructor, RegisterEncoder("nil", nil), "Expected an error registering a nil constructor.")
+}
+
+func TestRegisterEncoderDuplicateBuiltins(t *testing.T) {
+	for _, name := range []string{"json", "text", "console", "null", "access", "stackdriver", "binary"} {
+		assert.Error(t, RegisterEncoder(name, func(EncoderConfig) (Encoder, error) { return NullEncoder(), nil }), "Expected built-in encoder %q to be registered.", name)
+	}
+}
+
+func TestRegisterEncoderConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- RegisterEncoder("concurrent", func(EncoderConfig) (Encoder, error) { return NullEncoder(), nil })
+			_, err := NewEncoderByName("json", EncoderConfig{})
+			assert.NoError(t, err, "Unexpected error looking up the JSON encoder.")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded, "Expected exactly one concurrent registration to succeed.")
+}
+
+func TestNewEncoderByNameUnknown(t *testing.T) {
+	_, err := NewEncoderByName("msgpack", EncoderConfig{})
+	require.Error(t, err, "Expected an error looking up an unregistered encoder.")
+	assert.Contains(t, err.Error(), `"msgpack"`, "Expected the error to name the missing encoder.")
+	assert.Contains(t, err.Error(), "console, ", "Expected the error to list the registered encoders.")
+	assert.Contains(t, err.Error(), "json, ", "Expected the error to list the registered encoders.")
+}
+
+func TestNewEncoderByNameBuiltins(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      EncoderConfig
+		expected string
+	}{
+		{"json", EncoderConfig{}, `{"level":"info","ts":0,"msg":"hello"}`},
+		{"json", EncoderConfig{MessageKey: "M", LevelKey: "L", TimeKey: "T"}, `{"L":"info","T":0,"M":"hello"}`},
+		{"json", EncoderConfig{TimeFormat: time.RFC3339}, `{"level":"info","ts":"1970-01-01T00:00:00Z","msg":"hello"}`},
+		{"json", EncoderConfig{NoTime: true, TimeFormat: time.RFC3339}, `{"level":"info","msg":"hello"}`},
+		{"text", EncoderConfig{}, "[I] 1970-01-01T00:00:00Z hello"},
+		{"console", EncoderConfig{TimeFormat: "15:04"}, "[I] 00:00 hello"},
+		{"text", EncoderConfig{NoTime: true}, "[I] hello"},
+		{"null", EncoderConfig{}, ""},
+	}
+
+	for _, tt := range tests {
+		enc, err := NewEncoderByName(tt.name, tt.cfg)
+		require.NoError(t, err, "Unexpected error building the %q encoder.", tt.name)
+		buf := &testBuffer{}
+		require.NoError(t, enc.WriteEntry(buf, Entry{Message: "hello", Level: InfoLevel, Time: epoch}), "Unexpected error writing an entry.")
+		assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output from the %q encoder with config %+v.", tt.name, tt.cfg)
+		enc.Free()
+	}
+}
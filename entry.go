@@ -20,11 +20,16 @@
 
 package zap
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 // An Entry represents a complete log message. The entry's structured context
-// is already serialized, but the log level, time, and message are available
-// for inspection and modification.
+// is already serialized, but the log level, time, message, and any caller and
+// stack information recorded by hooks are available for inspection and
+// modification. Each write builds a single Entry, which is passed to the hooks
+// and then to the encoder.
 //
 // Entries are pooled, so any functions that accept them must be careful not to
 // retain references to them.
@@ -32,10 +37,53 @@ type Entry struct {
 	Level   Level
 	Time    time.Time
 	Message string
-	enc     Encoder
+	// Caller is the entry's call site. It's only defined if a hook (for
+	// example, AddCaller) recorded it.
+	Caller EntryCaller
+	// Stack is a stacktrace of the logging goroutine. It's only set if a hook
+	// (for example, AddStacks) recorded it.
+	Stack string
+	enc   Encoder
+}
+
+// An EntryCaller describes the call site of a log entry.
+type EntryCaller struct {
+	Defined bool
+	PC      uintptr
+	File    string
+	Line    int
 }
 
 // Fields returns a mutable reference to the entry's accumulated context.
 func (e Entry) Fields() KeyValue {
 	return e.enc
 }
+
+// Write encodes the entry, along with its accumulated context, to the supplied
+// writer. If the writer is an EntryWriteSyncer, the encoded entry is passed to
+// its WriteEntry method along with the entry itself.
+func (e *Entry) Write(w io.Writer) error {
+	if ews, ok := w.(EntryWriteSyncer); ok {
+		return e.enc.WriteEntry(entryWriter{ews, e}, *e)
+	}
+	return e.enc.WriteEntry(w, *e)
+}
+
+// entryWriter adapts an EntryWriteSyncer to an io.Writer for a single entry.
+type entryWriter struct {
+	ews EntryWriteSyncer
+	ent *Entry
+}
+
+func (w entryWriter) Write(p []byte) (int, error) {
+	return w.ews.WriteEntry(*w.ent, p)
+}
+
+// Free returns the entry and its encoder to their pools. Only the code that
+// created the entry (typically a Logger, using Meta.Encode) should free it;
+// the entry must not be used afterwards.
+func (e *Entry) Free() {
+	e.enc.Free()
+	*e = Entry{}
+	_entryPool.Put(e)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "fmt"
+
+// _maxCauses limits the length of the cause chains added by VerboseError,
+// protecting against errors that (directly or indirectly) cause themselves.
+const _maxCauses = 32
+
+// causer is implemented by errors that wrap another error, in the style of
+// github.com/pkg/errors.
+type causer interface {
+	Cause() error
+}
+
+// unwrapper is implemented by errors that wrap another error, in the style of
+// the golang.org/x/xerrors proposal.
+type unwrapper interface {
+	Unwrap() error
+}
+
+// Errors constructs a field that carries a slice of errors, encoded as an
+// array of their messages. Nil errors are skipped, and errors that implement
+// LogMarshaler are encoded as nested objects.
+func Errors(key string, errs []error) Field {
+	return Array(key, errorsArray(errs))
+}
+
+// VerboseError constructs a field that stores err.Error() under the provided
+// key, along with as much additional detail as the error supports. Errors
+// that implement fmt.Formatter (including those created by
+// github.com/pkg/errors) also have their "%+v" representation, which often
+// includes a stacktrace, stored under "<key>Verbose". If the error wraps other
+// errors (via a Cause or Unwrap method), the messages of the wrapped errors
+// are stored as an array under "<key>Causes".
+//
+// Since formatting an error's verbose representation is often expensive,
+// VerboseError is best reserved for unexpected errors. Like Error, it's a
+// no-op when passed a nil error. See VerboseErrorKeys to change the keys of
+// the additional details.
+func VerboseError(key string, err error) Field {
+	return VerboseErrorKeys(key, key+"Verbose", key+"Causes", err)
+}
+
+// VerboseErrorKeys is like VerboseError, but it stores the error's "%+v"
+// representation under verboseKey and its cause chain under causesKey. Either
+// detail is omitted if its key is empty.
+func VerboseErrorKeys(key, verboseKey, causesKey string, err error) Field {
+	if err == nil {
+		return Skip()
+	}
+	if isNilPointer(err) {
+		return Reflect(key, nil)
+	}
+	return Field{key: key, fieldType: verboseErrorType, obj: verboseError{
+		err:        err,
+		verboseKey: verboseKey,
+		causesKey:  causesKey,
+	}}
+}
+
+type verboseError struct {
+	err        error
+	verboseKey string
+	causesKey  string
+}
+
+func addError(kv KeyValue, key string, err error) error {
+	if m, ok := err.(LogMarshaler); ok {
+		return kv.AddMarshaler(key, m)
+	}
+	kv.AddString(key, err.Error())
+	return nil
+}
+
+func addVerboseError(kv KeyValue, key string, ve verboseError) error {
+	if marshalErr := addError(kv, key, ve.err); marshalErr != nil {
+		return marshalErr
+	}
+	if _, ok := ve.err.(fmt.Formatter); ok && ve.verboseKey != "" {
+		kv.AddString(ve.verboseKey, fmt.Sprintf("%+v", ve.err))
+	}
+	if ve.causesKey == "" {
+		return nil
+	}
+	if causes := causesOf(ve.err); len(causes) > 0 {
+		return kv.AddArray(ve.causesKey, errorsArray(causes))
+	}
+	return nil
+}
+
+// causesOf returns the chain of errors wrapped by err, starting with the error
+// it wraps directly.
+func causesOf(err error) []error {
+	var causes []error
+	for len(causes) < _maxCauses {
+		var next error
+		switch e := err.(type) {
+		case causer:
+			next = e.Cause()
+		case unwrapper:
+			next = e.Unwrap()
+		}
+		if next == nil || isNilPointer(next) {
+			break
+		}
+		causes = append(causes, next)
+		err = next
+	}
+	return causes
+}
+
+type errorsArray []error
+
+func (errs errorsArray) MarshalLogArray(arr ArrayEncoder) error {
+	for _, err := range errs {
+		if err == nil || isNilPointer(err) {
+			continue
+		}
+		if m, ok := err.(LogMarshaler); ok {
+			if marshalErr := arr.AppendMarshaler(m); marshalErr != nil {
+				return marshalErr
+			}
+			continue
+		}
+		arr.AppendString(err.Error())
+	}
+	return nil
+}
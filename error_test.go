@@ -0,0 +1,164 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stackError mimics the errors created by github.com/pkg/errors: it wraps a
+// cause and implements fmt.Formatter to print extra detail with "%+v".
+type stackError struct {
+	msg   string
+	cause error
+}
+
+func (e stackError) Error() string { return e.msg + ": " + e.cause.Error() }
+func (e stackError) Cause() error  { return e.cause }
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%+v\n%s\n\tstack.go:42", e.cause, e.msg)
+		return
+	}
+	io.WriteString(s, e.Error())
+}
+
+type unwrapError struct{ cause error }
+
+func (e unwrapError) Error() string { return "unwrapped: " + e.cause.Error() }
+func (e unwrapError) Unwrap() error { return e.cause }
+
+type marshalingError struct{ code int }
+
+func (e marshalingError) Error() string { return fmt.Sprintf("code %d", e.code) }
+
+func (e marshalingError) MarshalLog(kv KeyValue) error {
+	kv.AddInt("code", e.code)
+	return nil
+}
+
+type selfCausingError struct{}
+
+func (e *selfCausingError) Error() string { return "self" }
+func (e *selfCausingError) Cause() error  { return e }
+
+func TestErrorsField(t *testing.T) {
+	var typedNil *selfCausingError
+	tests := []struct {
+		field    Field
+		expected string
+	}{
+		{Errors("errs", nil), `"errs":[]`},
+		{Errors("errs", []error{nil, typedNil}), `"errs":[]`},
+		{Errors("errs", []error{errors.New("foo"), nil, errors.New("bar")}), `"errs":["foo","bar"]`},
+		{Errors("errs", multiError{errors.New("foo"), marshalingError{42}}), `"errs":["foo",{"code":42}]`},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, tt.field)
+		assertCanBeReused(t, tt.field)
+	}
+}
+
+func TestErrorFieldMarshaler(t *testing.T) {
+	assertFieldJSON(t, `"error":{"code":42}`, Error(marshalingError{42}))
+	assertFieldJSON(t, `"k":{"code":42}`, Any("k", marshalingError{42}))
+}
+
+func TestErrorFieldNils(t *testing.T) {
+	var typedNil *selfCausingError
+	assertFieldJSON(t, ``, Error(nil))
+	assertFieldJSON(t, `"error":null`, Error(typedNil))
+	assertFieldJSON(t, ``, VerboseError("err", nil))
+	assertFieldJSON(t, `"err":null`, VerboseError("err", typedNil))
+	assertFieldJSON(t, ``, VerboseErrorKeys("err", "v", "c", nil))
+	assertFieldJSON(t, `"err":null`, VerboseErrorKeys("err", "v", "c", typedNil))
+}
+
+func TestVerboseErrorField(t *testing.T) {
+	root := errors.New("root")
+	wrapped := stackError{"wrapped", stackError{"inner", root}}
+
+	tests := []struct {
+		desc     string
+		field    Field
+		expected string
+	}{
+		{
+			"plain error",
+			VerboseError("err", root),
+			`"err":"root"`,
+		},
+		{
+			"pkg/errors-style error",
+			VerboseError("err", wrapped),
+			`"err":"wrapped: inner: root",` +
+				`"errVerbose":"root\ninner\n\tstack.go:42\nwrapped\n\tstack.go:42",` +
+				`"errCauses":["inner: root","root"]`,
+		},
+		{
+			"Unwrap-style error",
+			VerboseError("err", unwrapError{unwrapError{root}}),
+			`"err":"unwrapped: unwrapped: root","errCauses":["unwrapped: root","root"]`,
+		},
+		{
+			"LogMarshaler error",
+			VerboseError("err", unwrapError{marshalingError{42}}),
+			`"err":"unwrapped: code 42","errCauses":[{"code":42}]`,
+		},
+		{
+			"multiple errors",
+			VerboseError("err", multiError{root, root}),
+			`"err":"root root "`,
+		},
+		{
+			"custom keys",
+			VerboseErrorKeys("err", "stack", "chain", wrapped),
+			`"err":"wrapped: inner: root",` +
+				`"stack":"root\ninner\n\tstack.go:42\nwrapped\n\tstack.go:42",` +
+				`"chain":["inner: root","root"]`,
+		},
+		{
+			"omitted details",
+			VerboseErrorKeys("err", "", "", wrapped),
+			`"err":"wrapped: inner: root"`,
+		},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, tt.field)
+		assertCanBeReused(t, tt.field)
+	}
+}
+
+func TestVerboseErrorCycle(t *testing.T) {
+	withJSONEncoder(func(enc *jsonEncoder) {
+		VerboseError("err", &selfCausingError{}).AddTo(enc)
+		assert.Contains(t, string(enc.bytes), `"errCauses":["self","self",`, "Expected to follow the cause chain.")
+	})
+	assert.Equal(t, _maxCauses, len(causesOf(&selfCausingError{})), "Expected cause chains to be capped.")
+}
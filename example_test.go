@@ -102,6 +102,24 @@ func ExampleNest() {
 	// {"level":"info","msg":"Logging a nested field.","outer":{"inner":42}}
 }
 
+func ExampleRedactKeys() {
+	logger := zap.New(
+		zap.NewJSONEncoder(zap.NoTime()), // drop timestamps in tests
+		zap.RedactKeys("password", "*token"),
+	)
+
+	// Keys are matched case-insensitively, and patterns may use wildcards.
+	logger.Info(
+		"Logged in.",
+		zap.String("user", "jane"),
+		zap.String("password", "hunter2"),
+		zap.String("authToken", "abc123"),
+	)
+
+	// Output:
+	// {"level":"info","msg":"Logged in.","user":"jane","password":"[REDACTED]","authToken":"[REDACTED]"}
+}
+
 func ExampleNew() {
 	// The default logger outputs to standard out and only writes logs that are
 	// Info level or higher.
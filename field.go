@@ -21,9 +21,13 @@
 package zap
 
 import (
+	"encoding"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
+	"reflect"
+	"sync"
 	"time"
 )
 
@@ -33,22 +37,38 @@ const (
 	unknownType fieldType = iota
 	boolType
 	floatType
+	float32Type
 	intType
 	int64Type
 	uintType
 	uint64Type
 	uintptrType
 	stringType
+	binaryType
+	byteStringType
+	rawJSONType
 	marshalerType
+	arrayType
 	objectType
 	stringerType
 	errorType
+	verboseErrorType
+	namespaceType
+	lazyType
+	replaceType
 	skipType
 )
 
 // A Field is a marshaling operation used to add a key-value pair to a logger's
 // context. Most fields are lazily marshaled, so it's inexpensive to add fields to
 // disabled debug-level log statements.
+//
+// Fields are a flat union: booleans, integers, floats (as their IEEE 754
+// bits), durations, and times are all stored inline, so constructing them
+// never allocates. Only fields carrying byte slices, errors, or arbitrary
+// objects use the interface slot. Note that passing fields to a method on the
+// Logger interface makes the variadic slice escape to the heap; the
+// Check-then-Write pattern avoids that allocation too.
 type Field struct {
 	key       string
 	fieldType fieldType
@@ -57,17 +77,46 @@ type Field struct {
 	obj       interface{}
 }
 
-// Skip constructs a no-op Field.
+// Skip constructs a no-op Field. Encoders ignore skipped fields entirely, so
+// they're a cheap way to build field lists conditionally.
 func Skip() Field {
 	return Field{fieldType: skipType}
 }
 
+// If returns the supplied field if the condition is true, and a no-op field
+// otherwise. Note that the field is constructed regardless, so expensive eager
+// constructors (like Stack) still do their work.
+func If(cond bool, f Field) Field {
+	if cond {
+		return f
+	}
+	return Skip()
+}
+
+// ErrIf is an alias for Error that reads naturally in conditional field
+// lists: it stores err.Error() under the key "error" if err is non-nil, and
+// is a no-op otherwise.
+func ErrIf(err error) Field {
+	return Error(err)
+}
+
 // Base64 constructs a field that encodes the given value as a padded base64
-// string. The byte slice is converted to a base64 string eagerly.
+// string. The byte slice is converted to a base64 string eagerly; see Binary
+// for a lazily-encoded alternative.
 func Base64(key string, val []byte) Field {
 	return String(key, base64.StdEncoding.EncodeToString(val))
 }
 
+// Binary constructs a field that carries an opaque binary blob. Binary data is
+// encoded lazily, and the representation is encoder-dependent: by default, the
+// JSON encoder writes padded base64 strings. Since the byte slice isn't copied,
+// callers shouldn't modify it after constructing the field.
+//
+// To log UTF-8 encoded text held in a []byte, use ByteString.
+func Binary(key string, val []byte) Field {
+	return Field{key: key, fieldType: binaryType, obj: val}
+}
+
 // Bool constructs a Field with the given key and value. Bools are marshaled
 // lazily.
 func Bool(key string, val bool) Field {
@@ -86,6 +135,13 @@ func Float64(key string, val float64) Field {
 	return Field{key: key, fieldType: floatType, ival: int64(math.Float64bits(val))}
 }
 
+// Float32 constructs a Field with the given key and value. Like Float64, the
+// representation is encoder-dependent; encoders use the shortest
+// representation that round-trips a 32-bit float.
+func Float32(key string, val float32) Field {
+	return Field{key: key, fieldType: float32Type, ival: int64(math.Float32bits(val))}
+}
+
 // Int constructs a Field with the given key and value. Marshaling ints is lazy.
 func Int(key string, val int) Field {
 	return Field{key: key, fieldType: intType, ival: int64(val)}
@@ -117,8 +173,34 @@ func String(key string, val string) Field {
 	return Field{key: key, fieldType: stringType, str: val}
 }
 
+// ByteString constructs a Field that carries UTF-8 encoded text as a []byte.
+// It avoids the copy required to convert the bytes to a string, and encoders
+// replace any invalid UTF-8 with the Unicode replacement character. Like
+// Binary, the byte slice isn't copied, so callers shouldn't modify it after
+// constructing the field.
+func ByteString(key string, val []byte) Field {
+	return Field{key: key, fieldType: byteStringType, obj: val}
+}
+
+// RawJSON constructs a field that carries a pre-encoded JSON value, which the
+// JSON encoder splices into its output without re-encoding. If the value
+// doesn't look like valid JSON, it's encoded as a string instead and the
+// problem is reported under the key "<key>Error". By default, the check only
+// inspects the first and last bytes of the value; see the StrictRawJSON
+// option for a complete validation. Since the byte slice isn't copied, callers
+// shouldn't modify it after constructing the field.
+func RawJSON(key string, val []byte) Field {
+	return Field{key: key, fieldType: rawJSONType, obj: val}
+}
+
 // Stringer constructs a Field with the given key and the output of the value's
-// String method. The Stringer's String method is called lazily.
+// String method. The Stringer's String method is called lazily, so it's never
+// called if the log entry is disabled.
+//
+// Nil Stringers are encoded as "<nil>". If the String method panics (which is
+// common for nil pointer receivers), the panic is recovered: the value is
+// encoded as "<nil>" or "<PANIC>", and the panic is reported under the key
+// "<key>Error".
 func Stringer(key string, val fmt.Stringer) Field {
 	return Field{key: key, fieldType: stringerType, obj: val}
 }
@@ -131,11 +213,18 @@ func Time(key string, val time.Time) Field {
 }
 
 // Error constructs a Field that lazily stores err.Error() under the key
-// "error". If passed a nil error, the field is a no-op.
+// "error". If passed a nil error, the field is a no-op; nil pointers wrapped
+// in an error interface are encoded as null. Errors that implement
+// LogMarshaler are encoded as nested objects instead.
+//
+// See VerboseError for a more detailed, but more expensive, alternative.
 func Error(err error) Field {
 	if err == nil {
 		return Skip()
 	}
+	if isNilPointer(err) {
+		return Reflect("error", nil)
+	}
 	return Field{key: "error", fieldType: errorType, obj: err}
 }
 
@@ -171,6 +260,14 @@ func Marshaler(key string, val LogMarshaler) Field {
 	return Field{key: key, fieldType: marshalerType, obj: val}
 }
 
+// Array constructs a field with the given key and zap.ArrayMarshaler. It's the
+// array equivalent of Marshaler: a type-safe and efficient way to add
+// user-defined sequences to the logging context. The ArrayMarshaler's
+// MarshalLogArray method is called lazily.
+func Array(key string, val ArrayMarshaler) Field {
+	return Field{key: key, fieldType: arrayType, obj: val}
+}
+
 // Object constructs a field with the given key and an arbitrary object. It uses
 // an encoding-appropriate, reflection-based function to lazily serialize nearly
 // any object into the logging context, but it's relatively slow and
@@ -182,12 +279,206 @@ func Object(key string, val interface{}) Field {
 	return Field{key: key, fieldType: objectType, obj: val}
 }
 
+// Reflect constructs a field with the given key and an arbitrary object. It's
+// equivalent to Object, but its name makes the cost of reflection-based
+// serialization explicit at the call site.
+func Reflect(key string, val interface{}) Field {
+	return Field{key: key, fieldType: objectType, obj: val}
+}
+
+// Any takes a key and an arbitrary value and chooses the best way to represent
+// them as a field, falling back to a reflection-based approach only if
+// necessary.
+//
+// Primitives, time.Time, time.Duration, []byte, map[string]interface{},
+// map[string]string, and values implementing LogMarshaler, error, or
+// fmt.Stringer use the corresponding typed constructors; everything else,
+// including errors and fmt.Stringers with a registered TypeEncoder, is passed
+// to Reflect. Nil values, including nil pointers wrapped in an interface, are
+// handed to Reflect too, so they're encoded as null instead of causing a
+// panic.
+func Any(key string, value interface{}) Field {
+	switch val := value.(type) {
+	case LogMarshaler:
+		if isNilPointer(val) {
+			return Reflect(key, nil)
+		}
+		return Marshaler(key, val)
+	case bool:
+		return Bool(key, val)
+	case float64:
+		return Float64(key, val)
+	case float32:
+		return Float32(key, val)
+	case int:
+		return Int(key, val)
+	case int64:
+		return Int64(key, val)
+	case int32:
+		return Int64(key, int64(val))
+	case int16:
+		return Int64(key, int64(val))
+	case int8:
+		return Int64(key, int64(val))
+	case uint:
+		return Uint(key, val)
+	case uint64:
+		return Uint64(key, val)
+	case uint32:
+		return Uint64(key, uint64(val))
+	case uint16:
+		return Uint64(key, uint64(val))
+	case uint8:
+		return Uint64(key, uint64(val))
+	case uintptr:
+		return Uintptr(key, val)
+	case string:
+		return String(key, val)
+	case []byte:
+		return Binary(key, val)
+	case map[string]interface{}:
+		return Map(key, val)
+	case map[string]string:
+		return StringMap(key, val)
+	case time.Time:
+		return Time(key, val)
+	case time.Duration:
+		return Duration(key, val)
+	case error:
+		if isNilPointer(val) {
+			return Reflect(key, nil)
+		}
+		if hasTypeEncoder(val) {
+			return Reflect(key, val)
+		}
+		return Field{key: key, fieldType: errorType, obj: val}
+	case fmt.Stringer:
+		if isNilPointer(val) {
+			return Reflect(key, nil)
+		}
+		if hasTypeEncoder(val) {
+			return Reflect(key, val)
+		}
+		return Stringer(key, val)
+	default:
+		return Reflect(key, val)
+	}
+}
+
+// isNilPointer reports whether the value is a nil pointer wrapped in a non-nil
+// interface. Methods called on such values (e.g., Error or String) often
+// panic.
+func isNilPointer(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// Lazy constructs a field whose value is computed by calling fn, but only when
+// the field is encoded. If the entry is never written (for example, because
+// its level is disabled or it's dropped by sampling), fn may never be called
+// at all.
+//
+// The result is memoized: fn is called at most once, no matter how many
+// times the field is encoded. Since context passed to With is encoded when
+// the child logger is created, lazy fields passed to With are computed at
+// that point and reused for every entry. The returned field is added under
+// key, regardless of its own key. If fn panics, the panic is recovered and
+// reported under the key "<key>Error".
+func Lazy(key string, fn func() Field) Field {
+	return Field{key: key, fieldType: lazyType, obj: &lazyField{key: key, fn: fn}}
+}
+
+// Replace wraps a field so that it replaces, rather than duplicates, any
+// earlier field with the same key: for example, a request-scoped logger can
+// carry a placeholder status in its context and log the real status with
+// Replace(Int("status", 200)). Only earlier fields in the same scope are
+// replaced, so a Replace field added within a namespace only replaces fields
+// in that namespace. Later fields with the same key are still added as usual.
+//
+// Unlike the DeduplicateKeys option, Replace has no cost for entries that
+// don't use it. Encoders that don't support replacement (currently, all but
+// the JSON encoder) add the wrapped field as-is.
+func Replace(f Field) Field {
+	switch f.fieldType {
+	case skipType, replaceType:
+		return f
+	}
+	return Field{key: f.key, fieldType: replaceType, obj: &f}
+}
+
+// A replacer is a KeyValue that supports Replace fields. It adds the field to
+// kv, which is either the replacer itself or a KeyValue wrapping it, and
+// arranges for it to replace earlier fields with the same key.
+type replacer interface {
+	addReplacement(f Field, kv KeyValue)
+}
+
+type lazyField struct {
+	once  sync.Once
+	key   string
+	fn    func() Field
+	field Field
+}
+
+func (lf *lazyField) evaluate() Field {
+	lf.once.Do(func() {
+		defer func() {
+			if v := recover(); v != nil {
+				lf.field = String(lf.key+"Error", fmt.Sprintf("PANIC=%v", v))
+			}
+			lf.fn = nil
+		}()
+		f := lf.fn()
+		f.key = lf.key
+		lf.field = f
+	})
+	return lf.field
+}
+
+// canonicalString returns the output of the value's Error or String method,
+// for values whose types define a canonical representation but can't marshal
+// themselves to JSON or text. Nil pointers aren't considered to have a
+// canonical string. If the method panics, the panic is recovered, and
+// canonicalString returns a placeholder along with an error.
+func canonicalString(obj interface{}) (s string, ok bool, err error) {
+	switch v := obj.(type) {
+	case json.Marshaler, encoding.TextMarshaler:
+		return "", false, nil
+	case error:
+		if !isNilPointer(v) {
+			s, err = safeString(v, v.Error)
+			return s, true, err
+		}
+	case fmt.Stringer:
+		if !isNilPointer(v) {
+			s, err = safeString(v, v.String)
+			return s, true, err
+		}
+	}
+	return "", false, nil
+}
+
 // Nest takes a key and a variadic number of Fields and creates a nested
 // namespace.
 func Nest(key string, fields ...Field) Field {
 	return Field{key: key, fieldType: marshalerType, obj: multiFields(fields)}
 }
 
+// Namespace creates a named, isolated scope within the logger's context. All
+// subsequent fields will be added to the new namespace, including fields added
+// later via Logger.With. Namespaces nest, and they're closed when the log
+// entry is written (or when the enclosing Marshaler finishes). Since an empty
+// key can't be meaningfully nested under, Namespace("") is a no-op.
+//
+// This helps prevent key collisions when injecting loggers into sub-components
+// or third-party libraries.
+func Namespace(key string) Field {
+	if key == "" {
+		return Skip()
+	}
+	return Field{key: key, fieldType: namespaceType}
+}
+
 // AddTo exports a field through the KeyValue interface. It's primarily useful
 // to library authors, and shouldn't be necessary in most applications.
 func (f Field) AddTo(kv KeyValue) {
@@ -198,6 +489,8 @@ func (f Field) AddTo(kv KeyValue) {
 		kv.AddBool(f.key, f.ival == 1)
 	case floatType:
 		kv.AddFloat64(f.key, math.Float64frombits(uint64(f.ival)))
+	case float32Type:
+		kv.AddFloat32(f.key, math.Float32frombits(uint32(f.ival)))
 	case intType:
 		kv.AddInt(f.key, int(f.ival))
 	case int64Type:
@@ -210,14 +503,34 @@ func (f Field) AddTo(kv KeyValue) {
 		kv.AddUintptr(f.key, uintptr(f.ival))
 	case stringType:
 		kv.AddString(f.key, f.str)
+	case binaryType:
+		kv.AddBinary(f.key, f.obj.([]byte))
+	case byteStringType:
+		kv.AddByteString(f.key, f.obj.([]byte))
+	case rawJSONType:
+		err = kv.AddRawJSON(f.key, f.obj.([]byte))
 	case stringerType:
-		kv.AddString(f.key, f.obj.(fmt.Stringer).String())
+		err = addStringer(kv, f.key, f.obj)
 	case marshalerType:
 		err = kv.AddMarshaler(f.key, f.obj.(LogMarshaler))
+	case arrayType:
+		err = kv.AddArray(f.key, f.obj.(ArrayMarshaler))
 	case objectType:
 		err = kv.AddObject(f.key, f.obj)
 	case errorType:
-		kv.AddString(f.key, f.obj.(error).Error())
+		err = addError(kv, f.key, f.obj.(error))
+	case verboseErrorType:
+		err = addVerboseError(kv, f.key, f.obj.(verboseError))
+	case namespaceType:
+		kv.OpenNamespace(f.key)
+	case lazyType:
+		f.obj.(*lazyField).evaluate().AddTo(kv)
+	case replaceType:
+		if r, ok := kv.(replacer); ok {
+			r.addReplacement(*f.obj.(*Field), kv)
+		} else {
+			f.obj.(*Field).AddTo(kv)
+		}
 	case skipType:
 		break
 	default:
@@ -229,6 +542,36 @@ func (f Field) AddTo(kv KeyValue) {
 	}
 }
 
+// addStringer adds the output of the stringer's String method to the KeyValue,
+// recovering from any panics.
+func addStringer(kv KeyValue, key string, stringer interface{}) (err error) {
+	if stringer == nil {
+		kv.AddString(key, "<nil>")
+		return nil
+	}
+	// Call String before adding anything to the KeyValue, so that a panic
+	// doesn't leave a dangling key.
+	s, err := safeString(stringer, stringer.(fmt.Stringer).String)
+	kv.AddString(key, s)
+	return err
+}
+
+// safeString calls fn, one of obj's Error or String methods, recovering from
+// any panics. If fn panics, safeString returns a placeholder and an error
+// describing the panic.
+func safeString(obj interface{}, fn func() string) (s string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			s = "<PANIC>"
+			if isNilPointer(obj) {
+				s = "<nil>"
+			}
+			err = fmt.Errorf("PANIC=%v", v)
+		}
+	}()
+	return fn(), nil
+}
+
 type multiFields []Field
 
 func (fs multiFields) MarshalLog(kv KeyValue) error {
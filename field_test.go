@@ -23,6 +23,7 @@ package zap
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net"
 	"strings"
 	"sync"
@@ -92,6 +93,69 @@ func TestSkipField(t *testing.T) {
 	assertCanBeReused(t, Skip())
 }
 
+func TestLazyField(t *testing.T) {
+	calls := 0
+	lazy := Lazy("foo", func() Field {
+		calls++
+		return String("ignored", "bar")
+	})
+
+	withJSONLogger(t, []Option{InfoLevel}, func(logger Logger, buf *testBuffer) {
+		logger.Debug("", lazy)
+		assert.Equal(t, 0, calls, "Expected lazy fields not to be evaluated at disabled levels.")
+
+		logger.Info("", lazy)
+		logger.Info("", lazy)
+		assert.Equal(t, 1, calls, "Expected lazy fields to be memoized.")
+
+		child := Lazy("child", func() Field {
+			calls++
+			return Int("", 42)
+		})
+		withChild := logger.With(child)
+		withChild.Info("")
+		withChild.Info("")
+		assert.Equal(t, 2, calls, "Expected lazy fields passed to With to be evaluated once.")
+
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"","foo":"bar"}`,
+			`{"level":"info","msg":"","foo":"bar"}`,
+			`{"level":"info","msg":"","child":42}`,
+			`{"level":"info","msg":"","child":42}`,
+		}, buf.Lines(), "Unexpected output with lazy fields.")
+	})
+}
+
+func TestLazyFieldPanics(t *testing.T) {
+	calls := 0
+	lazy := Lazy("foo", func() Field {
+		calls++
+		panic("oh no")
+	})
+	assert.NotPanics(t, func() {
+		assertFieldJSON(t, `"fooError":"PANIC=oh no"`, lazy)
+		assertCanBeReused(t, lazy)
+	}, "Expected panics in lazy fields to be recovered.")
+	assert.Equal(t, 1, calls, "Expected panicking lazy fields to be memoized too.")
+}
+
+func TestConditionalFields(t *testing.T) {
+	tests := []struct {
+		field    Field
+		expected string
+	}{
+		{If(true, String("foo", "bar")), `"foo":"bar"`},
+		{If(false, String("foo", "bar")), ``},
+		{ErrIf(errors.New("fail")), `"error":"fail"`},
+		{ErrIf(nil), ``},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, tt.field)
+		assertCanBeReused(t, tt.field)
+	}
+}
+
 func TestTrueBoolField(t *testing.T) {
 	assertFieldJSON(t, `"foo":true`, Bool("foo", true))
 	assertCanBeReused(t, Bool("foo", true))
@@ -112,6 +176,11 @@ func TestFloat64Field(t *testing.T) {
 	assertCanBeReused(t, Float64("foo", 1.314))
 }
 
+func TestFloat32Field(t *testing.T) {
+	assertFieldJSON(t, `"foo":1.314`, Float32("foo", 1.314))
+	assertCanBeReused(t, Float32("foo", 1.314))
+}
+
 func TestIntField(t *testing.T) {
 	assertFieldJSON(t, `"foo":1`, Int("foo", 1))
 	assertCanBeReused(t, Int("foo", 1))
@@ -142,12 +211,84 @@ func TestStringField(t *testing.T) {
 	assertCanBeReused(t, String("foo", "bar"))
 }
 
+func TestRawJSONField(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+	}{
+		{`{"foo":[1,"two",{"three":null}]}`, `"k":{"foo":[1,"two",{"three":null}]}`},
+		{`[1,2,3]`, `"k":[1,2,3]`},
+		{` {} `, `"k": {} `},
+		{`"bar"`, `"k":"bar"`},
+		{`42`, `"k":42`},
+		{`-1.5e10`, `"k":-1.5e10`},
+		{`true`, `"k":true`},
+		{`null`, `"k":null`},
+		{``, `"k":"","kError":"invalid raw JSON, encoded as a string instead"`},
+		{`{"foo":`, `"k":"{\"foo\":","kError":"invalid raw JSON, encoded as a string instead"`},
+		{`"`, `"k":"\"","kError":"invalid raw JSON, encoded as a string instead"`},
+		{`nope`, `"k":"nope","kError":"invalid raw JSON, encoded as a string instead"`},
+		{"{\n  \"foo\": [1,\r\n2]\n}", `"k":{   "foo": [1,  2] }`},
+		{"\"foo\nbar\"", `"k":"\"foo\nbar\"","kError":"invalid raw JSON, encoded as a string instead"`},
+		{"{\"foo\":\"\\\"\n\"}", `"k":"{\"foo\":\"\\\"\n\"}","kError":"invalid raw JSON, encoded as a string instead"`},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, RawJSON("k", []byte(tt.raw)))
+		assertCanBeReused(t, RawJSON("k", []byte(tt.raw)))
+	}
+}
+
 func TestStringerField(t *testing.T) {
 	ip := net.ParseIP("1.2.3.4")
 	assertFieldJSON(t, `"foo":"1.2.3.4"`, Stringer("foo", ip))
 	assertCanBeReused(t, Stringer("foo", ip))
 }
 
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic("oh no") }
+
+func TestStringerFieldNilsAndPanics(t *testing.T) {
+	var typedNil *nilStringer
+	tests := []struct {
+		desc     string
+		field    Field
+		expected string
+	}{
+		{"nil interface", Stringer("foo", nil), `"foo":"<nil>"`},
+		{"typed nil", Stringer("foo", typedNil), `"foo":"<nil>","fooError":"PANIC=runtime error: invalid memory address or nil pointer dereference"`},
+		{"panicking String method", Stringer("foo", panicStringer{}), `"foo":"<PANIC>","fooError":"PANIC=oh no"`},
+	}
+
+	for _, tt := range tests {
+		assert.NotPanics(t, func() {
+			assertFieldJSON(t, tt.expected, tt.field)
+		}, "Unexpected panic encoding a Stringer: %s.", tt.desc)
+		assertCanBeReused(t, tt.field)
+	}
+}
+
+type countingStringer struct{ calls int }
+
+func (c *countingStringer) String() string {
+	c.calls++
+	return "counted"
+}
+
+func TestStringerFieldIsLazy(t *testing.T) {
+	withJSONLogger(t, []Option{InfoLevel}, func(logger Logger, buf *testBuffer) {
+		c := &countingStringer{}
+		logger.Debug("disabled", Stringer("foo", c))
+		assert.Equal(t, 0, c.calls, "Expected String not to be called for disabled levels.")
+		assert.Equal(t, "", buf.String(), "Unexpected output from a disabled level.")
+
+		logger.Info("enabled", Stringer("foo", c))
+		assert.Equal(t, 1, c.calls, "Expected String to be called once for enabled levels.")
+		assert.Equal(t, `{"level":"info","msg":"enabled","foo":"counted"}`, buf.Stripped(), "Unexpected output.")
+	})
+}
+
 func TestTimeField(t *testing.T) {
 	assertFieldJSON(t, `"foo":0`, Time("foo", time.Unix(0, 0)))
 	assertFieldJSON(t, `"foo":1.5`, Time("foo", time.Unix(1, int64(500*time.Millisecond))))
@@ -165,6 +306,62 @@ func TestDurationField(t *testing.T) {
 	assertCanBeReused(t, Duration("foo", time.Nanosecond))
 }
 
+func TestPrimitiveFieldEdgeCases(t *testing.T) {
+	tests := []struct {
+		field Field
+		json  string
+		text  string
+	}{
+		{Bool("k", true), `"k":true`, "k=true"},
+		{Int("k", -1), `"k":-1`, "k=-1"},
+		{Int64("k", math.MinInt64), `"k":-9223372036854775808`, "k=-9223372036854775808"},
+		{Int64("k", math.MaxInt64), `"k":9223372036854775807`, "k=9223372036854775807"},
+		{Uint("k", 0), `"k":0`, "k=0"},
+		{Uint64("k", math.MaxUint64), `"k":18446744073709551615`, "k=18446744073709551615"},
+		{Uintptr("k", 0xdeadbeef), `"k":3735928559`, "k=0xdeadbeef"},
+		{Float64("k", -0.5), `"k":-0.5`, "k=-0.5"},
+		{Float64("k", 1e21), `"k":1000000000000000000000`, "k=1000000000000000000000"},
+		{Float64("k", 1e-7), `"k":0.0000001`, "k=0.0000001"},
+		{Float64("k", math.NaN()), `"k":"NaN"`, "k=NaN"},
+		{Float64("k", math.Inf(-1)), `"k":"-Inf"`, "k=-Inf"},
+		{Float32("k", 0.1), `"k":0.1`, "k=0.1"},
+		{Float32("k", float32(math.Inf(1))), `"k":"+Inf"`, "k=+Inf"},
+		{Duration("k", -time.Second), `"k":-1000000000`, "k=-1000000000"},
+		{Time("k", time.Unix(-1, 0)), `"k":-1`, "k=-1"},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.json, tt.field)
+		withTextEncoder(func(enc *textEncoder) {
+			tt.field.AddTo(enc)
+			assert.Equal(t, tt.text, string(enc.bytes), "Unexpected text output for field %+v.", tt.field)
+		})
+	}
+}
+
+var _fieldSink Field
+
+func TestPrimitiveFieldsDontAllocate(t *testing.T) {
+	now := time.Now()
+	constructors := map[string]func(){
+		"Bool":     func() { _fieldSink = Bool("k", true) },
+		"Float64":  func() { _fieldSink = Float64("k", 3.14) },
+		"Float32":  func() { _fieldSink = Float32("k", 3.14) },
+		"Int":      func() { _fieldSink = Int("k", 42) },
+		"Int64":    func() { _fieldSink = Int64("k", 42) },
+		"Uint":     func() { _fieldSink = Uint("k", 42) },
+		"Uint64":   func() { _fieldSink = Uint64("k", 42) },
+		"Uintptr":  func() { _fieldSink = Uintptr("k", 42) },
+		"String":   func() { _fieldSink = String("k", "v") },
+		"Duration": func() { _fieldSink = Duration("k", time.Second) },
+		"Time":     func() { _fieldSink = Time("k", now) },
+		"Skip":     func() { _fieldSink = Skip() },
+	}
+	for name, f := range constructors {
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, f), "Expected %s to be allocation-free.", name)
+	}
+}
+
 func TestMarshalerField(t *testing.T) {
 	// Marshaling the user failed, so we expect an empty object and an error
 	// message.
@@ -193,6 +390,200 @@ func TestObjectField(t *testing.T) {
 	assertCanBeReused(t, Object("foo", []int{5, 6}))
 }
 
+type jsonMarshaler struct{ json string }
+
+func (m jsonMarshaler) MarshalJSON() ([]byte, error) {
+	if m.json == "" {
+		return nil, errors.New("no JSON")
+	}
+	return []byte(m.json), nil
+}
+
+type textMarshaler struct{ text string }
+
+func (m textMarshaler) MarshalText() ([]byte, error) {
+	if m.text == "" {
+		return nil, errors.New("no text")
+	}
+	return []byte(m.text), nil
+}
+
+type stringer struct{ S string }
+
+func (s stringer) String() string { return "stringer" }
+
+type objectError struct{ E string }
+
+func (e objectError) Error() string { return "error" }
+
+type jsonAndError struct {
+	jsonMarshaler
+	objectError
+}
+
+type textAndStringer struct {
+	textMarshaler
+	stringer
+}
+
+type errorAndStringer struct {
+	objectError
+	stringer
+}
+
+type marshalerAndJSON struct {
+	loggable
+	jsonMarshaler
+}
+
+func TestObjectFieldPrecedence(t *testing.T) {
+	tests := []struct {
+		desc string
+		obj  interface{}
+		json string
+		text string
+	}{
+		{"json.Marshaler", jsonMarshaler{`{"json":true}`}, `"k":{"json":true}`, `k={json:{"json":true}}`},
+		{"TextMarshaler", textMarshaler{"text"}, `"k":"text"`, "k=text"},
+		{"error", objectError{"ignored"}, `"k":"error"`, "k=error"},
+		{"Stringer", stringer{"ignored"}, `"k":"stringer"`, "k=stringer"},
+		{"reflection", struct{ S string }{"foo"}, `"k":{"S":"foo"}`, "k={S:foo}"},
+		{"json.Marshaler and error", jsonAndError{jsonMarshaler{`[1]`}, objectError{}}, `"k":[1]`, "k=error"},
+		{"TextMarshaler and Stringer", textAndStringer{textMarshaler{"text"}, stringer{}}, `"k":"text"`, "k=text"},
+		{"error and Stringer", errorAndStringer{objectError{}, stringer{}}, `"k":"error"`, "k=error"},
+		{"LogMarshaler and json.Marshaler", marshalerAndJSON{loggable{true}, jsonMarshaler{`[1]`}}, `"k":{"loggable":"yes"}`, "k={loggable=yes}"},
+		{"failing TextMarshaler", textMarshaler{}, "", "kError=no text"},
+	}
+
+	for _, tt := range tests {
+		if tt.json != "" {
+			assertFieldJSON(t, tt.json, Object("k", tt.obj))
+		}
+		withTextEncoder(func(enc *textEncoder) {
+			Object("k", tt.obj).AddTo(enc)
+			assert.Equal(t, tt.text, string(enc.bytes), "Unexpected text output for %s.", tt.desc)
+		})
+	}
+}
+
+func TestObjectFieldMarshalingFailures(t *testing.T) {
+	// The exact error messages vary between Go versions.
+	tests := []struct {
+		obj      interface{}
+		contains string
+	}{
+		{jsonMarshaler{`{"json":`}, "error calling MarshalJSON"},
+		{jsonMarshaler{}, "no JSON"},
+		{textMarshaler{}, "no text"},
+	}
+
+	for _, tt := range tests {
+		withJSONEncoder(func(enc *jsonEncoder) {
+			Object("k", tt.obj).AddTo(enc)
+			assert.True(t, strings.HasPrefix(string(enc.bytes), `"kError":"json: `), "Expected only an error, got %s.", enc.bytes)
+			assert.Contains(t, string(enc.bytes), tt.contains, "Unexpected error message.")
+		})
+	}
+}
+
+type panicError struct{}
+
+func (panicError) Error() string { panic("oh no") }
+
+func TestObjectFieldPanickingMethods(t *testing.T) {
+	tests := []struct {
+		desc     string
+		field    Field
+		expected string
+	}{
+		{"String", Object("k", panicStringer{}), `"k":"<PANIC>","kError":"PANIC=oh no"`},
+		{"Error", Object("k", panicError{}), `"k":"<PANIC>","kError":"PANIC=oh no"`},
+		{"array element", Array("k", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+			return arr.AppendObject(panicStringer{})
+		})), `"k":["<PANIC>"],"kError":"PANIC=oh no"`},
+	}
+
+	for _, tt := range tests {
+		assert.NotPanics(t, func() {
+			assertFieldJSON(t, tt.expected, tt.field)
+		}, "Unexpected panic encoding an object with a panicking %s method.", tt.desc)
+	}
+}
+
+func TestNamespaceField(t *testing.T) {
+	assertFieldJSON(t, `"outer":{"foo":{"bar":1}}`, Nest("outer", Namespace("foo"), Int("bar", 1)))
+	assertFieldJSON(t, ``, Namespace(""))
+	assertCanBeReused(t, Namespace("foo"))
+}
+
+type nilStringer struct{ s string }
+
+func (n *nilStringer) String() string { return n.s }
+
+type nilError struct{ s string }
+
+func (n *nilError) Error() string { return n.s }
+
+func TestAnyField(t *testing.T) {
+	var (
+		nilPtr       *int
+		nilErr       *nilError
+		nilStr       *nilStringer
+		nilMarshaler *fakeUser
+	)
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, `"k":null`},
+		{true, `"k":true`},
+		{1.5, `"k":1.5`},
+		{float32(1.5), `"k":1.5`},
+		{42, `"k":42`},
+		{int8(-8), `"k":-8`},
+		{int16(-16), `"k":-16`},
+		{int32(-32), `"k":-32`},
+		{int64(-64), `"k":-64`},
+		{uint(42), `"k":42`},
+		{uint8(8), `"k":8`},
+		{uint16(16), `"k":16`},
+		{uint32(32), `"k":32`},
+		{uint64(64), `"k":64`},
+		{uintptr(0xa), `"k":10`},
+		{"foo", `"k":"foo"`},
+		{[]byte("ab12"), `"k":"YWIxMg=="`},
+		{time.Unix(1, int64(500*time.Millisecond)), `"k":1.5`},
+		{time.Nanosecond, `"k":1`},
+		{errors.New("fail"), `"k":"fail"`},
+		{net.ParseIP("1.2.3.4"), `"k":"1.2.3.4"`},
+		{fakeUser{"phil"}, `"k":{"name":"phil"}`},
+		{fakeUser{"fail"}, `"k":{},"kError":"fail"`},
+		{[]int{1, 2}, `"k":[1,2]`},
+		{map[string]int{"foo": 1}, `"k":{"foo":1}`},
+		{struct{ Name string }{"jane"}, `"k":{"Name":"jane"}`},
+		{nilPtr, `"k":null`},
+		{nilErr, `"k":null`},
+		{nilStr, `"k":null`},
+		{nilMarshaler, `"k":null`},
+	}
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, Any("k", tt.value))
+		assertCanBeReused(t, Any("k", tt.value))
+	}
+
+	// Serialization failures shouldn't drop the entry.
+	enc := newJSONEncoder()
+	defer enc.Free()
+	Any("k", noJSON{}).AddTo(enc)
+	assert.Contains(t, string(enc.bytes), `"kError":"json: error calling MarshalJSON`, "Expected serialization errors to be included in the output.")
+}
+
+func TestReflectField(t *testing.T) {
+	assertFieldJSON(t, `"foo":[5,6]`, Reflect("foo", []int{5, 6}))
+	assertFieldJSON(t, `"foo":null`, Reflect("foo", nil))
+	assertCanBeReused(t, Reflect("foo", []int{5, 6}))
+}
+
 func TestNestField(t *testing.T) {
 	assertFieldJSON(t, `"foo":{"name":"phil","age":42}`,
 		Nest("foo", String("name", "phil"), Int("age", 42)),
@@ -214,6 +605,19 @@ func TestBase64Field(t *testing.T) {
 	assertCanBeReused(t, Base64("foo", []byte("bar")))
 }
 
+func TestBinaryField(t *testing.T) {
+	assertFieldJSON(t, `"foo":"YWIxMg=="`, Binary("foo", []byte("ab12")))
+	assertFieldJSON(t, `"foo":""`, Binary("foo", nil))
+	assertCanBeReused(t, Binary("foo", []byte("bar")))
+}
+
+func TestByteStringField(t *testing.T) {
+	assertFieldJSON(t, `"foo":"bar"`, ByteString("foo", []byte("bar")))
+	assertFieldJSON(t, `"foo":"\ufffd"`, ByteString("foo", []byte("\xff")))
+	assertFieldJSON(t, `"foo":""`, ByteString("foo", nil))
+	assertCanBeReused(t, ByteString("foo", []byte("bar")))
+}
+
 func TestLogMarshalerFunc(t *testing.T) {
 	assertFieldJSON(t, `"foo":{"name":"phil"}`,
 		Marshaler("foo", LogMarshalerFunc(fakeUser{"phil"}.MarshalLog)))
@@ -0,0 +1,224 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "regexp"
+
+// A FilterFunc decides whether a log entry should be kept. When it's consulted
+// on the Check path, the entry's fields aren't known yet, so fields is nil.
+type FilterFunc func(lvl Level, msg string, fields []Field) bool
+
+// Filter wraps a Logger, dropping entries for which keep returns false. The
+// filter is also consulted by Check, so callers using CheckedMessages can skip
+// constructing fields for filtered messages; checked messages are filtered
+// again, with their fields, when they're written.
+//
+// Panic and Fatal entries are never filtered, and neither are calls to DPanic
+// (which may panic in development). Log-ing at DPanicLevel is filtered.
+//
+// Audit loggers (see Audit) are never filtered, so Filter returns them
+// unchanged.
+func Filter(log Logger, keep FilterFunc) Logger {
+	if IsAudit(log) {
+		return log
+	}
+	return &filterLogger{log: log, keep: keep}
+}
+
+// DropMessages returns a FilterFunc that drops entries whose message exactly
+// matches one of the supplied messages.
+func DropMessages(msgs ...string) FilterFunc {
+	drop := make(map[string]struct{}, len(msgs))
+	for _, m := range msgs {
+		drop[m] = struct{}{}
+	}
+	return func(_ Level, msg string, _ []Field) bool {
+		_, ok := drop[msg]
+		return !ok
+	}
+}
+
+// DropMessagesMatching returns a FilterFunc that drops entries whose message
+// matches the supplied regular expression.
+func DropMessagesMatching(re *regexp.Regexp) FilterFunc {
+	return func(_ Level, msg string, _ []Field) bool {
+		return !re.MatchString(msg)
+	}
+}
+
+// DropFieldEquals returns a FilterFunc that drops entries with a String field
+// that has the supplied key and value (for example, a "path" field equal to
+// "/healthz"). Only fields added at the log site are considered, not fields
+// added to the logger's context with With.
+func DropFieldEquals(key, value string) FilterFunc {
+	return func(_ Level, _ string, fields []Field) bool {
+		for _, f := range fields {
+			if f.fieldType == stringType && f.key == key && f.str == value {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+type filterLogger struct {
+	log  Logger
+	keep FilterFunc
+}
+
+func (fl *filterLogger) With(fields ...Field) Logger {
+	return &filterLogger{log: fl.log.With(fields...), keep: fl.keep}
+}
+
+func (fl *filterLogger) Sync() error {
+	return Sync(fl.log)
+}
+
+func (fl *filterLogger) Context() []Field {
+	return Context(fl.log)
+}
+
+// Audit returns an audit logger for the wrapped logger, so audit entries
+// aren't filtered.
+func (fl *filterLogger) Audit() Logger {
+	return Audit(fl.log)
+}
+
+func (fl *filterLogger) Check(lvl Level, msg string) *CheckedMessage {
+	switch lvl {
+	case PanicLevel, FatalLevel:
+		return fl.log.Check(lvl, msg)
+	}
+	if !fl.keep(lvl, msg, nil) {
+		return nil
+	}
+	cm := fl.log.Check(lvl, msg)
+	if !cm.OK() {
+		return nil
+	}
+	return NewCheckedMessage(&filteredMessage{Logger: fl.log, cm: cm, keep: fl.keep}, lvl, msg)
+}
+
+func (fl *filterLogger) Log(lvl Level, msg string, fields ...Field) {
+	switch lvl {
+	case PanicLevel, FatalLevel:
+		fl.log.Log(lvl, msg, fields...)
+	default:
+		if fl.keep(lvl, msg, fields) {
+			fl.log.Log(lvl, msg, fields...)
+		}
+	}
+}
+
+func (fl *filterLogger) Trace(msg string, fields ...Field) {
+	if fl.keep(TraceLevel, msg, fields) {
+		fl.log.Trace(msg, fields...)
+	}
+}
+
+func (fl *filterLogger) Debug(msg string, fields ...Field) {
+	if fl.keep(DebugLevel, msg, fields) {
+		fl.log.Debug(msg, fields...)
+	}
+}
+
+func (fl *filterLogger) Info(msg string, fields ...Field) {
+	if fl.keep(InfoLevel, msg, fields) {
+		fl.log.Info(msg, fields...)
+	}
+}
+
+func (fl *filterLogger) Warn(msg string, fields ...Field) {
+	if fl.keep(WarnLevel, msg, fields) {
+		fl.log.Warn(msg, fields...)
+	}
+}
+
+func (fl *filterLogger) Error(msg string, fields ...Field) {
+	if fl.keep(ErrorLevel, msg, fields) {
+		fl.log.Error(msg, fields...)
+	}
+}
+
+func (fl *filterLogger) DPanic(msg string, fields ...Field) {
+	fl.log.DPanic(msg, fields...)
+}
+
+func (fl *filterLogger) Panic(msg string, fields ...Field) {
+	fl.log.Panic(msg, fields...)
+}
+
+func (fl *filterLogger) Fatal(msg string, fields ...Field) {
+	fl.log.Fatal(msg, fields...)
+}
+
+func (fl *filterLogger) encodeTerminal(lvl Level, msg string, fields []Field) func() {
+	return encodeTerminal(fl.log, lvl, msg, fields)
+}
+
+// A filteredMessage writes a CheckedMessage from the wrapped logger, filtering
+// it again once its fields are known. CheckedMessages only call the level
+// methods below Panic (and Log, for other levels), so the remaining methods
+// are delegated to the wrapped logger.
+type filteredMessage struct {
+	Logger
+
+	cm   *CheckedMessage
+	keep FilterFunc
+}
+
+func (fm *filteredMessage) write(lvl Level, msg string, fields []Field) {
+	if fm.keep(lvl, msg, fields) {
+		fm.cm.Write(fields...)
+	}
+}
+
+func (fm *filteredMessage) wrappedLogger() Logger {
+	return fm.Logger
+}
+
+func (fm *filteredMessage) Log(lvl Level, msg string, fields ...Field) {
+	fm.write(lvl, msg, fields)
+}
+
+func (fm *filteredMessage) Trace(msg string, fields ...Field) {
+	fm.write(TraceLevel, msg, fields)
+}
+
+func (fm *filteredMessage) Debug(msg string, fields ...Field) {
+	fm.write(DebugLevel, msg, fields)
+}
+
+func (fm *filteredMessage) Info(msg string, fields ...Field) {
+	fm.write(InfoLevel, msg, fields)
+}
+
+func (fm *filteredMessage) Warn(msg string, fields ...Field) {
+	fm.write(WarnLevel, msg, fields)
+}
+
+func (fm *filteredMessage) Error(msg string, fields ...Field) {
+	fm.write(ErrorLevel, msg, fields)
+}
+
+func (fm *filteredMessage) DPanic(msg string, fields ...Field) {
+	fm.write(DPanicLevel, msg, fields)
+}
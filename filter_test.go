@@ -0,0 +1,177 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/uber-go/zap"
+	"github.com/uber-go/zap/spy"
+	"github.com/uber-go/zap/zwrap"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	base, sink := spy.New(zap.DebugLevel)
+	log := zap.Filter(base, zap.DropMessages("health check", "noisy"))
+
+	log.Info("health check")
+	log.Debug("noisy")
+	log.Log(zap.WarnLevel, "noisy")
+	log.Info("kept")
+	log.With(zap.Int("n", 1)).Error("noisy")
+	log.Error("kept", zap.Int("n", 2))
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.InfoLevel, Msg: "kept", Fields: []zap.Field{}},
+		{Level: zap.ErrorLevel, Msg: "kept", Fields: []zap.Field{zap.Int("n", 2)}},
+	}, sink.Logs())
+}
+
+func TestFilterNeverDropsPanicsOrFatals(t *testing.T) {
+	base, sink := spy.New(zap.DebugLevel)
+	log := zap.Filter(base, func(zap.Level, string, []zap.Field) bool { return false })
+
+	log.Info("dropped")
+	log.Log(zap.DPanicLevel, "dropped")
+	// The spy logger doesn't actually panic or exit.
+	log.Panic("panic")
+	log.Check(zap.PanicLevel, "checked panic").Write()
+	log.Fatal("fatal")
+	log.Log(zap.FatalLevel, "log fatal")
+	log.DPanic("dpanic")
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.PanicLevel, Msg: "panic", Fields: []zap.Field{}},
+		{Level: zap.PanicLevel, Msg: "checked panic", Fields: []zap.Field{}},
+		{Level: zap.FatalLevel, Msg: "fatal", Fields: []zap.Field{}},
+		{Level: zap.FatalLevel, Msg: "log fatal", Fields: []zap.Field{}},
+		{Level: zap.DPanicLevel, Msg: "dpanic", Fields: []zap.Field{}},
+	}, sink.Logs())
+}
+
+func TestFilterCheck(t *testing.T) {
+	base, sink := spy.New(zap.InfoLevel)
+	log := zap.Filter(base, zap.DropMessagesMatching(regexp.MustCompile(`^GET /healthz`)))
+
+	assert.False(t, log.Check(zap.InfoLevel, "GET /healthz 200").OK(), "Expected filtered messages to be non-OK.")
+	assert.False(t, log.Check(zap.DebugLevel, "GET /users 200").OK(), "Expected disabled levels to be non-OK.")
+
+	cm := log.Check(zap.InfoLevel, "GET /users 200")
+	assert.True(t, cm.OK(), "Expected unfiltered messages to be OK.")
+	cm.Write(zap.Int("bytes", 42))
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.InfoLevel, Msg: "GET /users 200", Fields: []zap.Field{zap.Int("bytes", 42)}},
+	}, sink.Logs())
+}
+
+func TestFilterFieldEquals(t *testing.T) {
+	base, sink := spy.New(zap.DebugLevel)
+	log := zap.Filter(base, zap.DropFieldEquals("path", "/healthz"))
+
+	log.Info("request", zap.String("path", "/healthz"))
+	log.Info("request", zap.String("path", "/users"))
+	log.Info("request", zap.Int("path", 1))
+	// The field isn't known at Check time, so it's filtered on Write.
+	cm := log.Check(zap.InfoLevel, "request")
+	assert.True(t, cm.OK(), "Expected field-based filters to pass Check.")
+	cm.Write(zap.String("path", "/healthz"))
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.InfoLevel, Msg: "request", Fields: []zap.Field{zap.String("path", "/users")}},
+		{Level: zap.InfoLevel, Msg: "request", Fields: []zap.Field{zap.Int("path", 1)}},
+	}, sink.Logs())
+}
+
+func TestFilterCheckDPanic(t *testing.T) {
+	base, sink := spy.New(zap.DebugLevel)
+	log := zap.Filter(base, zap.DropFieldEquals("path", "/healthz"))
+
+	cm := log.Check(zap.DPanicLevel, "dpanic")
+	assert.True(t, cm.OK(), "Expected field-based filters to pass Check.")
+	cm.Write(zap.String("path", "/healthz"))
+	log.Check(zap.DPanicLevel, "dpanic").Write(zap.String("path", "/users"))
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.DPanicLevel, Msg: "dpanic", Fields: []zap.Field{zap.String("path", "/users")}},
+	}, sink.Logs())
+}
+
+func TestFilterWithTee(t *testing.T) {
+	log1, sink1 := spy.New(zap.DebugLevel)
+	log2, sink2 := spy.New(zap.DebugLevel)
+	log := zap.Tee(zap.Filter(log1, zap.DropMessages("noisy")), log2)
+
+	log.Info("noisy")
+	log.Check(zap.InfoLevel, "noisy").Write()
+	log.Info("kept")
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.InfoLevel, Msg: "kept", Fields: []zap.Field{}},
+	}, sink1.Logs(), "Expected the filtered sub-logger to drop noisy messages.")
+	assert.Equal(t, []spy.Log{
+		{Level: zap.InfoLevel, Msg: "noisy", Fields: []zap.Field{}},
+		{Level: zap.InfoLevel, Msg: "noisy", Fields: []zap.Field{}},
+		{Level: zap.InfoLevel, Msg: "kept", Fields: []zap.Field{}},
+	}, sink2.Logs(), "Expected the unfiltered sub-logger to keep all messages.")
+}
+
+func TestFilterWithSampler(t *testing.T) {
+	base, sink := spy.New(zap.DebugLevel)
+	// Filtered messages shouldn't count against the sampler's limits.
+	log := zap.Filter(zwrap.Sample(base, time.Minute, 2, 1000), zap.DropFieldEquals("path", "/healthz"))
+
+	for i := 0; i < 5; i++ {
+		log.Info("request", zap.String("path", "/healthz"))
+	}
+	log.Info("request", zap.String("path", "/users"))
+	log.Check(zap.InfoLevel, "request").Write(zap.String("path", "/posts"))
+	log.Info("request", zap.String("path", "/dropped"))
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.InfoLevel, Msg: "request", Fields: []zap.Field{zap.String("path", "/users")}},
+		{Level: zap.InfoLevel, Msg: "request", Fields: []zap.Field{zap.String("path", "/posts")}},
+	}, sink.Logs())
+}
+
+func TestFilterAudit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	base := zap.New(zap.NewJSONEncoder(zap.NoTime()), zap.WarnLevel, zap.Output(zap.AddSync(buf)))
+	dropAll := func(zap.Level, string, []zap.Field) bool { return false }
+
+	zap.Filter(base, dropAll).Error("dropped")
+	zap.Filter(zap.Audit(base), dropAll).Info("filtered audit logger")
+	zap.Audit(zap.Filter(base, dropAll)).Info("audited filter")
+	zap.Audit(zap.Filter(base, dropAll)).Check(zap.InfoLevel, "checked").Write()
+	assert.Equal(
+		t,
+		"{\"level\":\"info\",\"msg\":\"filtered audit logger\",\"audit\":true}\n"+
+			"{\"level\":\"info\",\"msg\":\"audited filter\",\"audit\":true}\n"+
+			"{\"level\":\"info\",\"msg\":\"checked\",\"audit\":true}\n",
+		buf.String(),
+		"Expected audit entries to bypass the filter.",
+	)
+}
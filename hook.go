@@ -25,11 +25,14 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+
+	"github.com/uber-go/atomic"
 )
 
 var (
 	errHookNilEntry = errors.New("can't call a hook on a nil *Entry")
 	errCaller       = errors.New("failed to get caller")
+	errGoroutineID  = errors.New("failed to get goroutine ID")
 	// Skip Caller, Logger.log, and the leveled Logger method when using
 	// runtime.Caller.
 	_callerSkip = 4
@@ -49,16 +52,18 @@ func (h Hook) apply(m *Meta) {
 }
 
 // AddCaller configures the Logger to annotate each message with the filename
-// and line number of zap's caller.
+// and line number of zap's caller. It also records the call site in the
+// entry's Caller, so that hooks added later can inspect it.
 func AddCaller() Option {
 	return Hook(func(e *Entry) error {
 		if e == nil {
 			return errHookNilEntry
 		}
-		_, filename, line, ok := runtime.Caller(_callerSkip)
+		pc, filename, line, ok := runtime.Caller(_callerSkip)
 		if !ok {
 			return errCaller
 		}
+		e.Caller = EntryCaller{Defined: true, PC: pc, File: filename, Line: line}
 
 		// Re-use a buffer from the pool.
 		enc := jsonPool.Get().(*jsonEncoder)
@@ -78,16 +83,114 @@ func AddCaller() Option {
 }
 
 // AddStacks configures the Logger to record a stack trace for all messages at
-// or above a given level. Keep in mind that this is (relatively speaking) quite
-// expensive.
+// or above a given level, both as a field and in the entry's Stack. Keep in mind
+// that this is (relatively speaking) quite expensive.
 func AddStacks(lvl Level) Option {
 	return Hook(func(e *Entry) error {
 		if e == nil {
 			return errHookNilEntry
 		}
 		if e.Level >= lvl {
-			Stack().AddTo(e.Fields())
+			stack := Stack()
+			e.Stack = stack.str
+			stack.AddTo(e.Fields())
+		}
+		return nil
+	})
+}
+
+// RecordCaller configures the Logger to record the call site of each entry in
+// the entry's Caller, without changing its message. It's meant for encoders
+// that write the caller themselves (see CallerFormatter); to prefix messages
+// with the caller instead, use AddCaller.
+func RecordCaller() Option {
+	return Hook(func(e *Entry) error {
+		if e == nil {
+			return errHookNilEntry
+		}
+		pc, filename, line, ok := runtime.Caller(_callerSkip)
+		if !ok {
+			return errCaller
+		}
+		e.Caller = EntryCaller{Defined: true, PC: pc, File: filename, Line: line}
+		return nil
+	})
+}
+
+// RecordStacks configures the Logger to record a stack trace in the Stack of
+// entries at or above a given level, without adding a field. It's meant for
+// encoders that write the stack trace themselves (see StackFormatter); to add
+// it as a field instead, use AddStacks.
+func RecordStacks(lvl Level) Option {
+	return Hook(func(e *Entry) error {
+		if e == nil {
+			return errHookNilEntry
+		}
+		if e.Level >= lvl {
+			e.Stack = Stack().str
 		}
 		return nil
 	})
 }
+
+// AddSequence configures the Logger to number each entry it writes, storing
+// the sequence number under the supplied key. Numbers start at 1 and increase
+// by one for each entry that's actually written, so entries dropped by the
+// level check (or by a wrapping sampler) don't leave gaps. The counter is
+// shared by the Logger and all its children, including children combined with
+// Tee, so numbers are unique across all of them. Each call to AddSequence
+// creates a new counter.
+//
+// Sequence numbers are useful for ordering entries whose timestamps are
+// identical or too coarse, for example after merging the output of several
+// processes.
+func AddSequence(key string) Option {
+	seq := atomic.NewUint64(0)
+	return Hook(func(e *Entry) error {
+		if e == nil {
+			return errHookNilEntry
+		}
+		e.Fields().AddUint64(key, seq.Inc())
+		return nil
+	})
+}
+
+// AddGoroutineID configures the Logger to annotate each message with the ID
+// of the logging goroutine, under the "goroutine" key. Finding the ID requires
+// a call to runtime.Stack for every entry, so this is quite expensive. It's
+// intended for debugging deadlocks and interleaved output, not for production
+// use.
+func AddGoroutineID() Option {
+	return Hook(func(e *Entry) error {
+		if e == nil {
+			return errHookNilEntry
+		}
+		id, ok := goroutineID()
+		if !ok {
+			return errGoroutineID
+		}
+		e.Fields().AddUint64("goroutine", id)
+		return nil
+	})
+}
+
+// goroutineID parses the current goroutine's ID from the first line of its
+// stacktrace, which looks like "goroutine 42 [running]:".
+func goroutineID() (uint64, bool) {
+	const prefix = "goroutine "
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	if len(b) <= len(prefix) || string(b[:len(prefix)]) != prefix {
+		return 0, false
+	}
+	var id uint64
+	digits := 0
+	for _, c := range b[len(prefix):] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + uint64(c-'0')
+		digits++
+	}
+	return id, digits > 0
+}
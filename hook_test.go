@@ -21,7 +21,11 @@
 package zap
 
 import (
+	"encoding/json"
+	"io"
+	"path/filepath"
 	"regexp"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,6 +73,165 @@ func TestHookAddStacks(t *testing.T) {
 	assert.NotContains(t, buf.String(), "Unexpected stacktrace at Debug level.")
 }
 
+// entryEncoder records the entries passed to WriteEntry.
+type entryEncoder struct {
+	nullEncoder
+	entries *[]Entry
+}
+
+func (enc entryEncoder) Clone() Encoder { return enc }
+
+func (enc entryEncoder) WriteEntry(_ io.Writer, ent Entry) error {
+	ent.enc = nil
+	*enc.entries = append(*enc.entries, ent)
+	return nil
+}
+
+func TestHookEntry(t *testing.T) {
+	var written []Entry
+	var hooked Entry
+	logger := New(
+		entryEncoder{entries: &written},
+		Output(Discard),
+		ErrorOutput(Discard),
+		AddCaller(),
+		AddStacks(WarnLevel),
+		Hook(func(e *Entry) error {
+			hooked = *e
+			hooked.enc = nil
+			e.Message = "hooked " + e.Message
+			return nil
+		}),
+	)
+
+	logger.Info("info")
+	require.Len(t, written, 1, "Expected the entry to be written once.")
+	assert.True(t, hooked.Caller.Defined, "Expected AddCaller to record the caller.")
+	assert.Equal(t, "hook_test.go", filepath.Base(hooked.Caller.File), "Unexpected caller file.")
+	assert.NotZero(t, hooked.Caller.Line, "Expected a caller line.")
+	assert.NotZero(t, hooked.Caller.PC, "Expected a caller program counter.")
+	assert.Equal(t, "", hooked.Stack, "Unexpected stacktrace below the AddStacks level.")
+	assert.Equal(t, InfoLevel, written[0].Level, "Unexpected level written.")
+	assert.Equal(t, "hooked "+hooked.Message, written[0].Message, "Expected the encoder to see changes made by hooks.")
+	assert.Equal(t, hooked.Caller, written[0].Caller, "Expected the encoder to receive the hooks' Entry.")
+
+	logger.Warn("warn")
+	require.Len(t, written, 3, "Expected Warn entries to be written to both outputs.")
+	assert.Contains(t, hooked.Stack, "zap.TestHookEntry", "Expected AddStacks to record the stacktrace.")
+	assert.Equal(t, hooked.Stack, written[1].Stack, "Expected the encoder to receive the stacktrace.")
+	assert.Equal(t, written[1], written[2], "Expected both outputs to receive the same Entry.")
+}
+
+func TestEntryPooling(t *testing.T) {
+	logger := New(NullEncoder(), Output(Discard), AddCaller(), AddStacks(InfoLevel))
+	logger.Info("stack")
+
+	// Pooled entries shouldn't carry caller or stack information over to the
+	// next write.
+	var hooked Entry
+	plain := New(NullEncoder(), Output(Discard), Hook(func(e *Entry) error {
+		hooked = *e
+		return nil
+	}))
+	for i := 0; i < 10; i++ {
+		plain.Info("plain")
+		assert.False(t, hooked.Caller.Defined, "Unexpected caller on a pooled entry.")
+		assert.Equal(t, "", hooked.Stack, "Unexpected stacktrace on a pooled entry.")
+		logger.Info("stack")
+	}
+}
+
+func TestHookAddGoroutineID(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(NewJSONEncoder(NoTime()), DebugLevel, Output(buf), AddGoroutineID())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("Goroutines.")
+		}()
+	}
+	wg.Wait()
+
+	ids := make(map[uint64]struct{})
+	for _, line := range buf.Lines() {
+		var entry struct {
+			Goroutine *uint64 `json:"goroutine"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), "Unexpected error unmarshaling entry.")
+		require.NotNil(t, entry.Goroutine, "Expected a goroutine ID in each entry.")
+		ids[*entry.Goroutine] = struct{}{}
+	}
+	assert.Equal(t, 2, len(ids), "Expected different goroutine IDs from different goroutines.")
+}
+
+func TestHookAddSequence(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(NewJSONEncoder(NoTime()), InfoLevel, Output(buf), AddSequence("seq"))
+
+	const goroutines, perGoroutine = 10, 100
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Children and teed children share the root's counter.
+			child := logger.With(Int("goroutine", i))
+			tee := Tee(child.With(String("tee", "a")), child.With(String("tee", "b")))
+			for j := 0; j < perGoroutine; j++ {
+				child.Debug("Disabled.")
+				child.Info("Child.")
+				tee.Info("Tee.")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines := buf.Lines()
+	const expected = goroutines * perGoroutine * 3
+	require.Equal(t, expected, len(lines), "Unexpected number of entries.")
+	seen := make(map[uint64]struct{}, len(lines))
+	var max uint64
+	for _, line := range lines {
+		var entry struct {
+			Seq uint64 `json:"seq"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), "Unexpected error unmarshaling entry.")
+		_, dup := seen[entry.Seq]
+		require.False(t, dup, "Duplicate sequence number %d.", entry.Seq)
+		seen[entry.Seq] = struct{}{}
+		if entry.Seq > max {
+			max = entry.Seq
+		}
+	}
+	assert.Equal(t, uint64(expected), max, "Expected dense sequence numbers.")
+}
+
+func TestHookAddSequenceIsPerLogger(t *testing.T) {
+	buf := &testBuffer{}
+	first := New(NewJSONEncoder(NoTime()), Output(buf), AddSequence("seq"))
+	second := New(NewJSONEncoder(NoTime()), Output(buf), AddSequence("seq"))
+	first.Info("")
+	first.Info("")
+	second.Info("")
+	assert.Equal(t, []string{
+		`{"level":"info","msg":"","seq":1}`,
+		`{"level":"info","msg":"","seq":2}`,
+		`{"level":"info","msg":"","seq":1}`,
+	}, buf.Lines(), "Expected separate counters for separately constructed loggers.")
+}
+
+func TestGoroutineID(t *testing.T) {
+	id, ok := goroutineID()
+	require.True(t, ok, "Failed to parse goroutine ID.")
+	assert.NotZero(t, id, "Expected a non-zero goroutine ID.")
+
+	again, _ := goroutineID()
+	assert.Equal(t, id, again, "Expected the same ID from one goroutine.")
+}
+
 func TestHooksNilEntry(t *testing.T) {
 	tests := []struct {
 		name string
@@ -76,6 +239,8 @@ func TestHooksNilEntry(t *testing.T) {
 	}{
 		{"AddStacks", AddStacks(InfoLevel).(Hook)},
 		{"AddCaller", AddCaller().(Hook)},
+		{"AddGoroutineID", AddGoroutineID().(Hook)},
+		{"AddSequence", AddSequence("seq").(Hook)},
 	}
 	for _, tt := range tests {
 		assert.NotPanics(t, func() {
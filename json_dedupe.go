@@ -0,0 +1,188 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+// Entries with at most this many fields in each scope are deduplicated without
+// allocating.
+const _dedupeSmallScope = 32
+
+// A jsonMember locates one key-value pair of a JSON object in a buffer.
+type jsonMember struct {
+	start     int  // the member's first byte (the key's opening quote)
+	keyEnd    int  // the byte after the key's closing quote
+	value     int  // the value's first byte (only set when sorting)
+	end       int  // the byte after the member's value
+	open      int  // the marks of the value's brackets, or -1 for values
+	close     int  // without them (only set when sorting)
+	dropped   bool // a later member has the same key
+	namespace bool // the member is an open namespace (only set when sorting)
+}
+
+// appendDeduped appends the encoded members in src to dst, keeping only the
+// last occurrence of each key. The last member of each scope may be an open
+// namespace, within which keys are deduplicated too; members of closed nested
+// objects are copied as-is.
+func appendDeduped(dst, src []byte, openNamespaces int) []byte {
+	return appendUnique(dst, src, 0, openNamespaces, nil)
+}
+
+// appendReplaced is like appendDeduped, but it only drops the members that
+// are followed by a Replace field with the same key in the same scope.
+// replacements holds the offsets in src at which Replace fields start, in
+// ascending order.
+func appendReplaced(dst, src []byte, openNamespaces int, replacements []int) []byte {
+	return appendUnique(dst, src, 0, openNamespaces, replacements)
+}
+
+// appendUnique implements appendDeduped and appendReplaced. Since namespaces
+// are handled recursively, base is the offset of src within the original
+// buffer. If replacements is nil, all duplicate keys are dropped.
+func appendUnique(dst, src []byte, base, openNamespaces int, replacements []int) []byte {
+	var small [_dedupeSmallScope]jsonMember
+	members := small[:0]
+	for i := 0; i < len(src); {
+		if src[i] == ',' {
+			i++
+		}
+		m := jsonMember{start: i, keyEnd: skipJSONString(src, i)}
+		// Skip the colon between the key and value.
+		m.end = skipJSONValue(src, m.keyEnd+1)
+		members = append(members, m)
+		i = m.end
+	}
+
+	if replacements == nil {
+		markDuplicates(src, members)
+	} else {
+		markReplaced(src, members, base, replacements)
+	}
+
+	first := true
+	for i, m := range members {
+		if m.dropped {
+			continue
+		}
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		if openNamespaces > 0 && i == len(members)-1 {
+			// Copy the key, colon, and opening brace, then deduplicate the
+			// namespace's contents.
+			dst = append(dst, src[m.start:m.keyEnd+2]...)
+			inner := m.keyEnd + 2
+			return appendUnique(dst, src[inner:], base+inner, openNamespaces-1, replacements)
+		}
+		dst = append(dst, src[m.start:m.end]...)
+	}
+	return dst
+}
+
+// markDuplicates marks all but the last member with each key as dropped.
+func markDuplicates(src []byte, members []jsonMember) {
+	if len(members) <= _dedupeSmallScope {
+		for i := len(members) - 2; i >= 0; i-- {
+			key := src[members[i].start:members[i].keyEnd]
+			for j := i + 1; j < len(members); j++ {
+				if string(key) == string(src[members[j].start:members[j].keyEnd]) {
+					members[i].dropped = true
+					break
+				}
+			}
+		}
+		return
+	}
+
+	seen := make(map[string]struct{}, len(members))
+	for i := len(members) - 1; i >= 0; i-- {
+		key := src[members[i].start:members[i].keyEnd]
+		if _, ok := seen[string(key)]; ok {
+			members[i].dropped = true
+			continue
+		}
+		seen[string(key)] = struct{}{}
+	}
+}
+
+// markReplaced marks members as dropped if a later member with the same key
+// starts at one of the replacement offsets.
+func markReplaced(src []byte, members []jsonMember, base int, replacements []int) {
+	r := 0
+	for j, m := range members {
+		for r < len(replacements) && replacements[r] < base+m.start {
+			r++
+		}
+		if r == len(replacements) {
+			return
+		}
+		if replacements[r] != base+m.start {
+			continue
+		}
+		key := src[m.start:m.keyEnd]
+		for i := 0; i < j; i++ {
+			if string(key) == string(src[members[i].start:members[i].keyEnd]) {
+				members[i].dropped = true
+			}
+		}
+	}
+}
+
+// skipJSONString returns the index just past the JSON string starting at
+// src[i].
+func skipJSONString(src []byte, i int) int {
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// skipJSONValue returns the index just past the JSON value starting at src[i],
+// which is the index of the next separator or of the enclosing object or
+// array's closing bracket. If the value is an unclosed object (that is, an open
+// namespace), it returns len(src).
+func skipJSONValue(src []byte, i int) int {
+	depth := 0
+	for i < len(src) {
+		switch src[i] {
+		case '"':
+			i = skipJSONString(src, i)
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		}
+		i++
+	}
+	return i
+}
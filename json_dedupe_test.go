@@ -0,0 +1,298 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withDedupingLogger(t testing.TB, f func(Logger, *testBuffer)) {
+	sink := &testBuffer{}
+	errSink := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime(), DeduplicateKeys()), DebugLevel, Output(sink), ErrorOutput(errSink))
+	f(logger, sink)
+	assert.Empty(t, errSink.String(), "Expected error sink to be empty.")
+}
+
+func TestDeduplicateKeys(t *testing.T) {
+	tests := []struct {
+		desc     string
+		context  []Field
+		fields   []Field
+		expected string
+	}{
+		{
+			desc:     "no duplicates",
+			context:  []Field{String("component", "db")},
+			fields:   []Field{Int("n", 1)},
+			expected: `{"level":"info","msg":"","component":"db","n":1}`,
+		},
+		{
+			desc:     "duplicates within context",
+			context:  []Field{String("component", "db"), Int("n", 1), String("component", "sql")},
+			expected: `{"level":"info","msg":"","n":1,"component":"sql"}`,
+		},
+		{
+			desc:     "call shadows context",
+			context:  []Field{String("component", "db"), Int("n", 1)},
+			fields:   []Field{String("component", "sql")},
+			expected: `{"level":"info","msg":"","n":1,"component":"sql"}`,
+		},
+		{
+			desc:     "different types",
+			context:  []Field{String("k", "str")},
+			fields:   []Field{Int("k", 42), Bool("k", true)},
+			expected: `{"level":"info","msg":"","k":true}`,
+		},
+		{
+			desc:     "nested objects",
+			context:  []Field{Marshaler("obj", LogMarshalerFunc(func(kv KeyValue) error { kv.AddInt("n", 1); return nil }))},
+			fields:   []Field{Strings("obj", []string{"a", "b,c"}), Int("n", 2)},
+			expected: `{"level":"info","msg":"","obj":["a","b,c"],"n":2}`,
+		},
+		{
+			desc: "within namespace",
+			context: []Field{
+				String("k", "outer"),
+				Namespace("ns"),
+				String("k", "first"),
+				Int("n", 1),
+			},
+			fields:   []Field{String("k", "second")},
+			expected: `{"level":"info","msg":"","k":"outer","ns":{"n":1,"k":"second"}}`,
+		},
+		{
+			desc:     "namespace shadows field",
+			context:  []Field{String("ns", "value"), Int("n", 1)},
+			fields:   []Field{Namespace("ns"), Int("n", 2), Int("n", 3)},
+			expected: `{"level":"info","msg":"","n":1,"ns":{"n":3}}`,
+		},
+		{
+			desc:     "empty nested namespaces",
+			context:  []Field{Namespace("a"), Namespace("b")},
+			expected: `{"level":"info","msg":"","a":{"b":{}}}`,
+		},
+		{
+			desc:     "escaped keys",
+			context:  []Field{String(`"quoted"`, "a"), String(`"quoted"`, `"b"`)},
+			fields:   []Field{String("k\\", "{[,"), String("k\\", "}]")},
+			expected: `{"level":"info","msg":"","\"quoted\"":"\"b\"","k\\":"}]"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		withDedupingLogger(t, func(logger Logger, buf *testBuffer) {
+			logger.With(tt.context...).Info("", tt.fields...)
+			assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output with %s.", tt.desc)
+		})
+	}
+}
+
+func TestDeduplicateKeysAcrossWithChains(t *testing.T) {
+	withDedupingLogger(t, func(logger Logger, buf *testBuffer) {
+		db := logger.With(String("component", "db"), Int("shard", 1))
+		sql := db.With(String("component", "sql"))
+		sql.Info("", Int("shard", 2))
+		db.Info("")
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"","component":"sql","shard":2}`,
+			`{"level":"info","msg":"","component":"db","shard":1}`,
+		}, buf.Lines(), "Unexpected output from layered loggers.")
+	})
+}
+
+func TestDeduplicateKeysManyFields(t *testing.T) {
+	// Exceed the allocation-free fast path.
+	n := 3 * _dedupeSmallScope
+	fields := make([]Field, 0, 2*n)
+	expected := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		fields = append(fields, Int(fmt.Sprintf("k%d", i), i))
+	}
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			fields = append(fields, String(fmt.Sprintf("k%d", i), "shadowed"))
+		}
+	}
+	for i := 0; i < n; i++ {
+		if i%2 != 0 {
+			expected = append(expected, fmt.Sprintf(`"k%d":%d`, i, i))
+		}
+	}
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			expected = append(expected, fmt.Sprintf(`"k%d":"shadowed"`, i))
+		}
+	}
+
+	withDedupingLogger(t, func(logger Logger, buf *testBuffer) {
+		logger.With(fields[:n]...).Info("", fields[n:]...)
+		require.Equal(t, 1, len(buf.Lines()), "Expected a single entry.")
+		assert.Equal(
+			t,
+			`{"level":"info","msg":"",`+strings.Join(expected, ",")+"}",
+			buf.Stripped(),
+			"Unexpected output with many duplicated fields.",
+		)
+	})
+}
+
+func TestDuplicateKeysByDefault(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		logger.With(String("k", "a")).Info("", String("k", "b"))
+		assert.Equal(t, `{"level":"info","msg":"","k":"a","k":"b"}`, buf.Stripped(), "Expected duplicate keys by default.")
+	})
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		desc     string
+		context  []Field
+		fields   []Field
+		expected string
+	}{
+		{
+			desc:     "overrides context",
+			context:  []Field{String("path", "/"), Int("status", 0)},
+			fields:   []Field{Replace(Int("status", 200))},
+			expected: `{"level":"info","msg":"","path":"/","status":200}`,
+		},
+		{
+			desc:     "overrides earlier field",
+			fields:   []Field{Int("status", 0), Int("n", 1), Replace(Int("status", 500))},
+			expected: `{"level":"info","msg":"","n":1,"status":500}`,
+		},
+		{
+			desc:     "overrides every earlier field",
+			context:  []Field{String("k", "a"), String("k", "b")},
+			fields:   []Field{String("k", "c"), Replace(String("k", "d"))},
+			expected: `{"level":"info","msg":"","k":"d"}`,
+		},
+		{
+			desc:     "missing key",
+			context:  []Field{String("path", "/")},
+			fields:   []Field{Replace(Int("status", 200))},
+			expected: `{"level":"info","msg":"","path":"/","status":200}`,
+		},
+		{
+			desc:     "later fields still duplicate",
+			fields:   []Field{Replace(Int("status", 200)), Int("status", 500)},
+			expected: `{"level":"info","msg":"","status":200,"status":500}`,
+		},
+		{
+			desc:     "other duplicates kept",
+			context:  []Field{String("k", "a"), Int("status", 0)},
+			fields:   []Field{String("k", "b"), Replace(Int("status", 200))},
+			expected: `{"level":"info","msg":"","k":"a","k":"b","status":200}`,
+		},
+		{
+			desc:     "within namespace",
+			context:  []Field{Int("status", 0), Namespace("ns"), Int("status", 1)},
+			fields:   []Field{Replace(Int("status", 2))},
+			expected: `{"level":"info","msg":"","status":0,"ns":{"status":2}}`,
+		},
+		{
+			desc:     "nested objects untouched",
+			context:  []Field{Marshaler("obj", loggable{true})},
+			fields:   []Field{Replace(Bool("loggable", false))},
+			expected: `{"level":"info","msg":"","obj":{"loggable":"yes"},"loggable":false}`,
+		},
+		{
+			desc:     "replacing a namespace",
+			context:  []Field{String("ns", "value"), Int("n", 1)},
+			fields:   []Field{Replace(Namespace("ns")), Int("n", 2)},
+			expected: `{"level":"info","msg":"","n":1,"ns":{"n":2}}`,
+		},
+		{
+			desc:     "skipped",
+			context:  []Field{Int("status", 0)},
+			fields:   []Field{Replace(Skip())},
+			expected: `{"level":"info","msg":"","status":0}`,
+		},
+		{
+			desc:     "double-wrapped",
+			context:  []Field{Int("status", 0)},
+			fields:   []Field{Replace(Replace(Int("status", 200)))},
+			expected: `{"level":"info","msg":"","status":200}`,
+		},
+	}
+
+	for _, tt := range tests {
+		withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+			logger.With(tt.context...).Info("", tt.fields...)
+			assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output with Replace: %s.", tt.desc)
+		})
+	}
+}
+
+func TestReplaceInContext(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		parent := logger.With(Int("status", 0))
+		child := parent.With(Replace(Int("status", 1)))
+		child.Info("")
+		parent.Info("")
+		child.Info("", Replace(Int("status", 2)))
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"","status":1}`,
+			`{"level":"info","msg":"","status":0}`,
+			`{"level":"info","msg":"","status":2}`,
+		}, buf.Lines(), "Unexpected output with Replace fields in context.")
+	})
+}
+
+func TestReplaceWithOptions(t *testing.T) {
+	tests := []struct {
+		desc     string
+		opts     []JSONOption
+		expected string
+	}{
+		{"sorted", []JSONOption{SortKeys()}, `{"level":"info","msg":"","a":1,"k":"a","k":"b","status":200}`},
+		{"sorted and deduplicated", []JSONOption{SortKeys(), DeduplicateKeys()}, `{"level":"info","msg":"","a":1,"k":"b","status":200}`},
+		{"deduplicated", []JSONOption{DeduplicateKeys()}, `{"level":"info","msg":"","k":"b","status":200,"a":1}`},
+	}
+	for _, tt := range tests {
+		buf := &testBuffer{}
+		logger := New(NewJSONEncoder(append(tt.opts, NoTime())...), Output(buf))
+		logger.With(Int("status", 0), String("k", "a")).Info("", String("k", "b"), Replace(Int("status", 200)), Int("a", 1))
+		assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output with Replace and options: %s.", tt.desc)
+	}
+}
+
+func TestReplaceRedacted(t *testing.T) {
+	withJSONLogger(t, []Option{RedactKeys("token")}, func(logger Logger, buf *testBuffer) {
+		logger.With(String("token", "a")).Info("", Replace(String("token", "b")))
+		assert.Equal(t, `{"level":"info","msg":"","token":"[REDACTED]"}`, buf.Stripped(), "Expected Replace to respect redaction.")
+	})
+	assert.Equal(t, Replace(String("k", "[REDACTED]")), Redact(Replace(String("k", "v"))), "Expected Redact to preserve Replace.")
+}
+
+func TestReplaceUnsupported(t *testing.T) {
+	withTextEncoder(func(enc *textEncoder) {
+		enc.AddInt("status", 0)
+		Replace(Int("status", 200)).AddTo(enc)
+		assert.Equal(t, "status=0 status=200", string(enc.bytes), "Expected encoders without replacement support to add the field as-is.")
+	})
+}
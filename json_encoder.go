@@ -21,14 +21,17 @@
 package zap
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 	"strconv"
 	"sync"
-	"time"
 	"unicode/utf8"
 )
 
@@ -37,11 +40,27 @@ const (
 	_hex = "0123456789abcdef"
 	// Initial buffer size for encoders.
 	_initialBufSize = 1024
+	// Encoders whose buffers have grown beyond this size aren't returned to
+	// the pool, so that an occasional huge entry doesn't pin memory.
+	_maxPooledBufSize = 64 * 1024
+	// The largest magnitude that a float64 (and so JavaScript) can represent
+	// exactly; see the SafeIntegers option.
+	_maxSafeInteger = 1<<53 - 1
+	// Appended to Binary and ByteString values shortened by a ByteLimit.
+	_truncatedSuffix = "..."
+	// The part of each MaxEntryBytes budget set aside for the level, time,
+	// message, and truncation marker; fields get the rest.
+	_entryLimitReserve = 256
+	// Enough room for the truncation marker added to entries that exceed
+	// their MaxEntryBytes budget.
+	_entryMarkerLen = len(`,"truncated":true,"originalSize":`) + 20
 )
 
 var (
 	// errNilSink signals that Encoder.WriteEntry was called with a nil WriteSyncer.
 	errNilSink = errors.New("can't write encoded message a nil WriteSyncer")
+	// errInvalidRawJSON signals that a RawJSON field didn't contain valid JSON.
+	errInvalidRawJSON = errors.New("invalid raw JSON, encoded as a string instead")
 
 	// Default formatters for JSON encoders.
 	defaultMessageF = MessageKey("msg")
@@ -58,10 +77,47 @@ var (
 
 // jsonEncoder is an Encoder implementation that writes JSON.
 type jsonEncoder struct {
-	bytes    []byte
-	messageF MessageFormatter
-	timeF    TimeFormatter
-	levelF   LevelFormatter
+	bytes []byte
+	// Clones share their parent's encoded fields rather than copying them:
+	// prefix is the parent, and prefixBytes are the fields the parent had
+	// encoded when it was cloned. Since encoders only ever append to their
+	// bytes, the prefix is immutable as long as the parent isn't freed.
+	prefix         *jsonEncoder
+	prefixBytes    []byte
+	openNamespaces int
+	messageF       MessageFormatter
+	timeF          TimeFormatter
+	levelF         LevelFormatter
+	callerF        CallerFormatter
+	stackF         StackFormatter
+	binaryEnc      BinaryEncoding
+	byteLimit      int
+	markTruncated  bool
+	strictRawJSON  bool
+	nullNonFinite  bool
+	safeIntegers   bool
+	htmlSafe       bool
+	dedupe         bool
+	sortKeys       bool
+	// With SortKeys, the encoder records where it writes keys and brackets.
+	// Like the fields themselves, the marks recorded before the encoder was
+	// cloned are shared with its parent.
+	marks       []jsonMark
+	prefixMarks []jsonMark
+	// The offsets of Replace fields within the encoder's fields (including
+	// the prefix), in ascending order. Clones share their parent's offsets,
+	// so the slice's capacity is clipped to make appends copy it.
+	replacements []int
+	// The MaxEntryBytes budget, and the state needed to enforce it
+	// incrementally: prefixLen is the total length of the prefix chain,
+	// depth counts the closing brackets owed by unfinished nested objects and
+	// arrays (other than open namespaces), and droppedBytes estimates the size
+	// of the values dropped once the budget ran out.
+	maxEntryBytes  int
+	prefixLen      int
+	depth          int
+	entryTruncated bool
+	droppedBytes   int
 }
 
 // NewJSONEncoder creates a fast, low-allocation JSON encoder. By default, JSON
@@ -70,13 +126,13 @@ type jsonEncoder struct {
 // under the "level" key. The encoder appropriately escapes all field keys and
 // values.
 //
-// Note that the encoder doesn't deduplicate keys, so it's possible to produce a
-// message like
+// Note that by default the encoder doesn't deduplicate keys, so it's possible
+// to produce a message like
 //   {"foo":"bar","foo":"baz"}
 // This is permitted by the JSON specification, but not encouraged. Many
 // libraries will ignore duplicate key-value pairs (typically keeping the last
 // pair) when unmarshaling, but users should attempt to avoid adding duplicate
-// keys.
+// keys. If that's impractical, use the DeduplicateKeys option.
 func NewJSONEncoder(options ...JSONOption) Encoder {
 	enc := jsonPool.Get().(*jsonEncoder)
 	enc.truncate()
@@ -84,6 +140,18 @@ func NewJSONEncoder(options ...JSONOption) Encoder {
 	enc.messageF = defaultMessageF
 	enc.timeF = defaultTimeF
 	enc.levelF = defaultLevelF
+	enc.callerF = nil
+	enc.stackF = nil
+	enc.binaryEnc = Base64Encoding
+	enc.byteLimit = 0
+	enc.markTruncated = false
+	enc.strictRawJSON = false
+	enc.nullNonFinite = false
+	enc.safeIntegers = false
+	enc.htmlSafe = false
+	enc.dedupe = false
+	enc.sortKeys = false
+	enc.maxEntryBytes = 0
 	for _, opt := range options {
 		opt.apply(enc)
 	}
@@ -92,21 +160,92 @@ func NewJSONEncoder(options ...JSONOption) Encoder {
 }
 
 func (enc *jsonEncoder) Free() {
+	if cap(enc.bytes) > _maxPooledBufSize {
+		return
+	}
+	// Don't keep the parent alive while the encoder sits in the pool.
+	enc.prefix, enc.prefixBytes, enc.prefixMarks = nil, nil, nil
 	jsonPool.Put(enc)
 }
 
 // AddString adds a string key and value to the encoder's fields. Both key and
 // value are JSON-escaped.
 func (enc *jsonEncoder) AddString(key, val string) {
+	if !enc.reserveKey(key, len(val)+2) {
+		return
+	}
+	mark := len(enc.bytes)
 	enc.addKey(key)
 	enc.bytes = append(enc.bytes, '"')
 	enc.safeAddString(val)
 	enc.bytes = append(enc.bytes, '"')
+	enc.checkBudget(mark)
+}
+
+// AddByteString adds a string key and a UTF-8 encoded byte slice to the
+// encoder's fields. Both key and value are JSON-escaped, and the value is never
+// converted to a string.
+func (enc *jsonEncoder) AddByteString(key string, val []byte) {
+	truncated := enc.exceedsLimit(val)
+	if truncated {
+		n := enc.byteLimit
+		// Don't split a multi-byte character.
+		for start := n - 1; start >= 0 && start > n-utf8.UTFMax; start-- {
+			if !utf8.RuneStart(val[start]) {
+				continue
+			}
+			if _, size := utf8.DecodeRune(val[start:]); size > 1 && start+size > n {
+				n = start
+			}
+			break
+		}
+		val = val[:n]
+	}
+	if !enc.reserveKey(key, len(val)+2) {
+		return
+	}
+	mark := len(enc.bytes)
+	enc.addKey(key)
+	enc.bytes = append(enc.bytes, '"')
+	enc.safeAddByteString(val)
+	enc.addTruncated(key, truncated)
+	enc.checkBudget(mark)
+}
+
+// AddBinary adds a string key and an opaque binary value to the encoder's
+// fields. The key is JSON-escaped, and the value is encoded as a base64 or hex
+// string, depending on the encoder's BinaryEncoding.
+func (enc *jsonEncoder) AddBinary(key string, val []byte) {
+	truncated := enc.exceedsLimit(val)
+	if truncated {
+		val = val[:enc.byteLimit]
+	}
+	n := base64.StdEncoding.EncodedLen(len(val))
+	if enc.binaryEnc == HexEncoding {
+		n = hex.EncodedLen(len(val))
+	}
+	if !enc.reserveKey(key, n+2) {
+		return
+	}
+	mark := len(enc.bytes)
+	enc.addKey(key)
+	enc.bytes = append(enc.bytes, '"')
+	switch enc.binaryEnc {
+	case HexEncoding:
+		hex.Encode(enc.grow(n), val)
+	default:
+		base64.StdEncoding.Encode(enc.grow(n), val)
+	}
+	enc.addTruncated(key, truncated)
+	enc.checkBudget(mark)
 }
 
 // AddBool adds a string key and a boolean value to the encoder's fields. The
 // key is JSON-escaped.
 func (enc *jsonEncoder) AddBool(key string, val bool) {
+	if !enc.reserveKey(key, 5) {
+		return
+	}
 	enc.addKey(key)
 	enc.bytes = strconv.AppendBool(enc.bytes, val)
 }
@@ -118,9 +257,23 @@ func (enc *jsonEncoder) AddInt(key string, val int) {
 }
 
 // AddInt64 adds a string key and int64 value to the encoder's fields. The key
-// is JSON-escaped.
+// is JSON-escaped. If the encoder was configured with SafeIntegers, values
+// that can't be represented exactly by a float64 are encoded as strings.
 func (enc *jsonEncoder) AddInt64(key string, val int64) {
+	if !enc.reserveKey(key, 22) {
+		return
+	}
 	enc.addKey(key)
+	enc.appendInt64(val)
+}
+
+func (enc *jsonEncoder) appendInt64(val int64) {
+	if enc.safeIntegers && (val > _maxSafeInteger || val < -_maxSafeInteger) {
+		enc.bytes = append(enc.bytes, '"')
+		enc.bytes = strconv.AppendInt(enc.bytes, val, 10)
+		enc.bytes = append(enc.bytes, '"')
+		return
+	}
 	enc.bytes = strconv.AppendInt(enc.bytes, val, 10)
 }
 
@@ -131,9 +284,22 @@ func (enc *jsonEncoder) AddUint(key string, val uint) {
 }
 
 // AddUint64 adds a string key and integer value to the encoder's fields. The key
-// is JSON-escaped.
+// is JSON-escaped. Like AddInt64, it respects the SafeIntegers option.
 func (enc *jsonEncoder) AddUint64(key string, val uint64) {
+	if !enc.reserveKey(key, 22) {
+		return
+	}
 	enc.addKey(key)
+	enc.appendUint64(val)
+}
+
+func (enc *jsonEncoder) appendUint64(val uint64) {
+	if enc.safeIntegers && val > _maxSafeInteger {
+		enc.bytes = append(enc.bytes, '"')
+		enc.bytes = strconv.AppendUint(enc.bytes, val, 10)
+		enc.bytes = append(enc.bytes, '"')
+		return
+	}
 	enc.bytes = strconv.AppendUint(enc.bytes, val, 10)
 }
 
@@ -144,9 +310,37 @@ func (enc *jsonEncoder) AddUintptr(key string, val uintptr) {
 // AddFloat64 adds a string key and float64 value to the encoder's fields. The
 // key is JSON-escaped, and the floating-point value is encoded using
 // strconv.FormatFloat's 'f' option (always use grade-school notation, even for
-// large exponents).
+// large exponents). Since JSON can't represent NaN and infinite values, they're
+// encoded as the strings "NaN", "+Inf", and "-Inf" (or as null, if the encoder
+// was configured with NullNonFiniteFloats).
 func (enc *jsonEncoder) AddFloat64(key string, val float64) {
+	if !enc.reserveKey(key, 24) {
+		return
+	}
+	mark := len(enc.bytes)
+	enc.addKey(key)
+	enc.appendFloat(val, 64)
+	enc.checkBudget(mark)
+}
+
+// AddFloat32 adds a string key and float32 value to the encoder's fields. It's
+// encoded like a float64, but using the shortest representation that
+// round-trips a 32-bit float.
+func (enc *jsonEncoder) AddFloat32(key string, val float32) {
+	if !enc.reserveKey(key, 24) {
+		return
+	}
+	mark := len(enc.bytes)
 	enc.addKey(key)
+	enc.appendFloat(float64(val), 32)
+	enc.checkBudget(mark)
+}
+
+func (enc *jsonEncoder) appendFloat(val float64, bitSize int) {
+	if enc.nullNonFinite && (math.IsNaN(val) || math.IsInf(val, 0)) {
+		enc.bytes = append(enc.bytes, "null"...)
+		return
+	}
 	switch {
 	case math.IsNaN(val):
 		enc.bytes = append(enc.bytes, `"NaN"`...)
@@ -155,38 +349,383 @@ func (enc *jsonEncoder) AddFloat64(key string, val float64) {
 	case math.IsInf(val, -1):
 		enc.bytes = append(enc.bytes, `"-Inf"`...)
 	default:
-		enc.bytes = strconv.AppendFloat(enc.bytes, val, 'f', -1, 64)
+		enc.bytes = strconv.AppendFloat(enc.bytes, val, 'f', -1, bitSize)
 	}
 }
 
-// AddMarshaler adds a LogMarshaler to the encoder's fields.
+// AddMarshaler adds a LogMarshaler to the encoder's fields. Any namespaces
+// opened by the marshaler are closed along with the marshaled object.
 func (enc *jsonEncoder) AddMarshaler(key string, obj LogMarshaler) error {
+	if !enc.reserveKey(key, 2) {
+		return nil
+	}
 	enc.addKey(key)
+	return enc.appendMarshaler(obj)
+}
+
+func (enc *jsonEncoder) appendMarshaler(obj LogMarshaler) error {
 	enc.bytes = append(enc.bytes, '{')
+	enc.markBracket()
+	outer := enc.openNamespaces
+	enc.openNamespaces = 0
+	// The enclosing object and namespaces still need closing.
+	enc.depth += outer + 1
 	err := obj.MarshalLog(enc)
+	enc.closeOpenNamespaces()
+	enc.depth -= outer + 1
+	enc.openNamespaces = outer
 	enc.bytes = append(enc.bytes, '}')
+	enc.markBracket()
 	return err
 }
 
-// AddObject uses reflection to add an arbitrary object to the logging context.
+// AddArray adds an ArrayMarshaler to the encoder's fields as a JSON array.
+func (enc *jsonEncoder) AddArray(key string, arr ArrayMarshaler) error {
+	if !enc.reserveKey(key, 2) {
+		return nil
+	}
+	enc.addKey(key)
+	return enc.appendArray(arr)
+}
+
+func (enc *jsonEncoder) appendArray(arr ArrayMarshaler) error {
+	enc.bytes = append(enc.bytes, '[')
+	enc.markBracket()
+	enc.depth++
+	err := arr.MarshalLogArray(enc)
+	enc.depth--
+	enc.bytes = append(enc.bytes, ']')
+	enc.markBracket()
+	return err
+}
+
+// AppendMarshaler adds a LogMarshaler to the array being encoded as a nested
+// JSON object.
+func (enc *jsonEncoder) AppendMarshaler(obj LogMarshaler) error {
+	if !enc.reserve(3) {
+		return nil
+	}
+	enc.addElementSeparator()
+	return enc.appendMarshaler(obj)
+}
+
+// AppendArray adds an ArrayMarshaler to the array being encoded as a nested
+// JSON array.
+func (enc *jsonEncoder) AppendArray(arr ArrayMarshaler) error {
+	if !enc.reserve(3) {
+		return nil
+	}
+	enc.addElementSeparator()
+	return enc.appendArray(arr)
+}
+
+// AppendObject adds an arbitrary object to the array being encoded, using the
+// same precedence rules as AddObject. If the object can't be serialized,
+// nothing is added.
+func (enc *jsonEncoder) AppendObject(obj interface{}) error {
+	if m, ok := obj.(LogMarshaler); ok && !isNilPointer(m) {
+		return enc.AppendMarshaler(m)
+	}
+	if m, ok := lookupTypeEncoder(obj); ok {
+		return enc.AppendMarshaler(m)
+	}
+	if s, ok, err := canonicalString(obj); ok {
+		enc.AppendString(s)
+		return err
+	}
+	switch f := obj.(type) {
+	case marshaledObject:
+		if !enc.reserve(len(f.json) + 1) {
+			return nil
+		}
+		enc.addElementSeparator()
+		enc.bytes = append(enc.bytes, f.json...)
+		return nil
+	case float64:
+		enc.AppendFloat64(f)
+		return nil
+	case float32:
+		enc.AppendFloat32(f)
+		return nil
+	}
+	marshaled, err := json.Marshal(obj)
+	if isNonFiniteError(err) {
+		return addSanitized(&arrayElement{arr: enc}, "", reflect.ValueOf(obj), DefaultMapDepth)
+	}
+	if err != nil {
+		return err
+	}
+	if !enc.reserve(len(marshaled) + 1) {
+		return nil
+	}
+	enc.addElementSeparator()
+	enc.bytes = append(enc.bytes, marshaled...)
+	return nil
+}
+
+// AppendBool adds a boolean element to the array being encoded.
+func (enc *jsonEncoder) AppendBool(val bool) {
+	if !enc.reserve(6) {
+		return
+	}
+	enc.addElementSeparator()
+	enc.bytes = strconv.AppendBool(enc.bytes, val)
+}
+
+// AppendByteString adds a UTF-8 encoded byte slice to the array being encoded
+// as a JSON-escaped string, without converting it to a string first.
+func (enc *jsonEncoder) AppendByteString(val []byte) {
+	if !enc.reserve(len(val) + 3) {
+		return
+	}
+	mark := len(enc.bytes)
+	enc.addElementSeparator()
+	enc.bytes = append(enc.bytes, '"')
+	enc.safeAddByteString(val)
+	enc.bytes = append(enc.bytes, '"')
+	enc.checkBudget(mark)
+}
+
+// AppendFloat32 adds a float32 element to the array being encoded, using the
+// same representation as AddFloat32.
+func (enc *jsonEncoder) AppendFloat32(val float32) {
+	if !enc.reserve(25) {
+		return
+	}
+	mark := len(enc.bytes)
+	enc.addElementSeparator()
+	enc.appendFloat(float64(val), 32)
+	enc.checkBudget(mark)
+}
+
+// AppendFloat64 adds a float64 element to the array being encoded, using the
+// same representation as AddFloat64.
+func (enc *jsonEncoder) AppendFloat64(val float64) {
+	if !enc.reserve(25) {
+		return
+	}
+	mark := len(enc.bytes)
+	enc.addElementSeparator()
+	enc.appendFloat(val, 64)
+	enc.checkBudget(mark)
+}
+
+// AppendInt64 adds an int64 element to the array being encoded.
+func (enc *jsonEncoder) AppendInt64(val int64) {
+	if !enc.reserve(23) {
+		return
+	}
+	enc.addElementSeparator()
+	enc.appendInt64(val)
+}
+
+// AppendUint64 adds a uint64 element to the array being encoded.
+func (enc *jsonEncoder) AppendUint64(val uint64) {
+	if !enc.reserve(23) {
+		return
+	}
+	enc.addElementSeparator()
+	enc.appendUint64(val)
+}
+
+// AppendString adds a JSON-escaped string element to the array being encoded.
+func (enc *jsonEncoder) AppendString(val string) {
+	if !enc.reserve(len(val) + 3) {
+		return
+	}
+	mark := len(enc.bytes)
+	enc.addElementSeparator()
+	enc.bytes = append(enc.bytes, '"')
+	enc.safeAddString(val)
+	enc.bytes = append(enc.bytes, '"')
+	enc.checkBudget(mark)
+}
+
+// AddObject adds an arbitrary object to the logging context. Objects that
+// implement LogMarshaler are encoded without reflection, as are nil objects
+// and objects with a registered TypeEncoder. Otherwise, implementations of
+// json.Marshaler and encoding.TextMarshaler take precedence, then error and
+// fmt.Stringer implementations, and finally reflection-based serialization.
 func (enc *jsonEncoder) AddObject(key string, obj interface{}) error {
+	if obj == nil {
+		if !enc.reserveKey(key, 4) {
+			return nil
+		}
+		enc.addKey(key)
+		enc.bytes = append(enc.bytes, "null"...)
+		return nil
+	}
+	if m, ok := obj.(LogMarshaler); ok && !isNilPointer(m) {
+		return enc.AddMarshaler(key, m)
+	}
+	if m, ok := lookupTypeEncoder(obj); ok {
+		return enc.AddMarshaler(key, m)
+	}
+	if s, ok, err := canonicalString(obj); ok {
+		enc.AddString(key, s)
+		return err
+	}
+	// The standard library refuses to serialize NaN and infinite values, so
+	// handle top-level floats ourselves. Values with non-finite floats nested
+	// inside them are re-encoded by addSanitized.
+	switch f := obj.(type) {
+	case float64:
+		enc.AddFloat64(key, f)
+		return nil
+	case float32:
+		enc.AddFloat32(key, f)
+		return nil
+	case marshaledObject:
+		return enc.AddRawJSON(key, f.json)
+	}
+	// The standard library handles json.Marshaler and encoding.TextMarshaler,
+	// and validates the output of MarshalJSON.
 	marshaled, err := json.Marshal(obj)
+	if isNonFiniteError(err) {
+		return addSanitized(enc, key, reflect.ValueOf(obj), DefaultMapDepth)
+	}
 	if err != nil {
 		return err
 	}
+	if !enc.reserveKey(key, len(marshaled)) {
+		return nil
+	}
 	enc.addKey(key)
 	enc.bytes = append(enc.bytes, marshaled...)
 	return nil
 }
 
-// Clone copies the current encoder, including any data already encoded.
+// AddRawJSON splices a pre-encoded JSON value into the encoder's fields. The
+// key is JSON-escaped. Line breaks between tokens are replaced with spaces, so
+// that each entry stays on a single line. If the value isn't valid JSON, it's
+// added as an escaped string instead and an error is returned.
+func (enc *jsonEncoder) AddRawJSON(key string, val []byte) error {
+	if !enc.reserveKey(key, len(val)) {
+		return nil
+	}
+	mark := len(enc.bytes)
+	enc.addKey(key)
+	if enc.validRawJSON(val) {
+		if spliced, ok := appendSingleLine(enc.bytes, val); ok {
+			enc.bytes = spliced
+			return nil
+		}
+	}
+	enc.bytes = append(enc.bytes, '"')
+	enc.safeAddByteString(val)
+	enc.bytes = append(enc.bytes, '"')
+	enc.checkBudget(mark)
+	return errInvalidRawJSON
+}
+
+// validRawJSON reports whether the value can be safely spliced into the
+// encoder's output. Unless the encoder was configured with StrictRawJSON, it
+// only checks that the first and last bytes are balanced.
+func (enc *jsonEncoder) validRawJSON(val []byte) bool {
+	if enc.strictRawJSON {
+		// Unmarshal checks that the whole input is valid before decoding it.
+		// (json.Valid would do, but it needs Go 1.9.)
+		var raw json.RawMessage
+		return json.Unmarshal(val, &raw) == nil
+	}
+	val = bytes.TrimSpace(val)
+	if len(val) == 0 {
+		return false
+	}
+	first, last := val[0], val[len(val)-1]
+	switch first {
+	case '{':
+		return last == '}'
+	case '[':
+		return last == ']'
+	case '"':
+		return len(val) > 1 && last == '"'
+	case 't', 'f', 'n':
+		s := string(val)
+		return s == "true" || s == "false" || s == "null"
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return '0' <= last && last <= '9'
+	default:
+		return false
+	}
+}
+
+// appendSingleLine appends a raw JSON value to dst, replacing any carriage
+// returns and newlines between tokens with spaces; those are insignificant
+// whitespace, but they'd split the entry across lines. It reports false if
+// the value has a raw line break inside a string, which isn't valid JSON.
+func appendSingleLine(dst, val []byte) ([]byte, bool) {
+	if bytes.IndexAny(val, "\r\n") < 0 {
+		return append(dst, val...), true
+	}
+	inString, escaped := false, false
+	for _, b := range val {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && b == '\\':
+			escaped = true
+		case b == '"':
+			inString = !inString
+		case b == '\r' || b == '\n':
+			if inString {
+				return dst, false
+			}
+			b = ' '
+		}
+		dst = append(dst, b)
+	}
+	return dst, true
+}
+
+// OpenNamespace adds a nested object under the given key; all subsequent
+// fields are added to the nested object until the entry is written. The key is
+// JSON-escaped.
+func (enc *jsonEncoder) OpenNamespace(key string) {
+	if !enc.reserveKey(key, 2) {
+		return
+	}
+	enc.addKey(key)
+	enc.bytes = append(enc.bytes, '{')
+	enc.markBracket()
+	enc.openNamespaces++
+}
+
+// Clone copies the current encoder, including any data already encoded. Rather
+// than copying the encoded fields, the clone shares them with its parent, so
+// cloning is cheap even when the parent has a lot of context. As a result,
+// the parent must not be freed while the clone is in use.
 func (enc *jsonEncoder) Clone() Encoder {
 	clone := jsonPool.Get().(*jsonEncoder)
 	clone.truncate()
-	clone.bytes = append(clone.bytes, enc.bytes...)
+	if len(enc.bytes) > 0 {
+		clone.prefix = enc
+		clone.prefixBytes = enc.bytes[:len(enc.bytes):len(enc.bytes)]
+		clone.prefixMarks = enc.marks[:len(enc.marks):len(enc.marks)]
+	} else {
+		// Skip over encoders with no fields of their own.
+		clone.prefix, clone.prefixBytes, clone.prefixMarks = enc.prefix, enc.prefixBytes, enc.prefixMarks
+	}
+	clone.openNamespaces = enc.openNamespaces
 	clone.messageF = enc.messageF
 	clone.timeF = enc.timeF
 	clone.levelF = enc.levelF
+	clone.callerF = enc.callerF
+	clone.stackF = enc.stackF
+	clone.binaryEnc = enc.binaryEnc
+	clone.byteLimit = enc.byteLimit
+	clone.markTruncated = enc.markTruncated
+	clone.strictRawJSON = enc.strictRawJSON
+	clone.nullNonFinite = enc.nullNonFinite
+	clone.safeIntegers = enc.safeIntegers
+	clone.htmlSafe = enc.htmlSafe
+	clone.dedupe = enc.dedupe
+	clone.sortKeys = enc.sortKeys
+	clone.replacements = enc.replacements[:len(enc.replacements):len(enc.replacements)]
+	clone.maxEntryBytes = enc.maxEntryBytes
+	clone.prefixLen = enc.prefixLen + len(enc.bytes)
+	clone.entryTruncated = enc.entryTruncated
+	clone.droppedBytes = enc.droppedBytes
 	return clone
 }
 
@@ -194,23 +733,51 @@ func (enc *jsonEncoder) Clone() Encoder {
 // the encoder's accumulated fields. It doesn't modify or lock the encoder's
 // underlying byte slice. It's safe to call from multiple goroutines, but it's
 // not safe to call WriteEntry while adding fields.
-func (enc *jsonEncoder) WriteEntry(sink io.Writer, msg string, lvl Level, t time.Time) error {
+func (enc *jsonEncoder) WriteEntry(sink io.Writer, ent Entry) error {
 	if sink == nil {
 		return errNilSink
 	}
 
 	final := jsonPool.Get().(*jsonEncoder)
 	final.truncate()
-	final.bytes = append(final.bytes, '{')
-	enc.levelF(lvl).AddTo(final)
-	enc.timeF(t).AddTo(final)
-	enc.messageF(msg).AddTo(final)
-	if len(enc.bytes) > 0 {
+	// The formatters' fields should respect the encoder's numeric and
+	// escaping options.
+	final.nullNonFinite = enc.nullNonFinite
+	final.safeIntegers = enc.safeIntegers
+	final.htmlSafe = enc.htmlSafe
+	final.sortKeys = false
+	final.maxEntryBytes = 0
+	msg, fits := enc.addHeader(final, ent)
+	if fits && (len(enc.bytes) > 0 || enc.prefix != nil) {
 		if len(final.bytes) > 1 {
 			// All the formatters may have been no-ops.
 			final.bytes = append(final.bytes, ',')
 		}
-		final.bytes = append(final.bytes, enc.bytes...)
+		switch {
+		case enc.sortKeys:
+			// Sorting closes any open namespaces.
+			final.bytes = enc.appendSorted(final.bytes)
+		case enc.dedupe:
+			final.bytes = enc.appendDeduped(final.bytes)
+		case len(enc.replacements) > 0:
+			final.bytes = enc.appendReplaced(final.bytes)
+		default:
+			final.bytes = enc.appendFields(final.bytes)
+		}
+		if !enc.sortKeys {
+			final.openNamespaces = enc.openNamespaces
+			final.closeOpenNamespaces()
+		}
+	}
+	var truncErr error
+	if enc.entryTruncated || !fits || len(msg) < len(ent.Message) {
+		originalSize := len(final.bytes) + 2 + enc.droppedBytes + len(ent.Message) - len(msg)
+		if !fits {
+			originalSize += enc.size() + 1
+		}
+		final.AddBool("truncated", true)
+		final.AddInt("originalSize", originalSize)
+		truncErr = fmt.Errorf("entry exceeded MaxEntryBytes(%v), truncated from at least %v bytes", enc.maxEntryBytes, originalSize)
 	}
 	final.bytes = append(final.bytes, '}', '\n')
 
@@ -223,22 +790,286 @@ func (enc *jsonEncoder) WriteEntry(sink io.Writer, msg string, lvl Level, t time
 	if n != expectedBytes {
 		return fmt.Errorf("incomplete write: only wrote %v of %v bytes", n, expectedBytes)
 	}
-	return nil
+	return truncErr
+}
+
+// addHeader adds the level, time, message, and caller and stack fields to the
+// final encoder. If the encoder has a MaxEntryBytes budget, it shortens the
+// message as necessary to make room for the encoder's fields and the
+// truncation marker. It returns the message it added, and whether the fields
+// still fit.
+func (enc *jsonEncoder) addHeader(final *jsonEncoder, ent Entry) (string, bool) {
+	msg := ent.Message
+	for {
+		final.truncate()
+		final.bytes = append(final.bytes, '{')
+		enc.levelF(ent.Level).AddTo(final)
+		enc.timeF(ent.Time).AddTo(final)
+		enc.messageF(msg).AddTo(final)
+		enc.addEntryMetadata(final, ent)
+		if enc.maxEntryBytes <= 0 {
+			return msg, true
+		}
+		// The fields, the comma before them, the closing brace and newline,
+		// and (if anything's been dropped) the truncation marker.
+		total := len(final.bytes) + enc.size() + 3
+		if enc.entryTruncated || len(msg) < len(ent.Message) {
+			total += _entryMarkerLen
+		}
+		over := total - enc.maxEntryBytes
+		if over <= 0 {
+			return msg, true
+		}
+		if len(msg) == 0 {
+			// Even without a message, the fields don't fit.
+			return msg, false
+		}
+		if len(msg) == len(ent.Message) && !enc.entryTruncated {
+			over += _entryMarkerLen
+		}
+		msg = truncateString(msg, len(msg)-over)
+	}
 }
 
 func (enc *jsonEncoder) truncate() {
 	enc.bytes = enc.bytes[:0]
+	enc.prefix = nil
+	enc.prefixBytes = nil
+	enc.openNamespaces = 0
+	enc.marks = enc.marks[:0]
+	enc.prefixMarks = nil
+	enc.replacements = nil
+	enc.prefixLen = 0
+	enc.depth = 0
+	enc.entryTruncated = false
+	enc.droppedBytes = 0
+}
+
+// appendFields appends all the encoder's fields, including those shared with
+// its ancestors, to dst.
+func (enc *jsonEncoder) appendFields(dst []byte) []byte {
+	return append(enc.appendPrefix(dst), enc.bytes...)
+}
+
+func (enc *jsonEncoder) appendPrefix(dst []byte) []byte {
+	if enc.prefix == nil {
+		return dst
+	}
+	return append(enc.prefix.appendPrefix(dst), enc.prefixBytes...)
+}
+
+func (enc *jsonEncoder) appendDeduped(dst []byte) []byte {
+	if enc.prefix == nil {
+		return appendDeduped(dst, enc.bytes, enc.openNamespaces)
+	}
+	fields := jsonPool.Get().(*jsonEncoder)
+	fields.truncate()
+	fields.bytes = enc.appendFields(fields.bytes)
+	dst = appendDeduped(dst, fields.bytes, enc.openNamespaces)
+	fields.Free()
+	return dst
+}
+
+func (enc *jsonEncoder) appendReplaced(dst []byte) []byte {
+	if enc.prefix == nil {
+		return appendReplaced(dst, enc.bytes, enc.openNamespaces, enc.replacements)
+	}
+	fields := jsonPool.Get().(*jsonEncoder)
+	fields.truncate()
+	fields.bytes = enc.appendFields(fields.bytes)
+	dst = appendReplaced(dst, fields.bytes, enc.openNamespaces, enc.replacements)
+	fields.Free()
+	return dst
+}
+
+func (enc *jsonEncoder) appendSorted(dst []byte) []byte {
+	if enc.prefix == nil {
+		return appendSorted(dst, enc.bytes, enc.marks, enc.dedupe, enc.replacements)
+	}
+	fields := jsonPool.Get().(*jsonEncoder)
+	fields.truncate()
+	fields.bytes = enc.appendFields(fields.bytes)
+	fields.marks = append(enc.appendPrefixMarks(fields.marks), enc.marks...)
+	dst = appendSorted(dst, fields.bytes, fields.marks, enc.dedupe, enc.replacements)
+	fields.Free()
+	return dst
+}
+
+func (enc *jsonEncoder) appendPrefixMarks(dst []jsonMark) []jsonMark {
+	if enc.prefix == nil {
+		return dst
+	}
+	return append(enc.prefix.appendPrefixMarks(dst), enc.prefixMarks...)
+}
+
+// markBracket records the bracket that was just written, if the encoder
+// sorts keys.
+func (enc *jsonEncoder) markBracket() {
+	if enc.sortKeys {
+		enc.marks = append(enc.marks, jsonMark{offset: enc.prefixLen + len(enc.bytes) - 1})
+	}
+}
+
+// addReplacement adds a Replace field to kv and records where it starts, so
+// that WriteEntry can drop earlier fields with the same key.
+func (enc *jsonEncoder) addReplacement(f Field, kv KeyValue) {
+	mark := len(enc.bytes)
+	f.AddTo(kv)
+	if len(enc.bytes) == mark {
+		// The field was skipped or dropped.
+		return
+	}
+	if enc.bytes[mark] == ',' {
+		mark++
+	}
+	enc.replacements = append(enc.replacements, enc.prefixLen+mark)
+}
+
+// lastByte returns the last encoded byte, including the shared prefix.
+func (enc *jsonEncoder) lastByte() (byte, bool) {
+	if n := len(enc.bytes); n > 0 {
+		return enc.bytes[n-1], true
+	}
+	if n := len(enc.prefixBytes); n > 0 {
+		return enc.prefixBytes[n-1], true
+	}
+	return 0, false
+}
+
+func (enc *jsonEncoder) closeOpenNamespaces() {
+	for i := 0; i < enc.openNamespaces; i++ {
+		enc.bytes = append(enc.bytes, '}')
+		enc.markBracket()
+	}
+	enc.openNamespaces = 0
 }
 
 func (enc *jsonEncoder) addKey(key string) {
-	last := len(enc.bytes) - 1
 	// At some point, we'll also want to support arrays.
-	if last >= 0 && enc.bytes[last] != '{' {
+	if last, ok := enc.lastByte(); ok && last != '{' {
 		enc.bytes = append(enc.bytes, ',')
 	}
+	start := len(enc.bytes)
 	enc.bytes = append(enc.bytes, '"')
 	enc.safeAddString(key)
-	enc.bytes = append(enc.bytes, '"', ':')
+	enc.bytes = append(enc.bytes, '"')
+	if enc.sortKeys {
+		enc.marks = append(enc.marks, jsonMark{offset: enc.prefixLen + start, keyEnd: enc.prefixLen + len(enc.bytes)})
+	}
+	enc.bytes = append(enc.bytes, ':')
+}
+
+// grow extends the internal buffer by n bytes and returns the newly-added
+// region, so that encoders can write directly into it.
+func (enc *jsonEncoder) grow(n int) []byte {
+	start := len(enc.bytes)
+	if cap(enc.bytes)-start < n {
+		bigger := make([]byte, start, 2*cap(enc.bytes)+n)
+		copy(bigger, enc.bytes)
+		enc.bytes = bigger
+	}
+	enc.bytes = enc.bytes[:start+n]
+	return enc.bytes[start:]
+}
+
+// exceedsLimit reports whether the value is longer than the encoder's
+// ByteLimit, if any.
+func (enc *jsonEncoder) exceedsLimit(val []byte) bool {
+	return enc.byteLimit > 0 && len(val) > enc.byteLimit
+}
+
+// size returns the number of bytes the encoder's fields will occupy in an
+// entry, including the brackets needed to close any unfinished objects,
+// arrays, and namespaces.
+func (enc *jsonEncoder) size() int {
+	return enc.prefixLen + len(enc.bytes) + enc.depth + enc.openNamespaces
+}
+
+// reserve reports whether n more bytes of fields fit within the encoder's
+// MaxEntryBytes budget. Once a value doesn't fit, the entry is marked as
+// truncated and all subsequent values are dropped, so that nested objects are
+// cut off cleanly rather than riddled with holes.
+func (enc *jsonEncoder) reserve(n int) bool {
+	if enc.maxEntryBytes <= 0 {
+		return true
+	}
+	if !enc.entryTruncated && enc.size()+n <= enc.maxEntryBytes-_entryLimitReserve {
+		return true
+	}
+	enc.entryTruncated = true
+	enc.droppedBytes += n
+	return false
+}
+
+// reserveKey is like reserve, but also accounts for the key, the quotes and
+// colon around it, and the preceding comma.
+func (enc *jsonEncoder) reserveKey(key string, n int) bool {
+	return enc.reserve(len(key) + 4 + n)
+}
+
+// checkBudget removes the value encoded since mark if escaping or formatting
+// pushed the fields past their MaxEntryBytes budget. Since reserve only
+// checks a lower bound on each value's size, this keeps the budget exact.
+func (enc *jsonEncoder) checkBudget(mark int) {
+	if enc.maxEntryBytes <= 0 || enc.size() <= enc.maxEntryBytes-_entryLimitReserve {
+		return
+	}
+	enc.droppedBytes += len(enc.bytes) - mark
+	enc.bytes = enc.bytes[:mark]
+	n := len(enc.marks)
+	for n > 0 && enc.marks[n-1].offset >= enc.prefixLen+mark {
+		n--
+	}
+	enc.marks = enc.marks[:n]
+	enc.entryTruncated = true
+}
+
+// addTruncated closes a string value, marking truncated values with a suffix
+// and (optionally) a companion field.
+func (enc *jsonEncoder) addTruncated(key string, truncated bool) {
+	if !truncated {
+		enc.bytes = append(enc.bytes, '"')
+		return
+	}
+	enc.bytes = append(enc.bytes, _truncatedSuffix...)
+	enc.bytes = append(enc.bytes, '"')
+	if enc.markTruncated {
+		enc.AddBool(key+"Truncated", true)
+	}
+}
+
+// addEntryMetadata adds the fields produced by the encoder's CallerFormatter
+// and StackFormatter, if any, to the final encoder.
+func (enc *jsonEncoder) addEntryMetadata(final *jsonEncoder, ent Entry) {
+	if enc.callerF != nil && ent.Caller.Defined {
+		enc.callerF(ent.Caller).AddTo(final)
+	}
+	if enc.stackF != nil && ent.Stack != "" {
+		for _, f := range enc.stackF(ent) {
+			f.AddTo(final)
+		}
+	}
+}
+
+// truncateString shortens s to at most n bytes without splitting a
+// multi-byte character.
+func truncateString(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func (enc *jsonEncoder) addElementSeparator() {
+	if last, ok := enc.lastByte(); ok && last != '[' {
+		enc.bytes = append(enc.bytes, ',')
+	}
 }
 
 // safeAddString JSON-escapes a string and appends it to the internal buffer.
@@ -246,35 +1077,99 @@ func (enc *jsonEncoder) addKey(key string) {
 // protect the user from browser vulnerabilities or JSONP-related problems.
 func (enc *jsonEncoder) safeAddString(s string) {
 	for i := 0; i < len(s); {
-		if b := s[i]; b < utf8.RuneSelf {
+		if enc.tryAddRuneSelf(s[i]) {
 			i++
-			if 0x20 <= b && b != '\\' && b != '"' {
-				enc.bytes = append(enc.bytes, b)
-				continue
-			}
-			switch b {
-			case '\\', '"':
-				enc.bytes = append(enc.bytes, '\\', b)
-			case '\n':
-				enc.bytes = append(enc.bytes, '\\', 'n')
-			case '\r':
-				enc.bytes = append(enc.bytes, '\\', 'r')
-			case '\t':
-				enc.bytes = append(enc.bytes, '\\', 't')
-			default:
-				// Encode bytes < 0x20, except for the escape sequences above.
-				enc.bytes = append(enc.bytes, `\u00`...)
-				enc.bytes = append(enc.bytes, _hex[b>>4], _hex[b&0xF])
-			}
 			continue
 		}
-		c, size := utf8.DecodeRuneInString(s[i:])
-		if c == utf8.RuneError && size == 1 {
-			enc.bytes = append(enc.bytes, `\ufffd`...)
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if enc.tryAddRuneError(r, size) {
 			i++
 			continue
 		}
+		if enc.tryAddLineSeparator(r) {
+			i += size
+			continue
+		}
+		enc.bytes = append(enc.bytes, s[i:i+size]...)
+		i += size
+	}
+}
+
+// safeAddByteString is no-alloc equivalent of safeAddString(string(s)) for s
+// []byte.
+func (enc *jsonEncoder) safeAddByteString(s []byte) {
+	for i := 0; i < len(s); {
+		if enc.tryAddRuneSelf(s[i]) {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if enc.tryAddRuneError(r, size) {
+			i++
+			continue
+		}
+		if enc.tryAddLineSeparator(r) {
+			i += size
+			continue
+		}
 		enc.bytes = append(enc.bytes, s[i:i+size]...)
 		i += size
 	}
 }
+
+// tryAddRuneSelf appends b if it's valid UTF-8 character represented in a
+// single byte, escaping it if necessary.
+func (enc *jsonEncoder) tryAddRuneSelf(b byte) bool {
+	if b >= utf8.RuneSelf {
+		return false
+	}
+	if 0x20 <= b && b != '\\' && b != '"' && !(enc.htmlSafe && isHTMLSpecial(b)) {
+		enc.bytes = append(enc.bytes, b)
+		return true
+	}
+	switch b {
+	case '\\', '"':
+		enc.bytes = append(enc.bytes, '\\', b)
+	case '\n':
+		enc.bytes = append(enc.bytes, '\\', 'n')
+	case '\r':
+		enc.bytes = append(enc.bytes, '\\', 'r')
+	case '\t':
+		enc.bytes = append(enc.bytes, '\\', 't')
+	default:
+		// Encode bytes < 0x20 (and, in HTML-safe mode, <, >, and &), except
+		// for the escape sequences above.
+		enc.bytes = append(enc.bytes, `\u00`...)
+		enc.bytes = append(enc.bytes, _hex[b>>4], _hex[b&0xF])
+	}
+	return true
+}
+
+// tryAddRuneError replaces a byte that isn't valid UTF-8 with the Unicode
+// replacement character.
+func (enc *jsonEncoder) tryAddRuneError(r rune, size int) bool {
+	if r == utf8.RuneError && size == 1 {
+		enc.bytes = append(enc.bytes, `\ufffd`...)
+		return true
+	}
+	return false
+}
+
+// tryAddLineSeparator escapes U+2028 and U+2029. They're valid in JSON
+// strings, but not in JavaScript string literals, so they break consumers that
+// evaluate JSON as JavaScript.
+func (enc *jsonEncoder) tryAddLineSeparator(r rune) bool {
+	switch r {
+	case '\u2028':
+		enc.bytes = append(enc.bytes, `\u2028`...)
+	case '\u2029':
+		enc.bytes = append(enc.bytes, `\u2029`...)
+	default:
+		return false
+	}
+	return true
+}
+
+func isHTMLSpecial(b byte) bool {
+	return b == '<' || b == '>' || b == '&'
+}
@@ -51,7 +51,7 @@ func BenchmarkZapJSONInts(b *testing.B) {
 		for pb.Next() {
 			enc := NewJSONEncoder()
 			enc.AddObject("ints", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
-			enc.WriteEntry(ioutil.Discard, "fake", DebugLevel, ts)
+			enc.WriteEntry(ioutil.Discard, Entry{Message: "fake", Level: DebugLevel, Time: ts})
 			enc.Free()
 		}
 	})
@@ -63,7 +63,29 @@ func BenchmarkZapJSONStrings(b *testing.B) {
 		for pb.Next() {
 			enc := NewJSONEncoder()
 			enc.AddObject("strings", []string{"bar 1", "bar 2", "bar 3", "bar 4", "bar 5", "bar 6", "bar 7", "bar 8", "bar 9", "bar 10"})
-			enc.WriteEntry(ioutil.Discard, "fake", DebugLevel, ts)
+			enc.WriteEntry(ioutil.Discard, Entry{Message: "fake", Level: DebugLevel, Time: ts})
+			enc.Free()
+		}
+	})
+}
+
+func BenchmarkZapJSONASCIIStrings(b *testing.B) {
+	benchmarkJSONASCIIStrings(b)
+}
+
+func BenchmarkZapJSONHTMLSafeASCIIStrings(b *testing.B) {
+	benchmarkJSONASCIIStrings(b, HTMLSafe())
+}
+
+func benchmarkJSONASCIIStrings(b *testing.B, opts ...JSONOption) {
+	ts := time.Unix(0, 0)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			enc := NewJSONEncoder(opts...)
+			enc.AddString("string1", "Lorem ipsum dolor sit amet, consectetur adipiscing elit.")
+			enc.AddString("string2", "Sed do eiusmod tempor incididunt ut labore et dolore.")
+			enc.AddByteString("string3", []byte("Ut enim ad minim veniam, quis nostrud exercitation."))
+			enc.WriteEntry(ioutil.Discard, Entry{Message: "fake", Level: DebugLevel, Time: ts})
 			enc.Free()
 		}
 	})
@@ -83,7 +105,7 @@ func BenchmarkZapJSON(b *testing.B) {
 			enc.AddString("string3", "🤔")
 			enc.AddString("string4", "🙊")
 			enc.AddBool("bool", true)
-			enc.WriteEntry(ioutil.Discard, "fake", DebugLevel, ts)
+			enc.WriteEntry(ioutil.Discard, Entry{Message: "fake", Level: DebugLevel, Time: ts})
 			enc.Free()
 		}
 	})
@@ -113,3 +135,63 @@ func BenchmarkStandardJSON(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkZapJSONDuplicateKeys(b *testing.B) {
+	benchmarkJSONDuplicateKeys(b)
+}
+
+func BenchmarkZapJSONDeduplicateKeys(b *testing.B) {
+	benchmarkJSONDuplicateKeys(b, DeduplicateKeys())
+}
+
+func BenchmarkZapJSONUnsortedKeys(b *testing.B) {
+	benchmarkJSONSortKeys(b)
+}
+
+func BenchmarkZapJSONSortKeys(b *testing.B) {
+	benchmarkJSONSortKeys(b, SortKeys())
+}
+
+func benchmarkJSONSortKeys(b *testing.B, opts ...JSONOption) {
+	ts := time.Unix(0, 0)
+	enc := NewJSONEncoder(opts...)
+	enc.AddString("service", "api")
+	enc.AddString("host", "api-1.example.com")
+	enc.AddInt("pid", 42)
+	enc.AddMarshaler("user", LogMarshalerFunc(func(kv KeyValue) error {
+		kv.AddString("name", "jane")
+		kv.AddInt("id", 1)
+		return nil
+	}))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			clone := enc.Clone()
+			clone.AddString("route", "/checkout")
+			clone.AddInt("status", 200)
+			clone.AddString("method", "POST")
+			clone.AddInt64("bytes", 1024)
+			clone.WriteEntry(ioutil.Discard, Entry{Message: "fake", Level: DebugLevel, Time: ts})
+			clone.Free()
+		}
+	})
+}
+
+func benchmarkJSONDuplicateKeys(b *testing.B, opts ...JSONOption) {
+	ts := time.Unix(0, 0)
+	enc := NewJSONEncoder(opts...)
+	enc.AddString("component", "db")
+	enc.AddInt("shard", 1)
+	enc.AddString("host", "db-1.example.com")
+	enc.AddString("component", "sql")
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			clone := enc.Clone()
+			clone.AddString("query", "SELECT 1")
+			clone.AddInt("shard", 2)
+			clone.WriteEntry(ioutil.Discard, Entry{Message: "fake", Level: DebugLevel, Time: ts})
+			clone.Free()
+		}
+	})
+}
@@ -21,11 +21,14 @@
 package zap
 
 import (
+	"bytes"
 	"encoding/json"
 	"math/rand"
 	"reflect"
 	"testing"
 	"testing/quick"
+	"time"
+	"unicode/utf8"
 )
 
 func encodeString(s string) []byte {
@@ -81,3 +84,84 @@ func TestJSONQuick(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+// encodeEntry writes a complete log entry, using the input as the message,
+// as keys, and as values at every level of nesting.
+func encodeEntry(s []byte, opts ...JSONOption) []byte {
+	str := string(s)
+	enc := NewJSONEncoder(append(opts, NoTime())...)
+	defer enc.Free()
+	enc.AddString(str, str)
+	enc.AddByteString("bytes", s)
+	enc.AddMarshaler("nested", LogMarshalerFunc(func(kv KeyValue) error {
+		kv.AddString(str, str)
+		kv.OpenNamespace(str)
+		return kv.AddArray("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+			arr.AppendString(str)
+			arr.AppendByteString(s)
+			return nil
+		}))
+	}))
+	enc.AddObject("reflected", map[string]string{str: str})
+
+	buf := &bytes.Buffer{}
+	enc.WriteEntry(buf, Entry{Message: str, Level: InfoLevel, Time: time.Unix(0, 0)})
+	return buf.Bytes()
+}
+
+func validEntry(s []byte, opts ...JSONOption) bool {
+	// Whatever the input, the output must be valid UTF-8 and valid JSON, and it
+	// must never contain raw line or paragraph separators.
+	encoded := encodeEntry(s, opts...)
+	if !utf8.Valid(encoded) || bytes.ContainsAny(encoded, "\u2028\u2029") {
+		return false
+	}
+	var decoded map[string]interface{}
+	return json.Unmarshal(encoded, &decoded) == nil
+}
+
+func validHTMLSafeEntry(s []byte) bool {
+	if !validEntry(s, HTMLSafe()) {
+		return false
+	}
+	return !bytes.ContainsAny(encodeEntry(s, HTMLSafe()), "<>&")
+}
+
+func TestJSONQuickArbitraryBytes(t *testing.T) {
+	// Random byte slices are mostly invalid UTF-8.
+	err := quick.Check(func(s []byte) bool { return validEntry(s) }, &quick.Config{MaxCountScale: 10.0})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = quick.Check(func(s string) bool { return validEntry([]byte(s)) }, &quick.Config{MaxCountScale: 10.0})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	err = quick.Check(validHTMLSafeEntry, &quick.Config{MaxCountScale: 10.0})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestJSONArbitraryBytesEdgeCases(t *testing.T) {
+	inputs := []string{
+		"",
+		"\x00",
+		"\xff\xfe",
+		"\xed\xa0\x80",
+		"\u2028\u2029",
+		"\xe2\x80", // truncated U+2028
+		"</script>&amp;",
+		`"\`,
+	}
+	for _, in := range inputs {
+		if !validEntry([]byte(in)) {
+			t.Errorf("Invalid output for input %q: %s", in, encodeEntry([]byte(in)))
+		}
+		if !validHTMLSafeEntry([]byte(in)) {
+			t.Errorf("Invalid HTML-safe output for input %q: %s", in, encodeEntry([]byte(in), HTMLSafe()))
+		}
+	}
+}
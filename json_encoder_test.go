@@ -22,6 +22,9 @@ package zap
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -112,6 +115,11 @@ func TestJSONEncoderFields(t *testing.T) {
 		{"string", `"k":"v"`, func(e Encoder) { e.AddString("k", "v") }},
 		{"string", `"k":""`, func(e Encoder) { e.AddString("k", "") }},
 		{"string", `"k\\":"v\\"`, func(e Encoder) { e.AddString(`k\`, `v\`) }},
+		{"byte string", `"k":"v"`, func(e Encoder) { e.AddByteString("k", []byte("v")) }},
+		{"byte string", `"k":""`, func(e Encoder) { e.AddByteString("k", nil) }},
+		{"byte string", `"k\\":"v\\\ufffd"`, func(e Encoder) { e.AddByteString(`k\`, []byte("v\\\xff")) }},
+		{"binary", `"k":"YWIxMg=="`, func(e Encoder) { e.AddBinary("k", []byte("ab12")) }},
+		{"binary", `"k":""`, func(e Encoder) { e.AddBinary("k", nil) }},
 		{"bool", `"k":true`, func(e Encoder) { e.AddBool("k", true) }},
 		{"bool", `"k":false`, func(e Encoder) { e.AddBool("k", false) }},
 		{"bool", `"k\\":true`, func(e Encoder) { e.AddBool(`k\`, true) }},
@@ -131,6 +139,9 @@ func TestJSONEncoderFields(t *testing.T) {
 		{"float64", `"k":"NaN"`, func(e Encoder) { e.AddFloat64("k", math.NaN()) }},
 		{"float64", `"k":"+Inf"`, func(e Encoder) { e.AddFloat64("k", math.Inf(1)) }},
 		{"float64", `"k":"-Inf"`, func(e Encoder) { e.AddFloat64("k", math.Inf(-1)) }},
+		{"float32", `"k":1.1`, func(e Encoder) { e.AddFloat32("k", 1.1) }},
+		{"float32", `"k":"NaN"`, func(e Encoder) { e.AddFloat32("k", float32(math.NaN())) }},
+		{"float32", `"k":"+Inf"`, func(e Encoder) { e.AddFloat32("k", float32(math.Inf(1))) }},
 		{"marshaler", `"k":{"loggable":"yes"}`, func(e Encoder) {
 			assert.NoError(t, e.AddMarshaler("k", loggable{true}), "Unexpected error calling MarshalLog.")
 		}},
@@ -165,17 +176,12 @@ func TestJSONWriteEntry(t *testing.T) {
 	entry := &Entry{Level: InfoLevel, Message: `hello\`, Time: time.Unix(0, 0)}
 	enc := NewJSONEncoder()
 
-	assert.Equal(t, errNilSink, enc.WriteEntry(
-		nil,
-		entry.Message,
-		entry.Level,
-		entry.Time,
-	), "Expected an error writing to a nil sink.")
+	assert.Equal(t, errNilSink, enc.WriteEntry(nil, *entry), "Expected an error writing to a nil sink.")
 
 	// Messages should be escaped.
 	sink := &testBuffer{}
 	enc.AddString("foo", "bar")
-	err := enc.WriteEntry(sink, entry.Message, entry.Level, entry.Time)
+	err := enc.WriteEntry(sink, *entry)
 	assert.NoError(t, err, "WriteEntry returned an unexpected error.")
 	assert.Equal(
 		t,
@@ -186,7 +192,7 @@ func TestJSONWriteEntry(t *testing.T) {
 	// We should be able to re-use the encoder, preserving the accumulated
 	// fields.
 	sink.Reset()
-	err = enc.WriteEntry(sink, entry.Message, entry.Level, time.Unix(100, 0))
+	err = enc.WriteEntry(sink, Entry{Message: entry.Message, Level: entry.Level, Time: time.Unix(100, 0)})
 	assert.NoError(t, err, "WriteEntry returned an unexpected error.")
 	assert.Equal(
 		t,
@@ -195,12 +201,62 @@ func TestJSONWriteEntry(t *testing.T) {
 	)
 }
 
+func TestJSONNamespaces(t *testing.T) {
+	tests := []struct {
+		desc     string
+		expected string
+		f        func(Encoder)
+	}{
+		{"empty namespace", `{"level":"info","msg":"ns","outer":{}}`, func(e Encoder) {
+			e.OpenNamespace("outer")
+		}},
+		{"single namespace", `{"level":"info","msg":"ns","foo":1,"outer":{"bar":2}}`, func(e Encoder) {
+			e.AddInt("foo", 1)
+			e.OpenNamespace("outer")
+			e.AddInt("bar", 2)
+		}},
+		{"nested namespaces", `{"level":"info","msg":"ns","outer":{"foo":1,"inner":{"bar":2}}}`, func(e Encoder) {
+			e.OpenNamespace("outer")
+			e.AddInt("foo", 1)
+			e.OpenNamespace("inner")
+			e.AddInt("bar", 2)
+		}},
+		{"namespace in marshaler", `{"level":"info","msg":"ns","m":{"inner":{"foo":1}},"bar":2}`, func(e Encoder) {
+			e.AddMarshaler("m", LogMarshalerFunc(func(kv KeyValue) error {
+				kv.OpenNamespace("inner")
+				kv.AddInt("foo", 1)
+				return nil
+			}))
+			e.AddInt("bar", 2)
+		}},
+		{"marshaler in namespace", `{"level":"info","msg":"ns","outer":{"m":{"foo":1},"bar":2}}`, func(e Encoder) {
+			e.OpenNamespace("outer")
+			e.AddMarshaler("m", LogMarshalerFunc(func(kv KeyValue) error {
+				kv.AddInt("foo", 1)
+				return nil
+			}))
+			e.AddInt("bar", 2)
+		}},
+	}
+
+	for _, tt := range tests {
+		enc := NewJSONEncoder(NoTime())
+		tt.f(enc)
+		for _, e := range []Encoder{enc, enc.Clone()} {
+			buf := &testBuffer{}
+			require.NoError(t, e.WriteEntry(buf, Entry{Message: "ns", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+			assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output with %s.", tt.desc)
+		}
+		enc.Free()
+	}
+}
+
 func TestJSONWriteEntryLargeTimestamps(t *testing.T) {
 	// Ensure that we don't switch to exponential notation when encoding dates far in the future.
 	sink := &testBuffer{}
 	enc := NewJSONEncoder()
 	future := time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
-	require.NoError(t, enc.WriteEntry(sink, "fake msg", DebugLevel, future))
+	require.NoError(t, enc.WriteEntry(sink, Entry{Message: "fake msg", Level: DebugLevel, Time: future}))
 	assert.Contains(
 		t,
 		sink.Stripped(),
@@ -222,6 +278,43 @@ func TestJSONClone(t *testing.T) {
 	assertJSON(t, `"baz":"bing"`, clone.(*jsonEncoder))
 }
 
+func TestJSONCloneSharesFields(t *testing.T) {
+	parent := newJSONEncoder(NoTime())
+	defer parent.Free()
+	parent.AddString("foo", "bar")
+	parent.OpenNamespace("ns")
+	parent.AddInt("a", 1)
+
+	child := parent.Clone().(*jsonEncoder)
+	defer child.Free()
+	assert.Empty(t, child.bytes, "Expected the clone to share, rather than copy, its parent's fields.")
+
+	// Fields added after cloning shouldn't leak between encoders, even though
+	// the parent has plenty of spare capacity.
+	parent.AddInt("b", 2)
+	child.AddInt("c", 3)
+	empty := child.Clone().(*jsonEncoder)
+	defer empty.Free()
+	grandchild := empty.Clone().(*jsonEncoder)
+	defer grandchild.Free()
+	grandchild.AddInt("d", 4)
+
+	tests := []struct {
+		enc      *jsonEncoder
+		expected string
+	}{
+		{parent, `{"level":"info","msg":"","foo":"bar","ns":{"a":1,"b":2}}`},
+		{child, `{"level":"info","msg":"","foo":"bar","ns":{"a":1,"c":3}}`},
+		{empty, `{"level":"info","msg":"","foo":"bar","ns":{"a":1,"c":3}}`},
+		{grandchild, `{"level":"info","msg":"","foo":"bar","ns":{"a":1,"c":3,"d":4}}`},
+	}
+	for i, tt := range tests {
+		buf := &testBuffer{}
+		require.NoError(t, tt.enc.WriteEntry(buf, Entry{Message: "", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+		assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output from encoder %d.", i)
+	}
+}
+
 func TestJSONWriteEntryFailure(t *testing.T) {
 	withJSONEncoder(func(enc *jsonEncoder) {
 		tests := []struct {
@@ -232,7 +325,7 @@ func TestJSONWriteEntryFailure(t *testing.T) {
 			{spywrite.ShortWriter{}, "Expected an error on partial writes to sink."},
 		}
 		for _, tt := range tests {
-			err := enc.WriteEntry(tt.sink, "hello", InfoLevel, time.Unix(0, 0))
+			err := enc.WriteEntry(tt.sink, Entry{Message: "hello", Level: InfoLevel, Time: time.Unix(0, 0)})
 			assert.Error(t, err, tt.msg)
 		}
 	})
@@ -270,15 +363,72 @@ func TestJSONEscaping(t *testing.T) {
 		// Decodes to (RuneError, 1)
 		"\xed\xa0\x80":    `\ufffd\ufffd\ufffd`,
 		"foo\xed\xa0\x80": `foo\ufffd\ufffd\ufffd`,
+		// Line and paragraph separators are valid JSON, but they terminate
+		// JavaScript string literals.
+		"\u2028":             `\u2028`,
+		"\u2029":             `\u2029`,
+		"foo\u2028bar\u2029": `foo\u2028bar\u2029`,
 	}
 	enc := newJSONEncoder()
 	for input, output := range cases {
 		enc.truncate()
 		enc.safeAddString(input)
 		assertJSON(t, output, enc)
+
+		enc.truncate()
+		enc.safeAddByteString([]byte(input))
+		assertJSON(t, output, enc)
 	}
 }
 
+func TestJSONHTMLSafeEscaping(t *testing.T) {
+	cases := map[string]string{
+		`foo`:            `foo`,
+		"<":              `\u003c`,
+		">":              `\u003e`,
+		"&":              `\u0026`,
+		`<a href="x&y">`: `\u003ca href=\"x\u0026y\"\u003e`,
+		"\u2028":         `\u2028`,
+		"\n":             `\n`,
+	}
+	enc := newJSONEncoder(HTMLSafe())
+	for input, output := range cases {
+		enc.truncate()
+		enc.safeAddString(input)
+		assertJSON(t, output, enc)
+
+		enc.truncate()
+		enc.safeAddByteString([]byte(input))
+		assertJSON(t, output, enc)
+	}
+}
+
+func TestJSONHTMLSafeEntries(t *testing.T) {
+	enc := newJSONEncoder(NoTime(), HTMLSafe())
+	defer enc.Free()
+	enc.AddString("<k>", "a&b")
+	enc.AddByteString("bytes", []byte("<b>"))
+	enc.AddMarshaler("nested", LogMarshalerFunc(func(kv KeyValue) error {
+		kv.AddString("html", "</script>")
+		return kv.AddArray("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+			arr.AppendString("<&>")
+			return nil
+		}))
+	}))
+	clone := enc.Clone()
+	defer clone.Free()
+
+	buf := &testBuffer{}
+	require.NoError(t, clone.WriteEntry(buf, Entry{Message: "<msg>", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.Equal(
+		t,
+		`{"level":"info","msg":"\u003cmsg\u003e","\u003ck\u003e":"a\u0026b","bytes":"\u003cb\u003e",`+
+			`"nested":{"html":"\u003c/script\u003e","arr":["\u003c\u0026\u003e"]}}`,
+		buf.Stripped(),
+		"Expected HTML-safe mode to apply to keys, messages, and nested values.",
+	)
+}
+
 func TestJSONOptions(t *testing.T) {
 	root := NewJSONEncoder(
 		MessageKey("the-message"),
@@ -288,7 +438,7 @@ func TestJSONOptions(t *testing.T) {
 
 	for _, enc := range []Encoder{root, root.Clone()} {
 		buf := &bytes.Buffer{}
-		enc.WriteEntry(buf, "fake msg", DebugLevel, epoch)
+		enc.WriteEntry(buf, Entry{Message: "fake msg", Level: DebugLevel, Time: epoch})
 		assert.Equal(
 			t,
 			`{"the-level":"debug","the-timestamp":"1970-01-01T00:00:00Z","the-message":"fake msg"}`+"\n",
@@ -297,3 +447,436 @@ func TestJSONOptions(t *testing.T) {
 		)
 	}
 }
+
+func TestJSONBinaryRoundTrip(t *testing.T) {
+	// Every possible byte value, plus enough data to force the buffer to grow.
+	val := make([]byte, 4*_initialBufSize)
+	for i := range val {
+		val[i] = byte(i)
+	}
+
+	tests := []struct {
+		opts   []JSONOption
+		decode func(string) ([]byte, error)
+	}{
+		{nil, base64.StdEncoding.DecodeString},
+		{[]JSONOption{Base64Encoding}, base64.StdEncoding.DecodeString},
+		{[]JSONOption{HexEncoding}, hex.DecodeString},
+	}
+
+	for _, tt := range tests {
+		for _, b := range [][]byte{val, val[:1], val[:2], val[:3], {}} {
+			enc := newJSONEncoder(append(tt.opts, NoTime())...)
+			enc.AddBinary("k", b)
+
+			buf := &testBuffer{}
+			require.NoError(t, enc.WriteEntry(buf, Entry{Message: "", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+			var parsed struct{ K string }
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &parsed), "Binary data produced invalid JSON.")
+			decoded, err := tt.decode(parsed.K)
+			require.NoError(t, err, "Couldn't decode encoded binary data.")
+			assert.Equal(t, b, decoded, "Binary data didn't survive a round trip.")
+			enc.Free()
+		}
+	}
+}
+
+func TestJSONByteLimit(t *testing.T) {
+	tests := []struct {
+		desc     string
+		opts     []JSONOption
+		expected string
+		f        func(Encoder)
+	}{
+		{"short binary", []JSONOption{ByteLimit(3)}, `"k":"Zm9v"`, func(e Encoder) { e.AddBinary("k", []byte("foo")) }},
+		{"long binary", []JSONOption{ByteLimit(3)}, `"k":"Zm9v..."`, func(e Encoder) { e.AddBinary("k", []byte("foobar")) }},
+		{"long hex binary", []JSONOption{ByteLimit(3), HexEncoding}, `"k":"666f6f..."`, func(e Encoder) { e.AddBinary("k", []byte("foobar")) }},
+		{"no limit", []JSONOption{ByteLimit(0)}, `"k":"Zm9vYmFy"`, func(e Encoder) { e.AddBinary("k", []byte("foobar")) }},
+		{"short byte string", []JSONOption{ByteLimit(3)}, `"k":"foo"`, func(e Encoder) { e.AddByteString("k", []byte("foo")) }},
+		{"long byte string", []JSONOption{ByteLimit(3)}, `"k":"foo..."`, func(e Encoder) { e.AddByteString("k", []byte("foobar")) }},
+		{"multi-byte boundary", []JSONOption{ByteLimit(3)}, `"k":"f..."`, func(e Encoder) { e.AddByteString("k", []byte("f\u2603")) }},
+		{"invalid UTF-8", []JSONOption{ByteLimit(2)}, `"k":"\ufffd\ufffd..."`, func(e Encoder) { e.AddByteString("k", []byte("\x80\x80\x80")) }},
+		{"marked binary", []JSONOption{ByteLimit(3), MarkTruncated()}, `"k":"Zm9v...","kTruncated":true,"after":1`, func(e Encoder) {
+			e.AddBinary("k", []byte("foobar"))
+			e.AddInt("after", 1)
+		}},
+		{"marked byte string", []JSONOption{ByteLimit(3), MarkTruncated()}, `"k":"foo...","kTruncated":true`, func(e Encoder) { e.AddByteString("k", []byte("foobar")) }},
+		{"marked but short", []JSONOption{ByteLimit(3), MarkTruncated()}, `"k":"foo"`, func(e Encoder) { e.AddByteString("k", []byte("foo")) }},
+		{"strings unaffected", []JSONOption{ByteLimit(3), MarkTruncated()}, `"k":"foobar"`, func(e Encoder) { e.AddString("k", "foobar") }},
+	}
+
+	for _, tt := range tests {
+		root := newJSONEncoder(tt.opts...)
+		for _, enc := range []Encoder{root, root.Clone()} {
+			tt.f(enc)
+			assert.Equal(t, tt.expected, string(enc.(*jsonEncoder).bytes), "Unexpected output with a ByteLimit: %s.", tt.desc)
+			enc.Free()
+		}
+	}
+}
+
+func TestJSONStrictRawJSON(t *testing.T) {
+	tests := []struct {
+		raw   string
+		valid bool
+	}{
+		{`{"foo":[1,2]}`, true},
+		{` "bar" `, true},
+		{`1e3`, true},
+		{"{\n\"foo\": 1\r\n}", true},
+		{`{"foo":}`, false},
+		{`[1,2}]`, false},
+		{`"unterminated\"`, false},
+		{`12a3`, false},
+	}
+
+	for _, tt := range tests {
+		lax, strict := newJSONEncoder(), newJSONEncoder(StrictRawJSON())
+		err := strict.AddRawJSON("k", []byte(tt.raw))
+		if tt.valid {
+			assert.NoError(t, err, "Unexpected error adding valid raw JSON %q.", tt.raw)
+			assert.NoError(t, lax.AddRawJSON("k", []byte(tt.raw)), "Unexpected error adding valid raw JSON %q without strict checking.", tt.raw)
+			assert.Equal(t, string(lax.bytes), string(strict.bytes), "Expected strict and lax checking to produce the same output for %q.", tt.raw)
+		} else {
+			assert.Equal(t, errInvalidRawJSON, err, "Expected an error adding invalid raw JSON %q.", tt.raw)
+		}
+
+		// The output must remain valid JSON either way.
+		clone := strict.Clone()
+		buf := &testBuffer{}
+		require.NoError(t, clone.WriteEntry(buf, Entry{Message: "", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+		var parsed map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &parsed), "Strict raw JSON checking produced invalid JSON for %q.", tt.raw)
+		clone.Free()
+		lax.Free()
+		strict.Free()
+	}
+}
+
+func TestJSONNonFiniteFloats(t *testing.T) {
+	fieldsFor := func(f float64) []Field {
+		return []Field{
+			Float64("float64", f),
+			Float32("float32", float32(f)),
+			Float64s("float64s", []float64{1, f}),
+			Reflect("reflect", f),
+			Reflect("reflect32", float32(f)),
+			Any("any", f),
+			Map("map", map[string]interface{}{"f": f, "fs": []interface{}{f}}),
+		}
+	}
+
+	tests := []struct {
+		val      float64
+		opts     []JSONOption
+		expected interface{}
+	}{
+		{math.NaN(), nil, "NaN"},
+		{math.Inf(1), nil, "+Inf"},
+		{math.Inf(-1), nil, "-Inf"},
+		{math.NaN(), []JSONOption{NullNonFiniteFloats()}, nil},
+		{math.Inf(1), []JSONOption{NullNonFiniteFloats()}, nil},
+		{math.Inf(-1), []JSONOption{NullNonFiniteFloats()}, nil},
+	}
+
+	for _, tt := range tests {
+		enc := newJSONEncoder(append(tt.opts, NoTime())...)
+		addFields(enc, fieldsFor(tt.val))
+		buf := &testBuffer{}
+		require.NoError(t, enc.WriteEntry(buf, Entry{Message: "floats", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+		enc.Free()
+
+		var parsed map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &parsed), "Encoding %v produced invalid JSON: %s", tt.val, buf.Stripped())
+		for _, key := range []string{"float64", "float32", "reflect", "reflect32", "any"} {
+			assert.Equal(t, tt.expected, parsed[key], "Unexpected encoding of %v under key %q.", tt.val, key)
+		}
+		assert.Equal(t, []interface{}{1.0, tt.expected}, parsed["float64s"], "Unexpected encoding of %v in an array.", tt.val)
+		assert.Equal(t, map[string]interface{}{
+			"f":  tt.expected,
+			"fs": []interface{}{tt.expected},
+		}, parsed["map"], "Unexpected encoding of %v in a map.", tt.val)
+	}
+}
+
+type nestedNonFinite struct {
+	structEmbedded
+	F       float64
+	F32     float32 `json:"f32"`
+	Omitted float64 `json:",omitempty"`
+	Skipped float64 `json:"-"`
+	Slice   []float64
+	Map     map[string]float64
+	Ptr     *float64
+	Nested  struct{ Inf float64 }
+	Time    time.Time
+	Bytes   []byte
+	hidden  float64
+}
+
+func TestJSONNestedNonFiniteFloats(t *testing.T) {
+	nan := math.NaN()
+	val := nestedNonFinite{
+		structEmbedded: structEmbedded{"flat"},
+		F:              nan,
+		F32:            float32(math.Inf(1)),
+		Skipped:        nan,
+		Slice:          []float64{1, nan},
+		Map:            map[string]float64{"b": 1, "a": math.Inf(-1)},
+		Ptr:            &nan,
+		Nested:         struct{ Inf float64 }{math.Inf(1)},
+		Time:           time.Unix(0, 0).UTC(),
+		Bytes:          []byte("ab"),
+		hidden:         nan,
+	}
+	fields := []Field{
+		Reflect("obj", val),
+		Reflect("ptr", &val.Nested),
+		Array("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+			return arr.AppendObject([]float32{float32(nan)})
+		})),
+	}
+
+	tests := []struct {
+		opts     []JSONOption
+		expected string
+	}{
+		{
+			nil,
+			`"obj":{"Flattened":"flat","F":"NaN","f32":"+Inf","Slice":[1,"NaN"],"Map":{"a":"-Inf","b":1},"Ptr":"NaN",` +
+				`"Nested":{"Inf":"+Inf"},"Time":"1970-01-01T00:00:00Z","Bytes":"YWI="},"ptr":{"Inf":"+Inf"},"arr":[["NaN"]]`,
+		},
+		{
+			[]JSONOption{NullNonFiniteFloats()},
+			`"obj":{"Flattened":"flat","F":null,"f32":null,"Slice":[1,null],"Map":{"a":null,"b":1},"Ptr":null,` +
+				`"Nested":{"Inf":null},"Time":"1970-01-01T00:00:00Z","Bytes":"YWI="},"ptr":{"Inf":null},"arr":[[null]]`,
+		},
+	}
+
+	for _, tt := range tests {
+		enc := newJSONEncoder(append(tt.opts, NoTime())...)
+		addFields(enc, fields)
+		assert.Equal(t, tt.expected, string(enc.bytes), "Unexpected encoding of nested non-finite floats.")
+
+		buf := &testBuffer{}
+		require.NoError(t, enc.WriteEntry(buf, Entry{Message: "", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+		var parsed map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &parsed), "Produced invalid JSON: %s", buf.Stripped())
+		enc.Free()
+	}
+}
+
+func TestJSONSafeIntegers(t *testing.T) {
+	tests := []struct {
+		desc   string
+		f      func(Encoder)
+		plain  string
+		quoted string
+	}{
+		{"2^53-1", func(e Encoder) { e.AddInt64("k", 1<<53-1) }, `"k":9007199254740991`, `"k":9007199254740991`},
+		{"-(2^53-1)", func(e Encoder) { e.AddInt64("k", -(1<<53 - 1)) }, `"k":-9007199254740991`, `"k":-9007199254740991`},
+		{"2^53", func(e Encoder) { e.AddInt64("k", 1<<53) }, `"k":9007199254740992`, `"k":"9007199254740992"`},
+		{"-2^53", func(e Encoder) { e.AddInt64("k", -1<<53) }, `"k":-9007199254740992`, `"k":"-9007199254740992"`},
+		{"MaxInt64", func(e Encoder) { e.AddInt64("k", math.MaxInt64) }, `"k":9223372036854775807`, `"k":"9223372036854775807"`},
+		{"MinInt64", func(e Encoder) { e.AddInt64("k", math.MinInt64) }, `"k":-9223372036854775808`, `"k":"-9223372036854775808"`},
+		{"int", func(e Encoder) { e.AddInt("k", 1<<53) }, `"k":9007199254740992`, `"k":"9007199254740992"`},
+		{"small uint64", func(e Encoder) { e.AddUint64("k", 1<<53-1) }, `"k":9007199254740991`, `"k":9007199254740991`},
+		{"uint64 2^53", func(e Encoder) { e.AddUint64("k", 1<<53) }, `"k":9007199254740992`, `"k":"9007199254740992"`},
+		{"MaxUint64", func(e Encoder) { e.AddUint64("k", math.MaxUint64) }, `"k":18446744073709551615`, `"k":"18446744073709551615"`},
+		{"array", func(e Encoder) {
+			Int64s("k", []int64{1, 1 << 53}).AddTo(e)
+			Uint64s("u", []uint64{1, math.MaxUint64}).AddTo(e)
+		}, `"k":[1,9007199254740992],"u":[1,18446744073709551615]`, `"k":[1,"9007199254740992"],"u":[1,"18446744073709551615"]`},
+		{"nested", func(e Encoder) {
+			Nest("k", Int64("id", math.MaxInt64), Int("n", 1)).AddTo(e)
+		}, `"k":{"id":9223372036854775807,"n":1}`, `"k":{"id":"9223372036854775807","n":1}`},
+	}
+
+	for _, tt := range tests {
+		for _, safe := range []bool{false, true} {
+			var opts []JSONOption
+			expected := tt.plain
+			if safe {
+				opts = append(opts, SafeIntegers())
+				expected = tt.quoted
+			}
+			root := newJSONEncoder(opts...)
+			for _, enc := range []Encoder{root, root.Clone()} {
+				tt.f(enc)
+				assert.Equal(t, expected, string(enc.(*jsonEncoder).bytes), "Unexpected output for %s (safe integers: %v).", tt.desc, safe)
+				enc.Free()
+			}
+		}
+	}
+}
+
+func TestJSONSafeIntegerTimestamps(t *testing.T) {
+	ts := time.Unix(1500000000, 123456789)
+	for _, tt := range []struct {
+		opts     []JSONOption
+		expected string
+	}{
+		{[]JSONOption{EpochNanosFormatter("ts")}, `{"level":"info","ts":1500000000123456789,"msg":""}`},
+		{[]JSONOption{EpochNanosFormatter("ts"), SafeIntegers()}, `{"level":"info","ts":"1500000000123456789","msg":""}`},
+	} {
+		enc := NewJSONEncoder(tt.opts...)
+		buf := &testBuffer{}
+		require.NoError(t, enc.WriteEntry(buf, Entry{Message: "", Level: InfoLevel, Time: ts}), "Unexpected error writing entry.")
+		assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected nanosecond timestamp encoding.")
+		enc.Free()
+	}
+}
+
+func TestJSONMaxEntryBytes(t *testing.T) {
+	const limit = 512
+	big := strings.Repeat("x", 4*limit)
+	nestedObject := func(n int) LogMarshaler {
+		return LogMarshalerFunc(func(kv KeyValue) error {
+			kv.OpenNamespace("inner")
+			for i := 0; i < n; i++ {
+				kv.AddString(fmt.Sprintf("k%d", i), "some value")
+			}
+			return kv.AddArray("list", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+				for i := 0; i < n; i++ {
+					arr.AppendInt64(int64(i))
+				}
+				return nil
+			}))
+		})
+	}
+
+	tests := []struct {
+		desc      string
+		msg       string
+		f         func(Encoder)
+		truncated bool
+		kept      []string
+	}{
+		{
+			desc: "under the limit",
+			msg:  "hello",
+			f:    func(e Encoder) { e.AddString("k", "v") },
+			kept: []string{"k"},
+		},
+		{
+			desc: "oversized string field",
+			msg:  "hello",
+			f: func(e Encoder) {
+				e.AddString("before", "ok")
+				e.AddString("big", big)
+				e.AddString("after", "dropped")
+			},
+			truncated: true,
+			kept:      []string{"before"},
+		},
+		{
+			desc: "oversized nested object",
+			msg:  "hello",
+			f: func(e Encoder) {
+				e.AddInt("before", 1)
+				e.AddMarshaler("obj", nestedObject(100))
+			},
+			truncated: true,
+			kept:      []string{"before", "obj"},
+		},
+		{
+			desc: "oversized namespace",
+			msg:  "hello",
+			f: func(e Encoder) {
+				e.OpenNamespace("ns")
+				for i := 0; i < 100; i++ {
+					e.AddString(fmt.Sprintf("k%d", i), "some value")
+				}
+			},
+			truncated: true,
+			kept:      []string{"ns"},
+		},
+		{
+			desc:      "oversized message",
+			msg:       big,
+			f:         func(e Encoder) { e.AddString("k", "v") },
+			truncated: true,
+			kept:      []string{"k"},
+		},
+		{
+			desc: "escaping overruns the estimate",
+			msg:  "hello",
+			f: func(e Encoder) {
+				e.AddString("ctrl", strings.Repeat("\x00", limit/4))
+				e.AddString("after", "dropped")
+			},
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		enc := NewJSONEncoder(MaxEntryBytes(limit), NoTime())
+		tt.f(enc)
+		buf := &testBuffer{}
+		err := enc.WriteEntry(buf, Entry{Message: tt.msg, Level: InfoLevel, Time: epoch})
+		enc.Free()
+
+		assert.True(t, buf.Len() <= limit, "Entry exceeded MaxEntryBytes for %s: got %v bytes.", tt.desc, buf.Len())
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded), "Truncated output isn't valid JSON for %s: %s", tt.desc, buf.String())
+		for _, k := range tt.kept {
+			assert.Contains(t, decoded, k, "Expected field %q to survive for %s.", k, tt.desc)
+		}
+		assert.NotContains(t, decoded, "after", "Expected fields after the truncation to be dropped for %s.", tt.desc)
+		if !tt.truncated {
+			assert.NoError(t, err, "Unexpected error for %s.", tt.desc)
+			assert.NotContains(t, decoded, "truncated", "Unexpected truncation marker for %s.", tt.desc)
+			continue
+		}
+		assert.Error(t, err, "Expected an error reporting the truncation for %s.", tt.desc)
+		assert.Equal(t, true, decoded["truncated"], "Expected a truncation marker for %s.", tt.desc)
+		assert.True(t, decoded["originalSize"].(float64) > limit, "Expected originalSize to exceed the limit for %s.", tt.desc)
+	}
+}
+
+func TestJSONMaxEntryBytesNestedObjectStaysClosed(t *testing.T) {
+	enc := newJSONEncoder(MaxEntryBytes(_entryLimitReserve + 40))
+	defer enc.Free()
+	enc.AddMarshaler("obj", LogMarshalerFunc(func(kv KeyValue) error {
+		kv.AddString("a", "1")
+		kv.AddString("b", "1234567890")
+		kv.AddString("c", "dropped")
+		return nil
+	}))
+	enc.AddString("d", "dropped")
+	assert.Equal(t, `"obj":{"a":"1","b":"1234567890"}`, string(enc.bytes), "Unexpected fields after exhausting the budget.")
+	assert.True(t, enc.entryTruncated, "Expected the encoder to be marked as truncated.")
+}
+
+func TestJSONMaxEntryBytesContext(t *testing.T) {
+	const limit = 512
+	parent := NewJSONEncoder(MaxEntryBytes(limit), NoTime())
+	defer parent.Free()
+	parent.AddString("context", strings.Repeat("x", limit/4))
+
+	for i := 0; i < 2; i++ {
+		child := parent.Clone()
+		child.AddString("k", strings.Repeat("y", limit/2))
+		buf := &testBuffer{}
+		assert.Error(t, child.WriteEntry(buf, Entry{Message: "msg", Level: InfoLevel}), "Expected an error reporting the truncation.")
+		child.Free()
+		assert.True(t, buf.Len() <= limit, "Entry exceeded MaxEntryBytes: got %v bytes.", buf.Len())
+		assert.Contains(t, buf.String(), `"context":"xxx`, "Expected the context to survive.")
+		assert.NotContains(t, buf.String(), `"k":`, "Expected the oversized field to be dropped.")
+	}
+
+	buf := &testBuffer{}
+	assert.NoError(t, parent.WriteEntry(buf, Entry{Message: "msg", Level: InfoLevel}), "Truncating clones shouldn't affect the parent.")
+	assert.NotContains(t, buf.String(), "truncated", "Unexpected truncation marker on the parent.")
+}
+
+func TestJSONMaxEntryBytesReportsErrors(t *testing.T) {
+	errBuf := &testBuffer{}
+	logger := New(
+		NewJSONEncoder(MaxEntryBytes(300), NoTime()),
+		Output(&testBuffer{}),
+		ErrorOutput(errBuf),
+	)
+	logger.Info("big", String("k", strings.Repeat("x", 1000)))
+	assert.Contains(t, errBuf.String(), "encoder error: entry exceeded MaxEntryBytes(300)", "Expected truncation to be reported.")
+}
@@ -23,7 +23,8 @@ package zap
 import "time"
 
 // JSONOption is used to set options for a JSON encoder. MessageFormatters,
-// TimeFormatters, and LevelFormatters all implement the JSONOption interface.
+// TimeFormatters, LevelFormatters, CallerFormatters, StackFormatters,
+// BinaryEncodings, and ByteLimits all implement the JSONOption interface.
 type JSONOption interface {
 	apply(*jsonEncoder)
 }
@@ -59,6 +60,16 @@ func EpochFormatter(key string) TimeFormatter {
 	})
 }
 
+// EpochNanosFormatter encodes the entry time as an integer number of
+// nanoseconds since epoch under the provided key. Nanosecond timestamps are
+// too large to be represented exactly by a float64, so consider using the
+// SafeIntegers option too.
+func EpochNanosFormatter(key string) TimeFormatter {
+	return TimeFormatter(func(t time.Time) Field {
+		return Int64(key, t.UnixNano())
+	})
+}
+
 // RFC3339Formatter encodes the entry time as an RFC3339-formatted string under
 // the provided key.
 func RFC3339Formatter(key string) TimeFormatter {
@@ -83,6 +94,156 @@ func (lf LevelFormatter) apply(enc *jsonEncoder) {
 	enc.levelF = lf
 }
 
+// A CallerFormatter defines how to convert an entry's call site into a Field.
+// It's only used for entries whose Caller is defined (see RecordCaller), and
+// the field is added after the message. CallerFormatters implement the
+// JSONOption interface.
+type CallerFormatter func(EntryCaller) Field
+
+func (cf CallerFormatter) apply(enc *jsonEncoder) {
+	enc.callerF = cf
+}
+
+// A StackFormatter defines how to convert an entry with a stack trace (see
+// RecordStacks) into Fields, which are added after the message and caller.
+// It's not used for entries without a stack trace. StackFormatters implement
+// the JSONOption interface.
+type StackFormatter func(Entry) []Field
+
+func (sf StackFormatter) apply(enc *jsonEncoder) {
+	enc.stackF = sf
+}
+
+// A BinaryEncoding defines how the JSON encoder represents the values of
+// Binary fields. BinaryEncodings implement the JSONOption interface.
+type BinaryEncoding int
+
+const (
+	// Base64Encoding writes binary data as padded, standard base64 strings.
+	// It's the default.
+	Base64Encoding BinaryEncoding = iota
+	// HexEncoding writes binary data as lowercase hexadecimal strings.
+	HexEncoding
+)
+
+func (be BinaryEncoding) apply(enc *jsonEncoder) {
+	enc.binaryEnc = be
+}
+
+// A ByteLimit caps the number of bytes the JSON encoder writes for each Binary
+// and ByteString field; longer values are truncated, and the encoded string is
+// suffixed with "...". Limits less than or equal to zero disable truncation.
+// ByteLimits implement the JSONOption interface.
+type ByteLimit int
+
+func (bl ByteLimit) apply(enc *jsonEncoder) {
+	enc.byteLimit = int(bl)
+}
+
+type jsonOptionFunc func(*jsonEncoder)
+
+func (f jsonOptionFunc) apply(enc *jsonEncoder) {
+	f(enc)
+}
+
+// MarkTruncated adds a boolean field to each entry in which a ByteLimit
+// shortened a value. The field's key is the truncated field's key suffixed
+// with "Truncated", so a truncated "payload" field is followed by
+// "payloadTruncated":true.
+func MarkTruncated() JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.markTruncated = true
+	})
+}
+
+// StrictRawJSON fully validates the values of RawJSON fields before splicing
+// them into the output. By default, the JSON encoder only checks that each
+// value's first and last bytes are balanced, which is much cheaper but may
+// let some malformed values through.
+func StrictRawJSON() JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.strictRawJSON = true
+	})
+}
+
+// HTMLSafe escapes the characters <, >, and & in keys, messages, and string
+// values, so that the output can be safely embedded in HTML. Since that's
+// rarely necessary in a logging context, the JSON encoder doesn't escape them
+// by default.
+func HTMLSafe() JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.htmlSafe = true
+	})
+}
+
+// DeduplicateKeys keeps only the last occurrence of each key when writing an
+// entry, so fields added later (for example, when logging) replace fields with
+// the same key added earlier (for example, with Logger.With). Keys are
+// deduplicated at the top level of the entry and within any open namespaces,
+// but not within nested objects or against the level, time, and message keys.
+// Deduplication requires an extra pass over each entry, so it's off by
+// default.
+func DeduplicateKeys() JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.dedupe = true
+	})
+}
+
+// SortKeys writes each entry's fields in lexicographic key order, sorting the
+// members of nested objects (including those produced by Map, Struct, Reflect,
+// and RawJSON fields) and of namespaces within their own scope. The level,
+// time, and message are still written first. Members with the same key keep
+// their relative order; combine SortKeys with DeduplicateKeys to keep only the
+// last of them. The encoder records where it writes each key, so only the
+// values of Reflect and RawJSON fields need to be scanned, but sorting still
+// requires an extra pass over each entry, so it's off by default.
+func SortKeys() JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.sortKeys = true
+	})
+}
+
+// MaxEntryBytes caps the size of each encoded entry, including its trailing
+// newline, at n bytes. The limit is enforced as fields are added: once a field
+// doesn't fit, it and all subsequent fields (including the remaining members
+// of any nested objects and arrays) are dropped without being encoded, and
+// any open objects are closed so that the entry remains valid JSON. If
+// there's not enough room left for the message, it's shortened too.
+//
+// Entries that were cut short end with the fields "truncated":true and
+// "originalSize", an estimate of the entry's complete size in bytes. Writing
+// them returns an error, so Loggers report each truncation to their
+// ErrorOutput.
+//
+// Fields may use all but the last 256 bytes of the budget; the rest is set
+// aside for the level, time, message, and truncation marker. Limits less than
+// or equal to zero disable the cap.
+func MaxEntryBytes(n int) JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.maxEntryBytes = n
+	})
+}
+
+// SafeIntegers encodes integers whose magnitude is greater than 2^53-1 as
+// strings. Larger integers can't be represented exactly by a float64, so many
+// JSON parsers (including JavaScript's) silently round them. The option
+// applies to all integer fields, including elements of arrays, fields of
+// nested objects, and timestamps written by EpochNanosFormatter.
+func SafeIntegers() JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.safeIntegers = true
+	})
+}
+
+// NullNonFiniteFloats encodes NaN and infinite floating-point values as null.
+// By default, the JSON encoder writes them as the strings "NaN", "+Inf", and
+// "-Inf".
+func NullNonFiniteFloats() JSONOption {
+	return jsonOptionFunc(func(enc *jsonEncoder) {
+		enc.nullNonFinite = true
+	})
+}
+
 // LevelString encodes the entry's level under the provided key. It uses the
 // level's String method to serialize it.
 func LevelString(key string) LevelFormatter {
@@ -50,6 +50,7 @@ func TestTimeFormatters(t *testing.T) {
 		expected  Field
 	}{
 		{"EpochFormatter", EpochFormatter("the-time"), Float64("the-time", 0)},
+		{"EpochNanosFormatter", EpochNanosFormatter("ts"), Int64("ts", 0)},
 		{"RFC3339", RFC3339Formatter("ts"), String("ts", "1970-01-01T00:00:00Z")},
 		{"NoTime", NoTime(), Skip()},
 		{"Default", defaultTimeF, Float64("ts", 0)},
@@ -0,0 +1,244 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	_jsonMarshalerInterface = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	_textMarshalerInterface = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isNonFiniteError reports whether json.Marshal failed because the value
+// contains a NaN or infinite float.
+func isNonFiniteError(err error) bool {
+	ue, ok := err.(*json.UnsupportedValueError)
+	if !ok {
+		return false
+	}
+	switch ue.Str {
+	case "NaN", "+Inf", "-Inf":
+		return true
+	default:
+		return false
+	}
+}
+
+// addSanitized encodes a value that json.Marshal rejected because it contains
+// non-finite floats. It walks the value the way encoding/json does, honoring
+// json tags and flattening embedded structs, but it adds floats through the
+// KeyValue, which represents NaN and infinite values safely. Values that
+// marshal themselves are still passed to json.Marshal. Containers nested more
+// than depth levels deep are replaced with a marker string.
+func addSanitized(kv KeyValue, key string, v reflect.Value, depth int) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() || marshalsItself(v.Type()) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return kv.AddRawJSON(key, []byte("null"))
+	}
+	if !v.CanInterface() {
+		return nil
+	}
+	if marshalsItself(v.Type()) {
+		return addMarshaledJSON(kv, key, v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Float32:
+		kv.AddFloat32(key, float32(v.Float()))
+		return nil
+	case reflect.Float64:
+		kv.AddFloat64(key, v.Float())
+		return nil
+	case reflect.Struct:
+		if depth <= 0 {
+			kv.AddString(key, _maxDepthMarker)
+			return nil
+		}
+		return kv.AddMarshaler(key, LogMarshalerFunc(func(kv KeyValue) error {
+			return addSanitizedFields(kv, v, depth-1)
+		}))
+	case reflect.Map:
+		if v.IsNil() {
+			return kv.AddRawJSON(key, []byte("null"))
+		}
+		if depth <= 0 {
+			kv.AddString(key, _maxDepthMarker)
+			return nil
+		}
+		return kv.AddMarshaler(key, LogMarshalerFunc(func(kv KeyValue) error {
+			return addSanitizedEntries(kv, v, depth-1)
+		}))
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			// Let encoding/json handle null and base64-encoded byte slices.
+			return addMarshaledJSON(kv, key, v.Interface())
+		}
+		if depth <= 0 {
+			kv.AddString(key, _maxDepthMarker)
+			return nil
+		}
+		return kv.AddArray(key, ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+			var firstErr error
+			for i := 0; i < v.Len(); i++ {
+				err := addSanitized(&arrayElement{arr: arr}, "", v.Index(i), depth-1)
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			return firstErr
+		}))
+	default:
+		return addMarshaledJSON(kv, key, v.Interface())
+	}
+}
+
+func addSanitizedFields(kv KeyValue, v reflect.Value, depth int) error {
+	var firstErr error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx:]
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						continue
+					}
+					fv = fv.Elem()
+				}
+				if err := addSanitizedFields(kv, fv, depth); err != nil && firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// Unexported.
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(opts, ",omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		if err := addSanitized(kv, name, fv, depth); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func addSanitizedEntries(kv KeyValue, v reflect.Value, depth int) error {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	for _, k := range v.MapKeys() {
+		s := mapKeyString(k)
+		keys = append(keys, s)
+		values[s] = v.MapIndex(k)
+	}
+	sort.Strings(keys)
+
+	var firstErr error
+	for _, k := range keys {
+		if err := addSanitized(kv, k, values[k], depth); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// mapKeyString formats a map key the way encoding/json does.
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if text, err := tm.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	default:
+		return fmt.Sprint(k.Interface())
+	}
+}
+
+func addMarshaledJSON(kv KeyValue, key string, obj interface{}) error {
+	marshaled, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return kv.AddRawJSON(key, marshaled)
+}
+
+func marshalsItself(t reflect.Type) bool {
+	return t.Implements(_jsonMarshalerInterface) || t.Implements(_textMarshalerInterface)
+}
+
+// isEmptyValue reports whether encoding/json's omitempty option would omit the
+// value.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package zap
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+var _jsonSortPool = sync.Pool{New: func() interface{} {
+	return &jsonSortScratch{members: make([]jsonMember, 0, _dedupeSmallScope)}
+}}
+
+// A jsonMark records where a sorting encoder wrote a key, or opened or closed
+// a nested object, array, or namespace, so that its fields can be reordered
+// without re-scanning them. Offsets include the encoder's shared prefix.
+type jsonMark struct {
+	offset int // the key's opening quote, or the bracket
+	keyEnd int // the byte after the key's closing quote; zero for brackets
+}
+
+// jsonSortScratch holds the members of every scope being sorted, innermost
+// last, so that sorting an entry doesn't allocate once the scratch slice is
+// large enough.
+type jsonSortScratch struct {
+	members []jsonMember
+	sorter  jsonMemberSorter
+}
+
+// jsonMemberSorter sorts members by key, keeping members with the same key in
+// their original order.
+type jsonMemberSorter struct {
+	src     []byte
+	members []jsonMember
+}
+
+func (s *jsonMemberSorter) Len() int      { return len(s.members) }
+func (s *jsonMemberSorter) Swap(i, j int) { s.members[i], s.members[j] = s.members[j], s.members[i] }
+func (s *jsonMemberSorter) Less(i, j int) bool {
+	// Compare keys without their quotes, so that "a" sorts before "a!".
+	a, b := s.members[i], s.members[j]
+	return bytes.Compare(s.src[a.start+1:a.keyEnd-1], s.src[b.start+1:b.keyEnd-1]) < 0
+}
+
+// appendSorted appends the encoded members in src to dst in lexicographic key
+// order, locating them with the marks recorded as they were encoded. Members
+// of nested objects (including objects in arrays) are sorted too, and open
+// namespaces are sorted within their enclosing scope and then closed. Values
+// encoded without marks, like those of Reflect and RawJSON fields, are
+// scanned instead. In the top-level scope and in namespaces, dedupe keeps
+// only the last occurrence of each key; otherwise, members followed by a
+// Replace field with the same key (see the encoder's replacements) are
+// dropped.
+func appendSorted(dst, src []byte, marks []jsonMark, dedupe bool, replacements []int) []byte {
+	s := _jsonSortPool.Get().(*jsonSortScratch)
+	dst = s.appendScope(dst, src, marks, 0, len(marks), len(src), true, dedupe, replacements)
+	s.sorter = jsonMemberSorter{}
+	_jsonSortPool.Put(s)
+	return dst
+}
+
+// appendScope appends the members of an object or namespace, whose marks are
+// marks[from:to] and whose contents end at src[end]. If unique is set,
+// duplicate and replaced members are dropped.
+func (s *jsonSortScratch) appendScope(dst, src []byte, marks []jsonMark, from, to, end int, unique, dedupe bool, replacements []int) []byte {
+	base := len(s.members)
+	for i := from; i < to; {
+		key := marks[i]
+		m := jsonMember{start: key.offset, keyEnd: key.keyEnd, value: key.keyEnd + 1, open: -1, close: -1}
+		i++
+		switch {
+		case i < to && marks[i].keyEnd == 0 && marks[i].offset == m.value:
+			m.open, m.close = i, matchingMark(src, marks, i, to)
+			if m.close < to {
+				m.end = marks[m.close].offset + 1
+				i = m.close + 1
+			} else {
+				m.namespace = true
+				m.end = end
+				i = to
+			}
+		case i < to:
+			// Stop before the comma preceding the next key.
+			m.end = marks[i].offset - 1
+		default:
+			m.end = end
+		}
+		s.members = append(s.members, m)
+	}
+	n := len(s.members) - base
+	if unique && dedupe {
+		markDuplicates(src, s.members[base:])
+	} else if unique && len(replacements) > 0 {
+		markReplaced(src, s.members[base:], 0, replacements)
+	}
+	s.sorter = jsonMemberSorter{src: src, members: s.members[base:]}
+	sort.Stable(&s.sorter)
+
+	first := true
+	for i := base; i < base+n; i++ {
+		// Appending nested members may reallocate s.members, so copy each
+		// member first.
+		m := s.members[i]
+		if m.dropped {
+			continue
+		}
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = append(dst, src[m.start:m.value]...)
+		switch {
+		case m.open < 0:
+			// Spliced values may have insignificant whitespace.
+			v := skipJSONSpace(src[:m.end], m.value)
+			dst = append(dst, src[m.value:v]...)
+			dst = s.appendValue(dst, trimJSONSpace(src[v:m.end]))
+		case src[m.value] == '[':
+			dst = s.appendArray(dst, src, marks, m.open, m.close)
+		default:
+			contentEnd := end
+			if !m.namespace {
+				contentEnd = marks[m.close].offset
+			}
+			dst = append(dst, '{')
+			dst = s.appendScope(dst, src, marks, m.open+1, m.close, contentEnd, m.namespace, dedupe, replacements)
+			dst = append(dst, '}')
+		}
+	}
+	s.members = s.members[:base]
+	return dst
+}
+
+// appendArray appends the array whose brackets are marks[open] and
+// marks[close], sorting the members of the objects it contains.
+func (s *jsonSortScratch) appendArray(dst, src []byte, marks []jsonMark, open, close int) []byte {
+	dst = append(dst, '[')
+	next := marks[open].offset + 1
+	// Only nested objects and arrays have marks at this level; the elements
+	// between them are scanned.
+	for i := open + 1; i < close; {
+		c := matchingMark(src, marks, i, close)
+		dst = s.appendElements(dst, src[next:marks[i].offset])
+		if src[marks[i].offset] == '[' {
+			dst = s.appendArray(dst, src, marks, i, c)
+		} else {
+			dst = append(dst, '{')
+			dst = s.appendScope(dst, src, marks, i+1, c, marks[c].offset, false, false, nil)
+			dst = append(dst, '}')
+		}
+		next = marks[c].offset + 1
+		i = c + 1
+	}
+	dst = s.appendElements(dst, src[next:marks[close].offset])
+	return append(dst, ']')
+}
+
+// matchingMark returns the index of the mark that closes the bracket at
+// marks[i], or to if it's unclosed (that is, an open namespace).
+func matchingMark(src []byte, marks []jsonMark, i, to int) int {
+	depth := 0
+	for j := i; j < to; j++ {
+		if marks[j].keyEnd != 0 {
+			continue
+		}
+		switch src[marks[j].offset] {
+		case '{', '[':
+			depth++
+		default:
+			if depth--; depth == 0 {
+				return j
+			}
+		}
+	}
+	return to
+}
+
+// appendValue appends a JSON value that was encoded without marks, scanning
+// it to sort the members of any objects it contains.
+func (s *jsonSortScratch) appendValue(dst, v []byte) []byte {
+	if len(v) < 2 {
+		return append(dst, v...)
+	}
+	switch {
+	case v[0] == '{' && v[len(v)-1] == '}':
+		dst = append(dst, '{')
+		dst = s.appendScanned(dst, v[1:len(v)-1])
+		return append(dst, '}')
+	case v[0] == '[' && v[len(v)-1] == ']':
+		dst = append(dst, '[')
+		dst = s.appendElements(dst, v[1:len(v)-1])
+		return append(dst, ']')
+	}
+	return append(dst, v...)
+}
+
+// appendScanned appends the members of an object that was encoded without
+// marks in lexicographic key order.
+func (s *jsonSortScratch) appendScanned(dst, src []byte) []byte {
+	// Values nested in Reflect and RawJSON fields may contain insignificant
+	// whitespace, so skip it between tokens.
+	base := len(s.members)
+	for i := 0; ; {
+		i = skipJSONSpace(src, i)
+		if i < len(src) && src[i] == ',' {
+			i = skipJSONSpace(src, i+1)
+		}
+		if i >= len(src) {
+			break
+		}
+		m := jsonMember{start: i, keyEnd: skipJSONString(src, i)}
+		// Skip the colon between the key and value.
+		m.value = skipJSONSpace(src, skipJSONSpace(src, m.keyEnd)+1)
+		m.end = skipJSONValue(src, m.value)
+		s.members = append(s.members, m)
+		i = m.end
+	}
+	n := len(s.members) - base
+	s.sorter = jsonMemberSorter{src: src, members: s.members[base:]}
+	sort.Stable(&s.sorter)
+
+	for i := base; i < base+n; i++ {
+		m := s.members[i]
+		if i > base {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, src[m.start:m.value]...)
+		dst = s.appendValue(dst, trimJSONSpace(src[m.value:m.end]))
+	}
+	s.members = s.members[:base]
+	return dst
+}
+
+// appendElements appends comma-separated array elements that were encoded
+// without marks, sorting the members of any objects among them. Leading and
+// trailing commas are kept, so that runs of elements can be appended between
+// the elements that have marks.
+func (s *jsonSortScratch) appendElements(dst, elems []byte) []byte {
+	for i := 0; ; {
+		i = skipJSONSpace(elems, i)
+		if i < len(elems) && elems[i] == ',' {
+			dst = append(dst, ',')
+			i = skipJSONSpace(elems, i+1)
+		}
+		if i >= len(elems) {
+			break
+		}
+		end := skipJSONValue(elems, i)
+		dst = s.appendValue(dst, trimJSONSpace(elems[i:end]))
+		i = end
+	}
+	return dst
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// skipJSONSpace returns the index of the first non-whitespace byte at or after
+// src[i].
+func skipJSONSpace(src []byte, i int) int {
+	for i < len(src) && isJSONSpace(src[i]) {
+		i++
+	}
+	return i
+}
+
+func trimJSONSpace(v []byte) []byte {
+	end := len(v)
+	for end > 0 && isJSONSpace(v[end-1]) {
+		end--
+	}
+	return v[:end]
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withSortingLogger(t testing.TB, opts []JSONOption, f func(Logger, *testBuffer)) {
+	sink := &testBuffer{}
+	errSink := &testBuffer{}
+	opts = append([]JSONOption{NoTime(), SortKeys()}, opts...)
+	logger := New(newJSONEncoder(opts...), DebugLevel, Output(sink), ErrorOutput(errSink))
+	f(logger, sink)
+	assert.Empty(t, errSink.String(), "Expected error sink to be empty.")
+}
+
+func TestSortKeys(t *testing.T) {
+	type point struct {
+		Y int `json:"y"`
+		X int `json:"x"`
+	}
+	unsorted := LogMarshalerFunc(func(kv KeyValue) error {
+		kv.AddInt("z", 1)
+		kv.AddInt("a", 2)
+		return nil
+	})
+
+	tests := []struct {
+		desc     string
+		context  []Field
+		fields   []Field
+		expected string
+	}{
+		{
+			desc:     "context and call site",
+			context:  []Field{String("component", "db"), Int("b", 1)},
+			fields:   []Field{Int("a", 2), Bool("c", true)},
+			expected: `{"level":"info","msg":"","a":2,"b":1,"c":true,"component":"db"}`,
+		},
+		{
+			desc:     "prefixes sort first",
+			fields:   []Field{String("a!", "x"), String("a", "y"), String("A", "z")},
+			expected: `{"level":"info","msg":"","A":"z","a":"y","a!":"x"}`,
+		},
+		{
+			desc:     "duplicates keep their order",
+			context:  []Field{String("k", "first")},
+			fields:   []Field{Int("b", 1), String("k", "second")},
+			expected: `{"level":"info","msg":"","b":1,"k":"first","k":"second"}`,
+		},
+		{
+			desc:     "nested objects",
+			fields:   []Field{Marshaler("obj", unsorted), Struct("point", point{Y: 2, X: 1}), Reflect("reflected", point{Y: 4, X: 3})},
+			expected: `{"level":"info","msg":"","obj":{"a":2,"z":1},"point":{"x":1,"y":2},"reflected":{"x":3,"y":4}}`,
+		},
+		{
+			desc: "objects in arrays",
+			fields: []Field{Array("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+				arr.AppendInt64(1)
+				arr.AppendMarshaler(unsorted)
+				return arr.AppendArray(ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+					return arr.AppendMarshaler(unsorted)
+				}))
+			}))},
+			expected: `{"level":"info","msg":"","arr":[1,{"a":2,"z":1},[{"a":2,"z":1}]]}`,
+		},
+		{
+			desc: "namespaces in objects",
+			fields: []Field{Marshaler("obj", LogMarshalerFunc(func(kv KeyValue) error {
+				kv.AddInt("z", 1)
+				kv.OpenNamespace("n")
+				kv.AddInt("b", 1)
+				kv.AddInt("a", 2)
+				return nil
+			}))},
+			expected: `{"level":"info","msg":"","obj":{"n":{"a":2,"b":1},"z":1}}`,
+		},
+		{
+			desc: "mixed array elements",
+			fields: []Field{Array("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+				arr.AppendObject(point{Y: 2, X: 1})
+				arr.AppendMarshaler(unsorted)
+				arr.AppendString("s")
+				return arr.AppendMarshaler(unsorted)
+			}))},
+			expected: `{"level":"info","msg":"","arr":[{"x":1,"y":2},{"a":2,"z":1},"s",{"a":2,"z":1}]}`,
+		},
+		{
+			desc:     "raw JSON with whitespace",
+			fields:   []Field{RawJSON("raw", []byte(`{ "b" : [ {"d":1, "c":"}"} ] , "a":null }`))},
+			expected: `{"level":"info","msg":"","raw":{"a":null,"b" : [{"c":"}","d":1}]}}`,
+		},
+		{
+			desc:     "namespaces",
+			context:  []Field{String("z", "outer"), Namespace("m"), String("k", "ns")},
+			fields:   []Field{Int("b", 1), Namespace("inner"), Int("y", 2), Int("x", 3)},
+			expected: `{"level":"info","msg":"","m":{"b":1,"inner":{"x":3,"y":2},"k":"ns"},"z":"outer"}`,
+		},
+		{
+			desc:     "empty namespaces",
+			context:  []Field{Int("z", 1), Namespace("b"), Namespace("a")},
+			expected: `{"level":"info","msg":"","b":{"a":{}},"z":1}`,
+		},
+		{
+			desc:     "escaped keys",
+			fields:   []Field{String("k\\", "{[,"), String(`"quoted"`, `"b"`)},
+			expected: `{"level":"info","msg":"","\"quoted\"":"\"b\"","k\\":"{[,"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		withSortingLogger(t, nil, func(logger Logger, buf *testBuffer) {
+			logger.With(tt.context...).Info("", tt.fields...)
+			assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output with %s.", tt.desc)
+		})
+	}
+}
+
+func TestSortKeysWithDeduplication(t *testing.T) {
+	withSortingLogger(t, []JSONOption{DeduplicateKeys()}, func(logger Logger, buf *testBuffer) {
+		logger.With(String("k", "first"), Int("n", 1), Namespace("ns"), Int("y", 1)).Info(
+			"",
+			String("k", "nested"),
+			Int("y", 2),
+			Int("x", 3),
+		)
+		assert.Equal(t, `{"level":"info","msg":"","k":"first","n":1,"ns":{"k":"nested","x":3,"y":2}}`, buf.Stripped(), "Unexpected output sorting deduplicated keys.")
+	})
+}
+
+func TestSortKeysDeterministic(t *testing.T) {
+	// Map iteration order is randomized, so adding fields by ranging over a
+	// map produces a different insertion order on each run.
+	m := make(map[string]int, 100)
+	for i := 0; i < 100; i++ {
+		m[fmt.Sprintf("k%03d", i)] = i
+	}
+	withSortingLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		var first string
+		for run := 0; run < 10; run++ {
+			var context, fields []Field
+			for k, v := range m {
+				if v%2 == 0 {
+					context = append(context, Int(k, v))
+				} else {
+					fields = append(fields, Int(k, v))
+				}
+			}
+			buf.Reset()
+			logger.With(context...).Info("", fields...)
+			if run == 0 {
+				first = buf.String()
+				continue
+			}
+			require.Equal(t, first, buf.String(), "Expected identical output across runs.")
+		}
+		assert.Contains(t, first, `"k000":0,"k001":1,"k002":2,`, "Expected fields in lexicographic order.")
+	})
+}
+
+func TestSortKeysByDefault(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		logger.With(String("b", "1")).Info("", String("a", "2"))
+		assert.Equal(t, `{"level":"info","msg":"","b":"1","a":"2"}`, buf.Stripped(), "Expected insertion order by default.")
+	})
+}
+
+func TestSortKeysTruncated(t *testing.T) {
+	// Escaping pushes the second field past the budget after it's encoded, so
+	// the encoder has to forget the key it recorded.
+	sink := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime(), SortKeys(), MaxEntryBytes(_entryLimitReserve+50)), Output(sink), ErrorOutput(Discard))
+	logger.With(String("z", "context")).Info("", String("b", "fits"), String("a", strings.Repeat("\x00", 10)), Int("c", 1))
+	assert.Equal(t, `{"level":"info","msg":"","b":"fits","z":"context","truncated":true,"originalSize":145}`, sink.Stripped(), "Unexpected output sorting a truncated entry.")
+}
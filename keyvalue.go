@@ -26,7 +26,13 @@ package zap
 //
 // See Marshaler for an example.
 type KeyValue interface {
+	// AddBinary adds arbitrary bytes; how they're represented is
+	// encoder-dependent. AddByteString adds UTF-8 encoded text without
+	// converting it to a string first.
+	AddBinary(key string, value []byte)
 	AddBool(key string, value bool)
+	AddByteString(key string, value []byte)
+	AddFloat32(key string, value float32)
 	AddFloat64(key string, value float64)
 	AddInt(key string, value int)
 	AddInt64(key string, value int64)
@@ -34,8 +40,39 @@ type KeyValue interface {
 	AddUint64(key string, value uint64)
 	AddUintptr(key string, value uintptr)
 	AddMarshaler(key string, marshaler LogMarshaler) error
+	AddArray(key string, marshaler ArrayMarshaler) error
+	// AddRawJSON adds a pre-encoded JSON value. Encoders that can't splice it
+	// into their output verbatim may re-encode it, and they should return an
+	// error (rather than corrupting their output) if the value is invalid.
+	AddRawJSON(key string, value []byte) error
 	// AddObject uses reflection to serialize arbitrary objects, so it's slow and
 	// allocation-heavy. Consider implementing the LogMarshaler interface instead.
 	AddObject(key string, value interface{}) error
 	AddString(key, value string)
+	// OpenNamespace opens an isolated namespace; all subsequent fields are
+	// added to the new namespace until the enclosing object (or the log entry)
+	// is closed.
+	OpenNamespace(key string)
+}
+
+// ArrayEncoder is an encoding-agnostic interface to add array-like structures
+// to the logging context. Like KeyValues, ArrayEncoders aren't safe for
+// concurrent use.
+//
+// See ArrayMarshaler for an example.
+type ArrayEncoder interface {
+	// AppendMarshaler and AppendArray add nested objects and arrays, so
+	// structures may be nested arbitrarily deeply.
+	AppendMarshaler(marshaler LogMarshaler) error
+	AppendArray(marshaler ArrayMarshaler) error
+	// AppendObject uses reflection to serialize arbitrary objects, so it's
+	// slow and allocation-heavy.
+	AppendObject(value interface{}) error
+	AppendBool(value bool)
+	AppendByteString(value []byte)
+	AppendFloat32(value float32)
+	AppendFloat64(value float64)
+	AppendInt64(value int64)
+	AppendUint64(value uint64)
+	AppendString(value string)
 }
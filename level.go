@@ -27,7 +27,10 @@ import (
 	"github.com/uber-go/atomic"
 )
 
-var errMarshalNilLevel = errors.New("can't marshal a nil *Level to text")
+var (
+	errMarshalNilLevel = errors.New("can't marshal a nil *Level to text")
+	errUnsettableLevel = errors.New("can't set the level of a static LevelEnabler")
+)
 
 // A Level is a logging priority. Higher levels are more important.
 //
@@ -50,8 +53,11 @@ type LevelEnabler interface {
 }
 
 const (
-	invalidLevel Level = iota - 2
+	invalidLevel Level = iota - 3
 
+	// TraceLevel logs are even more voluminous than Debug logs; they're meant
+	// for hot inner loops, and are almost always disabled.
+	TraceLevel
 	// DebugLevel logs are typically voluminous, and are usually disabled in
 	// production.
 	DebugLevel
@@ -82,9 +88,38 @@ func (f LevelEnablerFunc) apply(m *Meta) { m.LevelEnabler = f }
 // Enabled calls the wrapped function.
 func (f LevelEnablerFunc) Enabled(lvl Level) bool { return f(lvl) }
 
+// ExactLevel returns a LevelEnabler that enables only the given level. For
+// example, ExactLevel(ErrorLevel) is useful for an errors-only output.
+func ExactLevel(lvl Level) LevelEnablerFunc {
+	return LevelEnablerFunc(func(l Level) bool { return l == lvl })
+}
+
+// RangeLevel returns a LevelEnabler that enables all the levels between min
+// and max, inclusive.
+func RangeLevel(min, max Level) LevelEnablerFunc {
+	return LevelEnablerFunc(func(l Level) bool { return l >= min && l <= max })
+}
+
+// SetLevel changes the minimum level of a settable LevelEnabler, like the
+// AtomicLevel returned by DynamicLevel. Other enablers, including concrete
+// Levels and LevelEnablerFuncs, can't be changed after they're passed to a
+// logger; SetLevel leaves them untouched and returns an error.
+func SetLevel(enab LevelEnabler, lvl Level) error {
+	settable, ok := enab.(interface {
+		SetLevel(Level)
+	})
+	if !ok {
+		return errUnsettableLevel
+	}
+	settable.SetLevel(lvl)
+	return nil
+}
+
 // String returns a lower-case ASCII representation of the log level.
 func (l Level) String() string {
 	switch l {
+	case TraceLevel:
+		return "trace"
 	case DebugLevel:
 		return "debug"
 	case InfoLevel:
@@ -121,6 +156,8 @@ func (l *Level) MarshalText() ([]byte, error) {
 // TOML, or JSON files.
 func (l *Level) UnmarshalText(text []byte) error {
 	switch string(text) {
+	case "trace":
+		*l = TraceLevel
 	case "debug":
 		*l = DebugLevel
 	case "info":
@@ -144,6 +181,8 @@ func (l *Level) UnmarshalText(text []byte) error {
 // Set sets the level for the flag.Value interface.
 func (l *Level) Set(s string) error {
 	switch s {
+	case "trace":
+		*l = TraceLevel
 	case "debug":
 		*l = DebugLevel
 	case "info":
@@ -206,3 +245,7 @@ func (lvl AtomicLevel) Level() Level {
 func (lvl AtomicLevel) SetLevel(l Level) {
 	lvl.l.Store(int32(l))
 }
+
+func (lvl AtomicLevel) compareAndSwap(from, to Level) bool {
+	return lvl.l.CAS(int32(from), int32(to))
+}
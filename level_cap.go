@@ -0,0 +1,200 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+// CapLevel wraps a Logger so that it never writes entries above the supplied
+// level. Entries above the cap are written at the cap instead, with an
+// additional "original_level" field recording the level they were logged at.
+// It's primarily useful when passing a Logger to third-party code: wrapping
+// the Logger with CapLevel(log, ErrorLevel) prevents that code from panicking
+// or terminating the process with DPanic, Panic, or Fatal.
+//
+// Check is consistent with the mapping: checking a level above the cap returns
+// a CheckedMessage (if the capped level is enabled) that writes an entry at the
+// capped level and doesn't panic or exit.
+func CapLevel(log Logger, max Level) Logger {
+	return &cappedLogger{log: log, max: max}
+}
+
+type cappedLogger struct {
+	log Logger
+	max Level
+}
+
+func (cl *cappedLogger) With(fields ...Field) Logger {
+	return &cappedLogger{log: cl.log.With(fields...), max: cl.max}
+}
+
+func (cl *cappedLogger) Sync() error {
+	return Sync(cl.log)
+}
+
+func (cl *cappedLogger) Context() []Field {
+	return Context(cl.log)
+}
+
+func (cl *cappedLogger) Audit() Logger {
+	return &cappedLogger{log: Audit(cl.log), max: cl.max}
+}
+
+func (cl *cappedLogger) IsAudit() bool {
+	return IsAudit(cl.log)
+}
+
+func (cl *cappedLogger) Check(lvl Level, msg string) *CheckedMessage {
+	if lvl <= cl.max {
+		return cl.log.Check(lvl, msg)
+	}
+	cm := cl.log.Check(cl.max, msg)
+	if !cm.OK() {
+		return nil
+	}
+	if cl.max >= DPanicLevel {
+		// Writing the wrapped logger's message would panic or exit, so give it
+		// back and write the entry with Log instead.
+		cm.discard()
+		return NewCheckedMessage(cl, lvl, msg)
+	}
+	return NewCheckedMessage(&cappedMessage{Logger: cl, cm: cm}, lvl, msg)
+}
+
+func (cl *cappedLogger) Log(lvl Level, msg string, fields ...Field) {
+	if lvl > cl.max {
+		cl.logCapped(lvl, msg, fields)
+		return
+	}
+	cl.log.Log(lvl, msg, fields...)
+}
+
+func (cl *cappedLogger) Trace(msg string, fields ...Field) {
+	if TraceLevel > cl.max {
+		cl.logCapped(TraceLevel, msg, fields)
+		return
+	}
+	cl.log.Trace(msg, fields...)
+}
+
+func (cl *cappedLogger) Debug(msg string, fields ...Field) {
+	if DebugLevel > cl.max {
+		cl.logCapped(DebugLevel, msg, fields)
+		return
+	}
+	cl.log.Debug(msg, fields...)
+}
+
+func (cl *cappedLogger) Info(msg string, fields ...Field) {
+	if InfoLevel > cl.max {
+		cl.logCapped(InfoLevel, msg, fields)
+		return
+	}
+	cl.log.Info(msg, fields...)
+}
+
+func (cl *cappedLogger) Warn(msg string, fields ...Field) {
+	if WarnLevel > cl.max {
+		cl.logCapped(WarnLevel, msg, fields)
+		return
+	}
+	cl.log.Warn(msg, fields...)
+}
+
+func (cl *cappedLogger) Error(msg string, fields ...Field) {
+	if ErrorLevel > cl.max {
+		cl.logCapped(ErrorLevel, msg, fields)
+		return
+	}
+	cl.log.Error(msg, fields...)
+}
+
+func (cl *cappedLogger) DPanic(msg string, fields ...Field) {
+	if DPanicLevel > cl.max {
+		cl.logCapped(DPanicLevel, msg, fields)
+		return
+	}
+	cl.log.DPanic(msg, fields...)
+}
+
+func (cl *cappedLogger) Panic(msg string, fields ...Field) {
+	if PanicLevel > cl.max {
+		cl.logCapped(PanicLevel, msg, fields)
+		return
+	}
+	cl.log.Panic(msg, fields...)
+}
+
+func (cl *cappedLogger) Fatal(msg string, fields ...Field) {
+	if FatalLevel > cl.max {
+		cl.logCapped(FatalLevel, msg, fields)
+		return
+	}
+	cl.log.Fatal(msg, fields...)
+}
+
+func (cl *cappedLogger) Terminates(lvl Level) bool {
+	return lvl <= cl.max && terminates(cl.log, lvl)
+}
+
+func (cl *cappedLogger) encodeTerminal(lvl Level, msg string, fields []Field) func() {
+	if lvl > cl.max {
+		return encodeTerminal(cl.log, cl.max, msg, withOriginalLevel(lvl, fields))
+	}
+	return encodeTerminal(cl.log, lvl, msg, fields)
+}
+
+// logCapped writes the entry at the capped level. Since Log never panics or
+// exits, this is safe even if the cap is PanicLevel or FatalLevel.
+func (cl *cappedLogger) logCapped(lvl Level, msg string, fields []Field) {
+	cl.log.Log(cl.max, msg, withOriginalLevel(lvl, fields)...)
+}
+
+// withOriginalLevel appends the original level to a copy of the fields, so
+// the caller's slice isn't modified.
+func withOriginalLevel(lvl Level, fields []Field) []Field {
+	capped := make([]Field, 0, len(fields)+1)
+	capped = append(capped, fields...)
+	return append(capped, String("original_level", lvl.String()))
+}
+
+// A cappedMessage writes a CheckedMessage from the wrapped logger, adding the
+// original level. Like a filteredMessage, it only needs the level methods and
+// Log; everything else is delegated to the cappedLogger.
+type cappedMessage struct {
+	Logger
+
+	cm *CheckedMessage
+}
+
+func (cm *cappedMessage) write(lvl Level, fields []Field) {
+	cm.cm.Write(withOriginalLevel(lvl, fields)...)
+}
+
+func (cm *cappedMessage) Terminates(lvl Level) bool { return false }
+func (cm *cappedMessage) wrappedLogger() Logger     { return cm.Logger }
+
+func (cm *cappedMessage) Log(lvl Level, msg string, fields ...Field) { cm.write(lvl, fields) }
+func (cm *cappedMessage) Trace(msg string, fields ...Field)          { cm.write(TraceLevel, fields) }
+func (cm *cappedMessage) Debug(msg string, fields ...Field)          { cm.write(DebugLevel, fields) }
+func (cm *cappedMessage) Info(msg string, fields ...Field)           { cm.write(InfoLevel, fields) }
+func (cm *cappedMessage) Warn(msg string, fields ...Field)           { cm.write(WarnLevel, fields) }
+func (cm *cappedMessage) Error(msg string, fields ...Field)          { cm.write(ErrorLevel, fields) }
+func (cm *cappedMessage) DPanic(msg string, fields ...Field)         { cm.write(DPanicLevel, fields) }
+func (cm *cappedMessage) Panic(msg string, fields ...Field)          { cm.write(PanicLevel, fields) }
+func (cm *cappedMessage) Fatal(msg string, fields ...Field)          { cm.write(FatalLevel, fields) }
@@ -0,0 +1,146 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withCappedLogger(max Level, opts []Option, f func(Logger, *testBuffer)) {
+	sink := &testBuffer{}
+	// Entries above InfoLevel are also written to ErrorOutput.
+	allOpts := append([]Option{TraceLevel, Output(sink), ErrorOutput(Discard)}, opts...)
+	f(CapLevel(New(newJSONEncoder(NoTime()), allOpts...), max), sink)
+}
+
+func TestCapLevel(t *testing.T) {
+	tests := []struct {
+		desc     string
+		f        func(Logger)
+		expected string
+	}{
+		{"Trace", func(l Logger) { l.Trace("") }, `{"level":"trace","msg":""}`},
+		{"Debug", func(l Logger) { l.Debug("") }, `{"level":"debug","msg":""}`},
+		{"Info", func(l Logger) { l.Info("") }, `{"level":"info","msg":""}`},
+		{"Warn", func(l Logger) { l.Warn("") }, `{"level":"warn","msg":""}`},
+		{"Error", func(l Logger) { l.Error("") }, `{"level":"error","msg":""}`},
+		{"DPanic", func(l Logger) { l.DPanic("", Int("n", 1)) }, `{"level":"error","msg":"","n":1,"original_level":"dpanic"}`},
+		{"Panic", func(l Logger) { l.Panic("") }, `{"level":"error","msg":"","original_level":"panic"}`},
+		{"Fatal", func(l Logger) { l.Fatal("") }, `{"level":"error","msg":"","original_level":"fatal"}`},
+		{"Log", func(l Logger) { l.Log(FatalLevel, "") }, `{"level":"error","msg":"","original_level":"fatal"}`},
+		{"Log", func(l Logger) { l.Log(WarnLevel, "") }, `{"level":"warn","msg":""}`},
+		{"Check", func(l Logger) { l.Check(FatalLevel, "").Write(Int("n", 1)) }, `{"level":"error","msg":"","n":1,"original_level":"fatal"}`},
+		{"Check", func(l Logger) { l.Check(PanicLevel, "").Write() }, `{"level":"error","msg":"","original_level":"panic"}`},
+		{"Check", func(l Logger) { l.Check(InfoLevel, "").Write() }, `{"level":"info","msg":""}`},
+	}
+
+	for _, tt := range tests {
+		withCappedLogger(ErrorLevel, []Option{Development()}, func(logger Logger, buf *testBuffer) {
+			stub := stubExit()
+			defer stub.Unstub()
+			assert.NotPanics(t, func() { tt.f(logger) }, "Unexpected panic from capped %s.", tt.desc)
+			stub.AssertNoExit(t)
+			assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output from capped %s.", tt.desc)
+		})
+	}
+}
+
+func TestCapLevelLowerCap(t *testing.T) {
+	withCappedLogger(WarnLevel, nil, func(capped Logger, buf *testBuffer) {
+		capped.Info("")
+		capped.Error("")
+		assert.Equal(t, []string{
+			`{"level":"info","msg":""}`,
+			`{"level":"warn","msg":"","original_level":"error"}`,
+		}, buf.Lines(), "Unexpected output from a logger capped at WarnLevel.")
+	})
+}
+
+func TestCapLevelPassesThrough(t *testing.T) {
+	withCappedLogger(FatalLevel, nil, func(capped Logger, buf *testBuffer) {
+		stub := stubExit()
+		defer stub.Unstub()
+		assert.Panics(t, func() { capped.Panic("") }, "Expected Panic to panic below the cap.")
+		capped.Fatal("")
+		stub.AssertStatus(t, 1)
+		assert.Equal(t, []string{
+			`{"level":"panic","msg":""}`,
+			`{"level":"fatal","msg":""}`,
+		}, buf.Lines(), "Unexpected output from a logger capped at FatalLevel.")
+	})
+}
+
+func TestCapLevelWith(t *testing.T) {
+	withCappedLogger(ErrorLevel, nil, func(logger Logger, buf *testBuffer) {
+		stub := stubExit()
+		defer stub.Unstub()
+		capped := logger.With(String("lib", "third-party"))
+		capped.Fatal("", String("reason", "oops"))
+		stub.AssertNoExit(t)
+		assert.Equal(
+			t,
+			`{"level":"error","msg":"","lib":"third-party","reason":"oops","original_level":"fatal"}`,
+			buf.Stripped(),
+			"Expected context to be preserved through With.",
+		)
+	})
+}
+
+func TestCapLevelCheckRespectsEnabled(t *testing.T) {
+	sink := &testBuffer{}
+	logger := CapLevel(New(NewJSONEncoder(NoTime()), FatalLevel, Output(sink)), ErrorLevel)
+	assert.Nil(t, logger.Check(FatalLevel, ""), "Expected a nil CheckedMessage when the capped level is disabled.")
+	assert.Nil(t, logger.Check(ErrorLevel, ""), "Expected a nil CheckedMessage for disabled levels.")
+	logger.Fatal("")
+	assert.Empty(t, sink.String(), "Expected no output when the capped level is disabled.")
+}
+
+// A redirectedCheck returns CheckedMessages from a different logger than the
+// one its level methods write to.
+type redirectedCheck struct {
+	Logger
+
+	checked Logger
+}
+
+func (rc redirectedCheck) Check(lvl Level, msg string) *CheckedMessage {
+	return rc.checked.Check(lvl, msg)
+}
+
+func TestCapLevelCheckWritesWrappedMessage(t *testing.T) {
+	logged, checked := &testBuffer{}, &testBuffer{}
+	logger := CapLevel(redirectedCheck{
+		Logger:  New(NewJSONEncoder(NoTime()), Output(logged)),
+		checked: New(NewJSONEncoder(NoTime()), Output(checked)),
+	}, ErrorLevel)
+
+	stub := stubExit()
+	defer stub.Unstub()
+	logger.Check(FatalLevel, "").Chain(logger.Check(PanicLevel, "")).Write(Int("n", 1))
+	stub.AssertNoExit(t)
+	assert.Equal(t, []string{
+		`{"level":"error","msg":"","n":1,"original_level":"fatal"}`,
+		`{"level":"error","msg":"","n":1,"original_level":"panic"}`,
+	}, checked.Lines(), "Expected capped messages to write the wrapped logger's CheckedMessage.")
+	assert.Empty(t, logged.String(), "Expected no output from the wrapped logger's level methods.")
+}
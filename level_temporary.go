@@ -0,0 +1,119 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"sync"
+	"time"
+)
+
+// An AdjustableLevel is a LevelSetter that can also report its current
+// level, like the AtomicLevel returned by DynamicLevel.
+type AdjustableLevel interface {
+	LevelSetter
+	Level() Level
+}
+
+// TemporarilySetLevel changes the target's level to lvl, and changes it back
+// when the duration elapses or the returned function is called, whichever
+// comes first. It's useful for turning up verbosity during an incident
+// without risking that nobody remembers to turn it back down. Calling the
+// returned function more than once, or after the duration has elapsed, is a
+// no-op.
+//
+// Both changes are logged to the supplied Logger at InfoLevel, along with the
+// supplied fields (for example, who made the change and why). Like
+// WatchConfig, each entry is written while the lower of the two levels is in
+// effect. If the target's level was changed by someone else in the meantime,
+// it's left alone and a warning is logged instead.
+func TemporarilySetLevel(log Logger, target AdjustableLevel, lvl Level, d time.Duration, fields ...Field) (cancel func()) {
+	t := &temporaryLevel{
+		log:    log,
+		target: target,
+		prev:   target.Level(),
+		lvl:    lvl,
+		fields: append([]Field(nil), fields...),
+	}
+	entry := t.with(Stringer("from", t.prev), Stringer("to", lvl), Duration("duration", d))
+	if lvl > t.prev {
+		// Log before raising the level, in case it silences the entry.
+		log.Info("Temporarily changing log level.", entry...)
+	}
+	target.SetLevel(lvl)
+	if lvl <= t.prev {
+		log.Info("Temporarily changing log level.", entry...)
+	}
+	t.timer = time.AfterFunc(d, t.restore)
+	return func() {
+		t.timer.Stop()
+		t.restore()
+	}
+}
+
+type temporaryLevel struct {
+	sync.Once
+
+	log    Logger
+	target AdjustableLevel
+	prev   Level
+	lvl    Level
+	fields []Field
+	timer  *time.Timer
+}
+
+func (t *temporaryLevel) restore() {
+	t.Do(func() {
+		entry := t.with(Stringer("from", t.lvl), Stringer("to", t.prev))
+		raising := t.prev > t.lvl
+		if raising && t.target.Level() == t.lvl {
+			t.log.Info("Restoring original log level.", entry...)
+		}
+		if !casLevel(t.target, t.lvl, t.prev) {
+			t.log.Warn(
+				"Log level was changed while temporarily set; leaving it unchanged.",
+				t.with(Stringer("expected", t.lvl), Stringer("current", t.target.Level()), Stringer("original", t.prev))...,
+			)
+			return
+		}
+		if !raising {
+			t.log.Info("Restoring original log level.", entry...)
+		}
+	})
+}
+
+func (t *temporaryLevel) with(extra ...Field) []Field {
+	return append(t.fields[:len(t.fields):len(t.fields)], extra...)
+}
+
+// casLevel changes the target's level, but only if it's still from. It's
+// atomic if the target supports it.
+func casLevel(target AdjustableLevel, from, to Level) bool {
+	if c, ok := target.(interface {
+		compareAndSwap(from, to Level) bool
+	}); ok {
+		return c.compareAndSwap(from, to)
+	}
+	if target.Level() != from {
+		return false
+	}
+	target.SetLevel(to)
+	return true
+}
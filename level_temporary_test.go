@@ -0,0 +1,101 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unatomicLevel is an AdjustableLevel that doesn't support compare-and-swap.
+type unatomicLevel struct{ AtomicLevel }
+
+func (l unatomicLevel) Level() Level      { return l.AtomicLevel.Level() }
+func (l unatomicLevel) SetLevel(lv Level) { l.AtomicLevel.SetLevel(lv) }
+
+func withTemporaryLevel(f func(lvl AtomicLevel, logger Logger, buf *lockedBuffer)) {
+	lvl := DynamicLevel()
+	buf := &lockedBuffer{}
+	f(lvl, New(newJSONEncoder(NoTime()), lvl, Output(buf), ErrorOutput(Discard)), buf)
+}
+
+func TestTemporarilySetLevelExpires(t *testing.T) {
+	withTemporaryLevel(func(lvl AtomicLevel, logger Logger, buf *lockedBuffer) {
+		TemporarilySetLevel(logger, lvl, DebugLevel, 10*time.Millisecond, String("user", "alice"))
+		assert.Equal(t, DebugLevel, lvl.Level(), "Expected the temporary level to be applied.")
+		waitFor(t, "the level to be restored", func() bool { return lvl.Level() == InfoLevel })
+		waitFor(t, "the restoration to be logged", func() bool { return len(buf.Lines()) == 2 })
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"Temporarily changing log level.","user":"alice","from":"info","to":"debug","duration":10000000}`,
+			`{"level":"info","msg":"Restoring original log level.","user":"alice","from":"debug","to":"info"}`,
+		}, buf.Lines(), "Unexpected output.")
+	})
+}
+
+func TestTemporarilySetLevelCancel(t *testing.T) {
+	withTemporaryLevel(func(lvl AtomicLevel, logger Logger, buf *lockedBuffer) {
+		cancel := TemporarilySetLevel(logger, lvl, ErrorLevel, time.Hour, String("reason", "noisy"))
+		assert.Equal(t, ErrorLevel, lvl.Level(), "Expected the temporary level to be applied.")
+		cancel()
+		assert.Equal(t, InfoLevel, lvl.Level(), "Expected cancel to restore the original level.")
+		cancel()
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"Temporarily changing log level.","reason":"noisy","from":"info","to":"error","duration":3600000000000}`,
+			`{"level":"info","msg":"Restoring original log level.","reason":"noisy","from":"error","to":"info"}`,
+		}, buf.Lines(), "Expected both changes to be logged exactly once.")
+	})
+}
+
+func TestTemporarilySetLevelCancelAfterExpiry(t *testing.T) {
+	withTemporaryLevel(func(lvl AtomicLevel, logger Logger, buf *lockedBuffer) {
+		cancel := TemporarilySetLevel(logger, lvl, DebugLevel, time.Millisecond)
+		waitFor(t, "the restoration to be logged", func() bool { return len(buf.Lines()) == 2 })
+		lvl.SetLevel(DebugLevel)
+		cancel()
+		assert.Equal(t, DebugLevel, lvl.Level(), "Expected cancel to be a no-op after expiry.")
+		assert.Equal(t, 2, len(buf.Lines()), "Expected cancel not to log after expiry.")
+	})
+}
+
+func TestTemporarilySetLevelConcurrentChange(t *testing.T) {
+	for _, wrap := range []func(AtomicLevel) AdjustableLevel{
+		func(lvl AtomicLevel) AdjustableLevel { return lvl },
+		func(lvl AtomicLevel) AdjustableLevel { return unatomicLevel{lvl} },
+	} {
+		withTemporaryLevel(func(lvl AtomicLevel, logger Logger, buf *lockedBuffer) {
+			cancel := TemporarilySetLevel(logger, wrap(lvl), DebugLevel, time.Hour, String("user", "alice"))
+			lvl.SetLevel(WarnLevel)
+			cancel()
+			assert.Equal(t, WarnLevel, lvl.Level(), "Expected a level changed by someone else to be left alone.")
+			lines := buf.Lines()
+			require.Equal(t, 2, len(lines), "Unexpected number of entries.")
+			assert.Equal(
+				t,
+				`{"level":"warn","msg":"Log level was changed while temporarily set; leaving it unchanged.","user":"alice","expected":"debug","current":"warn","original":"info"}`,
+				lines[1],
+				"Unexpected warning.",
+			)
+		})
+	}
+}
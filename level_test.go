@@ -40,8 +40,29 @@ func TestLevelEnablerFunc(t *testing.T) {
 	})
 }
 
+func TestExactAndRangeLevels(t *testing.T) {
+	exact := ExactLevel(ErrorLevel)
+	between := RangeLevel(InfoLevel, ErrorLevel)
+	for _, lvl := range []Level{TraceLevel, DebugLevel, InfoLevel, WarnLevel, ErrorLevel, DPanicLevel, PanicLevel, FatalLevel} {
+		assert.Equal(t, lvl == ErrorLevel, exact.Enabled(lvl), "Unexpected result from ExactLevel at %v.", lvl)
+		assert.Equal(t, lvl >= InfoLevel && lvl <= ErrorLevel, between.Enabled(lvl), "Unexpected result from RangeLevel at %v.", lvl)
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	dl := DynamicLevel()
+	assert.NoError(t, SetLevel(dl, ErrorLevel), "Unexpected error setting a dynamic level.")
+	assert.Equal(t, ErrorLevel, dl.Level(), "Expected SetLevel to change a dynamic level.")
+
+	for _, enab := range []LevelEnabler{WarnLevel, ExactLevel(WarnLevel)} {
+		assert.Error(t, SetLevel(enab, DebugLevel), "Expected an error setting a static LevelEnabler.")
+		assert.False(t, enab.Enabled(DebugLevel), "Expected a static LevelEnabler not to change.")
+	}
+}
+
 func TestLevelString(t *testing.T) {
 	tests := map[Level]string{
+		TraceLevel:  "trace",
 		DebugLevel:  "debug",
 		InfoLevel:   "info",
 		WarnLevel:   "warn",
@@ -62,6 +83,7 @@ func TestLevelText(t *testing.T) {
 		text  string
 		level Level
 	}{
+		{"trace", TraceLevel},
 		{"debug", DebugLevel},
 		{"info", InfoLevel},
 		{"warn", WarnLevel},
@@ -122,6 +144,8 @@ func TestLevelAsFlagValue(t *testing.T) {
 	assert.Equal(t, WarnLevel, lvl)
 	assert.NoError(t, fs.Parse([]string{"-level", "debug"}))
 	assert.Equal(t, DebugLevel, lvl)
+	assert.NoError(t, fs.Parse([]string{"-level", "trace"}))
+	assert.Equal(t, TraceLevel, lvl)
 
 	// errors work
 	assert.Error(t, fs.Parse([]string{"-level", "nope"}))
@@ -0,0 +1,202 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// How often WatchConfig checks its file for changes. It's a variable for tests.
+var _configPollInterval = time.Second
+
+var errNoWatchTargets = errors.New("must supply at least one LevelSetter to watch a config file")
+
+// A LevelSetter is a LevelEnabler whose level can be changed, like the
+// AtomicLevel returned by DynamicLevel.
+type LevelSetter interface {
+	SetLevel(Level)
+}
+
+// levelConfig is the format of the files read by WatchConfig. It's the same
+// as the payload accepted by AtomicLevel's ServeHTTP.
+type levelConfig struct {
+	Level *Level `json:"level"`
+}
+
+func parseLevelConfig(data []byte) (Level, error) {
+	var cfg levelConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return 0, fmt.Errorf("couldn't parse config: %v", err)
+	}
+	if cfg.Level == nil {
+		return 0, errors.New("config must specify a logging level")
+	}
+	return *cfg.Level, nil
+}
+
+// WatchConfig sets the level of the supplied targets from a JSON file, like
+// {"level":"info"}, and then polls the file (once a second) and applies any
+// changes, so that the log level can be managed without restarting the
+// process. Each change is logged to the supplied Logger at InfoLevel,
+// recording the old and new levels. The entry is written while the lower of
+// the two levels is in effect, so it's visible even if the logger's own level
+// is among the targets.
+//
+// If the file can't be read or doesn't contain a valid level, the targets are
+// left unchanged and the problem is logged at ErrorLevel (once, until the
+// file changes again). At the start, that's an error instead, and no watcher
+// is started.
+//
+// Calling the returned function stops the watcher and waits for its
+// goroutine to exit; it's safe to call more than once.
+func WatchConfig(log Logger, path string, targets ...LevelSetter) (stop func(), err error) {
+	if len(targets) == 0 {
+		return nil, errNoWatchTargets
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lvl, err := parseLevelConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %q: %v", path, err)
+	}
+
+	w := &configWatcher{
+		log:     log,
+		path:    path,
+		targets: append([]LevelSetter(nil), targets...),
+		last:    data,
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	// Set every target, even if the first one is already at lvl; the others
+	// may not be. Only log if we know the level changed.
+	w.level = lvl
+	if old, ok := w.current(); ok && old != lvl {
+		w.change(old, lvl)
+	} else {
+		w.set(lvl)
+	}
+	go w.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(w.done)
+			<-w.exited
+		})
+	}, nil
+}
+
+type configWatcher struct {
+	log     Logger
+	path    string
+	targets []LevelSetter
+	// The last level applied and the contents of the file when it was last
+	// read (nil if it couldn't be).
+	level Level
+	last  []byte
+
+	done   chan struct{}
+	exited chan struct{}
+}
+
+// current returns the level of the first target that can report one.
+func (w *configWatcher) current() (Level, bool) {
+	for _, t := range w.targets {
+		if l, ok := t.(interface {
+			Level() Level
+		}); ok {
+			return l.Level(), true
+		}
+	}
+	return 0, false
+}
+
+func (w *configWatcher) run() {
+	defer close(w.exited)
+	ticker := time.NewTicker(_configPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+func (w *configWatcher) poll() {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		if w.last != nil {
+			w.log.Error("Couldn't read log level config; keeping the current level.", String("path", w.path), Error(err))
+		}
+		w.last = nil
+		return
+	}
+	if w.last != nil && bytes.Equal(data, w.last) {
+		return
+	}
+	w.last = data
+	lvl, err := parseLevelConfig(data)
+	if err != nil {
+		w.log.Error("Invalid log level config; keeping the current level.", String("path", w.path), Error(err))
+		return
+	}
+	w.apply(lvl)
+}
+
+func (w *configWatcher) apply(lvl Level) {
+	if lvl == w.level {
+		return
+	}
+	old := w.level
+	w.level = lvl
+	w.change(old, lvl)
+}
+
+// change sets the targets' level, logging the change from old.
+func (w *configWatcher) change(old, lvl Level) {
+	fields := []Field{String("path", w.path), Stringer("from", old), Stringer("to", lvl)}
+	if lvl > old {
+		// Log before raising the level, in case it silences the entry.
+		w.log.Info("Log level changed.", fields...)
+	}
+	w.set(lvl)
+	if lvl < old {
+		w.log.Info("Log level changed.", fields...)
+	}
+}
+
+func (w *configWatcher) set(lvl Level) {
+	for _, t := range w.targets {
+		t.SetLevel(lvl)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/zap/testutils"
+)
+
+// lockedBuffer is a testBuffer that's safe to read while a watcher writes to
+// it.
+type lockedBuffer struct {
+	sync.Mutex
+	buf testBuffer
+}
+
+func (b *lockedBuffer) Write(bs []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(bs)
+}
+
+func (b *lockedBuffer) Sync() error {
+	return nil
+}
+
+func (b *lockedBuffer) Lines() []string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Lines()
+}
+
+func withConfigFile(t testing.TB, contents string, f func(path string)) {
+	dir, err := ioutil.TempDir("", "zap-watch")
+	require.NoError(t, err, "Failed to create a temporary directory.")
+	defer os.RemoveAll(dir)
+
+	defer func(d time.Duration) { _configPollInterval = d }(_configPollInterval)
+	_configPollInterval = time.Millisecond
+
+	path := filepath.Join(dir, "level.json")
+	writeConfigFile(t, path, contents)
+	f(path)
+}
+
+// writeConfigFile replaces the config file atomically, as most configuration
+// management systems do, so that the watcher never sees a partial write.
+func writeConfigFile(t testing.TB, path, contents string) {
+	tmp := path + ".tmp"
+	require.NoError(t, ioutil.WriteFile(tmp, []byte(contents), 0644), "Failed to write config file.")
+	require.NoError(t, os.Rename(tmp, path), "Failed to replace config file.")
+}
+
+// waitFor polls cond until it's true, failing the test if it takes too long.
+func waitFor(t testing.TB, desc string, cond func() bool) {
+	deadline := time.Now().Add(testutils.Timeout(time.Second))
+	for !cond() {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for %s.", desc)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	withConfigFile(t, `{"level":"warn"}`, func(path string) {
+		lvl, other := DynamicLevel(), DynamicLevel()
+		buf := &lockedBuffer{}
+		logger := New(newJSONEncoder(NoTime()), lvl, Output(buf), ErrorOutput(Discard))
+
+		stop, err := WatchConfig(logger, path, lvl, other)
+		require.NoError(t, err, "Unexpected error watching a valid config file.")
+		defer stop()
+		assert.Equal(t, WarnLevel, lvl.Level(), "Expected the initial level to be applied.")
+		assert.Equal(t, WarnLevel, other.Level(), "Expected the initial level to be applied to every target.")
+
+		writeConfigFile(t, path, `{"level":"debug"}`)
+		waitFor(t, "the level to change", func() bool { return lvl.Level() == DebugLevel })
+		assert.Equal(t, DebugLevel, other.Level(), "Expected changes to be applied to every target.")
+
+		writeConfigFile(t, path, `{"level":`)
+		waitFor(t, "the malformed file to be reported", func() bool { return len(buf.Lines()) == 3 })
+		writeConfigFile(t, path, `{"level":"verbose"}`)
+		waitFor(t, "the invalid level to be reported", func() bool { return len(buf.Lines()) == 4 })
+		assert.Equal(t, DebugLevel, lvl.Level(), "Expected invalid config files not to be applied.")
+
+		writeConfigFile(t, path, `{"level":"error"}`)
+		waitFor(t, "the level to change", func() bool { return lvl.Level() == ErrorLevel })
+
+		lines := buf.Lines()
+		require.Equal(t, 5, len(lines), "Unexpected number of entries.")
+		expectedPath := strings.Replace(path, `\`, `\\`, -1)
+		assert.Equal(t, `{"level":"info","msg":"Log level changed.","path":"`+expectedPath+`","from":"info","to":"warn"}`, lines[0], "Unexpected entry for the initial level.")
+		assert.Equal(t, `{"level":"info","msg":"Log level changed.","path":"`+expectedPath+`","from":"warn","to":"debug"}`, lines[1], "Unexpected entry for lowering the level.")
+		assert.Contains(t, lines[2], `"level":"error","msg":"Invalid log level config; keeping the current level."`, "Unexpected entry for a malformed file.")
+		assert.Contains(t, lines[2], "couldn't parse config", "Unexpected entry for a malformed file.")
+		assert.Contains(t, lines[3], "unrecognized level: verbose", "Unexpected entry for an unknown level.")
+		assert.Equal(t, `{"level":"info","msg":"Log level changed.","path":"`+expectedPath+`","from":"debug","to":"error"}`, lines[4], "Unexpected entry for raising the level.")
+	})
+}
+
+func TestWatchConfigTargetsAtDifferentLevels(t *testing.T) {
+	withConfigFile(t, `{"level":"warn"}`, func(path string) {
+		first, second := DynamicLevel(), DynamicLevel()
+		first.SetLevel(WarnLevel)
+		second.SetLevel(DebugLevel)
+		buf := &lockedBuffer{}
+		logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard))
+
+		stop, err := WatchConfig(logger, path, first, second)
+		require.NoError(t, err, "Unexpected error watching a valid config file.")
+		defer stop()
+		assert.Equal(t, WarnLevel, first.Level(), "Expected the first target to keep the configured level.")
+		assert.Equal(t, WarnLevel, second.Level(), "Expected the initial level to be applied to every target.")
+		assert.Empty(t, buf.Lines(), "Expected no entry when the first target's level is unchanged.")
+	})
+}
+
+func TestWatchConfigStop(t *testing.T) {
+	withConfigFile(t, `{"level":"warn"}`, func(path string) {
+		lvl := DynamicLevel()
+		stop, err := WatchConfig(New(NullEncoder()), path, lvl)
+		require.NoError(t, err, "Unexpected error watching a valid config file.")
+		stop()
+		stop()
+
+		writeConfigFile(t, path, `{"level":"debug"}`)
+		time.Sleep(10 * _configPollInterval)
+		assert.Equal(t, WarnLevel, lvl.Level(), "Expected no changes after stopping the watcher.")
+	})
+}
+
+func TestWatchConfigErrors(t *testing.T) {
+	withConfigFile(t, `{"level": 42}`, func(path string) {
+		lvl := DynamicLevel()
+		_, err := WatchConfig(New(NullEncoder()), path, lvl)
+		assert.Error(t, err, "Expected an error watching a malformed config file.")
+
+		writeConfigFile(t, path, `{}`)
+		_, err = WatchConfig(New(NullEncoder()), path, lvl)
+		assert.Contains(t, err.Error(), "config must specify a logging level", "Expected an error watching a config file without a level.")
+
+		_, err = WatchConfig(New(NullEncoder()), path+".missing", lvl)
+		assert.Error(t, err, "Expected an error watching a missing config file.")
+
+		_, err = WatchConfig(New(NullEncoder()), path)
+		assert.Equal(t, errNoWatchTargets, err, "Expected an error watching a config file without targets.")
+		assert.Equal(t, InfoLevel, lvl.Level(), "Expected failed watches not to change the level.")
+	})
+}
@@ -28,6 +28,11 @@ import (
 // For tests.
 var _exit = os.Exit
 
+// _terminalTimeout caps how long Panic and Fatal wait for their entries to be
+// written, so that the process still terminates if every output is wedged.
+// It's a variable for tests.
+var _terminalTimeout = 5 * time.Second
+
 // A Logger enables leveled, structured logging. All methods are safe for
 // concurrent use.
 type Logger interface {
@@ -48,7 +53,13 @@ type Logger interface {
 	// process, but calling Log(PanicLevel, ...) or Log(FatalLevel, ...) should
 	// not. It may not be possible for compatibility wrappers to comply with
 	// this last part (e.g. the bark wrapper).
+	//
+	// Loggers that combine others, like Tee, rely on this to terminate
+	// exactly once: they write Panic- and Fatal-level messages to each of
+	// their children with Log, flush them, and only then panic or exit.
+	// However deeply they're nested, only the outermost one terminates.
 	Log(Level, string, ...Field)
+	Trace(string, ...Field)
 	Debug(string, ...Field)
 	Info(string, ...Field)
 	Warn(string, ...Field)
@@ -58,6 +69,73 @@ type Logger interface {
 	Fatal(string, ...Field)
 }
 
+// Sync flushes any entries buffered by the logger's outputs. Loggers built on
+// a Meta (including those returned by New) sync their Output and ErrorOutput,
+// Tees sync all their children, and wrappers like CapLevel and Filter sync the
+// loggers they wrap. For Loggers that don't implement a Sync method, it's a
+// no-op.
+func Sync(log Logger) error {
+	if s, ok := log.(interface {
+		Sync() error
+	}); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Context returns a copy of the context fields the logger has accumulated
+// with the Fields option and With, in the order they were added. It's useful
+// for attaching the same context (request IDs, user IDs, and the like) to
+// reports sent elsewhere. Changing the returned slice doesn't affect the
+// logger.
+//
+// The fields are returned as they were added, except that top-level fields
+// whose keys match RedactKeys patterns are replaced with redacted copies.
+// Tees return only the fields added with the Tee's own With method, and
+// wrappers like CapLevel and Filter return the context of the logger they
+// wrap. For Loggers that don't implement a Context method, it returns nil.
+func Context(log Logger) []Field {
+	if c, ok := log.(interface {
+		Context() []Field
+	}); ok {
+		return c.Context()
+	}
+	return nil
+}
+
+// Audit returns an audit logger for security-relevant events, like logins,
+// permission changes, and data exports, which must be recorded regardless of
+// the operational log level. Audit loggers enable InfoLevel and above,
+// whatever the original logger's level, and mark each entry with the field
+// "audit":true. Loggers returned by New write audit entries to their
+// AuditOutput, if they have one.
+//
+// Audit loggers can't be sampled or filtered: wrappers like Filter and
+// zwrap.Sample return audit loggers unchanged, and auditing a wrapper audits
+// the logger it wraps instead. (Tees and CapLevel audit the loggers they wrap
+// but keep their own behavior.) Auditing an audit logger returns it
+// unchanged. Loggers that don't implement an Audit method just get the marker
+// field.
+func Audit(log Logger) Logger {
+	if a, ok := log.(interface {
+		Audit() Logger
+	}); ok {
+		return a.Audit()
+	}
+	return log.With(Bool("audit", true))
+}
+
+// IsAudit reports whether the logger is an audit logger (see Audit). For
+// Loggers that don't implement an IsAudit method, it returns false.
+func IsAudit(log Logger) bool {
+	if a, ok := log.(interface {
+		IsAudit() bool
+	}); ok {
+		return a.IsAudit()
+	}
+	return false
+}
+
 type logger struct{ Meta }
 
 // New constructs a logger that uses the provided encoder. By default, the
@@ -66,6 +144,10 @@ type logger struct{ Meta }
 //
 // Options can change the log level, the output location, the initial fields
 // that should be added as context, and many other behaviors.
+//
+// Panic and Fatal wait at most five seconds for their entries to be written,
+// so the process still panics or exits even if its outputs are wedged. To
+// keep other levels from blocking on a wedged output, see WithTimeout.
 func New(enc Encoder, options ...Option) Logger {
 	return &logger{
 		Meta: MakeMeta(enc, options...),
@@ -76,10 +158,34 @@ func (log *logger) With(fields ...Field) Logger {
 	clone := &logger{
 		Meta: log.Meta.Clone(),
 	}
-	addFields(clone.Encoder, fields)
+	clone.addContext(fields)
+	return clone
+}
+
+func (log *logger) Context() []Field {
+	return log.context.flatten()
+}
+
+func (log *logger) Audit() Logger {
+	if log.audit {
+		return log
+	}
+	clone := &logger{
+		Meta: log.Meta.Clone(),
+	}
+	clone.LevelEnabler = InfoLevel
+	clone.audit = true
+	if clone.auditOutput != nil {
+		clone.Output = clone.auditOutput
+	}
+	clone.addContext([]Field{Bool("audit", true)})
 	return clone
 }
 
+func (log *logger) IsAudit() bool {
+	return log.audit
+}
+
 func (log *logger) Check(lvl Level, msg string) *CheckedMessage {
 	return log.Meta.Check(log, lvl, msg)
 }
@@ -88,6 +194,10 @@ func (log *logger) Log(lvl Level, msg string, fields ...Field) {
 	log.log(lvl, msg, fields)
 }
 
+func (log *logger) Trace(msg string, fields ...Field) {
+	log.log(TraceLevel, msg, fields)
+}
+
 func (log *logger) Debug(msg string, fields ...Field) {
 	log.log(DebugLevel, msg, fields)
 }
@@ -112,35 +222,114 @@ func (log *logger) DPanic(msg string, fields ...Field) {
 }
 
 func (log *logger) Panic(msg string, fields ...Field) {
-	log.log(PanicLevel, msg, fields)
+	log.logTerminal(PanicLevel, msg, fields)
 	panic(msg)
 }
 
 func (log *logger) Fatal(msg string, fields ...Field) {
-	log.log(FatalLevel, msg, fields)
+	log.logTerminal(FatalLevel, msg, fields)
 	_exit(1)
 }
 
+// writeTerminal calls write, which writes an entry that's about to panic or
+// exit, giving up on it after _terminalTimeout. If write panics first, the
+// panic is re-raised on the calling goroutine.
+func writeTerminal(write func()) {
+	done := make(chan terminalResult, 1)
+	go func() {
+		res := terminalResult{panicked: true}
+		defer func() {
+			if res.panicked {
+				res.recovered = recover()
+			}
+			done <- res
+		}()
+		write()
+		res.panicked = false
+	}()
+	timer := time.NewTimer(_terminalTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		if res.panicked {
+			panic(res.recovered)
+		}
+	case <-timer.C:
+	}
+}
+
+type terminalResult struct {
+	recovered interface{}
+	panicked  bool
+}
+
+// A terminalEncoder builds an entry that's about to panic or exit without
+// writing it, returning a function that writes and syncs it. This lets
+// wrappers like Tee build their children's entries on the calling goroutine,
+// so that callers and stack traces are captured as usual, and hand only the
+// writes to writeTerminal.
+type terminalEncoder interface {
+	encodeTerminal(lvl Level, msg string, fields []Field) func()
+}
+
+// encodeTerminal prepares a terminal entry for any Logger. Loggers that can't
+// build entries ahead of time are written with Log and Sync when the returned
+// function runs, which may outlive the caller (see writeTerminal), so they get
+// a copy of the fields.
+func encodeTerminal(log Logger, lvl Level, msg string, fields []Field) func() {
+	if te, ok := log.(terminalEncoder); ok {
+		return te.encodeTerminal(lvl, msg, fields)
+	}
+	fields = append([]Field(nil), fields...)
+	return func() {
+		log.Log(lvl, msg, fields...)
+		Sync(log)
+	}
+}
+
 func (log *logger) log(lvl Level, msg string, fields []Field) {
 	if !log.Meta.Enabled(lvl) {
 		return
 	}
+	log.write(lvl, log.Encode(log.Clock.Now(), lvl, msg, fields))
+}
+
+// logTerminal is like log, but for entries that are about to panic or exit.
+// The entry is still built (and the hooks run) on the calling goroutine, so
+// that callers and stack traces are captured as usual; only writing and
+// syncing it are subject to _terminalTimeout.
+func (log *logger) logTerminal(lvl Level, msg string, fields []Field) {
+	if !log.Meta.Enabled(lvl) {
+		return
+	}
+	entry := log.Encode(log.Clock.Now(), lvl, msg, fields)
+	writeTerminal(func() { log.write(lvl, entry) })
+}
+
+func (log *logger) encodeTerminal(lvl Level, msg string, fields []Field) func() {
+	if !log.Meta.Enabled(lvl) {
+		return func() {}
+	}
+	entry := log.Encode(log.Clock.Now(), lvl, msg, fields)
+	return func() { log.write(lvl, entry) }
+}
 
-	t := time.Now()
-	enc := log.Encode(t, lvl, &msg, fields)
-	if err := enc.WriteEntry(log.Output, msg, lvl, t); err != nil {
+// write writes the entry to the logger's outputs and frees it.
+func (log *logger) write(lvl Level, entry *Entry) {
+	if err := entry.Write(log.Output); err != nil {
 		log.InternalError("encoder", err)
 	}
 
 	if lvl > InfoLevel {
-		if err := enc.WriteEntry(log.ErrorOutput, msg, lvl, t); err != nil {
+		if err := entry.Write(log.ErrorOutput); err != nil {
 			log.InternalError("encoder", err)
 		}
 	}
-	enc.Free()
+	entry.Free()
 
 	if lvl > ErrorLevel {
-		// Sync on Panic and Fatal, since they may crash the program.
-		log.Output.Sync()
+		// Sync on Panic and Fatal, since they may crash the program. Both
+		// outputs received the entry.
+		log.Meta.Sync()
 	}
 }
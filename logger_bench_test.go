@@ -22,6 +22,7 @@ package zap_test
 
 import (
 	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -103,6 +104,64 @@ func BenchmarkStringerField(b *testing.B) {
 	})
 }
 
+type countingStringer struct{ calls int64 }
+
+func (c *countingStringer) String() string {
+	atomic.AddInt64(&c.calls, 1)
+	return "counted"
+}
+
+func BenchmarkStringerFieldDisabled(b *testing.B) {
+	c := &countingStringer{}
+	logger := zap.New(zap.NewJSONEncoder(), zap.InfoLevel, zap.DiscardOutput)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Debug("Level.", zap.Stringer("foo", c))
+		}
+	})
+	if calls := atomic.LoadInt64(&c.calls); calls != 0 {
+		b.Fatalf("Expected no calls to String at a disabled level, got %d.", calls)
+	}
+}
+
+// writePrimitiveFields uses a CheckedMessage, since calling a method on the
+// Logger interface makes the variadic fields escape to the heap.
+func writePrimitiveFields(cm *zap.CheckedMessage, t time.Time) {
+	cm.Write(
+		zap.Bool("bool", true),
+		zap.Int("int", 1),
+		zap.Int64("int64", 2),
+		zap.Uint64("uint64", 3),
+		zap.Uintptr("uintptr", 4),
+		zap.Float64("float64", 5.5),
+		zap.Float32("float32", 6.5),
+		zap.String("string", "seven"),
+		zap.Duration("duration", time.Second),
+		zap.Time("time", t),
+	)
+}
+
+func BenchmarkPrimitiveFields(b *testing.B) {
+	t := time.Unix(0, 0)
+	b.ReportAllocs()
+	withBenchedLogger(b, func(log zap.Logger) {
+		writePrimitiveFields(log.Check(zap.InfoLevel, "Primitives."), t)
+	})
+}
+
+func BenchmarkPrimitiveFieldsDisabled(b *testing.B) {
+	t := time.Unix(0, 0)
+	logger := zap.New(zap.NewJSONEncoder(), zap.InfoLevel, zap.DiscardOutput)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			writePrimitiveFields(logger.Check(zap.DebugLevel, "Primitives."), t)
+		}
+	})
+}
+
 func BenchmarkTimeField(b *testing.B) {
 	t := time.Unix(0, 0)
 	withBenchedLogger(b, func(log zap.Logger) {
@@ -208,3 +267,62 @@ func Benchmark100Fields(b *testing.B) {
 		logger.With(first...).Info("Child loggers with lots of context.", second...)
 	}
 }
+
+func withChain(log zap.Logger, depth int) zap.Logger {
+	for i := 0; i < depth; i++ {
+		log = log.With(
+			zap.String("component", "handler"),
+			zap.Int("depth", i),
+			zap.String("request", "4f3e2d1c-0b9a-8765-4321-fedcba987654"),
+		)
+	}
+	return log
+}
+
+func benchmarkWithChainBuild(b *testing.B, depth int) {
+	logger := zap.New(zap.NewJSONEncoder(), zap.DebugLevel, zap.DiscardOutput)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		withChain(logger, depth)
+	}
+}
+
+func benchmarkWithChainLog(b *testing.B, depth int) {
+	logger := zap.New(zap.NewJSONEncoder(), zap.DebugLevel, zap.DiscardOutput)
+	withBenchedChild(b, withChain(logger, depth), func(log zap.Logger) {
+		log.Info("Deep context.", zap.Int("n", 1))
+	})
+}
+
+func withBenchedChild(b *testing.B, child zap.Logger, f func(zap.Logger)) {
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f(child)
+		}
+	})
+}
+
+func BenchmarkWithChainBuild1(b *testing.B) {
+	benchmarkWithChainBuild(b, 1)
+}
+
+func BenchmarkWithChainBuild4(b *testing.B) {
+	benchmarkWithChainBuild(b, 4)
+}
+
+func BenchmarkWithChainBuild16(b *testing.B) {
+	benchmarkWithChainBuild(b, 16)
+}
+
+func BenchmarkWithChainLog1(b *testing.B) {
+	benchmarkWithChainLog(b, 1)
+}
+
+func BenchmarkWithChainLog4(b *testing.B) {
+	benchmarkWithChainLog(b, 4)
+}
+
+func BenchmarkWithChainLog16(b *testing.B) {
+	benchmarkWithChainLog(b, 16)
+}
@@ -21,16 +21,21 @@
 package zap
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/uber-go/zap/spywrite"
+	"github.com/uber-go/zap/zaptest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func opts(opts ...Option) []Option {
@@ -161,6 +166,36 @@ func TestJSONLoggerInitialFields(t *testing.T) {
 	})
 }
 
+func TestJSONLoggerHostnameAndPID(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	withJSONLogger(t, opts(AddHostname(), AddPID()), func(logger Logger, buf *testBuffer) {
+		logger.Info("")
+		logger.With(Int("foo", 42)).Info("")
+		prefix := fmt.Sprintf(`{"level":"info","msg":"","hostname":%q,"pid":%d`, host, os.Getpid())
+		assert.Equal(t, []string{
+			prefix + "}",
+			prefix + `,"foo":42}`,
+		}, buf.Lines(), "Expected hostname and PID in every entry.")
+	})
+}
+
+func TestJSONLoggerHostnameFallback(t *testing.T) {
+	defer func() { _hostname = os.Hostname }()
+	for _, stub := range []func() (string, error){
+		func() (string, error) { return "", errors.New("fail") },
+		func() (string, error) { return "", nil },
+	} {
+		_hostname = stub
+		withJSONLogger(t, opts(AddHostname()), func(logger Logger, buf *testBuffer) {
+			logger.Info("")
+			assert.Equal(t, `{"level":"info","msg":"","hostname":"unknown"}`, buf.Stripped(), "Expected a fallback hostname.")
+		})
+	}
+}
+
 func TestJSONLoggerWith(t *testing.T) {
 	fieldOpts := opts(Fields(Int("foo", 42)))
 	withJSONLogger(t, fieldOpts, func(logger Logger, buf *testBuffer) {
@@ -177,6 +212,91 @@ func TestJSONLoggerWith(t *testing.T) {
 	})
 }
 
+func TestJSONLoggerWithChain(t *testing.T) {
+	withJSONLogger(t, opts(Fields(Int("foo", 1))), func(logger Logger, buf *testBuffer) {
+		child := logger.With(Namespace("ns"), Int("bar", 2))
+		grandchild := child.With().With(Int("baz", 3))
+		// Adding fields to a parent after creating children shouldn't affect
+		// the children.
+		sibling := logger.With(Int("qux", 4))
+
+		grandchild.Info("", Int("n", 0))
+		child.Info("", Int("n", 1))
+		sibling.Info("")
+		logger.Info("")
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"","foo":1,"ns":{"bar":2,"baz":3,"n":0}}`,
+			`{"level":"info","msg":"","foo":1,"ns":{"bar":2,"n":1}}`,
+			`{"level":"info","msg":"","foo":1,"qux":4}`,
+			`{"level":"info","msg":"","foo":1}`,
+		}, buf.Lines(), "Unexpected output from a chain of child loggers.")
+	})
+}
+
+func TestJSONLoggerConcurrentWith(t *testing.T) {
+	withJSONLogger(t, opts(Fields(Int("foo", 1))), func(logger Logger, buf *testBuffer) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				child := logger.With(Int("child", i))
+				for j := 0; j < 10; j++ {
+					logger.Info("")
+					child.Info("")
+					child.With(Int("grandchild", j)).Info("")
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		counts := make(map[string]int)
+		for _, line := range buf.Lines() {
+			counts[line]++
+		}
+		assert.Equal(t, 100, counts[`{"level":"info","msg":"","foo":1}`], "Unexpected output from the parent logger.")
+		for i := 0; i < 10; i++ {
+			child := fmt.Sprintf(`{"level":"info","msg":"","foo":1,"child":%d`, i)
+			assert.Equal(t, 10, counts[child+"}"], "Unexpected output from child %d.", i)
+			for j := 0; j < 10; j++ {
+				grandchild := fmt.Sprintf(`%s,"grandchild":%d}`, child, j)
+				assert.Equal(t, 1, counts[grandchild], "Unexpected output from grandchild %d of child %d.", j, i)
+			}
+		}
+	})
+}
+
+func TestJSONLoggerWithSkips(t *testing.T) {
+	withJSONLogger(t, opts(Fields(Skip(), Int("foo", 42), Skip())), func(log Logger, buf *testBuffer) {
+		base := log.(*logger).Encoder.(*jsonEncoder).appendFields(nil)
+		skipped := log.With(Skip(), If(false, String("hidden", "")), ErrIf(nil))
+		assert.Equal(t, string(base), string(skipped.(*logger).Encoder.(*jsonEncoder).appendFields(nil)), "Expected skipped fields not to grow the context.")
+
+		skipped.With(Skip(), String("one", "two"), Skip()).Info("", Skip(), If(true, Int("bar", 1)), Skip())
+		skipped.Info("", Skip())
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"","foo":42,"one":"two","bar":1}`,
+			`{"level":"info","msg":"","foo":42}`,
+		}, buf.Lines(), "Unexpected output with skipped fields.")
+	})
+}
+
+func TestJSONLoggerNamespace(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		logger.Info("", Int("foo", 1), Namespace("ns"), Int("bar", 2))
+		child := logger.With(String("foo", "bar"), Namespace("user"), String("name", "jane"))
+		child.Info("", Int("visits", 42))
+		child.With(Namespace("nested"), Namespace("")).Info("", Int("bar", 2))
+		logger.Info("")
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"","foo":1,"ns":{"bar":2}}`,
+			`{"level":"info","msg":"","foo":"bar","user":{"name":"jane","visits":42}}`,
+			`{"level":"info","msg":"","foo":"bar","user":{"name":"jane","nested":{"bar":2}}}`,
+			`{"level":"info","msg":""}`,
+		}, buf.Lines(), "Unexpected output using namespaces.")
+	})
+}
+
 func TestJSONLoggerLog(t *testing.T) {
 	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
 		logger.Log(DebugLevel, "foo")
@@ -230,6 +350,25 @@ func TestJSONLoggerLogFatal(t *testing.T) {
 	}
 }
 
+func TestJSONLoggerTrace(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		// Trace is below Debug, so it's disabled by default.
+		logger.Trace("hidden")
+		assert.False(t, logger.Check(TraceLevel, "hidden").OK(), "Expected TraceLevel to be disabled at DebugLevel.")
+		assert.Empty(t, buf.String(), "Expected no output at a disabled level.")
+	})
+	withJSONLogger(t, opts(TraceLevel), func(logger Logger, buf *testBuffer) {
+		logger.Trace("trace", Int("n", 1))
+		logger.Check(TraceLevel, "checked").Write(Int("n", 2))
+		logger.Debug("debug")
+		assert.Equal(t, []string{
+			`{"level":"trace","msg":"trace","n":1}`,
+			`{"level":"trace","msg":"checked","n":2}`,
+			`{"level":"debug","msg":"debug"}`,
+		}, buf.Lines(), "Unexpected output at TraceLevel.")
+	})
+}
+
 func TestJSONLoggerLeveledMethods(t *testing.T) {
 	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
 		tests := []struct {
@@ -297,6 +436,62 @@ func TestJSONLoggerFatal(t *testing.T) {
 	})
 }
 
+func TestLoggerTerminalCallerAndStack(t *testing.T) {
+	stub := stubExit()
+	defer stub.Unstub()
+
+	// Panic and Fatal time out writes to wedged outputs, but the caller and
+	// stack must still be captured on the logging goroutine.
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard), AddCaller(), AddStacks(PanicLevel))
+	assert.Panics(t, func() { logger.Panic("panic") }, "Expected Panic to panic.")
+	logger.Fatal("fatal")
+	stub.AssertStatus(t, 1)
+
+	lines := buf.Lines()
+	require.Equal(t, 2, len(lines), "Expected an entry for each terminal level.")
+	caller := regexp.MustCompile(`"msg":"logger_test.go:\d+: (panic|fatal)"`)
+	for _, line := range lines {
+		assert.Regexp(t, caller, line, "Expected the caller to be the logging call site.")
+		assert.Contains(t, line, "zap.TestLoggerTerminalCallerAndStack", "Expected the stacktrace to include the test function.")
+	}
+}
+
+func TestTeeTerminalCallerAndStack(t *testing.T) {
+	stub := stubExit()
+	defer stub.Unstub()
+
+	bufs := []*testBuffer{{}, {}}
+	tee := Tee(
+		New(newJSONEncoder(NoTime()), Output(bufs[0]), ErrorOutput(Discard), AddStacks(PanicLevel)),
+		New(newJSONEncoder(NoTime()), Output(bufs[1]), ErrorOutput(Discard), AddStacks(PanicLevel)),
+	)
+	assert.Panics(t, func() { tee.Panic("panic") }, "Expected Panic to panic.")
+	tee.Fatal("fatal")
+	stub.AssertStatus(t, 1)
+
+	for _, buf := range bufs {
+		lines := buf.Lines()
+		require.Equal(t, 2, len(lines), "Expected each sub-logger to write an entry for each terminal level.")
+		for _, line := range lines {
+			assert.Contains(t, line, "zap.TestTeeTerminalCallerAndStack", "Expected the stacktrace to include the test function.")
+			assert.NotContains(t, line, "zap.writeTerminal", "Expected the entry to be built on the calling goroutine.")
+		}
+	}
+}
+
+func TestLoggerTerminalHookPanics(t *testing.T) {
+	stub := stubExit()
+	defer stub.Unstub()
+
+	hook := Hook(func(*Entry) error { panic("hook") })
+	withJSONLogger(t, opts(hook), func(logger Logger, buf *testBuffer) {
+		assert.PanicsWithValue(t, "hook", func() { logger.Panic("panic") }, "Expected hook panics to reach Panic's caller.")
+		assert.PanicsWithValue(t, "hook", func() { logger.Fatal("fatal") }, "Expected hook panics to reach Fatal's caller.")
+		stub.AssertNoExit(t)
+	})
+}
+
 func TestJSONLoggerCheckFatal(t *testing.T) {
 	stub := stubExit()
 	defer stub.Unstub()
@@ -334,6 +529,94 @@ func TestJSONLoggerCheckAlwaysFatals(t *testing.T) {
 	})
 }
 
+// queueLogger simulates an asynchronous wrapper: it queues entries, and only
+// writes them to the wrapped logger when it's synced.
+type queueLogger struct {
+	Logger
+
+	mu     sync.Mutex
+	queued []func()
+}
+
+func (q *queueLogger) Log(lvl Level, msg string, fields ...Field) {
+	q.mu.Lock()
+	q.queued = append(q.queued, func() { q.Logger.Log(lvl, msg, fields...) })
+	q.mu.Unlock()
+}
+
+func (q *queueLogger) Sync() error {
+	q.mu.Lock()
+	queued := q.queued
+	q.queued = nil
+	q.mu.Unlock()
+	for _, write := range queued {
+		write()
+	}
+	return Sync(q.Logger)
+}
+
+// nestedTees builds a three-deep composition of Tees and wrappers, returning
+// the outermost and middle Tees and the sinks of all their loggers.
+func nestedTees() (outer, middle Logger, sinks []*syncSpy) {
+	newLogger := func() Logger {
+		sink := &syncSpy{}
+		sinks = append(sinks, sink)
+		return New(newJSONEncoder(NoTime()), Output(sink), ErrorOutput(Discard))
+	}
+	first := newLogger()
+	second := newLogger()
+	innermost := Tee(newLogger(), &queueLogger{Logger: newLogger()})
+	middle = Tee(second, DynamicFields(Filter(innermost, DropMessages("noisy")), func() []Field {
+		return []Field{String("dynamic", "yes")}
+	}))
+	outer = Tee(first, middle, newLogger())
+	return outer, middle, sinks
+}
+
+func TestNestedTeesFatalExactlyOnce(t *testing.T) {
+	tests := []struct {
+		msg   string
+		fatal func(outer, middle Logger)
+	}{
+		{"fatal", func(outer, _ Logger) { outer.Fatal("fatal", Int("n", 1)) }},
+		{"checked", func(outer, _ Logger) { outer.Check(FatalLevel, "checked").Write(Int("n", 1)) }},
+		{"chained", func(outer, middle Logger) {
+			outer.Check(FatalLevel, "chained").Chain(middle.Check(FatalLevel, "chained")).Write(Int("n", 1))
+		}},
+	}
+
+	for _, tt := range tests {
+		outer, middle, sinks := nestedTees()
+		stub := stubExit()
+		exits := 0
+		_exit = func(int) {
+			exits++
+			// Every sink should have received and flushed the entry by the
+			// time the process exits.
+			for i, sink := range sinks {
+				assert.Contains(t, sink.String(), `"msg":"`+tt.msg+`","n":1`, "Expected sink %v to receive the %s entry before exiting.", i, tt.msg)
+				assert.True(t, sink.Called(), "Expected sink %v to be synced before exiting.", i)
+			}
+		}
+		tt.fatal(outer, middle)
+		stub.Unstub()
+		assert.Equal(t, 1, exits, "Expected the %s entry to exit exactly once.", tt.msg)
+	}
+}
+
+func TestNestedTeesPanicExactlyOnce(t *testing.T) {
+	outer, _, sinks := nestedTees()
+	assert.Panics(t, func() {
+		defer func() {
+			for i, sink := range sinks {
+				assert.Contains(t, sink.String(), `"msg":"panic"`, "Expected sink %v to receive the entry before panicking.", i)
+				assert.True(t, sink.Called(), "Expected sink %v to be synced before panicking.", i)
+			}
+		}()
+		outer.Panic("panic")
+	}, "Expected the outermost Tee to panic.")
+}
+
 func TestJSONLoggerDPanic(t *testing.T) {
 	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
 		assert.NotPanics(t, func() { logger.DPanic("foo") })
@@ -352,6 +635,96 @@ func TestJSONLoggerNoOpsDisabledLevels(t *testing.T) {
 	})
 }
 
+func TestJSONLoggerClock(t *testing.T) {
+	clock := zaptest.NewClock(time.Unix(1, 0).UTC())
+	for _, tt := range []struct {
+		formatter TimeFormatter
+		expected  []string
+	}{
+		{EpochFormatter("ts"), []string{
+			`{"level":"info","ts":1,"msg":"foo"}`,
+			`{"level":"info","ts":1.5,"msg":"bar"}`,
+		}},
+		{RFC3339Formatter("ts"), []string{
+			`{"level":"info","ts":"1970-01-01T00:00:01Z","msg":"foo"}`,
+			`{"level":"info","ts":"1970-01-01T00:00:01Z","msg":"bar"}`,
+		}},
+	} {
+		// Output should be byte-identical every time we run.
+		for i := 0; i < 2; i++ {
+			clock := zaptest.NewClock(clock.Now())
+			buf := &testBuffer{}
+			logger := New(newJSONEncoder(tt.formatter), Output(buf), WithClock(clock))
+			logger.Info("foo")
+			clock.Add(500 * time.Millisecond)
+			logger.With().Info("bar")
+			assert.Equal(t, tt.expected, buf.Lines(), "Unexpected output using a fake clock.")
+		}
+	}
+}
+
+func TestLoggerUTCTimestamps(t *testing.T) {
+	// 1970-01-01T00:00:01Z, but in a non-UTC location.
+	local := time.Unix(1, 0).In(time.FixedZone("EST", -5*60*60))
+	tokyo := time.FixedZone("JST", 9*60*60)
+	for _, tt := range []struct {
+		desc     string
+		enc      func() Encoder
+		expected string
+		utc      string
+	}{
+		{
+			"JSON epoch",
+			func() Encoder { return newJSONEncoder(EpochFormatter("ts")) },
+			`{"level":"info","ts":1,"msg":"foo","at":1}`,
+			`{"level":"info","ts":1,"msg":"foo","at":1}`,
+		},
+		{
+			"JSON epoch nanos",
+			func() Encoder { return newJSONEncoder(EpochNanosFormatter("ts")) },
+			`{"level":"info","ts":1000000000,"msg":"foo","at":1}`,
+			`{"level":"info","ts":1000000000,"msg":"foo","at":1}`,
+		},
+		{
+			"JSON RFC3339",
+			func() Encoder { return newJSONEncoder(RFC3339Formatter("ts")) },
+			`{"level":"info","ts":"1969-12-31T19:00:01-05:00","msg":"foo","at":1}`,
+			`{"level":"info","ts":"1970-01-01T00:00:01Z","msg":"foo","at":1}`,
+		},
+		{
+			"text",
+			func() Encoder { return NewTextEncoder() },
+			"[I] 1969-12-31T19:00:01-05:00 foo at=1",
+			"[I] 1970-01-01T00:00:01Z foo at=1",
+		},
+		{
+			"text with location",
+			func() Encoder { return NewTextEncoder(TextTimeLocation(tokyo)) },
+			"[I] 1970-01-01T09:00:01+09:00 foo at=1",
+			"[I] 1970-01-01T09:00:01+09:00 foo at=1",
+		},
+		{
+			"text with nil location",
+			func() Encoder { return NewTextEncoder(TextTimeLocation(nil)) },
+			"[I] 1969-12-31T19:00:01-05:00 foo at=1",
+			"[I] 1970-01-01T00:00:01Z foo at=1",
+		},
+	} {
+		for _, utc := range []bool{false, true} {
+			buf := &testBuffer{}
+			opts := []Option{Output(buf), WithClock(zaptest.NewClock(local))}
+			expected := tt.expected
+			if utc {
+				opts = append(opts, UTCTimestamps())
+				expected = tt.utc
+			}
+			logger := New(tt.enc(), opts...)
+			logger.With(Time("at", local)).Info("foo")
+			assert.Equal(t, expected, buf.Stripped(), "Unexpected output from %s encoder (UTC: %v).", tt.desc, utc)
+		}
+	}
+}
+
 func TestJSONLoggerWriteEntryFailure(t *testing.T) {
 	errBuf := &testBuffer{}
 	errSink := &spywrite.WriteSyncer{Writer: errBuf}
@@ -379,6 +752,63 @@ func TestJSONLoggerSyncsOutput(t *testing.T) {
 	assert.True(t, sink.Called(), "Expected logging at panic level to Sync underlying WriteSyncer.")
 }
 
+func TestSync(t *testing.T) {
+	out, errOut := &syncSpy{}, &syncSpy{}
+	logger := New(newJSONEncoder(), Output(out), ErrorOutput(errOut))
+	assert.NoError(t, Sync(logger), "Unexpected error syncing a logger.")
+	assert.True(t, out.Called(), "Expected Sync to sync the Output.")
+	assert.True(t, errOut.Called(), "Expected Sync to sync the ErrorOutput.")
+
+	failing := &syncSpy{}
+	failing.SetError(errors.New("fail"))
+	wrapped := Tee(
+		CapLevel(New(newJSONEncoder(), Output(failing), ErrorOutput(Discard)), ErrorLevel),
+		Filter(New(newJSONEncoder(), Output(Discard), ErrorOutput(Discard)), func(Level, string, []Field) bool { return true }),
+	)
+	assert.Error(t, Sync(wrapped), "Expected Sync to return errors from wrapped loggers.")
+	assert.True(t, failing.Called(), "Expected Sync to reach loggers wrapped by Tee and CapLevel.")
+}
+
+func TestContext(t *testing.T) {
+	logger := New(newJSONEncoder(), Output(Discard), Fields(String("service", "api")))
+	assert.Equal(t, []Field{String("service", "api")}, Context(logger), "Expected the Fields option to be part of the context.")
+
+	parent := logger.With(String("request", "abc"))
+	first := parent.With(Int("user", 1))
+	second := parent.With(Int("user", 2), Bool("admin", true))
+	assert.Equal(t, []Field{String("service", "api"), String("request", "abc")}, Context(parent), "Unexpected parent context.")
+	assert.Equal(t, []Field{String("service", "api"), String("request", "abc"), Int("user", 1)}, Context(first), "Unexpected context for the first child.")
+	assert.Equal(t, []Field{String("service", "api"), String("request", "abc"), Int("user", 2), Bool("admin", true)}, Context(second), "Unexpected context for the second child.")
+
+	ctx := Context(first)
+	ctx[0] = String("service", "changed")
+	_ = append(ctx[:1], String("request", "changed"))
+	assert.Equal(t, []Field{String("service", "api"), String("request", "abc"), Int("user", 1)}, Context(first), "Expected changes to the returned slice not to affect the logger.")
+
+	fields := []Field{Int("n", 1)}
+	child := logger.With(fields...)
+	fields[0] = Int("n", 2)
+	assert.Equal(t, []Field{String("service", "api"), Int("n", 1)}, Context(child), "Expected changes to With's arguments not to affect the logger.")
+
+	assert.Nil(t, Context(New(newJSONEncoder())), "Expected a nil context for a logger without fields.")
+	assert.Equal(t, Context(first), Context(Filter(CapLevel(first, ErrorLevel), func(Level, string, []Field) bool { return true })), "Expected wrappers to return the wrapped logger's context.")
+}
+
+func TestContextRedaction(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), RedactKeys("password"), AddPID()).With(
+		String("user", "jane"),
+		String("password", "hunter2"),
+	)
+	assert.Equal(t, []Field{
+		Int("pid", os.Getpid()),
+		String("user", "jane"),
+		String("password", "[REDACTED]"),
+	}, Context(logger), "Expected redacted keys to be redacted in the context.")
+	logger.Info("")
+	assert.Contains(t, buf.String(), `"password":"[REDACTED]"`, "Expected the encoded context to be redacted too.")
+}
+
 func TestLoggerConcurrent(t *testing.T) {
 	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
 		child := logger.With(String("foo", "bar"))
@@ -410,3 +840,36 @@ func runConcurrently(goroutines, iterations int, wg *sync.WaitGroup, f func()) {
 		}()
 	}
 }
+
+func TestAudit(t *testing.T) {
+	buf, auditBuf := &testBuffer{}, &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), WarnLevel, Output(buf), AuditOutput(auditBuf), ErrorOutput(Discard))
+	assert.False(t, IsAudit(logger), "Expected a new logger not to be an audit logger.")
+
+	audit := Audit(logger.With(String("user", "alice")))
+	assert.True(t, IsAudit(audit), "Expected an audit logger.")
+	assert.Equal(t, audit, Audit(audit), "Expected auditing an audit logger to return it unchanged.")
+	assert.True(t, audit.Check(InfoLevel, "checked").OK(), "Expected audit loggers to enable InfoLevel.")
+	assert.False(t, audit.Check(DebugLevel, "checked").OK(), "Expected audit loggers to disable DebugLevel.")
+
+	logger.Info("operational")
+	audit.Info("login")
+	audit.Debug("dropped")
+	audit.With(Int("rows", 42)).Warn("export")
+	Audit(audit).Info("permissions changed")
+	assert.Empty(t, buf.Lines(), "Expected audit entries not to be written to the Output.")
+	assert.Equal(t, []string{
+		`{"level":"info","msg":"login","user":"alice","audit":true}`,
+		`{"level":"warn","msg":"export","user":"alice","audit":true,"rows":42}`,
+		`{"level":"info","msg":"permissions changed","user":"alice","audit":true}`,
+	}, auditBuf.Lines(), "Unexpected audit output.")
+	assert.Equal(t, []Field{String("user", "alice"), Bool("audit", true)}, Context(audit), "Expected the marker field in the audit logger's context.")
+}
+
+func TestAuditWithoutAuditOutput(t *testing.T) {
+	withJSONLogger(t, opts(ErrorLevel), func(logger Logger, buf *testBuffer) {
+		logger.Info("operational")
+		Audit(logger).Info("login")
+		assert.Equal(t, []string{`{"level":"info","msg":"login","audit":true}`}, buf.Lines(), "Expected audit entries in the Output.")
+	})
+}
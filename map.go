@@ -0,0 +1,240 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// DefaultMapDepth is the number of levels of nested maps and slices that the
+// Map constructor encodes before cutting off recursion.
+const DefaultMapDepth = 32
+
+const (
+	// _maxDepthMarker replaces maps and slices nested too deeply to encode.
+	_maxDepthMarker = "<max depth exceeded>"
+	// _cycleMarker replaces maps and slices that contain themselves.
+	_cycleMarker = "<cycle>"
+)
+
+// Map constructs a field that carries a map[string]interface{}, such as a
+// decoded JSON object, and encodes it as a nested object. Keys are sorted, so
+// the output is deterministic. Each value, including each element of a nested
+// []interface{}, is encoded as if it were passed to Any, except that nested
+// map[string]interface{} and []interface{} values are encoded structurally up
+// to DefaultMapDepth levels deep. Containers nested
+// more deeply, and containers that contain themselves, are replaced with a
+// marker string.
+func Map(key string, m map[string]interface{}) Field {
+	return MapDepth(key, m, DefaultMapDepth)
+}
+
+// MapDepth is like Map, but it encodes at most maxDepth levels of nested maps
+// and slices below the top-level map.
+func MapDepth(key string, m map[string]interface{}, maxDepth int) Field {
+	return Marshaler(key, mapMarshaler{m: m, depth: maxDepth})
+}
+
+// StringMap constructs a field that carries a map[string]string and encodes it
+// as a nested object with sorted keys. It's a faster alternative to Map, since
+// the values don't need to be boxed in interfaces.
+func StringMap(key string, m map[string]string) Field {
+	return Marshaler(key, stringMap(m))
+}
+
+// ancestors is the chain of maps and slices enclosing the value being encoded,
+// used to detect cycles.
+type ancestors struct {
+	ptr    uintptr
+	parent *ancestors
+}
+
+func (a *ancestors) push(container interface{}) *ancestors {
+	return &ancestors{ptr: reflect.ValueOf(container).Pointer(), parent: a}
+}
+
+func (a *ancestors) contains(container interface{}) bool {
+	ptr := reflect.ValueOf(container).Pointer()
+	if ptr == 0 {
+		// Nil and empty containers can't hold references to anything.
+		return false
+	}
+	for ; a != nil; a = a.parent {
+		if a.ptr == ptr {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedMarker returns the marker to use in place of a nested container, or
+// an empty string if the container should be encoded.
+func nestedMarker(container interface{}, depth int, path *ancestors) string {
+	if path.contains(container) {
+		return _cycleMarker
+	}
+	if depth <= 0 {
+		return _maxDepthMarker
+	}
+	return ""
+}
+
+type mapMarshaler struct {
+	m     map[string]interface{}
+	depth int
+	path  *ancestors
+}
+
+func (mm mapMarshaler) MarshalLog(kv KeyValue) error {
+	keys := make([]string, 0, len(mm.m))
+	for k := range mm.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	path := mm.path.push(mm.m)
+	for _, k := range keys {
+		switch v := mm.m[k].(type) {
+		case map[string]interface{}:
+			if marker := nestedMarker(v, mm.depth, path); marker != "" {
+				kv.AddString(k, marker)
+				continue
+			}
+			Marshaler(k, mapMarshaler{m: v, depth: mm.depth - 1, path: path}).AddTo(kv)
+		case []interface{}:
+			if marker := nestedMarker(v, mm.depth, path); marker != "" {
+				kv.AddString(k, marker)
+				continue
+			}
+			Array(k, sliceMarshaler{elems: v, depth: mm.depth - 1, path: path}).AddTo(kv)
+		default:
+			Any(k, v).AddTo(kv)
+		}
+	}
+	return nil
+}
+
+type sliceMarshaler struct {
+	elems []interface{}
+	depth int
+	path  *ancestors
+}
+
+func (sm sliceMarshaler) MarshalLogArray(arr ArrayEncoder) error {
+	var firstErr error
+	path := sm.path.push(sm.elems)
+	for _, elem := range sm.elems {
+		var err error
+		switch v := elem.(type) {
+		case map[string]interface{}:
+			if marker := nestedMarker(v, sm.depth, path); marker != "" {
+				arr.AppendString(marker)
+				continue
+			}
+			err = arr.AppendMarshaler(mapMarshaler{m: v, depth: sm.depth - 1, path: path})
+		case []interface{}:
+			if marker := nestedMarker(v, sm.depth, path); marker != "" {
+				arr.AppendString(marker)
+				continue
+			}
+			err = arr.AppendArray(sliceMarshaler{elems: v, depth: sm.depth - 1, path: path})
+		case ArrayMarshaler:
+			err = arr.AppendArray(v)
+		default:
+			err = appendAny(arr, v)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// appendAny appends a value to an array, encoding it as if it were passed to
+// Any.
+func appendAny(arr ArrayEncoder, v interface{}) error {
+	elem := &arrayElement{arr: arr}
+	Any("", v).AddTo(elem)
+	return elem.err
+}
+
+// An arrayElement adapts an ArrayEncoder to the KeyValue interface, so that a
+// field with an empty key can be appended as an array element. Any error the
+// field reports (under the key "Error") is returned by appendAny rather than
+// appended.
+type arrayElement struct {
+	arr ArrayEncoder
+	err error
+}
+
+func (ae *arrayElement) AddBinary(_ string, v []byte)     { ae.AddObject("", v) }
+func (ae *arrayElement) AddBool(_ string, v bool)         { ae.arr.AppendBool(v) }
+func (ae *arrayElement) AddByteString(_ string, v []byte) { ae.arr.AppendByteString(v) }
+func (ae *arrayElement) AddFloat32(_ string, v float32)   { ae.arr.AppendFloat32(v) }
+func (ae *arrayElement) AddFloat64(_ string, v float64)   { ae.arr.AppendFloat64(v) }
+func (ae *arrayElement) AddInt(_ string, v int)           { ae.arr.AppendInt64(int64(v)) }
+func (ae *arrayElement) AddInt64(_ string, v int64)       { ae.arr.AppendInt64(v) }
+func (ae *arrayElement) AddUint(_ string, v uint)         { ae.arr.AppendUint64(uint64(v)) }
+func (ae *arrayElement) AddUint64(_ string, v uint64)     { ae.arr.AppendUint64(v) }
+func (ae *arrayElement) AddUintptr(_ string, v uintptr)   { ae.arr.AppendUint64(uint64(v)) }
+func (ae *arrayElement) OpenNamespace(_ string)           {}
+
+func (ae *arrayElement) AddMarshaler(_ string, m LogMarshaler) error {
+	return ae.arr.AppendMarshaler(m)
+}
+
+func (ae *arrayElement) AddArray(_ string, m ArrayMarshaler) error {
+	return ae.arr.AppendArray(m)
+}
+
+func (ae *arrayElement) AddRawJSON(_ string, v []byte) error {
+	return ae.arr.AppendObject(json.RawMessage(v))
+}
+
+func (ae *arrayElement) AddObject(_ string, v interface{}) error {
+	return ae.arr.AppendObject(v)
+}
+
+func (ae *arrayElement) AddString(key, v string) {
+	if key == "Error" {
+		ae.err = errors.New(v)
+		return
+	}
+	ae.arr.AppendString(v)
+}
+
+type stringMap map[string]string
+
+func (sm stringMap) MarshalLog(kv KeyValue) error {
+	keys := make([]string, 0, len(sm))
+	for k := range sm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		kv.AddString(k, sm[k])
+	}
+	return nil
+}
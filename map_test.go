@@ -0,0 +1,159 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapFields(t *testing.T) {
+	decoded := map[string]interface{}{
+		"str":   "foo",
+		"num":   1.5,
+		"bool":  true,
+		"null":  nil,
+		"empty": map[string]interface{}{},
+		"obj": map[string]interface{}{
+			"z": "last",
+			"a": []interface{}{1.0, "two", nil, []interface{}{false}, map[string]interface{}{"k": "v"}},
+		},
+		"typed": map[string]string{"b": "2", "a": "1"},
+	}
+
+	tests := []struct {
+		desc  string
+		field Field
+		json  string
+		text  string
+	}{
+		{
+			"nil map",
+			Map("k", nil),
+			`"k":{}`,
+			"k={}",
+		},
+		{
+			"nested map",
+			Map("k", decoded),
+			`"k":{"bool":true,"empty":{},"null":null,"num":1.5,"obj":{"a":[1,"two",null,[false],{"k":"v"}],"z":"last"},"str":"foo","typed":{"a":"1","b":"2"}}`,
+			"k={bool=true empty={} null=<nil> num=1.5 obj={a=[1,two,<nil>,[false],{k=v}] z=last} str=foo typed={a=1 b=2}}",
+		},
+		{
+			"depth cutoff",
+			MapDepth("k", decoded, 1),
+			`"k":{"bool":true,"empty":{},"null":null,"num":1.5,"obj":{"a":"<max depth exceeded>","z":"last"},"str":"foo","typed":{"a":"1","b":"2"}}`,
+			`k={bool=true empty={} null=<nil> num=1.5 obj={a=<max depth exceeded> z=last} str=foo typed={a=1 b=2}}`,
+		},
+		{
+			"depth cutoff in an array",
+			MapDepth("k", map[string]interface{}{"a": []interface{}{1.0, []interface{}{2.0}, map[string]interface{}{}}}, 1),
+			`"k":{"a":[1,"<max depth exceeded>","<max depth exceeded>"]}`,
+			`k={a=[1,"<max depth exceeded>","<max depth exceeded>"]}`,
+		},
+		{
+			"zero depth",
+			MapDepth("k", map[string]interface{}{"a": map[string]interface{}{}, "b": 1}, 0),
+			`"k":{"a":"<max depth exceeded>","b":1}`,
+			"k={a=<max depth exceeded> b=1}",
+		},
+		{
+			"typed array elements",
+			Map("k", map[string]interface{}{"a": []interface{}{
+				time.Unix(1, 0), int32(-3), uint8(7), time.Second, float32(1.5), net.ParseIP("1.2.3.4"), errors.New("oops"),
+			}}),
+			`"k":{"a":[1,-3,7,1000000000,1.5,"1.2.3.4","oops"]}`,
+			"k={a=[1,-3,7,1000000000,1.5,1.2.3.4,oops]}",
+		},
+		{
+			"string map",
+			StringMap("k", map[string]string{"foo": "bar", "baz": "quux"}),
+			`"k":{"baz":"quux","foo":"bar"}`,
+			"k={baz=quux foo=bar}",
+		},
+		{
+			"nil string map",
+			StringMap("k", nil),
+			`"k":{}`,
+			"k={}",
+		},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.json, tt.field)
+		assertCanBeReused(t, tt.field)
+		withTextEncoder(func(enc *textEncoder) {
+			tt.field.AddTo(enc)
+			assert.Equal(t, tt.text, string(enc.bytes), "Unexpected text output for %s.", tt.desc)
+		})
+	}
+}
+
+func TestMapFieldCycle(t *testing.T) {
+	cyclic := map[string]interface{}{"name": "root"}
+	cyclic["self"] = cyclic
+	cyclic["list"] = []interface{}{cyclic}
+	list := []interface{}{"elem", nil}
+	list[1] = list
+	cyclic["cyclicList"] = list
+
+	// Compare strings directly, since testify can't print cyclic maps.
+	withJSONEncoder(func(enc *jsonEncoder) {
+		Map("k", cyclic).AddTo(enc)
+		assert.Equal(
+			t,
+			`"k":{"cyclicList":["elem","<cycle>"],"list":["<cycle>"],"name":"root","self":"<cycle>"}`,
+			string(enc.bytes),
+			"Unexpected output encoding a cyclic map.",
+		)
+	})
+
+	// Repeated, but acyclic, references aren't cycles.
+	shared := map[string]interface{}{"a": 1}
+	assertFieldJSON(t, `"k":{"x":{"a":1},"y":[{"a":1}]}`, Map("k", map[string]interface{}{"x": shared, "y": []interface{}{shared}}))
+}
+
+func TestMapFieldErrors(t *testing.T) {
+	m := map[string]interface{}{
+		"bad":  noJSON{},
+		"list": []interface{}{noJSON{}, "ok"},
+		"ok":   1,
+	}
+	withJSONEncoder(func(enc *jsonEncoder) {
+		Map("k", m).AddTo(enc)
+		assert.Contains(t, string(enc.bytes), `"listError":"json: error calling MarshalJSON`, "Expected an in-band error for the unserializable element.")
+		assert.Contains(t, string(enc.bytes), `"list":["ok"]`, "Expected encoding to continue after an unserializable element.")
+		assert.Contains(t, string(enc.bytes), `"ok":1}`, "Expected encoding to continue after an unserializable value.")
+	})
+
+	assertFieldJSON(t, `"k":{"list":["<PANIC>","ok"],"listError":"PANIC=oh no"}`, Map("k", map[string]interface{}{
+		"list": []interface{}{panicStringer{}, "ok"},
+	}))
+}
+
+func TestAnyMaps(t *testing.T) {
+	assertFieldJSON(t, `"k":{"a":{"b":1}}`, Any("k", map[string]interface{}{"a": map[string]interface{}{"b": 1}}))
+	assertFieldJSON(t, `"k":{"a":"b"}`, Any("k", map[string]string{"a": "b"}))
+}
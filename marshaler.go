@@ -35,3 +35,20 @@ type LogMarshalerFunc func(KeyValue) error
 func (f LogMarshalerFunc) MarshalLog(kv KeyValue) error {
 	return f(kv)
 }
+
+// ArrayMarshaler allows user-defined types to efficiently add themselves to
+// the logging context as arrays, without resorting to reflection. Errors
+// returned by MarshalLogArray are included in the log output, but they don't
+// prevent the rest of the entry from being written.
+type ArrayMarshaler interface {
+	MarshalLogArray(ArrayEncoder) error
+}
+
+// ArrayMarshalerFunc is a type adapter that allows using a function as an
+// ArrayMarshaler.
+type ArrayMarshalerFunc func(ArrayEncoder) error
+
+// MarshalLogArray calls the underlying function.
+func (f ArrayMarshalerFunc) MarshalLogArray(arr ArrayEncoder) error {
+	return f(arr)
+}
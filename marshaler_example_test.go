@@ -68,3 +68,27 @@ func ExampleMarshaler() {
 	// Output:
 	// {"level":"info","msg":"Successful login.","user":{"name":"Jane Doe","age":42,"auth":{"expires_at":100,"token":"---"}}}
 }
+
+type Users []User
+
+func (us Users) MarshalLogArray(arr zap.ArrayEncoder) error {
+	for _, u := range us {
+		if err := arr.AppendMarshaler(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ExampleArray() {
+	users := Users{
+		{Name: "Jane Doe", Age: 42, Auth: Auth{ExpiresAt: time.Unix(0, 100)}},
+		{Name: "John Doe", Age: 37, Auth: Auth{ExpiresAt: time.Unix(0, 200)}},
+	}
+
+	logger := zap.New(zap.NewJSONEncoder(zap.NoTime()))
+	logger.Info("Successful logins.", zap.Array("users", users))
+
+	// Output:
+	// {"level":"info","msg":"Successful logins.","users":[{"name":"Jane Doe","age":42,"auth":{"expires_at":100,"token":"---"}},{"name":"John Doe","age":37,"auth":{"expires_at":200,"token":"---"}}]}
+}
@@ -42,25 +42,46 @@ type Meta struct {
 	LevelEnabler
 
 	Development bool
+	Clock       Clock
 	Encoder     Encoder
 	Hooks       []Hook
 	Output      WriteSyncer
 	ErrorOutput WriteSyncer
+
+	redactor *keyRedactor
+	// Whether to convert entry timestamps to UTC; see UTCTimestamps.
+	utc bool
+	// Whether this is an audit logger, and where audit loggers write; see
+	// Audit and AuditOutput.
+	audit       bool
+	auditOutput WriteSyncer
+	// The fields added with the Fields option and Logger.With, in order. See
+	// the Context function.
+	context *fieldContext
+	// While MakeMeta applies options, context fields are only recorded;
+	// they're encoded once all the options (including RedactKeys) have been
+	// applied.
+	constructing bool
 }
 
 // MakeMeta returns a new meta struct with sensible defaults: logging at
-// InfoLevel, development mode off, and writing to standard error and standard
-// out.
+// InfoLevel, development mode off, timestamping with the system clock, and
+// writing to standard error and standard out.
 func MakeMeta(enc Encoder, options ...Option) Meta {
 	m := Meta{
+		Clock:        _systemClock,
 		Encoder:      enc,
 		Output:       newLockedWriteSyncer(os.Stdout),
 		ErrorOutput:  newLockedWriteSyncer(os.Stderr),
 		LevelEnabler: InfoLevel,
+		constructing: true,
 	}
 	for _, opt := range options {
 		opt.apply(&m)
 	}
+	ctx := m.context.flatten()
+	m.context, m.constructing = nil, false
+	m.addContext(ctx)
 	return m
 }
 
@@ -87,6 +108,82 @@ func (m Meta) Check(log Logger, lvl Level, msg string) *CheckedMessage {
 	return NewCheckedMessage(log, lvl, msg)
 }
 
+// AddFields adds fields to the supplied encoder (typically a clone of the
+// Meta's encoder), redacting them if necessary.
+func (m Meta) AddFields(enc Encoder, fields []Field) {
+	if m.redactor != nil {
+		addFields(m.redactor.wrap(enc), fields)
+		return
+	}
+	addFields(enc, fields)
+}
+
+// addContext adds fields to the Meta's encoder and records them, so that they
+// can be retrieved with Context. Fields whose keys match RedactKeys patterns
+// are recorded redacted.
+func (m *Meta) addContext(fields []Field) {
+	if m.constructing {
+		m.context = m.context.with(fields)
+		return
+	}
+	m.AddFields(m.Encoder, fields)
+	if m.redactor == nil {
+		m.context = m.context.with(fields)
+		return
+	}
+	redacted := make([]Field, len(fields))
+	for i, f := range fields {
+		if m.redactor.matches(f.key) {
+			f = Redact(f)
+		}
+		redacted[i] = f
+	}
+	m.context = m.context.with(redacted)
+}
+
+// A fieldContext holds a logger's accumulated context fields. Like the JSON
+// encoder's prefix, it shares its parent's fields rather than copying them, so
+// that building a long chain of loggers with With doesn't copy the context at
+// every step. A nil fieldContext is empty.
+type fieldContext struct {
+	parent *fieldContext
+	fields []Field
+	// The total number of fields, including the parent's.
+	n int
+}
+
+// with returns a context that adds a copy of fields to c.
+func (c *fieldContext) with(fields []Field) *fieldContext {
+	if len(fields) == 0 {
+		return c
+	}
+	child := &fieldContext{
+		parent: c,
+		fields: append([]Field(nil), fields...),
+		n:      len(fields),
+	}
+	if c != nil {
+		child.n += c.n
+	}
+	return child
+}
+
+// flatten returns a copy of all the fields in the context, in order.
+func (c *fieldContext) flatten() []Field {
+	if c == nil {
+		return nil
+	}
+	all := make([]Field, c.n)
+	for ; c != nil; c = c.parent {
+		copy(all[c.n-len(c.fields):], c.fields)
+	}
+	return all
+}
+
+func (m Meta) development() bool {
+	return m.Development
+}
+
 // InternalError prints an internal error message to the configured
 // ErrorOutput. This method should only be used to report internal logger
 // problems and should not be used to report user-caused problems.
@@ -95,24 +192,50 @@ func (m Meta) InternalError(cause string, err error) {
 	m.ErrorOutput.Sync()
 }
 
-// Encode runs any Hook functions and then writes an encoded log entry to the
-// given io.Writer, returning any error.
-func (m Meta) Encode(t time.Time, lvl Level, msg *string, fields []Field) Encoder {
+// Sync flushes any entries buffered by the Output and ErrorOutput.
+func (m Meta) Sync() error {
+	var errs multiError
+	for _, ws := range []WriteSyncer{m.Output, m.ErrorOutput} {
+		if ws == nil {
+			continue
+		}
+		if err := ws.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.asError()
+}
+
+// reportInternalError reports an error to the logger's ErrorOutput if it has
+// one, and to standard error otherwise.
+func reportInternalError(log Logger, cause string, err error) {
+	if r, ok := log.(internalErrorReporter); ok {
+		r.InternalError(cause, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%v %s error: %v\n", time.Now(), cause, err)
+}
+
+// Encode builds a pooled Entry with the Meta's accumulated context and the
+// supplied fields, then runs any Hook functions on it. The caller should write
+// the entry with Entry.Write and then release it with Entry.Free.
+func (m Meta) Encode(t time.Time, lvl Level, msg string, fields []Field) *Entry {
+	if m.utc {
+		t = t.UTC()
+	}
 	enc := m.Encoder.Clone()
-	addFields(enc, fields)
-	if len(m.Hooks) >= 0 {
-		entry := _entryPool.Get().(*Entry)
-		entry.Level = lvl
-		entry.Message = *msg
-		entry.Time = t
-		entry.enc = enc
-		for _, hook := range m.Hooks {
-			if err := hook(entry); err != nil {
-				m.InternalError("hook", err)
-			}
+	m.AddFields(enc, fields)
+	entry := _entryPool.Get().(*Entry)
+	*entry = Entry{
+		Level:   lvl,
+		Time:    t,
+		Message: msg,
+		enc:     enc,
+	}
+	for _, hook := range m.Hooks {
+		if err := hook(entry); err != nil {
+			m.InternalError("hook", err)
 		}
-		*msg, enc = entry.Message, entry.enc
-		_entryPool.Put(entry)
 	}
-	return enc
+	return entry
 }
@@ -20,10 +20,7 @@
 
 package zap
 
-import (
-	"io"
-	"time"
-)
+import "io"
 
 // nullEncoder is an Encoder implementation that throws everything away.
 type nullEncoder struct{}
@@ -37,16 +34,22 @@ func NullEncoder() Encoder {
 
 func (nullEncoder) Free() {}
 
-func (nullEncoder) AddString(_, _ string)          {}
-func (nullEncoder) AddBool(_ string, _ bool)       {}
-func (nullEncoder) AddInt(_ string, _ int)         {}
-func (nullEncoder) AddInt64(_ string, _ int64)     {}
-func (nullEncoder) AddUint(_ string, _ uint)       {}
-func (nullEncoder) AddUint64(_ string, _ uint64)   {}
-func (nullEncoder) AddUintptr(_ string, _ uintptr) {}
-func (nullEncoder) AddFloat64(_ string, _ float64) {}
+func (nullEncoder) AddString(_, _ string)            {}
+func (nullEncoder) AddBool(_ string, _ bool)         {}
+func (nullEncoder) AddBinary(_ string, _ []byte)     {}
+func (nullEncoder) AddByteString(_ string, _ []byte) {}
+func (nullEncoder) AddInt(_ string, _ int)           {}
+func (nullEncoder) AddInt64(_ string, _ int64)       {}
+func (nullEncoder) AddUint(_ string, _ uint)         {}
+func (nullEncoder) AddUint64(_ string, _ uint64)     {}
+func (nullEncoder) AddUintptr(_ string, _ uintptr)   {}
+func (nullEncoder) AddFloat32(_ string, _ float32)   {}
+func (nullEncoder) AddFloat64(_ string, _ float64)   {}
+func (nullEncoder) OpenNamespace(_ string)           {}
 
 func (nullEncoder) AddMarshaler(_ string, _ LogMarshaler) error { return nil }
+func (nullEncoder) AddArray(_ string, _ ArrayMarshaler) error   { return nil }
+func (nullEncoder) AddRawJSON(_ string, _ []byte) error         { return nil }
 func (nullEncoder) AddObject(_ string, _ interface{}) error     { return nil }
 
 // Clone copies the current encoder, including any data already encoded.
@@ -56,7 +59,7 @@ func (nullEncoder) Clone() Encoder {
 
 // WriteEntry writes nothing to the supplied writer, but demands a valid writer.
 // It's safe to call from multiple goroutines.
-func (nullEncoder) WriteEntry(sink io.Writer, _ string, _ Level, _ time.Time) error {
+func (nullEncoder) WriteEntry(sink io.Writer, _ Entry) error {
 	if sink == nil {
 		return errNilSink
 	}
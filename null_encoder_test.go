@@ -43,6 +43,8 @@ func TestNullEncoderFields(t *testing.T) {
 	}{
 		{"string", func(e Encoder) { e.AddString("k", "v") }},
 		{"bool", func(e Encoder) { e.AddBool("k", true) }},
+		{"binary", func(e Encoder) { e.AddBinary("k", []byte("v")) }},
+		{"byte string", func(e Encoder) { e.AddByteString("k", []byte("v")) }},
 		{"bool", func(e Encoder) { e.AddBool("k", false) }},
 		{"int", func(e Encoder) { e.AddInt("k", 42) }},
 		{"int64", func(e Encoder) { e.AddInt64("k", math.MaxInt64) }},
@@ -51,6 +53,11 @@ func TestNullEncoderFields(t *testing.T) {
 		{"uint64", func(e Encoder) { e.AddUint64("k", math.MaxUint64) }},
 		{"uintptr", func(e Encoder) { e.AddUintptr("k", uintptr(math.MaxUint64)) }},
 		{"float64", func(e Encoder) { e.AddFloat64("k", 1.0) }},
+		{"float32", func(e Encoder) { e.AddFloat32("k", 1.0) }},
+		{"namespace", func(e Encoder) { e.OpenNamespace("k") }},
+		{"raw JSON", func(e Encoder) {
+			assert.NoError(t, e.AddRawJSON("k", []byte("{}")), "Unexpected error adding raw JSON.")
+		}},
 		{"marshaler", func(e Encoder) {
 			assert.NoError(t, e.AddMarshaler("k", loggable{true}), "Unexpected error calling MarshalLog.")
 		}},
@@ -66,18 +73,13 @@ func TestNullWriteEntry(t *testing.T) {
 	entry := &Entry{Level: InfoLevel, Message: `ohai`, Time: time.Unix(0, 0)}
 	enc := NullEncoder()
 
-	assert.Equal(t, errNilSink, enc.WriteEntry(
-		nil,
-		entry.Message,
-		entry.Level,
-		entry.Time,
-	), "Expected an error writing to a nil sink.")
+	assert.Equal(t, errNilSink, enc.WriteEntry(nil, *entry), "Expected an error writing to a nil sink.")
 
 	// Messages should be thrown away.
 	sink := &bytes.Buffer{}
 	enc.AddString("foo", "bar")
 	assert.Len(t, sink.Bytes(), 0)
-	err := enc.WriteEntry(sink, entry.Message, entry.Level, entry.Time)
+	err := enc.WriteEntry(sink, *entry)
 	assert.NoError(t, err, "WriteEntry returned an unexpected error.")
 	assert.Len(
 		t,
@@ -20,6 +20,11 @@
 
 package zap
 
+import "os"
+
+// For tests.
+var _hostname = os.Hostname
+
 // Option is used to set options for the logger.
 type Option interface {
 	apply(*Meta)
@@ -39,7 +44,27 @@ func (lvl AtomicLevel) apply(m *Meta) { m.LevelEnabler = lvl }
 // Fields sets the initial fields for the logger.
 func Fields(fields ...Field) Option {
 	return OptionFunc(func(m *Meta) {
-		addFields(m.Encoder, fields)
+		m.addContext(fields)
+	})
+}
+
+// AddHostname adds the machine's hostname to the logger's context under the
+// "hostname" key. The hostname is resolved once, when the logger is
+// constructed; if it can't be determined, the field's value is "unknown".
+func AddHostname() Option {
+	return OptionFunc(func(m *Meta) {
+		host, err := _hostname()
+		if err != nil || host == "" {
+			host = "unknown"
+		}
+		m.addContext([]Field{String("hostname", host)})
+	})
+}
+
+// AddPID adds the process ID to the logger's context under the "pid" key.
+func AddPID() Option {
+	return OptionFunc(func(m *Meta) {
+		m.addContext([]Field{Int("pid", os.Getpid())})
 	})
 }
 
@@ -68,3 +93,32 @@ func Development() Option {
 		m.Development = true
 	})
 }
+
+// UTCTimestamps converts each entry's timestamp to UTC before encoding it, so
+// that encoders which format times as text (like RFC3339Formatter, the text
+// encoder, and the access log encoder) write the same offset on every host.
+// Timestamps written as seconds or nanoseconds since epoch, including Time
+// fields, don't depend on the location, and they're unaffected.
+func UTCTimestamps() Option {
+	return OptionFunc(func(m *Meta) {
+		m.utc = true
+	})
+}
+
+// AuditOutput sets the destination for entries written by the logger's audit
+// loggers (see Audit), so that they can be kept separately from operational
+// logs, typically somewhere more durable. By default, audit loggers share the
+// logger's Output. The supplied WriteSyncer must be safe for concurrent use.
+func AuditOutput(w WriteSyncer) Option {
+	return OptionFunc(func(m *Meta) {
+		m.auditOutput = w
+	})
+}
+
+// WithClock configures the logger to timestamp entries using the supplied
+// Clock instead of the system clock. It's primarily useful in tests.
+func WithClock(clock Clock) Option {
+	return OptionFunc(func(m *Meta) {
+		m.Clock = clock
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPointerFields(t *testing.T) {
+	var (
+		b   = true
+		f   = 1.5
+		i   = -42
+		i64 = int64(42)
+		u   = uint(42)
+		u64 = uint64(42)
+		s   = "foo bar"
+		ts  = time.Unix(1, int64(500*time.Millisecond))
+		d   = time.Microsecond
+	)
+
+	tests := []struct {
+		field Field
+		json  string
+		text  string
+	}{
+		{Boolp("k", &b), `"k":true`, "k=true"},
+		{Boolp("k", nil), `"k":null`, "k=<nil>"},
+		{Float64p("k", &f), `"k":1.5`, "k=1.5"},
+		{Float64p("k", nil), `"k":null`, "k=<nil>"},
+		{Intp("k", &i), `"k":-42`, "k=-42"},
+		{Intp("k", nil), `"k":null`, "k=<nil>"},
+		{Int64p("k", &i64), `"k":42`, "k=42"},
+		{Int64p("k", nil), `"k":null`, "k=<nil>"},
+		{Uintp("k", &u), `"k":42`, "k=42"},
+		{Uintp("k", nil), `"k":null`, "k=<nil>"},
+		{Uint64p("k", &u64), `"k":42`, "k=42"},
+		{Uint64p("k", nil), `"k":null`, "k=<nil>"},
+		{Stringp("k", &s), `"k":"foo bar"`, "k=foo bar"},
+		{Stringp("k", nil), `"k":null`, "k=<nil>"},
+		{Timep("k", &ts), `"k":1.5`, "k=1.5"},
+		{Timep("k", nil), `"k":null`, "k=<nil>"},
+		{Durationp("k", &d), `"k":1000`, "k=1000"},
+		{Durationp("k", nil), `"k":null`, "k=<nil>"},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.json, tt.field)
+		assertCanBeReused(t, tt.field)
+		withTextEncoder(func(enc *textEncoder) {
+			tt.field.AddTo(enc)
+			assert.Equal(t, tt.text, string(enc.bytes), "Unexpected text output for field %+v.", tt.field)
+		})
+	}
+}
+
+func TestPointerFieldsInNamespacesAndArrays(t *testing.T) {
+	n := 1
+	elem := LogMarshalerFunc(func(kv KeyValue) error {
+		Intp("set", &n).AddTo(kv)
+		Intp("unset", nil).AddTo(kv)
+		return nil
+	})
+	fields := []Field{
+		Namespace("ns"),
+		Stringp("s", nil),
+		Array("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+			return arr.AppendMarshaler(elem)
+		})),
+	}
+
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		logger.Info("ptrs", fields...)
+		assert.Equal(t, `{"level":"info","msg":"ptrs","ns":{"s":null,"arr":[{"set":1,"unset":null}]}}`, buf.Stripped(), "Unexpected JSON output.")
+	})
+	withTextLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		logger.Info("ptrs", fields...)
+		assert.Equal(t, "[I] ptrs ns.s=<nil> ns.arr=[{set=1 unset=<nil>}]", buf.Stripped(), "Unexpected text output.")
+	})
+}
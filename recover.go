@@ -0,0 +1,154 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "strings"
+
+// A RecoverOption configures a Recoverer.
+type RecoverOption interface {
+	apply(*Recoverer)
+}
+
+type recoverOptionFunc func(*Recoverer)
+
+func (f recoverOptionFunc) apply(r *Recoverer) {
+	f(r)
+}
+
+// RecoverLevel sets the level at which recovered panics are logged. By
+// default, they're logged at ErrorLevel. Since the entry is written with the
+// logger's Log method, PanicLevel and FatalLevel don't panic or exit; use
+// Repanic to keep the panic going.
+func RecoverLevel(lvl Level) RecoverOption {
+	return recoverOptionFunc(func(r *Recoverer) {
+		r.lvl = lvl
+	})
+}
+
+// Repanic re-raises recovered panics with their original values after logging
+// and syncing (see Sync), so that the process still crashes but the entry
+// isn't lost. By default, recovered panics are swallowed.
+func Repanic() RecoverOption {
+	return recoverOptionFunc(func(r *Recoverer) {
+		r.repanic = true
+	})
+}
+
+// A Recoverer logs recovered panics. It's safe for concurrent use.
+type Recoverer struct {
+	log     Logger
+	lvl     Level
+	repanic bool
+}
+
+// NewRecoverer creates a Recoverer that logs to the supplied Logger.
+func NewRecoverer(log Logger, opts ...RecoverOption) *Recoverer {
+	r := &Recoverer{log: log, lvl: ErrorLevel}
+	for _, opt := range opts {
+		opt.apply(r)
+	}
+	return r
+}
+
+// Recover must be deferred. If the calling goroutine is panicking, it stops
+// the panic and logs the message and fields, along with the panic value under
+// the key "panic" (see Any) and a stacktrace of the panicking goroutine under
+// the key "stacktrace". The stacktrace begins at the function that panicked,
+// without the frames of the Recoverer itself.
+//
+// If the goroutine isn't panicking, Recover does nothing and doesn't
+// allocate.
+func (r *Recoverer) Recover(msg string, fields ...Field) {
+	// recover only works when called directly by a deferred function.
+	if v := recover(); v != nil {
+		r.handle(v, msg, fields)
+	}
+}
+
+// Go runs the function in a new goroutine, recovering and logging any panic
+// with Recover.
+func (r *Recoverer) Go(fn func()) {
+	go func() {
+		defer r.Recover("Recovered from a panic in a goroutine.")
+		fn()
+	}()
+}
+
+func (r *Recoverer) handle(v interface{}, msg string, fields []Field) {
+	fs := make([]Field, 0, len(fields)+2)
+	fs = append(fs, fields...)
+	fs = append(fs, Any("panic", v), String("stacktrace", panicStack()))
+	r.log.Log(r.lvl, msg, fs...)
+	if r.repanic {
+		if err := Sync(r.log); err != nil {
+			reportInternalError(r.log, "sync", err)
+		}
+		panic(v)
+	}
+}
+
+// RecoverAndLog is a deferrable shorthand for NewRecoverer(log).Recover: it
+// recovers from any panic and logs it at ErrorLevel, without re-raising it.
+func RecoverAndLog(log Logger, msg string, fields ...Field) {
+	if v := recover(); v != nil {
+		NewRecoverer(log).handle(v, msg, fields)
+	}
+}
+
+// Go is a shorthand for NewRecoverer(log).Go: it runs the function in a new
+// goroutine, logging (and swallowing) any panic.
+func Go(log Logger, fn func()) {
+	NewRecoverer(log).Go(fn)
+}
+
+// panicStack returns a stacktrace of the current goroutine, which must be
+// running deferred calls because of a panic, starting at the function that
+// panicked.
+func panicStack() string {
+	stack := takeStacktrace(nil, false)
+	// The frames above the call to panic belong to the deferred calls
+	// (including this one), and those just below it to the runtime if the
+	// panic was a runtime error (like a nil dereference).
+	i := strings.Index(stack, "\npanic(")
+	if i < 0 {
+		return stack
+	}
+	header := stack[:strings.IndexByte(stack, '\n')+1]
+	frames := stack[i+1:]
+	for {
+		frames = skipFrame(frames)
+		if !strings.HasPrefix(frames, "runtime.") {
+			return header + frames
+		}
+	}
+}
+
+// skipFrame removes the first frame, which spans two lines, from a stacktrace.
+func skipFrame(frames string) string {
+	for n := 0; n < 2; n++ {
+		i := strings.IndexByte(frames, '\n')
+		if i < 0 {
+			return ""
+		}
+		frames = frames[i+1:]
+	}
+	return frames
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recoveredStringer struct{}
+
+func (recoveredStringer) String() string { return "stringer" }
+
+// decodeRecovered decodes the single entry written by a recovered panic.
+func decodeRecovered(t testing.TB, buf *testBuffer) map[string]interface{} {
+	lines := buf.Lines()
+	require.Equal(t, 1, len(lines), "Expected exactly one entry.")
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry), "Expected valid JSON.")
+	return entry
+}
+
+func panickingFunction(v interface{}) {
+	panic(v)
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	tests := []struct {
+		desc     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"error", errors.New("boom"), "boom"},
+		{"string", "boom", "boom"},
+		{"Stringer", recoveredStringer{}, "stringer"},
+		{"int", 42, float64(42)},
+		{"struct", struct{ N int }{42}, map[string]interface{}{"N": float64(42)}},
+	}
+
+	for _, tt := range tests {
+		buf := &testBuffer{}
+		logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard))
+		assert.NotPanics(t, func() {
+			defer RecoverAndLog(logger, "recovered", String("k", "v"))
+			panickingFunction(tt.value)
+		}, "Expected RecoverAndLog to swallow a panic with a %s.", tt.desc)
+
+		entry := decodeRecovered(t, buf)
+		assert.Equal(t, "error", entry["level"], "Unexpected level for a panic with a %s.", tt.desc)
+		assert.Equal(t, "recovered", entry["msg"], "Unexpected message for a panic with a %s.", tt.desc)
+		assert.Equal(t, "v", entry["k"], "Expected fields to be logged for a panic with a %s.", tt.desc)
+		assert.Equal(t, tt.expected, entry["panic"], "Unexpected panic value for a panic with a %s.", tt.desc)
+
+		stack, _ := entry["stacktrace"].(string)
+		lines := strings.Split(stack, "\n")
+		require.True(t, len(lines) > 1, "Expected a stacktrace for a panic with a %s.", tt.desc)
+		assert.True(t, strings.HasPrefix(lines[0], "goroutine "), "Expected the stacktrace to keep its header.")
+		assert.Contains(t, lines[1], "zap.panickingFunction(", "Expected the stacktrace to begin at the panic.")
+		for _, frame := range []string{"zap.RecoverAndLog(", "zap.(*Recoverer)", "zap.panicStack("} {
+			assert.NotContains(t, stack, frame, "Expected the stacktrace to exclude the recovery helper.")
+		}
+	}
+}
+
+func TestRecoverRuntimeError(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard))
+	func() {
+		defer RecoverAndLog(logger, "recovered")
+		var m map[string]int
+		m["nil"] = 1
+	}()
+	entry := decodeRecovered(t, buf)
+	assert.Contains(t, entry["panic"], "nil map", "Unexpected panic value for a runtime error.")
+	lines := strings.Split(entry["stacktrace"].(string), "\n")
+	require.True(t, len(lines) > 1, "Expected a stacktrace.")
+	assert.Contains(t, lines[1], "zap.TestRecoverRuntimeError.", "Expected the stacktrace to skip the runtime's frames.")
+}
+
+func TestRecovererOptions(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard))
+	sink := &syncSpy{}
+	r := NewRecoverer(New(newJSONEncoder(NoTime()), Output(sink), ErrorOutput(Discard)), RecoverLevel(FatalLevel), Repanic())
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		defer r.Recover("recovered")
+		panic("boom")
+	}()
+	assert.Equal(t, "boom", recovered, "Expected Repanic to re-raise the original value.")
+	assert.True(t, sink.Called(), "Expected the logger to be synced before re-raising.")
+	assert.Contains(t, sink.String(), `"level":"fatal","msg":"recovered","panic":"boom"`, "Unexpected output with RecoverLevel.")
+
+	stub := stubExit()
+	defer stub.Unstub()
+	func() {
+		defer NewRecoverer(logger, RecoverLevel(PanicLevel)).Recover("swallowed")
+		panic("boom")
+	}()
+	stub.AssertNoExit(t)
+	assert.Equal(t, "panic", decodeRecovered(t, buf)["level"], "Unexpected level with RecoverLevel.")
+}
+
+// notifyingBuffer is a testBuffer that signals each write on a channel.
+type notifyingBuffer struct {
+	testBuffer
+	written chan struct{}
+}
+
+func (b *notifyingBuffer) Write(p []byte) (int, error) {
+	n, err := b.testBuffer.Write(p)
+	b.written <- struct{}{}
+	return n, err
+}
+
+func TestRecovererGo(t *testing.T) {
+	buf := &notifyingBuffer{written: make(chan struct{}, 1)}
+	logger := New(newJSONEncoder(NoTime()), Output(buf), ErrorOutput(Discard))
+	Go(logger, func() { panickingFunction("boom") })
+	<-buf.written
+	entry := decodeRecovered(t, &buf.testBuffer)
+	assert.Equal(t, "Recovered from a panic in a goroutine.", entry["msg"], "Unexpected message.")
+	assert.Equal(t, "boom", entry["panic"], "Unexpected panic value.")
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(newJSONEncoder(NoTime()), Output(buf))
+	r := NewRecoverer(logger, Repanic())
+	allocs := testing.AllocsPerRun(100, func() {
+		defer RecoverAndLog(logger, "recovered")
+	})
+	assert.Equal(t, 0.0, allocs, "Expected RecoverAndLog to be allocation-free without a panic.")
+	allocs = testing.AllocsPerRun(100, func() {
+		defer r.Recover("recovered")
+	})
+	assert.Equal(t, 0.0, allocs, "Expected Recover to be allocation-free without a panic.")
+	assert.Empty(t, buf.Lines(), "Expected no output without a panic.")
+}
@@ -0,0 +1,415 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// _redacted replaces the values of redacted fields.
+const _redacted = "[REDACTED]"
+
+// Redact wraps a field, replacing its value with "[REDACTED]" but keeping its
+// key. The wrapped field's value is never evaluated.
+func Redact(f Field) Field {
+	switch f.fieldType {
+	case skipType:
+		return f
+	case replaceType:
+		return Replace(String(f.key, _redacted))
+	}
+	return String(f.key, _redacted)
+}
+
+// RedactKeys configures the logger to replace the value of any field whose key
+// matches one of the supplied patterns with "[REDACTED]". Patterns are matched
+// case-insensitively against the whole key, and they may include the
+// wildcards '*' (any sequence of characters) and '?' (any single character).
+//
+// Keys are matched at every level of nesting, including the keys of nested
+// LogMarshalers, Maps, Structs, and objects serialized with reflection. The
+// logger's initial fields are redacted too, regardless of whether they're
+// passed before or after this option.
+func RedactKeys(patterns ...string) Option {
+	r := newKeyRedactor(patterns)
+	return OptionFunc(func(m *Meta) {
+		m.redactor = r
+	})
+}
+
+// A keyRedactor is a precompiled set of key patterns.
+type keyRedactor struct {
+	exact map[string]struct{}
+	globs []string
+}
+
+func newKeyRedactor(patterns []string) *keyRedactor {
+	if len(patterns) == 0 {
+		return nil
+	}
+	r := &keyRedactor{exact: make(map[string]struct{}, len(patterns))}
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if strings.ContainsAny(p, "*?") {
+			r.globs = append(r.globs, p)
+			continue
+		}
+		r.exact[p] = struct{}{}
+	}
+	return r
+}
+
+// matches reports whether the key should be redacted. It's safe to call on a
+// nil keyRedactor, which doesn't match anything.
+func (r *keyRedactor) matches(key string) bool {
+	if r == nil {
+		return false
+	}
+	key = strings.ToLower(key)
+	if _, ok := r.exact[key]; ok {
+		return true
+	}
+	for _, g := range r.globs {
+		if globMatch(g, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch reports whether s matches the pattern, in which '*' matches any
+// sequence of bytes and '?' matches any single byte.
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	// The position of the last star in the pattern, and the position in s
+	// that it's currently matched up to.
+	star, starMatch := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, starMatch = p, i
+			p++
+		case star >= 0:
+			// Backtrack, letting the last star consume one more byte.
+			starMatch++
+			p, i = star+1, starMatch
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func (r *keyRedactor) wrap(kv KeyValue) KeyValue {
+	return redactingKeyValue{kv, r}
+}
+
+// redactingKeyValue replaces the values of matching keys before passing them
+// to the wrapped KeyValue. It also wraps nested LogMarshalers and
+// ArrayMarshalers, so that nested keys are redacted too.
+type redactingKeyValue struct {
+	kv KeyValue
+	r  *keyRedactor
+}
+
+func (rkv redactingKeyValue) redacted(key string) bool {
+	if rkv.r.matches(key) {
+		rkv.kv.AddString(key, _redacted)
+		return true
+	}
+	return false
+}
+
+func (rkv redactingKeyValue) AddBinary(key string, val []byte) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddBinary(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddBool(key string, val bool) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddBool(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddByteString(key string, val []byte) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddByteString(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddFloat32(key string, val float32) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddFloat32(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddFloat64(key string, val float64) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddFloat64(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddInt(key string, val int) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddInt(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddInt64(key string, val int64) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddInt64(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddUint(key string, val uint) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddUint(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddUint64(key string, val uint64) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddUint64(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddUintptr(key string, val uintptr) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddUintptr(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) AddMarshaler(key string, obj LogMarshaler) error {
+	if rkv.redacted(key) {
+		return nil
+	}
+	return rkv.kv.AddMarshaler(key, rkv.r.wrapMarshaler(obj))
+}
+
+func (rkv redactingKeyValue) AddArray(key string, arr ArrayMarshaler) error {
+	if rkv.redacted(key) {
+		return nil
+	}
+	return rkv.kv.AddArray(key, rkv.r.wrapArray(arr))
+}
+
+func (rkv redactingKeyValue) AddRawJSON(key string, val []byte) error {
+	if rkv.redacted(key) {
+		return nil
+	}
+	if redacted, ok := rkv.r.redactJSON(val); ok {
+		val = redacted
+	}
+	return rkv.kv.AddRawJSON(key, val)
+}
+
+func (rkv redactingKeyValue) AddObject(key string, obj interface{}) error {
+	if rkv.redacted(key) {
+		return nil
+	}
+	if m, ok := obj.(LogMarshaler); ok && !isNilPointer(m) {
+		return rkv.AddMarshaler(key, m)
+	}
+	if m, ok := lookupTypeEncoder(obj); ok {
+		return rkv.AddMarshaler(key, m)
+	}
+	if s, ok, err := canonicalString(obj); ok {
+		rkv.kv.AddString(key, s)
+		return err
+	}
+	redacted, ok := rkv.r.redactObject(obj)
+	if !ok {
+		return addSanitized(rkv, key, reflect.ValueOf(obj), DefaultMapDepth)
+	}
+	return rkv.kv.AddObject(key, redacted)
+}
+
+func (rkv redactingKeyValue) AddString(key, val string) {
+	if !rkv.redacted(key) {
+		rkv.kv.AddString(key, val)
+	}
+}
+
+func (rkv redactingKeyValue) addReplacement(f Field, kv KeyValue) {
+	if r, ok := rkv.kv.(replacer); ok {
+		r.addReplacement(f, kv)
+		return
+	}
+	f.AddTo(kv)
+}
+
+func (rkv redactingKeyValue) OpenNamespace(key string) {
+	rkv.kv.OpenNamespace(key)
+}
+
+func (r *keyRedactor) wrapMarshaler(obj LogMarshaler) LogMarshaler {
+	return LogMarshalerFunc(func(kv KeyValue) error {
+		return obj.MarshalLog(r.wrap(kv))
+	})
+}
+
+func (r *keyRedactor) wrapArray(arr ArrayMarshaler) ArrayMarshaler {
+	return ArrayMarshalerFunc(func(enc ArrayEncoder) error {
+		return arr.MarshalLogArray(redactingArrayEncoder{enc, r})
+	})
+}
+
+// redactingArrayEncoder wraps nested objects and arrays, so that their keys
+// are redacted.
+type redactingArrayEncoder struct {
+	ArrayEncoder
+	r *keyRedactor
+}
+
+func (rae redactingArrayEncoder) AppendMarshaler(obj LogMarshaler) error {
+	return rae.ArrayEncoder.AppendMarshaler(rae.r.wrapMarshaler(obj))
+}
+
+func (rae redactingArrayEncoder) AppendArray(arr ArrayMarshaler) error {
+	return rae.ArrayEncoder.AppendArray(rae.r.wrapArray(arr))
+}
+
+func (rae redactingArrayEncoder) AppendObject(obj interface{}) error {
+	if m, ok := obj.(LogMarshaler); ok && !isNilPointer(m) {
+		return rae.AppendMarshaler(m)
+	}
+	if m, ok := lookupTypeEncoder(obj); ok {
+		return rae.AppendMarshaler(m)
+	}
+	if s, ok, err := canonicalString(obj); ok {
+		rae.ArrayEncoder.AppendString(s)
+		return err
+	}
+	redacted, ok := rae.r.redactObject(obj)
+	if !ok {
+		return addSanitized(&arrayElement{arr: rae}, "", reflect.ValueOf(obj), DefaultMapDepth)
+	}
+	return rae.ArrayEncoder.AppendObject(redacted)
+}
+
+// A marshaledObject is an object that's already been serialized with
+// json.Marshal, possibly with some values redacted. The JSON and binary
+// encoders splice the serialized bytes in directly rather than marshaling the
+// object again. Other encoders see a json.Marshaler and TextMarshaler.
+type marshaledObject struct {
+	obj      interface{}
+	json     []byte
+	redacted bool
+}
+
+func (mo marshaledObject) MarshalJSON() ([]byte, error) {
+	return mo.json, nil
+}
+
+func (mo marshaledObject) MarshalText() ([]byte, error) {
+	return mo.json, nil
+}
+
+// redactObject serializes an object that would otherwise be serialized with
+// reflection, redacting any matching keys, and returns it as a
+// marshaledObject. If the object can't be serialized, it's returned as-is, so
+// that the encoder can report the error. Callers should handle LogMarshalers,
+// types with registered TypeEncoders, and values with a canonical string
+// first.
+//
+// If the object contains non-finite floats, which encoding/json can't
+// serialize, redactObject reports false; the caller should encode it with
+// addSanitized through the redacting wrapper instead.
+func (r *keyRedactor) redactObject(obj interface{}) (interface{}, bool) {
+	if obj == nil {
+		return nil, true
+	}
+	marshaled, err := json.Marshal(obj)
+	if isNonFiniteError(err) {
+		return nil, false
+	}
+	if err != nil {
+		// Let the encoder report the error.
+		return obj, true
+	}
+	redacted, changed := r.redactJSON(marshaled)
+	return marshaledObject{obj: obj, json: redacted, redacted: changed}, true
+}
+
+// redactJSON redacts the values of matching keys in a compact JSON value. It
+// reports whether anything was redacted.
+func (r *keyRedactor) redactJSON(src []byte) ([]byte, bool) {
+	if bytes.IndexByte(src, ':') < 0 {
+		// Not an object, or an object with no keys.
+		return src, false
+	}
+	dst, changed := r.appendRedactedJSON(make([]byte, 0, len(src)), src)
+	return dst, changed
+}
+
+func (r *keyRedactor) appendRedactedJSON(dst, src []byte) ([]byte, bool) {
+	if len(src) == 0 || (src[0] != '{' && src[0] != '[') {
+		return append(dst, src...), false
+	}
+	changed := false
+	isObject := src[0] == '{'
+	dst = append(dst, src[0])
+	i := 1
+	for i < len(src) && src[i] != '}' && src[i] != ']' {
+		if src[i] == ',' {
+			dst = append(dst, ',')
+			i++
+		}
+		if isObject {
+			keyEnd := skipJSONString(src, i)
+			rawKey := src[i:keyEnd]
+			dst = append(dst, rawKey...)
+			dst = append(dst, ':')
+			i = keyEnd + 1
+			if r.matches(unquoteJSONKey(rawKey)) {
+				dst = append(dst, `"`+_redacted+`"`...)
+				i = skipJSONValue(src, i)
+				changed = true
+				continue
+			}
+		}
+		end := skipJSONValue(src, i)
+		var nestedChanged bool
+		dst, nestedChanged = r.appendRedactedJSON(dst, src[i:end])
+		changed = changed || nestedChanged
+		i = end
+	}
+	return append(dst, src[i:]...), changed
+}
+
+func unquoteJSONKey(quoted []byte) string {
+	if bytes.IndexByte(quoted, '\\') < 0 && len(quoted) >= 2 {
+		return string(quoted[1 : len(quoted)-1])
+	}
+	var key string
+	json.Unmarshal(quoted, &key)
+	return key
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type redactedUser struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Tokens   []redactedToken
+}
+
+type redactedToken struct {
+	ID        int    `json:"id"`
+	APISecret string `json:"api_secret"`
+}
+
+type redactedRequest struct {
+	Path    string
+	Headers map[string]string
+}
+
+func (r redactedRequest) MarshalLog(kv KeyValue) error {
+	kv.AddString("path", r.Path)
+	return kv.AddArray("headers", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+		for k, v := range r.Headers {
+			arr.AppendMarshaler(LogMarshalerFunc(func(kv KeyValue) error {
+				kv.AddString(k, v)
+				return nil
+			}))
+		}
+		return nil
+	}))
+}
+
+func TestRedactField(t *testing.T) {
+	tests := []struct {
+		field    Field
+		expected string
+	}{
+		{Redact(String("password", "hunter2")), `"password":"[REDACTED]"`},
+		{Redact(Int("ssn", 123456789)), `"ssn":"[REDACTED]"`},
+		{Redact(Marshaler("user", redactedRequest{Path: "/"})), `"user":"[REDACTED]"`},
+		{Redact(Skip()), ``},
+	}
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, tt.field)
+		assertCanBeReused(t, tt.field)
+	}
+}
+
+func TestRedactFieldIsNotEvaluated(t *testing.T) {
+	s := &countingStringer{}
+	f := Redact(Stringer("k", s))
+	assertFieldJSON(t, `"k":"[REDACTED]"`, f)
+	assert.Equal(t, 0, s.calls, "Expected the redacted Stringer not to be called.")
+}
+
+func TestRedactKeys(t *testing.T) {
+	opts := []Option{RedactKeys("password", "Authorization", "*secret*", "ss?")}
+	user := redactedUser{
+		Name:     "jane",
+		Password: "hunter2",
+		Tokens:   []redactedToken{{ID: 1, APISecret: "s3cr3t"}},
+	}
+	tests := []struct {
+		desc     string
+		fields   []Field
+		expected string
+	}{
+		{
+			"top-level keys",
+			[]Field{String("password", "hunter2"), Int("SSN", 123456789), String("user", "jane")},
+			`"password":"[REDACTED]","SSN":"[REDACTED]","user":"jane"`,
+		},
+		{
+			"globs",
+			[]Field{String("apiSecretKey", "x"), String("secret", "y"), String("ssno", "z"), String("sec", "w")},
+			`"apiSecretKey":"[REDACTED]","secret":"[REDACTED]","ssno":"z","sec":"w"`,
+		},
+		{
+			"non-string values",
+			[]Field{Bool("password", true), Array("authorization", nested(1)), Object("ssn", user)},
+			`"password":"[REDACTED]","authorization":"[REDACTED]","ssn":"[REDACTED]"`,
+		},
+		{
+			"nested marshalers and arrays",
+			[]Field{Marshaler("req", redactedRequest{Path: "/", Headers: map[string]string{"Authorization": "Bearer x"}})},
+			`"req":{"path":"/","headers":[{"Authorization":"[REDACTED]"}]}`,
+		},
+		{
+			"namespaces",
+			[]Field{Namespace("creds"), String("password", "hunter2"), String("user", "jane")},
+			`"creds":{"password":"[REDACTED]","user":"jane"}`,
+		},
+		{
+			"maps",
+			[]Field{Map("m", map[string]interface{}{"inner": map[string]interface{}{"password": "x", "ok": 1}})},
+			`"m":{"inner":{"ok":1,"password":"[REDACTED]"}}`,
+		},
+		{
+			"structs",
+			[]Field{Struct("u", user)},
+			`"u":{"name":"jane","password":"[REDACTED]","Tokens":[{"id":1,"api_secret":"[REDACTED]"}]}`,
+		},
+		{
+			"reflection",
+			[]Field{Object("u", user), Object("nothing", []int{1, 2})},
+			`"u":{"name":"jane","password":"[REDACTED]","Tokens":[{"id":1,"api_secret":"[REDACTED]"}]},"nothing":[1,2]`,
+		},
+		{
+			"reflection in arrays",
+			[]Field{Array("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+				return arr.AppendObject(user.Tokens[0])
+			}))},
+			`"arr":[{"id":1,"api_secret":"[REDACTED]"}]`,
+		},
+		{
+			"reflection with non-finite floats",
+			[]Field{Object("u", struct {
+				Password string `json:"password"`
+				Scores   []float64
+			}{"hunter2", []float64{math.Inf(1)}})},
+			`"u":{"password":"[REDACTED]","Scores":["+Inf"]}`,
+		},
+		{
+			"raw JSON",
+			[]Field{RawJSON("raw", []byte(`{"a":[{"password":{"nested":true}}],"bs":"x"}`))},
+			`"raw":{"a":[{"password":"[REDACTED]"}],"bs":"x"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		withJSONLogger(t, opts, func(logger Logger, buf *testBuffer) {
+			logger.Info("", tt.fields...)
+			assert.Equal(t, `{"level":"info","msg":"",`+tt.expected+`}`, buf.Stripped(), "Unexpected output redacting %s.", tt.desc)
+		})
+	}
+}
+
+func TestRedactKeysInContext(t *testing.T) {
+	optionOrders := [][]Option{
+		{RedactKeys("password"), Fields(String("password", "a"))},
+		{Fields(String("password", "a")), RedactKeys("password")},
+	}
+	for _, opts := range optionOrders {
+		withJSONLogger(t, opts, func(logger Logger, buf *testBuffer) {
+			logger.With(String("password", "b")).Info("", String("password", "c"))
+			assert.Equal(
+				t,
+				`{"level":"info","msg":"","password":"[REDACTED]","password":"[REDACTED]","password":"[REDACTED]"}`,
+				buf.Stripped(),
+				"Expected context fields to be redacted.",
+			)
+			assert.Equal(t, []Field{Redact(String("password", "a"))}, Context(logger), "Expected the recorded context to be redacted.")
+		})
+	}
+}
+
+func TestRedactKeysCanonicalStrings(t *testing.T) {
+	withJSONLogger(t, []Option{RedactKeys("password")}, func(logger Logger, buf *testBuffer) {
+		s := &countingStringer{}
+		logger.Info("", Object("k", s), Object("p", panicStringer{}))
+		assert.Equal(
+			t,
+			`{"level":"info","msg":"","k":"counted","p":"<PANIC>","pError":"PANIC=oh no"}`,
+			buf.Stripped(),
+			"Unexpected output redacting objects with String methods.",
+		)
+		assert.Equal(t, 1, s.calls, "Expected String to be called once.")
+	})
+}
+
+type countingMarshaler struct{ calls int }
+
+func (c *countingMarshaler) MarshalJSON() ([]byte, error) {
+	c.calls++
+	return []byte(`"marshaled"`), nil
+}
+
+func TestRedactKeysMarshalsOnce(t *testing.T) {
+	withJSONLogger(t, []Option{RedactKeys("password")}, func(logger Logger, buf *testBuffer) {
+		m := &countingMarshaler{}
+		logger.Info("", Object("k", struct{ M *countingMarshaler }{m}))
+		assert.Equal(t, `{"level":"info","msg":"","k":{"M":"marshaled"}}`, buf.Stripped(), "Unexpected output.")
+		assert.Equal(t, 1, m.calls, "Expected reflected objects to be marshaled once.")
+	})
+}
+
+func TestRedactKeysText(t *testing.T) {
+	user := redactedUser{Name: "jane", Password: "hunter2"}
+	withTextLogger(t, []Option{RedactKeys("password")}, func(logger Logger, buf *testBuffer) {
+		logger.Info("", Object("u", user), Object("plain", redactedToken{ID: 1}), String("password", "x"))
+		assert.Equal(
+			t,
+			`[I]  u={"name":"jane","password":"[REDACTED]","Tokens":null} plain={ID:1 APISecret:} password=[REDACTED]`,
+			buf.Stripped(),
+			"Unexpected redacted text output.",
+		)
+	})
+}
+
+func TestRedactKeysMatching(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		key      string
+		matches  bool
+	}{
+		{nil, "password", false},
+		{[]string{"password"}, "password", true},
+		{[]string{"password"}, "PassWord", true},
+		{[]string{"PASSWORD"}, "password", true},
+		{[]string{"password"}, "passwords", false},
+		{[]string{"pass*"}, "pass", true},
+		{[]string{"pass*"}, "passphrase", true},
+		{[]string{"*token"}, "accessToken", true},
+		{[]string{"*token"}, "tokens", false},
+		{[]string{"a*b*c"}, "aXbYbZc", true},
+		{[]string{"a*b*c"}, "aXbYbZ", false},
+		{[]string{"?"}, "", false},
+		{[]string{"*"}, "", true},
+		{[]string{"x-*-key"}, "X-Api-Key", true},
+	}
+	for _, tt := range tests {
+		r := newKeyRedactor(tt.patterns)
+		assert.Equal(t, tt.matches, r.matches(tt.key), "Unexpected result matching %q against %v.", tt.key, tt.patterns)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// A RuntimeStat selects one of the runtime statistics logged by
+// LogRuntimeStats. RuntimeStats may be combined with bitwise OR, and they
+// implement the RuntimeStatsOption interface.
+type RuntimeStat uint
+
+const (
+	// HeapAllocStat logs the bytes of allocated heap objects under the
+	// "heapAlloc" key.
+	HeapAllocStat RuntimeStat = 1 << iota
+	// HeapInuseStat logs the bytes in in-use heap spans under the "heapInuse"
+	// key.
+	HeapInuseStat
+	// HeapIdleStat logs the bytes in idle heap spans under the "heapIdle" key.
+	HeapIdleStat
+	// SysStat logs the total bytes of memory obtained from the OS under the
+	// "sys" key.
+	SysStat
+	// NumGCStat logs the number of completed GC cycles under the "numGC" key.
+	NumGCStat
+	// LastGCPauseStat logs the duration of the most recent GC pause under the
+	// "lastGCPause" key.
+	LastGCPauseStat
+	// GCCPUFractionStat logs the fraction of CPU time used by the GC since the
+	// program started under the "gcCPUFraction" key.
+	GCCPUFractionStat
+	// GoroutinesStat logs the number of goroutines under the "goroutines" key.
+	GoroutinesStat
+	// CgoCallsStat logs the number of cgo calls made by the process under the
+	// "cgoCalls" key.
+	CgoCallsStat
+
+	// AllRuntimeStats logs all the statistics above. It's the default.
+	AllRuntimeStats = CgoCallsStat<<1 - 1
+
+	// Reading MemStats stops the world, so avoid it unless it's necessary.
+	_memStats = HeapAllocStat | HeapInuseStat | HeapIdleStat | SysStat | NumGCStat | LastGCPauseStat | GCCPUFractionStat
+)
+
+func (rs RuntimeStat) apply(cfg *runtimeStatsConfig) {
+	cfg.stats = rs
+}
+
+// A RuntimeStatsOption configures LogRuntimeStats.
+type RuntimeStatsOption interface {
+	apply(*runtimeStatsConfig)
+}
+
+type runtimeStatsConfig struct {
+	stats RuntimeStat
+}
+
+// LogRuntimeStats starts a goroutine that logs a snapshot of the Go runtime's
+// statistics at InfoLevel on every tick of the supplied interval. Each entry
+// has the message "runtime stats" and typed fields for the heap, GC, goroutine,
+// and cgo statistics selected by the options (by default, all of them).
+//
+// Snapshots are taken one at a time: if writing an entry blocks, ticks are
+// dropped rather than queued. Calling the returned function stops the
+// goroutine and waits for it to exit; it's safe to call more than once.
+func LogRuntimeStats(log Logger, interval time.Duration, opts ...RuntimeStatsOption) (stop func()) {
+	cfg := runtimeStatsConfig{stats: AllRuntimeStats}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			// Don't log after stop has been called, even if a tick was ready
+			// at the same time.
+			select {
+			case <-done:
+				return
+			default:
+				logRuntimeStats(log, cfg.stats)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			<-exited
+		})
+	}
+}
+
+func logRuntimeStats(log Logger, stats RuntimeStat) {
+	cm := log.Check(InfoLevel, "runtime stats")
+	if !cm.OK() {
+		return
+	}
+	fields := make([]Field, 0, 9)
+	if stats&_memStats != 0 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		if stats&HeapAllocStat != 0 {
+			fields = append(fields, Uint64("heapAlloc", ms.HeapAlloc))
+		}
+		if stats&HeapInuseStat != 0 {
+			fields = append(fields, Uint64("heapInuse", ms.HeapInuse))
+		}
+		if stats&HeapIdleStat != 0 {
+			fields = append(fields, Uint64("heapIdle", ms.HeapIdle))
+		}
+		if stats&SysStat != 0 {
+			fields = append(fields, Uint64("sys", ms.Sys))
+		}
+		if stats&NumGCStat != 0 {
+			fields = append(fields, Uint64("numGC", uint64(ms.NumGC)))
+		}
+		if stats&LastGCPauseStat != 0 {
+			var pause time.Duration
+			if ms.NumGC > 0 {
+				pause = time.Duration(ms.PauseNs[(ms.NumGC+255)%256])
+			}
+			fields = append(fields, Duration("lastGCPause", pause))
+		}
+		if stats&GCCPUFractionStat != 0 {
+			fields = append(fields, Float64("gcCPUFraction", ms.GCCPUFraction))
+		}
+	}
+	if stats&GoroutinesStat != 0 {
+		fields = append(fields, Int("goroutines", runtime.NumGoroutine()))
+	}
+	if stats&CgoCallsStat != 0 {
+		fields = append(fields, Int64("cgoCalls", runtime.NumCgoCall()))
+	}
+	cm.Write(fields...)
+}
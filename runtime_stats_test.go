@@ -0,0 +1,132 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/uber-go/zap"
+	"github.com/uber-go/zap/spy"
+	"github.com/uber-go/zap/testutils"
+	"github.com/uber-go/zap/zwrap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/atomic"
+)
+
+func fieldKeys(fields []zap.Field) []string {
+	m := make(zwrap.KeyValueMap)
+	for _, f := range fields {
+		f.AddTo(m)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func waitForLogs(t testing.TB, sink *spy.Sink, n int) []spy.Log {
+	deadline := time.Now().Add(testutils.Timeout(time.Second))
+	for time.Now().Before(deadline) {
+		if logs := sink.Logs(); len(logs) >= n {
+			return logs
+		}
+		time.Sleep(time.Millisecond)
+	}
+	require.FailNow(t, "Timed out waiting for runtime stats.")
+	return nil
+}
+
+func TestLogRuntimeStats(t *testing.T) {
+	log, sink := spy.New()
+	stop := zap.LogRuntimeStats(log, time.Millisecond)
+	defer stop()
+
+	logs := waitForLogs(t, sink, 2)
+	for _, l := range logs[:2] {
+		assert.Equal(t, zap.InfoLevel, l.Level, "Unexpected level.")
+		assert.Equal(t, "runtime stats", l.Msg, "Unexpected message.")
+		assert.Equal(t, []string{
+			"cgoCalls",
+			"gcCPUFraction",
+			"goroutines",
+			"heapAlloc",
+			"heapIdle",
+			"heapInuse",
+			"lastGCPause",
+			"numGC",
+			"sys",
+		}, fieldKeys(l.Fields), "Unexpected runtime stats.")
+	}
+}
+
+func TestLogRuntimeStatsSubset(t *testing.T) {
+	log, sink := spy.New()
+	stop := zap.LogRuntimeStats(log, time.Millisecond, zap.GoroutinesStat|zap.HeapAllocStat)
+	defer stop()
+
+	logs := waitForLogs(t, sink, 1)
+	assert.Equal(t, []string{"goroutines", "heapAlloc"}, fieldKeys(logs[0].Fields), "Unexpected runtime stats.")
+}
+
+func TestLogRuntimeStatsStop(t *testing.T) {
+	log, sink := spy.New()
+	stop := zap.LogRuntimeStats(log, time.Millisecond)
+	waitForLogs(t, sink, 1)
+
+	stop()
+	n := len(sink.Logs())
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, n, len(sink.Logs()), "Expected no runtime stats after stopping.")
+	assert.NotPanics(t, stop, "Expected stop to be idempotent.")
+}
+
+func TestLogRuntimeStatsDisabled(t *testing.T) {
+	log, sink := spy.New(zap.WarnLevel)
+	stop := zap.LogRuntimeStats(log, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	stop()
+	assert.Empty(t, sink.Logs(), "Expected no runtime stats when InfoLevel is disabled.")
+}
+
+func TestLogRuntimeStatsDoesntOverlap(t *testing.T) {
+	base, sink := spy.New()
+	active := atomic.NewInt32(0)
+	overlapped := atomic.NewBool(false)
+	// Block each entry for much longer than the interval.
+	slow := zap.Filter(base, func(zap.Level, string, []zap.Field) bool {
+		if active.Inc() > 1 {
+			overlapped.Store(true)
+		}
+		time.Sleep(5 * time.Millisecond)
+		active.Dec()
+		return true
+	})
+	stop := zap.LogRuntimeStats(slow, 100*time.Microsecond)
+	waitForLogs(t, sink, 3)
+	stop()
+	assert.False(t, overlapped.Load(), "Expected snapshots to be taken one at a time.")
+}
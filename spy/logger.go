@@ -88,10 +88,15 @@ func (l *Logger) With(fields ...zap.Field) zap.Logger {
 	return &Logger{
 		Meta:    l.Meta.Clone(),
 		sink:    l.sink,
-		context: append(l.context, fields...),
+		context: append(l.context[:len(l.context):len(l.context)], fields...),
 	}
 }
 
+// Context returns a copy of the fields added with With.
+func (l *Logger) Context() []zap.Field {
+	return append([]zap.Field(nil), l.context...)
+}
+
 // Check returns a CheckedMessage if logging a particular message would succeed.
 func (l *Logger) Check(lvl zap.Level, msg string) *zap.CheckedMessage {
 	return l.Meta.Check(l, lvl, msg)
@@ -102,6 +107,11 @@ func (l *Logger) Log(lvl zap.Level, msg string, fields ...zap.Field) {
 	l.log(lvl, msg, fields)
 }
 
+// Trace logs at the Trace level.
+func (l *Logger) Trace(msg string, fields ...zap.Field) {
+	l.log(zap.TraceLevel, msg, fields)
+}
+
 // Debug logs at the Debug level.
 func (l *Logger) Debug(msg string, fields ...zap.Field) {
 	l.log(zap.DebugLevel, msg, fields)
@@ -134,6 +144,14 @@ func (l *Logger) Fatal(msg string, fields ...zap.Field) {
 	l.log(zap.FatalLevel, msg, fields)
 }
 
+// Terminates reports whether the level method for lvl panics or exits. Since
+// the spy Logger's Panic and Fatal methods only record the message, it never
+// does; this keeps chained CheckedMessages (see zap.CheckedMessage.Chain)
+// from exiting the process.
+func (l *Logger) Terminates(lvl zap.Level) bool {
+	return false
+}
+
 // DPanic logs at the DPanic level, and panics if the development flag is set.
 func (l *Logger) DPanic(msg string, fields ...zap.Field) {
 	l.log(zap.DPanicLevel, msg, fields)
@@ -0,0 +1,144 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	_stackdriverSourceLocationKey = "logging.googleapis.com/sourceLocation"
+	// Marks entries as error events for Google Cloud Error Reporting.
+	_reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+)
+
+// NewStackdriverEncoder creates a JSON encoder that lays out entries the way
+// Google Cloud Logging (formerly Stackdriver) expects, so that its agents can
+// parse them from a container's standard output:
+//
+//	{"severity":"ERROR","timestamp":{"seconds":1,"nanos":0},"message":"oh no",
+//	"logging.googleapis.com/sourceLocation":{"file":"main.go","line":"42","function":"main.main"},
+//	"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent",
+//	"stack_trace":"oh no\n\ngoroutine 1 [running]:\n..."}
+//
+// The source location and Error Reporting fields are only added to entries
+// that recorded a caller and a stack trace, so use the encoder with the
+// RecordCaller and RecordStacks options (not AddCaller and AddStacks, which
+// change the message and add a field instead). Any supplied options are
+// applied after the Stackdriver defaults, so they can override them.
+func NewStackdriverEncoder(options ...JSONOption) Encoder {
+	opts := []JSONOption{
+		StackdriverSeverity(),
+		StackdriverTimestamp(),
+		MessageKey("message"),
+		StackdriverSourceLocation(),
+		StackdriverErrorReport(),
+	}
+	return NewJSONEncoder(append(opts, options...)...)
+}
+
+// StackdriverSeverity encodes the entry's level under the "severity" key,
+// using Cloud Logging's severity names. TraceLevel and DebugLevel map to
+// DEBUG, InfoLevel to INFO, WarnLevel to WARNING, and ErrorLevel to ERROR;
+// DPanicLevel, PanicLevel, and FatalLevel all map to CRITICAL.
+func StackdriverSeverity() LevelFormatter {
+	return LevelFormatter(func(l Level) Field {
+		return String("severity", stackdriverSeverity(l))
+	})
+}
+
+func stackdriverSeverity(l Level) string {
+	switch l {
+	case TraceLevel, DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarnLevel:
+		return "WARNING"
+	case ErrorLevel:
+		return "ERROR"
+	case DPanicLevel, PanicLevel, FatalLevel:
+		return "CRITICAL"
+	default:
+		return "DEFAULT"
+	}
+}
+
+// StackdriverTimestamp encodes the entry time under the "timestamp" key, as an
+// object with integer "seconds" and "nanos" members.
+func StackdriverTimestamp() TimeFormatter {
+	return TimeFormatter(func(t time.Time) Field {
+		return Marshaler("timestamp", stackdriverTime{t.Unix(), t.Nanosecond()})
+	})
+}
+
+type stackdriverTime struct {
+	seconds int64
+	nanos   int
+}
+
+func (t stackdriverTime) MarshalLog(kv KeyValue) error {
+	kv.AddInt64("seconds", t.seconds)
+	kv.AddInt("nanos", t.nanos)
+	return nil
+}
+
+// StackdriverSourceLocation encodes the entry's call site as a LogEntry
+// sourceLocation, under the "logging.googleapis.com/sourceLocation" key. As
+// in Cloud Logging's API, the line number is encoded as a string.
+func StackdriverSourceLocation() CallerFormatter {
+	return CallerFormatter(func(c EntryCaller) Field {
+		return Marshaler(_stackdriverSourceLocationKey, stackdriverSourceLocation(c))
+	})
+}
+
+type stackdriverSourceLocation EntryCaller
+
+func (c stackdriverSourceLocation) MarshalLog(kv KeyValue) error {
+	kv.AddString("file", c.File)
+	kv.AddString("line", strconv.Itoa(c.Line))
+	if fn := runtime.FuncForPC(c.PC); fn != nil {
+		kv.AddString("function", fn.Name())
+	}
+	return nil
+}
+
+// StackdriverErrorReport marks entries with a stack trace as error events
+// for Google Cloud Error Reporting: it adds the ReportedErrorEvent type under
+// the "@type" key and the message, followed by a blank line and the stack
+// trace, under the "stack_trace" key.
+func StackdriverErrorReport() StackFormatter {
+	return StackFormatter(func(ent Entry) []Field {
+		return []Field{
+			String("@type", _reportedErrorEventType),
+			String("stack_trace", ent.Message+"\n\n"+ent.Stack),
+		}
+	})
+}
+
+func newStackdriverEncoderFromConfig(cfg EncoderConfig) (Encoder, error) {
+	if cfg.NoTime {
+		return NewStackdriverEncoder(NoTime()), nil
+	}
+	return NewStackdriverEncoder(), nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stackdriverLogEntry mirrors the parts of Cloud Logging's LogEntry schema
+// (and the special jsonPayload fields its agents understand) that the
+// Stackdriver encoder produces.
+type stackdriverLogEntry struct {
+	Severity  string `json:"severity"`
+	Timestamp struct {
+		Seconds int64 `json:"seconds"`
+		Nanos   int32 `json:"nanos"`
+	} `json:"timestamp"`
+	Message        string `json:"message"`
+	SourceLocation *struct {
+		File     string `json:"file"`
+		Line     string `json:"line"`
+		Function string `json:"function"`
+	} `json:"logging.googleapis.com/sourceLocation"`
+	Type       string `json:"@type"`
+	StackTrace string `json:"stack_trace"`
+}
+
+var _stackdriverSeverities = map[string]bool{
+	"DEFAULT": true, "DEBUG": true, "INFO": true, "NOTICE": true, "WARNING": true,
+	"ERROR": true, "CRITICAL": true, "ALERT": true, "EMERGENCY": true,
+}
+
+// validateStackdriverEntry checks an encoded entry against the LogEntry
+// schema and returns the decoded entry.
+func validateStackdriverEntry(t testing.TB, line string) stackdriverLogEntry {
+	var ent stackdriverLogEntry
+	require.NoError(t, json.Unmarshal([]byte(line), &ent), "Entry isn't valid JSON: %s", line)
+	assert.True(t, _stackdriverSeverities[ent.Severity], "Unexpected severity %q.", ent.Severity)
+	assert.True(t, ent.Timestamp.Nanos >= 0 && ent.Timestamp.Nanos < 1e9, "Timestamp nanos out of range.")
+	if loc := ent.SourceLocation; loc != nil {
+		_, err := strconv.ParseInt(loc.Line, 10, 64)
+		assert.NoError(t, err, "Expected the source line to be an int64 encoded as a string.")
+	}
+	if ent.Type != "" {
+		assert.Equal(t, _reportedErrorEventType, ent.Type, "Unexpected @type.")
+		assert.NotEmpty(t, ent.StackTrace, "Expected error events to have a stack trace.")
+	}
+	return ent
+}
+
+func TestStackdriverSeverity(t *testing.T) {
+	tests := []struct {
+		level    Level
+		severity string
+	}{
+		{TraceLevel, "DEBUG"},
+		{DebugLevel, "DEBUG"},
+		{InfoLevel, "INFO"},
+		{WarnLevel, "WARNING"},
+		{ErrorLevel, "ERROR"},
+		{DPanicLevel, "CRITICAL"},
+		{PanicLevel, "CRITICAL"},
+		{FatalLevel, "CRITICAL"},
+		{Level(42), "DEFAULT"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, String("severity", tt.severity), StackdriverSeverity()(tt.level), "Unexpected severity for %v.", tt.level)
+	}
+}
+
+func TestStackdriverEncoderGolden(t *testing.T) {
+	pc, _, _, ok := runtime.Caller(0)
+	require.True(t, ok, "Failed to get the test's PC.")
+	caller := EntryCaller{Defined: true, PC: pc, File: "/src/app/main.go", Line: 42}
+	ts := time.Unix(1500000000, 123456789)
+	const stack = "goroutine 1 [running]:\nmain.main()\n\t/src/app/main.go:42 +0x20\n"
+
+	tests := []struct {
+		desc     string
+		ent      Entry
+		expected string
+	}{
+		{
+			desc: "plain",
+			ent:  Entry{Level: InfoLevel, Time: ts, Message: "hello"},
+			expected: `{"severity":"INFO","timestamp":{"seconds":1500000000,"nanos":123456789},"message":"hello",` +
+				`"user":"alice"}`,
+		},
+		{
+			desc: "with caller",
+			ent:  Entry{Level: WarnLevel, Time: ts, Message: "careful", Caller: caller},
+			expected: `{"severity":"WARNING","timestamp":{"seconds":1500000000,"nanos":123456789},"message":"careful",` +
+				`"logging.googleapis.com/sourceLocation":{"file":"/src/app/main.go","line":"42","function":"github.com/uber-go/zap.TestStackdriverEncoderGolden"},` +
+				`"user":"alice"}`,
+		},
+		{
+			desc: "error event",
+			ent:  Entry{Level: ErrorLevel, Time: ts, Message: "oh no", Caller: caller, Stack: stack},
+			expected: `{"severity":"ERROR","timestamp":{"seconds":1500000000,"nanos":123456789},"message":"oh no",` +
+				`"logging.googleapis.com/sourceLocation":{"file":"/src/app/main.go","line":"42","function":"github.com/uber-go/zap.TestStackdriverEncoderGolden"},` +
+				`"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent",` +
+				`"stack_trace":"oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/app/main.go:42 +0x20\n",` +
+				`"user":"alice"}`,
+		},
+		{
+			desc: "critical",
+			ent:  Entry{Level: FatalLevel, Time: ts, Message: "fatal", Stack: stack},
+			expected: `{"severity":"CRITICAL","timestamp":{"seconds":1500000000,"nanos":123456789},"message":"fatal",` +
+				`"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent",` +
+				`"stack_trace":"fatal\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/app/main.go:42 +0x20\n",` +
+				`"user":"alice"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		enc := NewStackdriverEncoder()
+		enc.AddString("user", "alice")
+		buf := &testBuffer{}
+		require.NoError(t, enc.WriteEntry(buf, tt.ent), "Unexpected error writing %s entry.", tt.desc)
+		enc.Free()
+		assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output for %s entry.", tt.desc)
+		validateStackdriverEntry(t, buf.Stripped())
+	}
+}
+
+func TestStackdriverLogger(t *testing.T) {
+	buf := &testBuffer{}
+	logger := New(
+		NewStackdriverEncoder(),
+		RecordCaller(),
+		RecordStacks(ErrorLevel),
+		Output(buf),
+		ErrorOutput(&testBuffer{}),
+	)
+	logger.Info("hello", Int("n", 1))
+	logger.Error("oh no")
+
+	lines := buf.Lines()
+	require.Equal(t, 2, len(lines), "Expected two entries.")
+
+	info := validateStackdriverEntry(t, lines[0])
+	assert.Equal(t, "INFO", info.Severity, "Unexpected severity.")
+	assert.Equal(t, "hello", info.Message, "Expected RecordCaller to leave the message unchanged.")
+	require.NotNil(t, info.SourceLocation, "Expected a source location.")
+	assert.Equal(t, "stackdriver_test.go", filepath.Base(info.SourceLocation.File), "Unexpected source file.")
+	assert.Equal(t, "github.com/uber-go/zap.TestStackdriverLogger", info.SourceLocation.Function, "Unexpected source function.")
+	assert.Empty(t, info.Type, "Expected no error event without a stack trace.")
+	assert.Contains(t, lines[0], `"n":1`, "Expected fields to follow the Stackdriver fields.")
+	assert.NotContains(t, lines[0], "stacktrace", "Expected RecordStacks to leave the fields unchanged.")
+
+	errEnt := validateStackdriverEntry(t, lines[1])
+	assert.Equal(t, "ERROR", errEnt.Severity, "Unexpected severity.")
+	assert.Equal(t, _reportedErrorEventType, errEnt.Type, "Expected an error event.")
+	assert.True(t, strings.HasPrefix(errEnt.StackTrace, "oh no\n\ngoroutine "), "Expected the stack trace to follow the message: %q", errEnt.StackTrace)
+	assert.NotContains(t, lines[1], `"stacktrace"`, "Expected RecordStacks to leave the fields unchanged.")
+}
+
+func TestStackdriverEncoderByName(t *testing.T) {
+	enc, err := NewEncoderByName("stackdriver", EncoderConfig{NoTime: true})
+	require.NoError(t, err, "Unexpected error building the Stackdriver encoder.")
+	defer enc.Free()
+	buf := &testBuffer{}
+	require.NoError(t, enc.WriteEntry(buf, Entry{Level: WarnLevel, Message: "hi"}), "Unexpected error writing entry.")
+	assert.Equal(t, `{"severity":"WARNING","message":"hi"}`, buf.Stripped(), "Unexpected output.")
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_timeType = reflect.TypeOf(time.Time{})
+
+	_logMarshalerInterface = reflect.TypeOf((*LogMarshaler)(nil)).Elem()
+	_errorInterface        = reflect.TypeOf((*error)(nil)).Elem()
+	_stringerInterface     = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+	// Reflecting over a struct's fields and parsing their tags is expensive, so
+	// we do it once per type.
+	_structPlans = struct {
+		sync.RWMutex
+		m map[reflect.Type]*structPlan
+	}{m: make(map[reflect.Type]*structPlan)}
+)
+
+// Struct constructs a field that encodes a struct (or a pointer to a struct)
+// as a nested object without requiring a LogMarshaler implementation. Only
+// exported fields are encoded. Each field's key is taken from its zap tag,
+// then its json tag, and finally its Go name; fields tagged with `zap:"-"` (or
+// `json:"-"`) are omitted. As in encoding/json, embedded structs are
+// flattened into the enclosing object unless their tag supplies a name, and
+// the exported fields of unexported embedded structs are promoted too.
+// Embedded types that Any encodes specially, like time.Time, aren't
+// flattened; they're encoded under their type name.
+//
+// Primitive fields are encoded without boxing, nil pointers are encoded as
+// null, and all other fields are encoded as if passed to Any. The reflection
+// required to plan the encoding is done once per type and cached, so Struct is
+// much cheaper than Reflect, though still slower than a hand-written
+// LogMarshaler. Nil pointers and values that aren't structs are passed to
+// Reflect.
+func Struct(key string, val interface{}) Field {
+	if val == nil || isNilPointer(val) {
+		return Reflect(key, nil)
+	}
+	t := reflect.TypeOf(val)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return Reflect(key, val)
+	}
+	return Marshaler(key, structMarshaler{val})
+}
+
+type structMarshaler struct {
+	val interface{}
+}
+
+func (sm structMarshaler) MarshalLog(kv KeyValue) error {
+	v := reflect.ValueOf(sm.val)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	addStruct(kv, v, DefaultMapDepth)
+	return nil
+}
+
+// structPlan describes how to encode a struct type.
+type structPlan struct {
+	fields []structField
+}
+
+type structField struct {
+	name  string
+	index int
+	// For fields of primitive types, the kind to encode directly; zero for
+	// fields that need more inspection.
+	kind reflect.Kind
+	// Whether the field's type is better encoded by Any than by its kind.
+	useAny bool
+	// If non-nil, the field is an embedded struct (or pointer to a struct)
+	// whose fields are flattened into the enclosing object.
+	embedded *structPlan
+}
+
+func planFor(t reflect.Type) *structPlan {
+	_structPlans.RLock()
+	plan, ok := _structPlans.m[t]
+	_structPlans.RUnlock()
+	if ok {
+		return plan
+	}
+
+	plan = buildPlan(t, map[reflect.Type]bool{})
+	_structPlans.Lock()
+	// If another goroutine planned the same type first, keep its plan.
+	if existing, ok := _structPlans.m[t]; ok {
+		plan = existing
+	} else {
+		_structPlans.m[t] = plan
+	}
+	_structPlans.Unlock()
+	return plan
+}
+
+func buildPlan(t reflect.Type, planning map[reflect.Type]bool) *structPlan {
+	planning[t] = true
+	plan := &structPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		et := f.Type
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		// Embedded structs are flattened unless they're named by a tag or
+		// encoded by Any. The exported fields of unexported embedded structs
+		// are still accessible, so those are always flattened.
+		name, named, skip := fieldName(f)
+		if skip {
+			continue
+		}
+		flatten := f.Anonymous && et.Kind() == reflect.Struct && !planning[et]
+		if f.PkgPath != "" {
+			if !flatten {
+				// Unexported.
+				continue
+			}
+		} else if named || usesAny(f.Type) || usesAny(et) {
+			flatten = false
+		}
+		sf := structField{name: name, index: i}
+		switch k := f.Type.Kind(); {
+		case k == reflect.Ptr || k == reflect.Interface:
+			// Depends on the value.
+		case usesAny(f.Type):
+			sf.useAny = true
+		case isPrimitive(k):
+			sf.kind = k
+		}
+		if flatten {
+			sf.embedded = buildPlan(et, planning)
+		}
+		plan.fields = append(plan.fields, sf)
+	}
+	delete(planning, t)
+	return plan
+}
+
+// fieldName returns the key for a struct field, whether the key was supplied
+// by a tag, and whether the field should be skipped.
+func fieldName(f reflect.StructField) (string, bool, bool) {
+	for _, tagKey := range []string{"zap", "json"} {
+		tag := f.Tag.Get(tagKey)
+		if tag == "-" {
+			return "", false, true
+		}
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			tag = tag[:idx]
+		}
+		if tag != "" {
+			return tag, true, false
+		}
+	}
+	return f.Name, false, false
+}
+
+func addStruct(kv KeyValue, v reflect.Value, depth int) {
+	addPlannedFields(kv, planFor(v.Type()), v, depth)
+}
+
+func addPlannedFields(kv KeyValue, plan *structPlan, v reflect.Value, depth int) {
+	for _, f := range plan.fields {
+		fv := v.Field(f.index)
+		if f.embedded != nil {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			addPlannedFields(kv, f.embedded, fv, depth)
+			continue
+		}
+		if f.useAny {
+			Any(f.name, fv.Interface()).AddTo(kv)
+			continue
+		}
+		if f.kind != reflect.Invalid {
+			addPrimitive(kv, f.name, fv)
+			continue
+		}
+		addStructValue(kv, f.name, fv, depth)
+	}
+}
+
+func addStructValue(kv KeyValue, key string, v reflect.Value, depth int) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			kv.AddObject(key, nil)
+			return
+		}
+		if usesAny(v.Type()) {
+			break
+		}
+		v = v.Elem()
+	}
+
+	if usesAny(v.Type()) {
+		Any(key, v.Interface()).AddTo(kv)
+		return
+	}
+
+	switch kind := v.Kind(); {
+	case isPrimitive(kind):
+		addPrimitive(kv, key, v)
+	case kind == reflect.Struct:
+		if depth <= 0 {
+			kv.AddString(key, _maxDepthMarker)
+			return
+		}
+		kv.AddMarshaler(key, LogMarshalerFunc(func(kv KeyValue) error {
+			addStruct(kv, v, depth-1)
+			return nil
+		}))
+	default:
+		Any(key, v.Interface()).AddTo(kv)
+	}
+}
+
+func isPrimitive(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String, reflect.Uintptr,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// addPrimitive adds a value whose kind satisfies isPrimitive.
+func addPrimitive(kv KeyValue, key string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		kv.AddBool(key, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		kv.AddInt64(key, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		kv.AddUint64(key, v.Uint())
+	case reflect.Uintptr:
+		kv.AddUintptr(key, uintptr(v.Uint()))
+	case reflect.Float32:
+		kv.AddFloat32(key, float32(v.Float()))
+	case reflect.Float64:
+		kv.AddFloat64(key, v.Float())
+	case reflect.String:
+		kv.AddString(key, v.String())
+	}
+}
+
+// usesAny reports whether values of the type have a better representation
+// than their kind suggests, which Any already knows how to find.
+func usesAny(t reflect.Type) bool {
+	return t == _timeType ||
+		t.Implements(_logMarshalerInterface) ||
+		t.Implements(_errorInterface) ||
+		t.Implements(_stringerInterface)
+}
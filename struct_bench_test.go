@@ -0,0 +1,74 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+	"time"
+)
+
+type benchStruct struct {
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Age       int       `json:"age"`
+	Admin     bool      `json:"admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var _benchStruct = benchStruct{
+	Name:      "Jane Doe",
+	Email:     "jane@test.com",
+	Age:       42,
+	CreatedAt: time.Date(1980, 1, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func (s benchStruct) MarshalLog(kv KeyValue) error {
+	kv.AddString("name", s.Name)
+	kv.AddString("email", s.Email)
+	kv.AddInt("age", s.Age)
+	kv.AddBool("admin", s.Admin)
+	Time("created_at", s.CreatedAt).AddTo(kv)
+	return nil
+}
+
+func benchmarkField(b *testing.B, field Field) {
+	b.ReportAllocs()
+	enc := newJSONEncoder()
+	defer enc.Free()
+	for i := 0; i < b.N; i++ {
+		enc.truncate()
+		field.AddTo(enc)
+	}
+}
+
+func BenchmarkStructField(b *testing.B) {
+	// Strip the MarshalLog method, so that Struct has to use reflection.
+	type plain benchStruct
+	benchmarkField(b, Struct("user", plain(_benchStruct)))
+}
+
+func BenchmarkStructReflectField(b *testing.B) {
+	benchmarkField(b, Reflect("user", _benchStruct))
+}
+
+func BenchmarkStructMarshalerField(b *testing.B) {
+	benchmarkField(b, Marshaler("user", _benchStruct))
+}
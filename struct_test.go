@@ -0,0 +1,184 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type structInner struct {
+	Name string `json:"name"`
+}
+
+type structEmbedded struct {
+	Flattened string
+}
+
+type structTagged struct {
+	Plain      string
+	ZapTag     string `zap:"zap_name" json:"overridden"`
+	JSONTag    string `json:"json_name,omitempty"`
+	OptionsTag string `json:",omitempty"`
+	ZapSkip    string `zap:"-" json:"ignored"`
+	JSONSkip   string `json:"-"`
+	unexported string
+}
+
+type structKitchenSink struct {
+	structEmbedded
+	*structInner
+	Tagged   structEmbedded `zap:"tagged"`
+	Bool     bool
+	Int      int8
+	Uint     uint16
+	Float    float32
+	Ptr      *int
+	NilPtr   *int
+	NilIface interface{}
+	Nested   structInner
+	NestedP  *structInner
+	Time     time.Time
+	Duration time.Duration
+	IP       net.IP
+	Err      error
+	Ints     []int
+}
+
+type structNode struct {
+	Val  int
+	Next *structNode
+}
+
+func TestStructTagPrecedence(t *testing.T) {
+	s := structTagged{"a", "b", "c", "d", "e", "f", "g"}
+	assertFieldJSON(t, `"k":{"Plain":"a","zap_name":"b","json_name":"c","OptionsTag":"d"}`, Struct("k", s))
+	assertFieldJSON(t, `"k":{"Plain":"a","zap_name":"b","json_name":"c","OptionsTag":"d"}`, Struct("k", &s))
+	assertCanBeReused(t, Struct("k", s))
+}
+
+func TestStructField(t *testing.T) {
+	n := 42
+	s := structKitchenSink{
+		structEmbedded: structEmbedded{"flat"},
+		structInner:    nil,
+		Tagged:         structEmbedded{"nested"},
+		Bool:           true,
+		Int:            -8,
+		Uint:           16,
+		Float:          1.5,
+		Ptr:            &n,
+		Nested:         structInner{"inner"},
+		NestedP:        &structInner{"inner pointer"},
+		Time:           time.Unix(1, 0),
+		Duration:       time.Second,
+		IP:             net.ParseIP("1.2.3.4"),
+		Err:            errors.New("fail"),
+		Ints:           []int{1, 2},
+	}
+	// The exported fields of unexported embedded structs are promoted, and other
+	// unexported fields are skipped.
+	expected := `"k":{"Flattened":"flat","tagged":{"Flattened":"nested"},"Bool":true,"Int":-8,"Uint":16,"Float":1.5,"Ptr":42,"NilPtr":null,"NilIface":null,` +
+		`"Nested":{"name":"inner"},"NestedP":{"name":"inner pointer"},"Time":1,"Duration":1000000000,"IP":"1.2.3.4","Err":"fail","Ints":[1,2]}`
+	assertFieldJSON(t, expected, Struct("k", s))
+	assertCanBeReused(t, Struct("k", s))
+}
+
+type StructExportedEmbed struct {
+	Flattened string
+}
+
+func TestStructEmbedding(t *testing.T) {
+	type embedsValue struct {
+		StructExportedEmbed
+		Own string
+	}
+	type embedsPointer struct {
+		*StructExportedEmbed
+		Own string
+	}
+	type embedsTagged struct {
+		StructExportedEmbed `json:"embed"`
+		Own                 string
+	}
+	type embedsUnexported struct {
+		structEmbedded
+		Own string
+	}
+	type embedsUnexportedPointer struct {
+		*structEmbedded
+		Own string
+	}
+	type embedsTime struct {
+		time.Time
+		Own string
+	}
+	type embedsTimePointer struct {
+		*time.Time
+		Own string
+	}
+
+	tests := []struct {
+		val      interface{}
+		expected string
+	}{
+		{embedsValue{StructExportedEmbed{"flat"}, "own"}, `"k":{"Flattened":"flat","Own":"own"}`},
+		{embedsPointer{&StructExportedEmbed{"flat"}, "own"}, `"k":{"Flattened":"flat","Own":"own"}`},
+		{embedsPointer{nil, "own"}, `"k":{"Own":"own"}`},
+		{embedsTagged{StructExportedEmbed{"nested"}, "own"}, `"k":{"embed":{"Flattened":"nested"},"Own":"own"}`},
+		{embedsUnexported{structEmbedded{"flat"}, "own"}, `"k":{"Flattened":"flat","Own":"own"}`},
+		{embedsUnexportedPointer{&structEmbedded{"flat"}, "own"}, `"k":{"Flattened":"flat","Own":"own"}`},
+		{embedsUnexportedPointer{nil, "own"}, `"k":{"Own":"own"}`},
+		{embedsTime{time.Unix(1, 0), "own"}, `"k":{"Time":1,"Own":"own"}`},
+		{embedsTimePointer{nil, "own"}, `"k":{"Time":null,"Own":"own"}`},
+	}
+
+	for _, tt := range tests {
+		assertFieldJSON(t, tt.expected, Struct("k", tt.val))
+	}
+}
+
+func TestStructFallbacks(t *testing.T) {
+	var nilPtr *structInner
+	assertFieldJSON(t, `"k":null`, Struct("k", nil))
+	assertFieldJSON(t, `"k":null`, Struct("k", nilPtr))
+	assertFieldJSON(t, `"k":[1,2]`, Struct("k", []int{1, 2}))
+	assertFieldJSON(t, `"k":"foo"`, Struct("k", "foo"))
+}
+
+func TestStructCycle(t *testing.T) {
+	n := &structNode{Val: 1}
+	n.Next = n
+	withJSONEncoder(func(enc *jsonEncoder) {
+		Struct("k", n).AddTo(enc)
+		assert.Contains(t, string(enc.bytes), `{"Val":1,"Next":"<max depth exceeded>"}`, "Expected cyclic structs to be cut off.")
+	})
+}
+
+func TestStructPlanCache(t *testing.T) {
+	typ := reflect.TypeOf(structTagged{})
+	assert.True(t, planFor(typ) == planFor(typ), "Expected plans to be cached.")
+}
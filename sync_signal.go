@@ -0,0 +1,174 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// _reraise restores a signal's default disposition and sends it to the
+// current process again. It's a variable for tests.
+var _reraise = func(sig os.Signal) error {
+	signal.Reset(sig)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// All installed signal handlers, so that a handler about to re-raise a signal
+// can sync every logger waiting for it first.
+var _signalHandlers = struct {
+	sync.Mutex
+	all map[*signalHandler]struct{}
+}{all: make(map[*signalHandler]struct{})}
+
+// A SignalOption configures SyncOnSignal.
+type SignalOption interface {
+	apply(*signalHandler)
+}
+
+type signalOptionFunc func(*signalHandler)
+
+func (f signalOptionFunc) apply(h *signalHandler) {
+	f(h)
+}
+
+// Signals sets the signals that trigger a sync. By default, SyncOnSignal
+// handles SIGINT and SIGTERM.
+func Signals(sigs ...os.Signal) SignalOption {
+	return signalOptionFunc(func(h *signalHandler) {
+		h.sigs = append([]os.Signal(nil), sigs...)
+	})
+}
+
+// OnSignal calls the supplied function after syncing, rather than re-raising
+// the signal. It's useful for programs that shut down gracefully. The function
+// runs on its own goroutine, so it may safely stop the handler.
+func OnSignal(f func(os.Signal)) SignalOption {
+	return signalOptionFunc(func(h *signalHandler) {
+		h.onSignal = f
+	})
+}
+
+type signalHandler struct {
+	log      Logger
+	sigs     []os.Signal
+	onSignal func(os.Signal)
+
+	ch     chan os.Signal
+	done   chan struct{}
+	exited chan struct{}
+}
+
+// SyncOnSignal installs a handler that syncs the logger (see Sync) when the
+// process receives any of the configured signals, so that buffered entries
+// aren't lost on shutdown. Sync errors are reported to the logger's
+// ErrorOutput, if it has one, and to standard error otherwise.
+//
+// After syncing, the handler either calls the OnSignal callback or, by
+// default, restores the signal's default disposition (undoing any other
+// signal.Notify calls for it) and re-raises it. Before re-raising a signal,
+// the handler syncs every other logger passed to SyncOnSignal for that signal,
+// so it's safe to install more than one handler.
+//
+// Calling the returned function removes the handler and waits for its
+// goroutine to exit; it's safe to call more than once.
+func SyncOnSignal(log Logger, opts ...SignalOption) (stop func()) {
+	h := &signalHandler{
+		log:    log,
+		sigs:   []os.Signal{os.Interrupt, syscall.SIGTERM},
+		ch:     make(chan os.Signal, 1),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(h)
+	}
+
+	_signalHandlers.Lock()
+	_signalHandlers.all[h] = struct{}{}
+	_signalHandlers.Unlock()
+	signal.Notify(h.ch, h.sigs...)
+	go h.run()
+
+	var once sync.Once
+	return func() { once.Do(h.stop) }
+}
+
+func (h *signalHandler) run() {
+	defer close(h.exited)
+	for {
+		select {
+		case <-h.done:
+			return
+		case sig := <-h.ch:
+			h.handle(sig)
+		}
+	}
+}
+
+func (h *signalHandler) handle(sig os.Signal) {
+	if h.onSignal != nil {
+		h.sync()
+		go h.onSignal(sig)
+		return
+	}
+
+	_signalHandlers.Lock()
+	defer _signalHandlers.Unlock()
+	for other := range _signalHandlers.all {
+		if other.handles(sig) {
+			other.sync()
+		}
+	}
+	if err := _reraise(sig); err != nil {
+		reportInternalError(h.log, "signal", err)
+	}
+}
+
+func (h *signalHandler) handles(sig os.Signal) bool {
+	for _, s := range h.sigs {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *signalHandler) sync() {
+	if err := Sync(h.log); err != nil {
+		reportInternalError(h.log, "sync", err)
+	}
+}
+
+func (h *signalHandler) stop() {
+	signal.Stop(h.ch)
+	_signalHandlers.Lock()
+	delete(_signalHandlers.all, h)
+	_signalHandlers.Unlock()
+	close(h.done)
+	<-h.exited
+}
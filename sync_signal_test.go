@@ -0,0 +1,164 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/uber-go/zap/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withSignalGuard keeps SIGHUP from killing the test binary, and passes the
+// test a function that sends SIGHUP to the current process.
+func withSignalGuard(t *testing.T, f func(raise func())) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Can't send signals to the current process on Windows.")
+	}
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, syscall.SIGHUP)
+	defer signal.Stop(guard)
+
+	f(func() {
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err, "Unexpected error finding the current process.")
+		require.NoError(t, p.Signal(syscall.SIGHUP), "Unexpected error sending SIGHUP.")
+	})
+}
+
+func waitForSignal(t *testing.T, ch <-chan os.Signal) {
+	select {
+	case sig := <-ch:
+		assert.Equal(t, syscall.SIGHUP, sig, "Unexpected signal.")
+	case <-time.After(testutils.Timeout(time.Second)):
+		t.Fatal("Timed out waiting for a signal to be handled.")
+	}
+}
+
+func TestSyncOnSignalCallback(t *testing.T) {
+	withSignalGuard(t, func(raise func()) {
+		out, errOut := &syncSpy{}, &syncSpy{}
+		log := New(NullEncoder(), Output(out), ErrorOutput(errOut))
+		handled := make(chan os.Signal, 1)
+		stop := SyncOnSignal(log, Signals(syscall.SIGHUP), OnSignal(func(sig os.Signal) { handled <- sig }))
+
+		raise()
+		waitForSignal(t, handled)
+		stop()
+		assert.True(t, out.Called(), "Expected the Output to be synced.")
+		assert.True(t, errOut.Called(), "Expected the ErrorOutput to be synced.")
+		assert.NotPanics(t, stop, "Expected stopping twice to be safe.")
+	})
+}
+
+func TestSyncOnSignalTee(t *testing.T) {
+	withSignalGuard(t, func(raise func()) {
+		first, second := &syncSpy{}, &syncSpy{}
+		log := Tee(
+			New(NullEncoder(), Output(first), ErrorOutput(Discard)),
+			CapLevel(New(NullEncoder(), Output(second), ErrorOutput(Discard)), ErrorLevel),
+		)
+		handled := make(chan os.Signal, 1)
+		stop := SyncOnSignal(log, Signals(syscall.SIGHUP), OnSignal(func(sig os.Signal) { handled <- sig }))
+		defer stop()
+
+		raise()
+		waitForSignal(t, handled)
+		assert.True(t, first.Called(), "Expected the first child to be synced.")
+		assert.True(t, second.Called(), "Expected the second, wrapped child to be synced.")
+	})
+}
+
+func TestSyncOnSignalReportsErrors(t *testing.T) {
+	withSignalGuard(t, func(raise func()) {
+		out, errOut := &syncSpy{}, &syncSpy{}
+		out.SetError(errors.New("fail"))
+		log := New(NullEncoder(), Output(out), ErrorOutput(errOut))
+		handled := make(chan os.Signal, 1)
+		stop := SyncOnSignal(log, Signals(syscall.SIGHUP), OnSignal(func(sig os.Signal) { handled <- sig }))
+
+		raise()
+		waitForSignal(t, handled)
+		stop()
+		assert.Contains(t, errOut.String(), "sync error: fail", "Expected sync errors to be reported.")
+	})
+}
+
+func TestSyncOnSignalReraise(t *testing.T) {
+	first, second := &syncSpy{}, &syncSpy{}
+	reraised := make(chan bool, 2)
+	reraise := _reraise
+	_reraise = func(sig os.Signal) error {
+		assert.Equal(t, syscall.SIGHUP, sig, "Unexpected signal re-raised.")
+		reraised <- first.Called() && second.Called()
+		return nil
+	}
+	defer func() { _reraise = reraise }()
+
+	withSignalGuard(t, func(raise func()) {
+		stopFirst := SyncOnSignal(New(NullEncoder(), Output(first), ErrorOutput(Discard)), Signals(syscall.SIGHUP))
+		stopSecond := SyncOnSignal(New(NullEncoder(), Output(second), ErrorOutput(Discard)), Signals(syscall.SIGHUP))
+
+		raise()
+		select {
+		case synced := <-reraised:
+			assert.True(t, synced, "Expected both loggers to be synced before re-raising.")
+		case <-time.After(testutils.Timeout(time.Second)):
+			t.Fatal("Timed out waiting for the signal to be re-raised.")
+		}
+		stopFirst()
+		stopSecond()
+	})
+}
+
+func TestSyncOnSignalStop(t *testing.T) {
+	withSignalGuard(t, func(raise func()) {
+		out := &syncSpy{}
+		handled := make(chan os.Signal, 1)
+		stop := SyncOnSignal(New(NullEncoder(), Output(out), ErrorOutput(Discard)), Signals(syscall.SIGHUP), OnSignal(func(sig os.Signal) {
+			handled <- sig
+		}))
+		stop()
+
+		// Install a second handler, so that we know when the signal has been
+		// delivered.
+		delivered := make(chan os.Signal, 1)
+		signal.Notify(delivered, syscall.SIGHUP)
+		defer signal.Stop(delivered)
+		raise()
+		waitForSignal(t, delivered)
+
+		select {
+		case <-handled:
+			t.Fatal("Expected a stopped handler not to handle signals.")
+		default:
+		}
+		assert.False(t, out.Called(), "Expected a stopped handler not to sync.")
+	})
+}
@@ -27,14 +27,24 @@ package zap
 // each sub-logger's level method.
 //
 // Exceptions are made for the DPanic, Panic, and Fatal methods: the returned
-// logger calls .Log(DPanicLevel, ...), .Log(PanicLevel, ...), and
-// .Log(FatalLevel, ...) respectively. Only after all sub-loggers have received
-// the message, then the Tee terminates the process (using os.Exit or panic()
-// per usual semantics).
+// logger writes the message to each sub-logger at DPanicLevel, PanicLevel,
+// and FatalLevel respectively, as if with .Log. Only after all sub-loggers
+// have received the message and flushed it (see Sync), then the Tee
+// terminates the process (using os.Exit or panic() per usual semantics).
+// Since writing the message never panics or exits, sub-loggers that are
+// themselves Tees or wrappers (like CapLevel, Filter, and DynamicFields) write
+// it without terminating, so however deeply loggers are nested, only the
+// outermost Tee panics or exits, and only once.
+//
+// The Tee doesn't have a Clock of its own; each sub-logger timestamps entries
+// with whatever Clock it was constructed with.
 //
 // NOTE: DPanic will currently never panic, since the Tee Logger does not
 // accept options (nor even have a development flag).
 //
+// The Tee's Context method (see the package-level Context function) returns
+// only the fields added with the Tee's own With method.
+//
 // Check returns a CheckedMessage chain of any OK CheckedMessages returned by
 // all sub-loggers. The returned message is OK if any of the sub-messages are.
 // An exception is made for FatalLevel and PanicLevel, where a CheckedMessage
@@ -47,16 +57,24 @@ func Tee(logs ...Logger) Logger {
 	case 1:
 		return logs[0]
 	default:
-		return multiLogger(logs)
+		return multiLogger{logs: logs}
 	}
 }
 
-type multiLogger []Logger
+type multiLogger struct {
+	logs []Logger
+	// The fields added with the Tee's own With method.
+	context *fieldContext
+}
 
 func (ml multiLogger) Log(lvl Level, msg string, fields ...Field) {
 	ml.log(lvl, msg, fields)
 }
 
+func (ml multiLogger) Trace(msg string, fields ...Field) {
+	ml.log(TraceLevel, msg, fields)
+}
+
 func (ml multiLogger) Debug(msg string, fields ...Field) {
 	ml.log(DebugLevel, msg, fields)
 }
@@ -74,17 +92,33 @@ func (ml multiLogger) Error(msg string, fields ...Field) {
 }
 
 func (ml multiLogger) Panic(msg string, fields ...Field) {
-	ml.log(PanicLevel, msg, fields)
+	writeTerminal(ml.encodeTerminal(PanicLevel, msg, fields))
 	panic(msg)
 }
 
 func (ml multiLogger) Fatal(msg string, fields ...Field) {
-	ml.log(FatalLevel, msg, fields)
+	writeTerminal(ml.encodeTerminal(FatalLevel, msg, fields))
 	_exit(1)
 }
 
+// encodeTerminal builds a Panic- or Fatal-level entry for each of the
+// sub-loggers on the calling goroutine. The returned function writes and
+// flushes all of them, so that entries buffered by any of them (for example,
+// in a queue feeding a network sink) aren't lost when the process dies.
+func (ml multiLogger) encodeTerminal(lvl Level, msg string, fields []Field) func() {
+	writes := make([]func(), len(ml.logs))
+	for i, log := range ml.logs {
+		writes[i] = encodeTerminal(log, lvl, msg, fields)
+	}
+	return func() {
+		for _, write := range writes {
+			write()
+		}
+	}
+}
+
 func (ml multiLogger) log(lvl Level, msg string, fields []Field) {
-	for _, log := range ml {
+	for _, log := range ml.logs {
 		log.Log(lvl, msg, fields...)
 	}
 }
@@ -95,13 +129,54 @@ func (ml multiLogger) DPanic(msg string, fields ...Field) {
 }
 
 func (ml multiLogger) With(fields ...Field) Logger {
-	clone := make(multiLogger, len(ml))
-	for i := range ml {
-		clone[i] = ml[i].With(fields...)
+	clone := multiLogger{
+		logs:    make([]Logger, len(ml.logs)),
+		context: ml.context.with(fields),
+	}
+	for i := range ml.logs {
+		clone.logs[i] = ml.logs[i].With(fields...)
 	}
 	return clone
 }
 
+// Context returns a copy of the fields added with the Tee's With method. It
+// doesn't include fields that the sub-loggers accumulated before they were
+// combined.
+func (ml multiLogger) Context() []Field {
+	return ml.context.flatten()
+}
+
+func (ml multiLogger) Audit() Logger {
+	clone := multiLogger{
+		logs:    make([]Logger, len(ml.logs)),
+		context: ml.context,
+	}
+	for i := range ml.logs {
+		clone.logs[i] = Audit(ml.logs[i])
+	}
+	return clone
+}
+
+// IsAudit reports whether all the Tee's children are audit loggers.
+func (ml multiLogger) IsAudit() bool {
+	for _, log := range ml.logs {
+		if !IsAudit(log) {
+			return false
+		}
+	}
+	return len(ml.logs) > 0
+}
+
+func (ml multiLogger) Sync() error {
+	var errs multiError
+	for _, log := range ml.logs {
+		if err := Sync(log); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.asError()
+}
+
 func (ml multiLogger) Check(lvl Level, msg string) *CheckedMessage {
 	switch lvl {
 	case FatalLevel, PanicLevel:
@@ -111,7 +186,7 @@ func (ml multiLogger) Check(lvl Level, msg string) *CheckedMessage {
 		return NewCheckedMessage(ml, lvl, msg)
 	}
 	var cm *CheckedMessage
-	for _, log := range ml {
+	for _, log := range ml.logs {
 		cm = cm.Chain(log.Check(lvl, msg))
 	}
 	return cm
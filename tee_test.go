@@ -21,6 +21,7 @@
 package zap_test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/uber-go/zap"
@@ -140,6 +141,90 @@ func TestTee_Panic(t *testing.T) {
 	}, sink2.Logs())
 }
 
+func TestTeeTrace(t *testing.T) {
+	log1, sink1 := spy.New(zap.TraceLevel)
+	log2, sink2 := spy.New(zap.DebugLevel)
+	log := zap.Tee(log1, log2)
+
+	log.Trace("trace")
+	log.Check(zap.TraceLevel, "checked").Write()
+	log.Log(zap.TraceLevel, "log")
+	log.Debug("debug")
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.TraceLevel, Msg: "trace", Fields: []zap.Field{}},
+		{Level: zap.TraceLevel, Msg: "checked", Fields: []zap.Field{}},
+		{Level: zap.TraceLevel, Msg: "log", Fields: []zap.Field{}},
+		{Level: zap.DebugLevel, Msg: "debug", Fields: []zap.Field{}},
+	}, sink1.Logs(), "Expected Trace logs in the trace-enabled sink.")
+	assert.Equal(t, []spy.Log{
+		{Level: zap.DebugLevel, Msg: "debug", Fields: []zap.Field{}},
+	}, sink2.Logs(), "Expected no Trace logs in the debug-enabled sink.")
+}
+
+func TestTeeWithLevelEnablers(t *testing.T) {
+	errLog, errSink := spy.New(zap.ExactLevel(zap.ErrorLevel))
+	debugLog, debugSink := spy.New(zap.DebugLevel)
+	log := zap.Tee(errLog, debugLog)
+
+	log.Debug("debug")
+	if cm := log.Check(zap.InfoLevel, "checked info"); cm.OK() {
+		cm.Write()
+	}
+	log.Error("error")
+	if cm := log.Check(zap.ErrorLevel, "checked error"); cm.OK() {
+		cm.Write()
+	}
+	log.Log(zap.DPanicLevel, "dpanic")
+
+	assert.Equal(t, []spy.Log{
+		{Level: zap.ErrorLevel, Msg: "error", Fields: []zap.Field{}},
+		{Level: zap.ErrorLevel, Msg: "checked error", Fields: []zap.Field{}},
+	}, errSink.Logs(), "Expected only Error logs in the errors-only sink.")
+	assert.Equal(t, []spy.Log{
+		{Level: zap.DebugLevel, Msg: "debug", Fields: []zap.Field{}},
+		{Level: zap.InfoLevel, Msg: "checked info", Fields: []zap.Field{}},
+		{Level: zap.ErrorLevel, Msg: "error", Fields: []zap.Field{}},
+		{Level: zap.ErrorLevel, Msg: "checked error", Fields: []zap.Field{}},
+		{Level: zap.DPanicLevel, Msg: "dpanic", Fields: []zap.Field{}},
+	}, debugSink.Logs(), "Expected all logs in the debug sink.")
+	assert.False(t, zap.Tee(errLog, errLog).Check(zap.WarnLevel, "").OK(), "Expected the tee to be disabled if no child is enabled.")
+}
+
 // XXX: we cannot presently write `func TestTee_Fatal(t *testing.T)`,
 // because we can't have both a spy logger and an exit stub without a
 // dependency cycle.
+
+func TestTeeContext(t *testing.T) {
+	log1, _ := spy.New()
+	log2, _ := spy.New()
+	tee := zap.Tee(log1.With(zap.String("child", "one")), log2)
+	assert.Nil(t, zap.Context(tee), "Expected a new Tee to have an empty context.")
+
+	parent := tee.With(zap.String("request", "abc"))
+	first := parent.With(zap.Int("user", 1))
+	second := parent.With(zap.Int("user", 2))
+	assert.Equal(t, []zap.Field{zap.String("request", "abc"), zap.Int("user", 1)}, zap.Context(first), "Unexpected context for the first child.")
+	assert.Equal(t, []zap.Field{zap.String("request", "abc"), zap.Int("user", 2)}, zap.Context(second), "Unexpected context for the second child.")
+
+	ctx := zap.Context(first)
+	ctx[0] = zap.String("request", "changed")
+	assert.Equal(t, []zap.Field{zap.String("request", "abc"), zap.Int("user", 1)}, zap.Context(first), "Expected changes to the returned slice not to affect the Tee.")
+}
+
+func TestTeeAudit(t *testing.T) {
+	buf1, buf2 := &bytes.Buffer{}, &bytes.Buffer{}
+	log1 := zap.New(zap.NewJSONEncoder(zap.NoTime()), zap.WarnLevel, zap.Output(zap.AddSync(buf1)))
+	log2 := zap.New(zap.NewJSONEncoder(zap.NoTime()), zap.ErrorLevel, zap.Output(zap.AddSync(buf2)))
+	tee := zap.Tee(log1, log2)
+	assert.False(t, zap.IsAudit(tee), "Expected a Tee of operational loggers not to be an audit logger.")
+
+	audit := zap.Audit(tee)
+	assert.True(t, zap.IsAudit(audit), "Expected a Tee of audit loggers to be an audit logger.")
+	audit.Info("login")
+	zap.Audit(zap.CapLevel(tee, zap.InfoLevel)).Warn("capped")
+	expected := "{\"level\":\"info\",\"msg\":\"login\",\"audit\":true}\n" +
+		"{\"level\":\"info\",\"msg\":\"capped\",\"audit\":true,\"original_level\":\"warn\"}\n"
+	assert.Equal(t, expected, buf1.String(), "Unexpected output from the first audited child.")
+	assert.Equal(t, expected, buf2.String(), "Unexpected output from the second audited child.")
+}
@@ -21,9 +21,13 @@
 package zap
 
 import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -35,18 +39,29 @@ var textPool = sync.Pool{New: func() interface{} {
 }}
 
 type textEncoder struct {
-	bytes       []byte
+	bytes []byte
+	// Like the JSON encoder, clones share their parent's encoded fields
+	// instead of copying them.
+	prefix      *textEncoder
+	prefixBytes []byte
 	timeFmt     string
+	timeLoc     *time.Location
 	firstNested bool
+	namespace   string
+	multiline   bool
 }
 
 // NewTextEncoder creates a line-oriented text encoder whose output is optimized
 // for human, rather than machine, consumption. By default, the encoder uses
-// RFC3339-formatted timestamps.
+// RFC3339-formatted timestamps and escapes newlines and carriage returns, so
+// that each entry occupies exactly one line. Backslashes are escaped as well,
+// so the escaping is unambiguous.
 func NewTextEncoder(options ...TextOption) Encoder {
 	enc := textPool.Get().(*textEncoder)
 	enc.truncate()
 	enc.timeFmt = time.RFC3339
+	enc.timeLoc = nil
+	enc.multiline = false
 	for _, opt := range options {
 		opt.apply(enc)
 	}
@@ -54,12 +69,49 @@ func NewTextEncoder(options ...TextOption) Encoder {
 }
 
 func (enc *textEncoder) Free() {
+	if cap(enc.bytes) > _maxPooledBufSize {
+		return
+	}
+	enc.prefix, enc.prefixBytes = nil, nil
 	textPool.Put(enc)
 }
 
 func (enc *textEncoder) AddString(key, val string) {
 	enc.addKey(key)
-	enc.bytes = append(enc.bytes, val...)
+	enc.bytes = enc.appendLine(enc.bytes, val)
+}
+
+// AddByteString writes the UTF-8 encoded byte slice without first converting
+// it to a string.
+func (enc *textEncoder) AddByteString(key string, val []byte) {
+	enc.addKey(key)
+	enc.bytes = enc.appendLineBytes(enc.bytes, val)
+}
+
+// AddBinary writes opaque binary data as a padded base64 string.
+func (enc *textEncoder) AddBinary(key string, val []byte) {
+	enc.addKey(key)
+	start := len(enc.bytes)
+	enc.bytes = append(enc.bytes, make([]byte, base64.StdEncoding.EncodedLen(len(val)))...)
+	base64.StdEncoding.Encode(enc.bytes[start:], val)
+}
+
+// AddRawJSON writes the pre-encoded JSON value as-is, apart from replacing
+// line breaks in insignificant whitespace with spaces. (Valid JSON can't have
+// line breaks anywhere else, so the value needn't be escaped; if it does, it's
+// escaped like any other string.)
+func (enc *textEncoder) AddRawJSON(key string, val []byte) error {
+	enc.addKey(key)
+	if enc.multiline {
+		enc.bytes = append(enc.bytes, val...)
+		return nil
+	}
+	if spliced, ok := appendSingleLine(enc.bytes, val); ok {
+		enc.bytes = spliced
+		return nil
+	}
+	enc.bytes = enc.appendLineBytes(enc.bytes, val)
+	return nil
 }
 
 func (enc *textEncoder) AddBool(key string, val bool) {
@@ -96,46 +148,183 @@ func (enc *textEncoder) AddFloat64(key string, val float64) {
 	enc.bytes = strconv.AppendFloat(enc.bytes, val, 'f', -1, 64)
 }
 
+func (enc *textEncoder) AddFloat32(key string, val float32) {
+	enc.addKey(key)
+	enc.bytes = strconv.AppendFloat(enc.bytes, float64(val), 'f', -1, 32)
+}
+
 func (enc *textEncoder) AddMarshaler(key string, obj LogMarshaler) error {
 	enc.addKey(key)
+	return enc.appendMarshaler(obj)
+}
+
+func (enc *textEncoder) appendMarshaler(obj LogMarshaler) error {
 	enc.firstNested = true
 	enc.bytes = append(enc.bytes, '{')
+	outer := enc.namespace
+	enc.namespace = ""
 	err := obj.MarshalLog(enc)
+	enc.namespace = outer
 	enc.bytes = append(enc.bytes, '}')
 	enc.firstNested = false
 	return err
 }
 
+// AddArray adds an ArrayMarshaler to the encoder's fields. Arrays are written
+// as bracketed, comma-separated lists, and string elements are quoted if
+// they'd otherwise be ambiguous.
+func (enc *textEncoder) AddArray(key string, arr ArrayMarshaler) error {
+	enc.addKey(key)
+	return enc.appendArray(arr)
+}
+
+func (enc *textEncoder) appendArray(arr ArrayMarshaler) error {
+	enc.bytes = append(enc.bytes, '[')
+	err := arr.MarshalLogArray(enc)
+	enc.bytes = append(enc.bytes, ']')
+	return err
+}
+
+func (enc *textEncoder) AppendMarshaler(obj LogMarshaler) error {
+	enc.addElementSeparator()
+	return enc.appendMarshaler(obj)
+}
+
+func (enc *textEncoder) AppendArray(arr ArrayMarshaler) error {
+	enc.addElementSeparator()
+	return enc.appendArray(arr)
+}
+
+func (enc *textEncoder) AppendObject(obj interface{}) error {
+	if m, ok := obj.(LogMarshaler); ok && !isNilPointer(m) {
+		return enc.AppendMarshaler(m)
+	}
+	if m, ok := lookupTypeEncoder(obj); ok {
+		return enc.AppendMarshaler(m)
+	}
+	s, err := textString(obj)
+	if err != nil {
+		return err
+	}
+	enc.AppendString(s)
+	return nil
+}
+
+func (enc *textEncoder) AppendBool(val bool) {
+	enc.addElementSeparator()
+	enc.bytes = strconv.AppendBool(enc.bytes, val)
+}
+
+func (enc *textEncoder) AppendByteString(val []byte) {
+	enc.AppendString(string(val))
+}
+
+func (enc *textEncoder) AppendFloat32(val float32) {
+	enc.addElementSeparator()
+	enc.bytes = strconv.AppendFloat(enc.bytes, float64(val), 'f', -1, 32)
+}
+
+func (enc *textEncoder) AppendFloat64(val float64) {
+	enc.addElementSeparator()
+	enc.bytes = strconv.AppendFloat(enc.bytes, val, 'f', -1, 64)
+}
+
+func (enc *textEncoder) AppendInt64(val int64) {
+	enc.addElementSeparator()
+	enc.bytes = strconv.AppendInt(enc.bytes, val, 10)
+}
+
+func (enc *textEncoder) AppendUint64(val uint64) {
+	enc.addElementSeparator()
+	enc.bytes = strconv.AppendUint(enc.bytes, val, 10)
+}
+
+func (enc *textEncoder) AppendString(val string) {
+	enc.addElementSeparator()
+	if textNeedsQuotes(val) {
+		enc.bytes = strconv.AppendQuote(enc.bytes, val)
+		return
+	}
+	enc.bytes = append(enc.bytes, val...)
+}
+
+// AddObject adds an arbitrary object to the logging context. Objects that
+// implement LogMarshaler are encoded without reflection, and objects that
+// implement encoding.TextMarshaler use their text representation. Everything
+// else is formatted with fmt, which prefers Error and String methods.
 func (enc *textEncoder) AddObject(key string, obj interface{}) error {
-	enc.AddString(key, fmt.Sprintf("%+v", obj))
+	if m, ok := obj.(LogMarshaler); ok && !isNilPointer(m) {
+		return enc.AddMarshaler(key, m)
+	}
+	if m, ok := lookupTypeEncoder(obj); ok {
+		return enc.AddMarshaler(key, m)
+	}
+	s, err := textString(obj)
+	if err != nil {
+		return err
+	}
+	enc.AddString(key, s)
 	return nil
 }
 
+func textString(obj interface{}) (string, error) {
+	if mo, ok := obj.(marshaledObject); ok && !mo.redacted {
+		// Nothing was redacted, so format the original object as usual.
+		obj = mo.obj
+	}
+	if tm, ok := obj.(encoding.TextMarshaler); ok && !isNilPointer(tm) {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	return fmt.Sprintf("%+v", obj), nil
+}
+
+// OpenNamespace flattens namespaces into the keys of subsequent fields using
+// dotted prefixes, so fields added after OpenNamespace("foo") are written as
+// foo.key=value.
+func (enc *textEncoder) OpenNamespace(key string) {
+	enc.namespace += key + "."
+}
+
 func (enc *textEncoder) Clone() Encoder {
 	clone := textPool.Get().(*textEncoder)
 	clone.truncate()
-	clone.bytes = append(clone.bytes, enc.bytes...)
+	if len(enc.bytes) > 0 {
+		clone.prefix = enc
+		clone.prefixBytes = enc.bytes[:len(enc.bytes):len(enc.bytes)]
+	} else {
+		clone.prefix, clone.prefixBytes = enc.prefix, enc.prefixBytes
+	}
 	clone.timeFmt = enc.timeFmt
+	clone.timeLoc = enc.timeLoc
 	clone.firstNested = enc.firstNested
+	clone.namespace = enc.namespace
+	clone.multiline = enc.multiline
 	return clone
 }
 
-func (enc *textEncoder) WriteEntry(sink io.Writer, msg string, lvl Level, t time.Time) error {
+func (enc *textEncoder) WriteEntry(sink io.Writer, ent Entry) error {
 	if sink == nil {
 		return errNilSink
 	}
 
 	final := textPool.Get().(*textEncoder)
 	final.truncate()
-	enc.addLevel(final, lvl)
-	enc.addTime(final, t)
-	enc.addMessage(final, msg)
+	enc.addLevel(final, ent.Level)
+	enc.addTime(final, ent.Time)
+	enc.addMessage(final, ent.Message)
 
-	if len(enc.bytes) > 0 {
+	if len(enc.bytes) > 0 || enc.prefix != nil {
 		final.bytes = append(final.bytes, ' ')
-		final.bytes = append(final.bytes, enc.bytes...)
+		final.bytes = enc.appendFields(final.bytes)
 	}
-	final.bytes = append(final.bytes, '\n')
+	// In multi-line mode, the last value may end with its own newlines. Each
+	// entry should still end with exactly one.
+	last := len(final.bytes) - 1
+	for last >= 0 && final.bytes[last] == '\n' {
+		last--
+	}
+	final.bytes = append(final.bytes[:last+1], '\n')
 
 	expectedBytes := len(final.bytes)
 	n, err := sink.Write(final.bytes)
@@ -151,22 +340,114 @@ func (enc *textEncoder) WriteEntry(sink io.Writer, msg string, lvl Level, t time
 
 func (enc *textEncoder) truncate() {
 	enc.bytes = enc.bytes[:0]
+	enc.prefix = nil
+	enc.prefixBytes = nil
+	enc.namespace = ""
+}
+
+// appendFields appends all the encoder's fields, including those shared with
+// its ancestors, to dst.
+func (enc *textEncoder) appendFields(dst []byte) []byte {
+	return append(enc.appendPrefix(dst), enc.bytes...)
+}
+
+func (enc *textEncoder) appendPrefix(dst []byte) []byte {
+	if enc.prefix == nil {
+		return dst
+	}
+	return append(enc.prefix.appendPrefix(dst), enc.prefixBytes...)
+}
+
+// lastByte returns the last encoded byte, including the shared prefix.
+func (enc *textEncoder) lastByte() (byte, bool) {
+	if n := len(enc.bytes); n > 0 {
+		return enc.bytes[n-1], true
+	}
+	if n := len(enc.prefixBytes); n > 0 {
+		return enc.prefixBytes[n-1], true
+	}
+	return 0, false
 }
 
 func (enc *textEncoder) addKey(key string) {
-	lastIdx := len(enc.bytes) - 1
-	if lastIdx >= 0 && !enc.firstNested {
+	if _, ok := enc.lastByte(); ok && !enc.firstNested {
 		enc.bytes = append(enc.bytes, ' ')
 	} else {
 		enc.firstNested = false
 	}
-	enc.bytes = append(enc.bytes, key...)
+	enc.bytes = enc.appendLine(enc.bytes, enc.namespace)
+	enc.bytes = enc.appendLine(enc.bytes, key)
 	enc.bytes = append(enc.bytes, '=')
 }
 
+// appendLine appends the string to dst, escaping newlines and carriage returns
+// unless the encoder allows multi-line output. Backslashes are escaped too, so
+// that an escaped newline can't be confused with a literal "\n".
+func (enc *textEncoder) appendLine(dst []byte, s string) []byte {
+	if enc.multiline || strings.IndexAny(s, "\\\r\n") < 0 {
+		return append(dst, s...)
+	}
+	for i := 0; i < len(s); i++ {
+		dst = appendLineByte(dst, s[i])
+	}
+	return dst
+}
+
+// appendLineBytes is like appendLine, but it avoids converting a byte slice to
+// a string.
+func (enc *textEncoder) appendLineBytes(dst []byte, s []byte) []byte {
+	if enc.multiline || bytes.IndexAny(s, "\\\r\n") < 0 {
+		return append(dst, s...)
+	}
+	for _, b := range s {
+		dst = appendLineByte(dst, b)
+	}
+	return dst
+}
+
+func appendLineByte(dst []byte, b byte) []byte {
+	switch b {
+	case '\\':
+		return append(dst, '\\', '\\')
+	case '\n':
+		return append(dst, '\\', 'n')
+	case '\r':
+		return append(dst, '\\', 'r')
+	default:
+		return append(dst, b)
+	}
+}
+
+func (enc *textEncoder) addElementSeparator() {
+	if last, ok := enc.lastByte(); ok && last != '[' {
+		enc.bytes = append(enc.bytes, ',')
+	}
+}
+
+// textNeedsQuotes reports whether an array element would be ambiguous if it
+// were written without quotes.
+func textNeedsQuotes(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; b {
+		case ' ', ',', '=', '"', '\\', '[', ']', '{', '}':
+			return true
+		default:
+			if b < 0x20 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (enc *textEncoder) addLevel(final *textEncoder, lvl Level) {
 	final.bytes = append(final.bytes, '[')
 	switch lvl {
+	case TraceLevel:
+		final.bytes = append(final.bytes, 'T')
 	case DebugLevel:
 		final.bytes = append(final.bytes, 'D')
 	case InfoLevel:
@@ -189,13 +470,16 @@ func (enc *textEncoder) addTime(final *textEncoder, t time.Time) {
 	if enc.timeFmt == "" {
 		return
 	}
+	if enc.timeLoc != nil {
+		t = t.In(enc.timeLoc)
+	}
 	final.bytes = append(final.bytes, ' ')
 	final.bytes = t.AppendFormat(final.bytes, enc.timeFmt)
 }
 
 func (enc *textEncoder) addMessage(final *textEncoder, msg string) {
 	final.bytes = append(final.bytes, ' ')
-	final.bytes = append(final.bytes, msg...)
+	final.bytes = enc.appendLine(final.bytes, msg)
 }
 
 // A TextOption is used to set options for a text encoder.
@@ -217,7 +501,27 @@ func TextTimeFormat(layout string) TextOption {
 	})
 }
 
+// TextTimeLocation formats log timestamps in the supplied location, regardless
+// of the location of the entry's time. It's intended for development, where
+// local times are easiest to read; it takes precedence over the logger's
+// UTCTimestamps option. A nil location keeps each timestamp's own location.
+func TextTimeLocation(loc *time.Location) TextOption {
+	return textOptionFunc(func(enc *textEncoder) {
+		enc.timeLoc = loc
+	})
+}
+
 // TextNoTime omits timestamps from the serialized log entries.
 func TextNoTime() TextOption {
 	return TextTimeFormat("")
 }
+
+// TextMultiline writes newlines and carriage returns in messages and values
+// literally, so a single entry may span several lines. It's intended for
+// development, where stacktraces are much easier to read unescaped; most
+// line-oriented log shippers can't handle multi-line entries.
+func TextMultiline() TextOption {
+	return textOptionFunc(func(enc *textEncoder) {
+		enc.multiline = true
+	})
+}
@@ -67,6 +67,9 @@ func TestTextEncoderFields(t *testing.T) {
 	}{
 		{"string", "k=v", func(e Encoder) { e.AddString("k", "v") }},
 		{"string", "k=", func(e Encoder) { e.AddString("k", "") }},
+		{"byte string", "k=v", func(e Encoder) { e.AddByteString("k", []byte("v")) }},
+		{"binary", "k=YWIxMg==", func(e Encoder) { e.AddBinary("k", []byte("ab12")) }},
+		{"raw JSON", `k={"foo":[1,2]}`, func(e Encoder) { e.AddRawJSON("k", []byte(`{"foo":[1,2]}`)) }},
 		{"bool", "k=true", func(e Encoder) { e.AddBool("k", true) }},
 		{"bool", "k=false", func(e Encoder) { e.AddBool("k", false) }},
 		{"int", "k=42", func(e Encoder) { e.AddInt("k", 42) }},
@@ -81,6 +84,8 @@ func TestTextEncoderFields(t *testing.T) {
 		{"float64", "k=NaN", func(e Encoder) { e.AddFloat64("k", math.NaN()) }},
 		{"float64", "k=+Inf", func(e Encoder) { e.AddFloat64("k", math.Inf(1)) }},
 		{"float64", "k=-Inf", func(e Encoder) { e.AddFloat64("k", math.Inf(-1)) }},
+		{"float32", "k=1.1", func(e Encoder) { e.AddFloat32("k", 1.1) }},
+		{"float32", "k=NaN", func(e Encoder) { e.AddFloat32("k", float32(math.NaN())) }},
 		{"marshaler", "k={loggable=yes}", func(e Encoder) {
 			assert.NoError(t, e.AddMarshaler("k", loggable{true}), "Unexpected error calling MarshalLog.")
 		}},
@@ -92,6 +97,23 @@ func TestTextEncoderFields(t *testing.T) {
 			func(e Encoder) {
 				e.AddObject("k", []string{"bar 1", "bar 2", "bar 3"})
 			}},
+		{"namespace", "outer.k=v", func(e Encoder) {
+			e.OpenNamespace("outer")
+			e.AddString("k", "v")
+		}},
+		{"nested namespaces", "outer.inner.k=v", func(e Encoder) {
+			e.OpenNamespace("outer")
+			e.OpenNamespace("inner")
+			e.AddString("k", "v")
+		}},
+		{"namespace in marshaler", "m={inner.k=v} bar=1", func(e Encoder) {
+			e.AddMarshaler("m", LogMarshalerFunc(func(kv KeyValue) error {
+				kv.OpenNamespace("inner")
+				kv.AddString("k", "v")
+				return nil
+			}))
+			e.AddInt("bar", 1)
+		}},
 		{"map[string]string", "k=map[loggable:yes]", func(e Encoder) {
 			assert.NoError(t, e.AddObject("k", map[string]string{"loggable": "yes"}), "Unexpected error serializing a map.")
 		}},
@@ -138,7 +160,7 @@ func TestTextWriteEntry(t *testing.T) {
 	for _, tt := range tests {
 		assert.NoError(
 			t,
-			tt.enc.WriteEntry(sink, entry.Message, entry.Level, entry.Time),
+			tt.enc.WriteEntry(sink, *entry),
 			"Unexpected failure writing entry with text time formatter %s.", tt.name,
 		)
 		assert.Equal(t, tt.expected, sink.Stripped(), "Unexpected output from text time formatter %s.", tt.name)
@@ -151,6 +173,7 @@ func TestTextWriteEntryLevels(t *testing.T) {
 		level    Level
 		expected string
 	}{
+		{TraceLevel, "T"},
 		{DebugLevel, "D"},
 		{InfoLevel, "I"},
 		{WarnLevel, "W"},
@@ -165,7 +188,7 @@ func TestTextWriteEntryLevels(t *testing.T) {
 	for _, tt := range tests {
 		assert.NoError(
 			t,
-			enc.WriteEntry(sink, "Fake message.", tt.level, epoch),
+			enc.WriteEntry(sink, Entry{Message: "Fake message.", Level: tt.level, Time: epoch}),
 			"Unexpected failure writing entry with level %s.", tt.level,
 		)
 		expected := fmt.Sprintf("[%s] Fake message.", tt.expected)
@@ -186,6 +209,39 @@ func TestTextClone(t *testing.T) {
 	assert.Equal(t, "baz=bing", string(clone.(*textEncoder).bytes), "Unexpected serialized fields in cloned encoder.")
 }
 
+func TestTextCloneSharesFields(t *testing.T) {
+	parent := newTextEncoder(TextNoTime())
+	defer parent.Free()
+	parent.AddString("foo", "bar")
+
+	child := parent.Clone().(*textEncoder)
+	defer child.Free()
+	assert.Empty(t, child.bytes, "Expected the clone to share, rather than copy, its parent's fields.")
+
+	parent.AddInt("a", 1)
+	child.AddInt("b", 2)
+	grandchild := child.Clone().(*textEncoder)
+	defer grandchild.Free()
+	grandchild.AddArray("c", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+		arr.AppendInt64(3)
+		return nil
+	}))
+
+	tests := []struct {
+		enc      *textEncoder
+		expected string
+	}{
+		{parent, "[I] hi foo=bar a=1"},
+		{child, "[I] hi foo=bar b=2"},
+		{grandchild, "[I] hi foo=bar b=2 c=[3]"},
+	}
+	for i, tt := range tests {
+		buf := &testBuffer{}
+		assert.NoError(t, tt.enc.WriteEntry(buf, Entry{Message: "hi", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+		assert.Equal(t, tt.expected, buf.Stripped(), "Unexpected output from encoder %d.", i)
+	}
+}
+
 func TestTextWriteEntryFailure(t *testing.T) {
 	withTextEncoder(func(enc *textEncoder) {
 		tests := []struct {
@@ -197,7 +253,7 @@ func TestTextWriteEntryFailure(t *testing.T) {
 			{spywrite.ShortWriter{}, "Expected an error on partial writes to sink."},
 		}
 		for _, tt := range tests {
-			err := enc.WriteEntry(tt.sink, "hello", InfoLevel, time.Unix(0, 0))
+			err := enc.WriteEntry(tt.sink, Entry{Message: "hello", Level: InfoLevel, Time: time.Unix(0, 0)})
 			assert.Error(t, err, tt.msg)
 		}
 	})
@@ -211,7 +267,7 @@ func TestTextTimeOptions(t *testing.T) {
 
 	sink := &testBuffer{}
 	enc.AddString("foo", "bar")
-	err := enc.WriteEntry(sink, entry.Message, entry.Level, entry.Time)
+	err := enc.WriteEntry(sink, *entry)
 	assert.NoError(t, err, "WriteEntry returned an unexpected error.")
 	assert.Equal(
 		t,
@@ -219,3 +275,94 @@ func TestTextTimeOptions(t *testing.T) {
 		sink.Stripped(),
 	)
 }
+
+func TestTextEscapesNewlines(t *testing.T) {
+	enc := newTextEncoder(TextNoTime())
+	defer enc.Free()
+	enc.AddString("multi\nline", "foo\r\nbar")
+	enc.AddByteString("bytes", []byte("foo\nbar\n"))
+	enc.AddRawJSON("json", []byte("{\n  \"foo\": 1\n}"))
+	enc.AddMarshaler("nested", LogMarshalerFunc(func(kv KeyValue) error {
+		kv.OpenNamespace("ns\n")
+		kv.AddString("k", "v\n")
+		return kv.AddArray("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+			arr.AppendString("a\nb")
+			return nil
+		}))
+	}))
+	enc.AddString("trailing", "newline\n")
+
+	sink := &testBuffer{}
+	assert.NoError(t, enc.WriteEntry(sink, Entry{Message: "first\nsecond\r\n", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.Equal(
+		t,
+		`[I] first\nsecond\r\n multi\nline=foo\r\nbar bytes=foo\nbar\n json={   "foo": 1 } `+
+			`nested={ns\n.k=v\n ns\n.arr=["a\nb"]} trailing=newline\n`+"\n",
+		sink.String(),
+		"Expected newlines to be escaped.",
+	)
+}
+
+func TestTextEscapesBackslashes(t *testing.T) {
+	enc := newTextEncoder(TextNoTime())
+	defer enc.Free()
+	enc.AddString("escaped", "a\nb")
+	enc.AddString("literal", `a\nb`)
+	enc.AddString(`back\slash`, `C:\dir`)
+	enc.AddRawJSON("json", []byte(`{"quote":"\""}`))
+	enc.AddArray("arr", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+		arr.AppendString(`a\b`)
+		return nil
+	}))
+
+	sink := &testBuffer{}
+	assert.NoError(t, enc.WriteEntry(sink, Entry{Message: `literal\n`, Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.Equal(
+		t,
+		`[I] literal\\n escaped=a\nb literal=a\\nb back\\slash=C:\\dir json={"quote":"\""} arr=["a\\b"]`+"\n",
+		sink.String(),
+		"Expected backslashes to be escaped.",
+	)
+
+	multi := newTextEncoder(TextNoTime(), TextMultiline())
+	defer multi.Free()
+	multi.AddString("literal", `a\nb`)
+	sink.Reset()
+	assert.NoError(t, multi.WriteEntry(sink, Entry{Message: "", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.Equal(t, `[I]  literal=a\nb`+"\n", sink.String(), "Expected backslashes to be written literally in multi-line mode.")
+}
+
+func TestTextEscapesStacktraces(t *testing.T) {
+	enc := newTextEncoder(TextNoTime())
+	defer enc.Free()
+	Stack().AddTo(enc)
+
+	sink := &testBuffer{}
+	assert.NoError(t, enc.WriteEntry(sink, Entry{Message: "stack", Level: ErrorLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.Equal(t, 1, len(sink.Lines()), "Expected the stacktrace to stay on one line.")
+	assert.Contains(t, sink.String(), `\n`, "Expected escaped newlines in the stacktrace.")
+}
+
+func TestTextMultiline(t *testing.T) {
+	enc := newTextEncoder(TextNoTime(), TextMultiline())
+	defer enc.Free()
+	enc.AddString("k", "foo\nbar")
+	clone := enc.Clone()
+	defer clone.Free()
+	clone.AddString("trailing", "newlines\n\n")
+
+	sink := &testBuffer{}
+	assert.NoError(t, clone.WriteEntry(sink, Entry{Message: "first\nsecond", Level: InfoLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.Equal(
+		t,
+		"[I] first\nsecond k=foo\nbar trailing=newlines\n",
+		sink.String(),
+		"Expected literal newlines and exactly one trailing newline.",
+	)
+
+	sink.Reset()
+	Stack().AddTo(clone)
+	assert.NoError(t, clone.WriteEntry(sink, Entry{Message: "stack", Level: ErrorLevel, Time: epoch}), "Unexpected error writing entry.")
+	assert.True(t, len(sink.Lines()) > 1, "Expected the stacktrace to span multiple lines.")
+	assert.NotContains(t, sink.String(), `\n`, "Expected unescaped newlines in the stacktrace.")
+}
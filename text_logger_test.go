@@ -59,6 +59,20 @@ func TestTextLoggerNestedMarshal(t *testing.T) {
 	})
 }
 
+func TestTextLoggerNamespace(t *testing.T) {
+	withTextLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		child := logger.With(String("foo", "bar"), Namespace("user"))
+		child.Info("hello", String("name", "jane"))
+		child.With(Namespace("nested")).Info("hello", Int("visits", 42))
+		logger.Info("hello", Int("visits", 42))
+		assert.Equal(t, []string{
+			"[I] hello foo=bar user.name=jane",
+			"[I] hello foo=bar user.nested.visits=42",
+			"[I] hello visits=42",
+		}, buf.Lines(), "Unexpected output using namespaces.")
+	})
+}
+
 func TestTextLoggerAddMarshalEmpty(t *testing.T) {
 	empty := LogMarshalerFunc(func(_ KeyValue) error { return nil })
 	withTextLogger(t, nil, func(logger Logger, buf *testBuffer) {
@@ -26,3 +26,17 @@ func timeToSeconds(t time.Time) float64 {
 	nanos := float64(t.UnixNano())
 	return nanos / float64(time.Second)
 }
+
+// A Clock is a source of the current time. Loggers consult their Clock once
+// per entry to timestamp it, so substituting a fake Clock makes encoded output
+// deterministic (see the zaptest package).
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock; it just calls time.Now.
+type systemClock struct{}
+
+var _systemClock Clock = systemClock{}
+
+func (systemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,112 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// A TypeEncoder adds a value of a registered type to the logging context as
+// an object. See RegisterTypeEncoder.
+type TypeEncoder func(obj interface{}, kv KeyValue) error
+
+// _typeEncoders holds a map[reflect.Type]TypeEncoder. It's replaced wholesale
+// on each registration, so that lookups don't need a lock.
+var _typeEncoders struct {
+	sync.Mutex // serializes registrations
+	m          atomic.Value
+}
+
+// RegisterTypeEncoder changes how all encoders serialize values of the given
+// type when they're passed to Object, Reflect, or Any: instead of using
+// reflection (or the value's String or Error methods), encoders add an object
+// populated by the supplied function. It's useful for domain types that should
+// be rendered consistently everywhere without wrapping them at every call
+// site. Types are matched exactly, so registering T doesn't affect *T, and
+// values that implement LogMarshaler are unaffected.
+//
+// Registrations apply to all subsequent entries, including those written by
+// existing loggers. Registering a type again replaces its TypeEncoder, and
+// registering a nil TypeEncoder removes it. RegisterTypeEncoder is safe for
+// concurrent use, but it's relatively expensive, so it's best called during
+// initialization.
+//
+// Unlike the rest of an encoder's configuration, the registry is global to the
+// process, like encoding/gob's Register: Any and Reflect consult it when the
+// field is constructed, before any encoder is involved, and a registration
+// should reach loggers built by libraries too. Tests that register types can
+// undo all registrations with ResetTypeEncoders.
+func RegisterTypeEncoder(t reflect.Type, f TypeEncoder) {
+	_typeEncoders.Lock()
+	defer _typeEncoders.Unlock()
+
+	old := loadTypeEncoders()
+	m := make(map[reflect.Type]TypeEncoder, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	if f == nil {
+		delete(m, t)
+	} else {
+		m[t] = f
+	}
+	_typeEncoders.m.Store(m)
+}
+
+// ResetTypeEncoders removes every registered TypeEncoder, restoring the
+// default serialization for all types. It's intended for tests.
+func ResetTypeEncoders() {
+	_typeEncoders.Lock()
+	defer _typeEncoders.Unlock()
+	_typeEncoders.m.Store(map[reflect.Type]TypeEncoder(nil))
+}
+
+func loadTypeEncoders() map[reflect.Type]TypeEncoder {
+	m, _ := _typeEncoders.m.Load().(map[reflect.Type]TypeEncoder)
+	return m
+}
+
+// lookupTypeEncoder returns a LogMarshaler for the object if its type has a
+// registered TypeEncoder.
+func lookupTypeEncoder(obj interface{}) (LogMarshaler, bool) {
+	m := loadTypeEncoders()
+	if len(m) == 0 || obj == nil {
+		return nil, false
+	}
+	f, ok := m[reflect.TypeOf(obj)]
+	if !ok {
+		return nil, false
+	}
+	return LogMarshalerFunc(func(kv KeyValue) error {
+		return f(obj, kv)
+	}), true
+}
+
+func hasTypeEncoder(obj interface{}) bool {
+	m := loadTypeEncoders()
+	if len(m) == 0 || obj == nil {
+		return false
+	}
+	_, ok := m[reflect.TypeOf(obj)]
+	return ok
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type money struct {
+	Currency string
+	Cents    int64
+}
+
+func (m money) String() string { return fmt.Sprintf("%d %s cents", m.Cents, m.Currency) }
+
+type accountID int64
+
+type ipPrefix struct {
+	IP   string
+	Bits int
+}
+
+func encodeMoney(obj interface{}, kv KeyValue) error {
+	m := obj.(money)
+	kv.AddString("currency", m.Currency)
+	kv.AddInt64("units", m.Cents)
+	return nil
+}
+
+func encodeAccountID(obj interface{}, kv KeyValue) error {
+	kv.AddString("kind", "account")
+	kv.AddInt64("id", int64(obj.(accountID)))
+	return nil
+}
+
+func withTypeEncoders(f func()) {
+	RegisterTypeEncoder(reflect.TypeOf(money{}), encodeMoney)
+	RegisterTypeEncoder(reflect.TypeOf(accountID(0)), encodeAccountID)
+	defer ResetTypeEncoders()
+	f()
+}
+
+func TestRegisterTypeEncoder(t *testing.T) {
+	usd := money{Currency: "USD", Cents: 1234}
+	prefix := ipPrefix{IP: "10.0.0.0", Bits: 8}
+
+	// Before registration, objects use the default precedence rules.
+	assertFieldJSON(t, `"amount":"1234 USD cents"`, Any("amount", usd))
+	assertFieldJSON(t, `"account":42`, Object("account", accountID(42)))
+
+	withTypeEncoders(func() {
+		tests := []struct {
+			field Field
+			json  string
+			text  string
+		}{
+			{Any("amount", usd), `"amount":{"currency":"USD","units":1234}`, "amount={currency=USD units=1234}"},
+			{Object("amount", usd), `"amount":{"currency":"USD","units":1234}`, "amount={currency=USD units=1234}"},
+			{Reflect("account", accountID(42)), `"account":{"kind":"account","id":42}`, "account={kind=account id=42}"},
+			{Any("prefix", prefix), `"prefix":{"IP":"10.0.0.0","Bits":8}`, "prefix={IP:10.0.0.0 Bits:8}"},
+			{Any("ptr", &usd), `"ptr":"1234 USD cents"`, "ptr=1234 USD cents"},
+			{
+				Array("amounts", ArrayMarshalerFunc(func(arr ArrayEncoder) error {
+					return arr.AppendObject(usd)
+				})),
+				`"amounts":[{"currency":"USD","units":1234}]`,
+				"amounts=[{currency=USD units=1234}]",
+			},
+		}
+
+		for _, tt := range tests {
+			assertFieldJSON(t, tt.json, tt.field)
+			withTextEncoder(func(enc *textEncoder) {
+				tt.field.AddTo(enc)
+				assert.Equal(t, tt.text, string(enc.bytes), "Unexpected text output for field %+v.", tt.field)
+			})
+		}
+
+		// Registering a nil TypeEncoder removes only that type's encoder.
+		RegisterTypeEncoder(reflect.TypeOf(money{}), nil)
+		assertFieldJSON(t, `"amount":"1234 USD cents"`, Any("amount", usd))
+		assertFieldJSON(t, `"account":{"kind":"account","id":42}`, Object("account", accountID(42)))
+	})
+
+	// ResetTypeEncoders restores the defaults.
+	assertFieldJSON(t, `"account":42`, Object("account", accountID(42)))
+}
+
+func TestRegisterTypeEncoderExistingLogger(t *testing.T) {
+	withJSONLogger(t, nil, func(logger Logger, buf *testBuffer) {
+		child := logger.With(Object("account", accountID(7)))
+		withTypeEncoders(func() {
+			logger.Info("registered", Any("account", accountID(7)))
+		})
+		child.Info("child")
+		assert.Equal(t, []string{
+			`{"level":"info","msg":"registered","account":{"kind":"account","id":7}}`,
+			`{"level":"info","msg":"child","account":7}`,
+		}, buf.Lines(), "Expected registration to apply to subsequent entries.")
+	})
+}
+
+func TestRegisterTypeEncoderConcurrent(t *testing.T) {
+	logger := New(NewJSONEncoder(), Output(Discard))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			withTypeEncoders(func() {})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			logger.Info("concurrent", Any("amount", money{Currency: "EUR", Cents: int64(i)}), Object("account", accountID(i)))
+		}
+	}()
+	wg.Wait()
+}
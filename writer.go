@@ -22,9 +22,14 @@ package zap
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"sync"
+	"time"
+
+	"github.com/uber-go/atomic"
 )
 
 var (
@@ -32,6 +37,8 @@ var (
 	Discard = AddSync(ioutil.Discard)
 	// DiscardOutput is an Option that discards logger output.
 	DiscardOutput = Output(Discard)
+
+	errTimeoutClosed = errors.New("WithTimeout WriteSyncer is closed")
 )
 
 // A WriteFlusher is an io.Writer that can also flush any buffered data.
@@ -47,6 +54,16 @@ type WriteSyncer interface {
 	Sync() error
 }
 
+// An EntryWriteSyncer is a WriteSyncer that also wants each entry's metadata
+// along with its encoded bytes, for example to route entries by level. When a
+// Logger's Output or ErrorOutput implements EntryWriteSyncer, entries are
+// written with WriteEntry instead of Write. The encoded bytes are only valid
+// until WriteEntry returns.
+type EntryWriteSyncer interface {
+	WriteSyncer
+	WriteEntry(ent Entry, p []byte) (int, error)
+}
+
 // AddSync converts an io.Writer to a WriteSyncer. It attempts to be
 // intelligent: if the concrete type of the io.Writer implements WriteSyncer or
 // WriteFlusher, we'll use the existing Sync or Flush methods. If it doesn't,
@@ -68,9 +85,24 @@ type lockedWriteSyncer struct {
 }
 
 func newLockedWriteSyncer(ws WriteSyncer) WriteSyncer {
+	if ews, ok := ws.(EntryWriteSyncer); ok {
+		return lockedEntryWriteSyncer{&lockedWriteSyncer{ws: ws}, ews}
+	}
 	return &lockedWriteSyncer{ws: ws}
 }
 
+type lockedEntryWriteSyncer struct {
+	*lockedWriteSyncer
+	ews EntryWriteSyncer
+}
+
+func (s lockedEntryWriteSyncer) WriteEntry(ent Entry, bs []byte) (int, error) {
+	s.Lock()
+	n, err := s.ews.WriteEntry(ent, bs)
+	s.Unlock()
+	return n, err
+}
+
 func (s *lockedWriteSyncer) Write(bs []byte) (int, error) {
 	s.Lock()
 	n, err := s.ws.Write(bs)
@@ -85,6 +117,168 @@ func (s *lockedWriteSyncer) Sync() error {
 	return err
 }
 
+// WithTimeout wraps a WriteSyncer so that each Write and Sync returns within
+// the supplied timeout, even if the underlying WriteSyncer blocks (for
+// example, because a network filesystem is hung). It's safe for concurrent
+// use even if the underlying WriteSyncer isn't, since calls are handed off to
+// a single worker goroutine. Each Write copies its input.
+//
+// Calls that don't finish in time return an error, which Loggers report to
+// their ErrorOutput; the entry is dropped. While a timed-out call is still
+// running, subsequent calls fail immediately rather than waiting in turn.
+// Note that a timed-out call isn't canceled: it may still complete later, so
+// the underlying WriteSyncer may eventually receive an entry whose write was
+// reported as failed (and which the caller may have retried).
+//
+// If the underlying WriteSyncer is an EntryWriteSyncer, so is the returned
+// one. Since the worker may outlive the call, the Entry it passes along has no
+// Fields; only its metadata (level, time, message, caller, and stack) and
+// encoded bytes are available.
+//
+// The worker goroutine runs until the returned WriteSyncer's Close method is
+// called, so call Close once the WriteSyncer is no longer in use.
+func WithTimeout(ws WriteSyncer, timeout time.Duration) TimeoutWriteSyncer {
+	s := &timeoutWriteSyncer{
+		ws:       ws,
+		timeout:  timeout,
+		ops:      make(chan timeoutOp),
+		done:     make(chan struct{}),
+		wedged:   atomic.NewBool(false),
+		errWrite: fmt.Errorf("write timed out after %v", timeout),
+		errSync:  fmt.Errorf("sync timed out after %v", timeout),
+	}
+	go s.run()
+	if ews, ok := ws.(EntryWriteSyncer); ok {
+		return timeoutEntryWriteSyncer{s, ews}
+	}
+	return s
+}
+
+// A TimeoutWriteSyncer is a WriteSyncer returned by WithTimeout.
+type TimeoutWriteSyncer interface {
+	WriteSyncer
+	// Close stops the worker goroutine; subsequent calls to Write and Sync
+	// return an error. It doesn't wait for a timed-out call that's still
+	// running, and it doesn't close the underlying WriteSyncer. It's safe to
+	// call more than once.
+	Close() error
+}
+
+type timeoutWriteSyncer struct {
+	ws      WriteSyncer
+	timeout time.Duration
+	ops     chan timeoutOp
+	// Closed to stop the worker.
+	done      chan struct{}
+	closeOnce sync.Once
+	// Whether a timed-out call is still running.
+	wedged *atomic.Bool
+
+	errWrite error
+	errSync  error
+}
+
+type timeoutEntryWriteSyncer struct {
+	*timeoutWriteSyncer
+	ews EntryWriteSyncer
+}
+
+func (s timeoutEntryWriteSyncer) WriteEntry(ent Entry, bs []byte) (int, error) {
+	// Don't hand the entry's pooled encoder to the worker.
+	ent.enc = nil
+	res := s.do(timeoutOp{bs: append([]byte(nil), bs...), ent: &ent, ews: s.ews}, s.errWrite)
+	return res.n, res.err
+}
+
+// A timeoutOp is a Write (or, if sync is set, a Sync, or if ent is set, a
+// WriteEntry) for the worker. The result channel is buffered, so the worker
+// never blocks on callers that gave up.
+type timeoutOp struct {
+	bs     []byte
+	sync   bool
+	ent    *Entry
+	ews    EntryWriteSyncer
+	result chan timeoutResult
+}
+
+type timeoutResult struct {
+	n   int
+	err error
+}
+
+func (s *timeoutWriteSyncer) Write(bs []byte) (int, error) {
+	res := s.do(timeoutOp{bs: append([]byte(nil), bs...)}, s.errWrite)
+	return res.n, res.err
+}
+
+func (s *timeoutWriteSyncer) Sync() error {
+	return s.do(timeoutOp{sync: true}, s.errSync).err
+}
+
+func (s *timeoutWriteSyncer) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+func (s *timeoutWriteSyncer) do(op timeoutOp, errTimeout error) timeoutResult {
+	select {
+	case <-s.done:
+		return timeoutResult{err: errTimeoutClosed}
+	default:
+	}
+	if s.wedged.Load() {
+		return timeoutResult{err: errTimeout}
+	}
+	op.result = make(chan timeoutResult, 1)
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	select {
+	case s.ops <- op:
+	case <-s.done:
+		return timeoutResult{err: errTimeoutClosed}
+	case <-timer.C:
+		return timeoutResult{err: errTimeout}
+	}
+	select {
+	case res := <-op.result:
+		return res
+	case <-timer.C:
+	}
+	s.wedged.Store(true)
+	// The worker clears the flag after sending the result, so check whether
+	// we raced with it.
+	select {
+	case res := <-op.result:
+		s.wedged.Store(false)
+		return res
+	default:
+		return timeoutResult{err: errTimeout}
+	}
+}
+
+func (s *timeoutWriteSyncer) run() {
+	for {
+		var op timeoutOp
+		select {
+		case op = <-s.ops:
+		case <-s.done:
+			return
+		}
+		var res timeoutResult
+		switch {
+		case op.sync:
+			res.err = s.ws.Sync()
+		case op.ent != nil:
+			res.n, res.err = op.ews.WriteEntry(*op.ent, op.bs)
+		default:
+			res.n, res.err = s.ws.Write(op.bs)
+		}
+		op.result <- res
+		s.wedged.Store(false)
+	}
+}
+
 type writerWrapper struct {
 	io.Writer
 }
@@ -105,7 +299,13 @@ func (f flusherWrapper) Sync() error {
 // and sync calls, similarly to to io.MultiWriter.
 func MultiWriteSyncer(ws ...WriteSyncer) WriteSyncer {
 	// Copy to protect against https://github.com/golang/go/issues/7809
-	return multiWriteSyncer(append([]WriteSyncer(nil), ws...))
+	multi := multiWriteSyncer(append([]WriteSyncer(nil), ws...))
+	for _, w := range ws {
+		if _, ok := w.(EntryWriteSyncer); ok {
+			return multiEntryWriteSyncer{multi}
+		}
+	}
+	return multi
 }
 
 // See https://golang.org/src/io/multi.go
@@ -129,6 +329,37 @@ func (ws multiWriteSyncer) Write(p []byte) (int, error) {
 	return nWritten, errs.asError()
 }
 
+// multiEntryWriteSyncer passes entries' metadata along to any wrapped
+// EntryWriteSyncers.
+type multiEntryWriteSyncer struct {
+	multiWriteSyncer
+}
+
+func (ws multiEntryWriteSyncer) WriteEntry(ent Entry, p []byte) (int, error) {
+	var errs multiError
+	nWritten := 0
+	for _, w := range ws.multiWriteSyncer {
+		var (
+			n   int
+			err error
+		)
+		if ew, ok := w.(EntryWriteSyncer); ok {
+			n, err = ew.WriteEntry(ent, p)
+		} else {
+			n, err = w.Write(p)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if nWritten == 0 && n != 0 {
+			nWritten = n
+		} else if n < nWritten {
+			nWritten = n
+		}
+	}
+	return nWritten, errs.asError()
+}
+
 func (ws multiWriteSyncer) Sync() error {
 	return wrapMultiError(ws...)
 }
@@ -23,7 +23,11 @@ package zap
 import (
 	"bytes"
 	"errors"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -166,3 +170,192 @@ type syncSpy struct {
 	bytes.Buffer
 	spywrite.Syncer
 }
+
+// entrySpy is an EntryWriteSyncer that records the levels of the entries
+// written to it.
+type entrySpy struct {
+	syncSpy
+	levels []Level
+}
+
+func (s *entrySpy) WriteEntry(ent Entry, p []byte) (int, error) {
+	s.levels = append(s.levels, ent.Level)
+	return s.Write(p)
+}
+
+func TestEntryWriteSyncer(t *testing.T) {
+	spy := &entrySpy{}
+	errSpy := &entrySpy{}
+	logger := New(NewJSONEncoder(NoTime()), Output(spy), ErrorOutput(errSpy))
+	logger.Info("info")
+	logger.Error("error")
+	assert.Equal(t, []Level{InfoLevel, ErrorLevel}, spy.levels, "Expected entries' metadata to be passed to the Output.")
+	assert.Equal(t, []Level{ErrorLevel}, errSpy.levels, "Expected entries' metadata to be passed to the ErrorOutput.")
+	assert.Equal(t, "{\"level\":\"info\",\"msg\":\"info\"}\n{\"level\":\"error\",\"msg\":\"error\"}\n", spy.String(), "Unexpected output.")
+}
+
+func TestMultiWriteSyncerEntries(t *testing.T) {
+	spy := &entrySpy{}
+	plain := &bytes.Buffer{}
+	ws := MultiWriteSyncer(AddSync(plain), spy)
+	_, ok := ws.(EntryWriteSyncer)
+	require.True(t, ok, "Expected MultiWriteSyncer to pass along entries when it wraps an EntryWriteSyncer.")
+	_, ok = MultiWriteSyncer(AddSync(plain)).(EntryWriteSyncer)
+	assert.False(t, ok, "Expected plain MultiWriteSyncers not to be EntryWriteSyncers.")
+
+	logger := New(NewJSONEncoder(NoTime()), Output(ws))
+	logger.Warn("warn")
+	assert.Equal(t, []Level{WarnLevel}, spy.levels, "Expected entries' metadata to be passed to the wrapped EntryWriteSyncer.")
+	assert.Equal(t, spy.String(), plain.String(), "Expected both outputs to receive the entry.")
+}
+
+// blockingSyncer is a WriteSyncer whose Write and Sync block until its
+// release channel is closed.
+type blockingSyncer struct {
+	sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+}
+
+func newBlockingSyncer() *blockingSyncer {
+	return &blockingSyncer{release: make(chan struct{})}
+}
+
+func (s *blockingSyncer) Write(bs []byte) (int, error) {
+	<-s.release
+	s.Lock()
+	defer s.Unlock()
+	return s.buf.Write(bs)
+}
+
+func (s *blockingSyncer) Sync() error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingSyncer) String() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.buf.String()
+}
+
+func TestWithTimeout(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ws := WithTimeout(AddSync(buf), time.Second)
+	defer ws.Close()
+	requireWriteWorks(t, ws)
+	assert.NoError(t, ws.Sync(), "Unexpected error syncing.")
+	assert.Equal(t, "foo", buf.String(), "Unexpected output.")
+
+	failing := WithTimeout(AddSync(spywrite.FailWriter{}), time.Second)
+	defer failing.Close()
+	_, err := failing.Write([]byte("foo"))
+	assert.Error(t, err, "Expected errors from the underlying WriteSyncer to be returned.")
+}
+
+func TestWithTimeoutClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	buf := &bytes.Buffer{}
+	ws := WithTimeout(AddSync(buf), time.Second)
+	requireWriteWorks(t, ws)
+	assert.NoError(t, ws.Close(), "Unexpected error closing.")
+	assert.NoError(t, ws.Close(), "Expected Close to be idempotent.")
+
+	_, err := ws.Write([]byte("bar"))
+	assert.Equal(t, errTimeoutClosed, err, "Expected writes to fail after Close.")
+	assert.Equal(t, errTimeoutClosed, ws.Sync(), "Expected syncs to fail after Close.")
+	assert.Equal(t, "foo", buf.String(), "Unexpected output after Close.")
+	waitFor(t, "the worker to exit", func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestWithTimeoutEntryWriteSyncer(t *testing.T) {
+	spy := &entrySpy{}
+	ws := WithTimeout(spy, time.Second)
+	defer ws.Close()
+	_, ok := ws.(EntryWriteSyncer)
+	require.True(t, ok, "Expected WithTimeout to preserve EntryWriteSyncers.")
+	plain := WithTimeout(AddSync(&bytes.Buffer{}), time.Second)
+	defer plain.Close()
+	_, ok = plain.(EntryWriteSyncer)
+	assert.False(t, ok, "Expected plain WriteSyncers not to become EntryWriteSyncers.")
+
+	logger := New(NewJSONEncoder(NoTime()), Output(ws))
+	logger.Warn("warn")
+	assert.Equal(t, []Level{WarnLevel}, spy.levels, "Expected entries' metadata to be passed to the wrapped EntryWriteSyncer.")
+	assert.Equal(t, `{"level":"warn","msg":"warn"}`+"\n", spy.String(), "Unexpected output.")
+}
+
+func TestWithTimeoutBlocked(t *testing.T) {
+	blocked := newBlockingSyncer()
+	ws := WithTimeout(blocked, 10*time.Millisecond)
+	defer ws.Close()
+
+	bs := []byte("first")
+	n, err := ws.Write(bs)
+	assert.Equal(t, 0, n, "Expected a timed-out write to report no bytes written.")
+	assert.EqualError(t, err, "write timed out after 10ms", "Expected a timeout error.")
+	copy(bs, "xxxxx")
+
+	start := time.Now()
+	_, err = ws.Write([]byte("second"))
+	assert.EqualError(t, err, "write timed out after 10ms", "Expected writes to fail while the WriteSyncer is wedged.")
+	assert.EqualError(t, ws.Sync(), "sync timed out after 10ms", "Expected syncs to fail while the WriteSyncer is wedged.")
+	assert.True(t, time.Since(start) < time.Second, "Expected calls to fail immediately while the WriteSyncer is wedged.")
+
+	close(blocked.release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := ws.Write([]byte(" third")); err == nil {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "Expected writes to succeed once the WriteSyncer is unblocked.")
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, "first third", blocked.String(), "Expected the timed-out write to complete later, with a copy of its input.")
+}
+
+func TestWithTimeoutLogger(t *testing.T) {
+	blocked := newBlockingSyncer()
+	defer close(blocked.release)
+	errBuf := &testBuffer{}
+	ws := WithTimeout(blocked, 10*time.Millisecond)
+	defer ws.Close()
+	logger := New(newJSONEncoder(NoTime()), Output(ws), ErrorOutput(errBuf))
+
+	done := make(chan struct{})
+	go func() {
+		logger.Info("dropped")
+		logger.Info("dropped")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected logging to a wedged WriteSyncer not to block.")
+	}
+	assert.Equal(t, 2, strings.Count(errBuf.String(), "encoder error: write timed out after 10ms"), "Expected timeouts to be reported.")
+}
+
+func TestTerminalTimeout(t *testing.T) {
+	blocked := newBlockingSyncer()
+	defer close(blocked.release)
+	defer func(d time.Duration) { _terminalTimeout = d }(_terminalTimeout)
+	_terminalTimeout = 10 * time.Millisecond
+
+	logger := New(newJSONEncoder(NoTime()), Output(blocked), ErrorOutput(blocked))
+	tee := Tee(logger, New(newJSONEncoder(NoTime()), Output(blocked), ErrorOutput(blocked)))
+	assert.Panics(t, func() { logger.Panic("panic") }, "Expected Panic to panic even if the output is wedged.")
+	assert.Panics(t, func() { tee.Panic("panic") }, "Expected Tee.Panic to panic even if the outputs are wedged.")
+
+	for _, log := range []Logger{logger, tee} {
+		stub := stubExit()
+		log.Fatal("fatal")
+		stub.AssertStatus(t, 1)
+		stub.Unstub()
+
+		stub = stubExit()
+		log.Check(FatalLevel, "chained").Chain(log.Check(FatalLevel, "chained")).Write()
+		stub.AssertStatus(t, 1)
+		stub.Unstub()
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapdecode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+// MaxEntrySize is the largest encoded entry, in bytes, that a Decoder reads.
+// Larger entries are skipped so that a corrupt length prefix can't exhaust
+// memory.
+const MaxEntrySize = 64 << 20
+
+// The limit Decoders enforce. It's a variable for tests.
+var _maxEntrySize uint64 = MaxEntrySize
+
+var (
+	errTruncated = errors.New("truncated message")
+	errOverflow  = errors.New("varint overflows a 64-bit integer")
+	errTooDeep   = errors.New("fields are nested too deeply")
+)
+
+// Nested objects, arrays, and namespaces deeper than this are rejected, which
+// bounds the decoder's recursion.
+const _maxDepth = 1000
+
+// An Entry is a decoded log entry.
+type Entry struct {
+	Level   zap.Level
+	Time    time.Time
+	Message string
+	// Caller is only defined if the encoded entry recorded its call site.
+	Caller zap.EntryCaller
+	Stack  string
+	Fields []Field
+}
+
+// A Decoder reads entries from a stream written by the binary encoder.
+type Decoder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewDecoder creates a Decoder reading from r. The Decoder buffers its input,
+// so it may read past the last entry it returns.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next entry from the stream. At the end of the stream, it
+// returns io.EOF; if the stream ends partway through an entry, it returns
+// io.ErrUnexpectedEOF.
+//
+// Entries that can't be decoded, or that are larger than MaxEntrySize, are
+// consumed and reported with an error, so callers may keep decoding the
+// entries that follow. Once Decode returns io.EOF, io.ErrUnexpectedEOF, or an
+// error from the underlying reader, the stream can't be decoded any further.
+func (d *Decoder) Decode() (Entry, error) {
+	size, err := binary.ReadUvarint(d.r)
+	if err == io.EOF {
+		return Entry{}, io.EOF
+	}
+	if err != nil {
+		return Entry{}, unexpectedEOF(err)
+	}
+	if size > _maxEntrySize {
+		if _, err := io.CopyN(ioutil.Discard, d.r, int64(size)); err != nil {
+			return Entry{}, unexpectedEOF(err)
+		}
+		return Entry{}, fmt.Errorf("entry of %v bytes exceeds the %v byte limit", size, _maxEntrySize)
+	}
+	if uint64(cap(d.buf)) < size {
+		d.buf = make([]byte, size)
+	}
+	d.buf = d.buf[:size]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		return Entry{}, unexpectedEOF(err)
+	}
+	return Unmarshal(d.buf)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Unmarshal decodes a single entry without its length prefix. The entry
+// doesn't retain any references to data.
+func Unmarshal(data []byte) (Entry, error) {
+	var ent Entry
+	msg := message{data}
+	for !msg.done() {
+		num, wire, err := msg.tag()
+		if err != nil {
+			return Entry{}, err
+		}
+		switch {
+		case num == 1 && wire == _varint:
+			n, err := msg.sint()
+			if err != nil {
+				return Entry{}, err
+			}
+			ent.Time = time.Unix(0, n)
+		case num == 2 && wire == _varint:
+			n, err := msg.sint()
+			if err != nil {
+				return Entry{}, err
+			}
+			ent.Level = zap.Level(n)
+		case num == 4 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return Entry{}, err
+			}
+			ent.Message = string(b)
+		case num == 5 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return Entry{}, err
+			}
+			if ent.Caller, err = decodeCaller(b); err != nil {
+				return Entry{}, err
+			}
+		case num == 6 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return Entry{}, err
+			}
+			ent.Stack = string(b)
+		case num == 7 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return Entry{}, err
+			}
+			f, err := decodeField(b, 0)
+			if err != nil {
+				return Entry{}, err
+			}
+			ent.Fields = append(ent.Fields, f)
+		default:
+			if err := msg.skip(wire); err != nil {
+				return Entry{}, err
+			}
+		}
+	}
+	return ent, nil
+}
+
+func decodeCaller(data []byte) (zap.EntryCaller, error) {
+	caller := zap.EntryCaller{Defined: true}
+	msg := message{data}
+	for !msg.done() {
+		num, wire, err := msg.tag()
+		if err != nil {
+			return zap.EntryCaller{}, err
+		}
+		switch {
+		case num == 1 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return zap.EntryCaller{}, err
+			}
+			caller.File = string(b)
+		case num == 2 && wire == _varint:
+			n, err := msg.uvarint()
+			if err != nil {
+				return zap.EntryCaller{}, err
+			}
+			caller.Line = int(int64(n))
+		case num == 3 && wire == _varint:
+			n, err := msg.uvarint()
+			if err != nil {
+				return zap.EntryCaller{}, err
+			}
+			caller.PC = uintptr(n)
+		default:
+			if err := msg.skip(wire); err != nil {
+				return zap.EntryCaller{}, err
+			}
+		}
+	}
+	return caller, nil
+}
+
+// Protobuf wire types.
+const (
+	_varint  = 0
+	_fixed64 = 1
+	_bytes   = 2
+	_fixed32 = 5
+)
+
+// A message reads the contents of an encoded protobuf message.
+type message struct {
+	b []byte
+}
+
+func (m *message) done() bool {
+	return len(m.b) == 0
+}
+
+func (m *message) tag() (num uint64, wire uint64, err error) {
+	t, err := m.uvarint()
+	return t >> 3, t & 7, err
+}
+
+func (m *message) uvarint() (uint64, error) {
+	n, size := binary.Uvarint(m.b)
+	switch {
+	case size == 0:
+		return 0, errTruncated
+	case size < 0:
+		return 0, errOverflow
+	}
+	m.b = m.b[size:]
+	return n, nil
+}
+
+// sint reads a zigzag-encoded signed varint.
+func (m *message) sint() (int64, error) {
+	n, err := m.uvarint()
+	return int64(n>>1) ^ -int64(n&1), err
+}
+
+func (m *message) double() (float64, error) {
+	if len(m.b) < 8 {
+		return 0, errTruncated
+	}
+	bits := binary.LittleEndian.Uint64(m.b)
+	m.b = m.b[8:]
+	return math.Float64frombits(bits), nil
+}
+
+// bytes reads a length-delimited value. The result aliases the message.
+func (m *message) bytes() ([]byte, error) {
+	n, err := m.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(m.b)) {
+		return nil, errTruncated
+	}
+	b := m.b[:n]
+	m.b = m.b[n:]
+	return b, nil
+}
+
+func (m *message) skip(wire uint64) error {
+	var err error
+	switch wire {
+	case _varint:
+		_, err = m.uvarint()
+	case _fixed64:
+		_, err = m.double()
+	case _bytes:
+		_, err = m.bytes()
+	case _fixed32:
+		if len(m.b) < 4 {
+			return errTruncated
+		}
+		m.b = m.b[4:]
+	default:
+		return fmt.Errorf("unsupported wire type %v", wire)
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapdecode
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeAll decodes entries until the stream ends, skipping bad entries. Each
+// call to Decode consumes at least one byte unless the stream has ended, so
+// more calls than there are bytes means the decoder is stuck.
+func decodeAll(data []byte) (ents []Entry, end error, ok bool) {
+	dec := NewDecoder(bytes.NewReader(data))
+	for i := 0; i <= len(data); i++ {
+		ent, err := dec.Decode()
+		switch err {
+		case nil:
+			ents = append(ents, ent)
+		case io.EOF, io.ErrUnexpectedEOF:
+			return ents, err, true
+		}
+	}
+	return ents, nil, false
+}
+
+func sampleStream(t testing.TB) ([]byte, []int) {
+	var (
+		stream     []byte
+		boundaries []int
+	)
+	ent := zap.Entry{
+		Level:   zap.WarnLevel,
+		Time:    time.Unix(1475000000, 0),
+		Message: "sample",
+		Caller:  zap.EntryCaller{Defined: true, File: "sample.go", Line: 7},
+		Stack:   "stack",
+	}
+	samples := [][]zap.Field{
+		nil,
+		{zap.Bool("b", true), zap.Int("i", -1), zap.Uint("u", 1), zap.Uintptr("p", 2)},
+		{zap.Float32("f32", 1), zap.Float64("f64", 2), zap.String("s", "str"), zap.ByteString("bs", []byte("x"))},
+		{zap.Binary("bin", []byte{0xff}), zap.RawJSON("raw", []byte("{}")), zap.Nest("obj", zap.Int("n", 1))},
+		{zap.Strings("arr", []string{"a", "b"}), zap.Namespace("ns"), zap.Int("in", 1)},
+	}
+	for _, fields := range samples {
+		stream = append(stream, encode(t, ent, fields...)...)
+		boundaries = append(boundaries, len(stream))
+	}
+	return stream, boundaries
+}
+
+func TestDecodeTruncatedStreams(t *testing.T) {
+	stream, boundaries := sampleStream(t)
+	all, end, ok := decodeAll(stream)
+	require.True(t, ok, "Decoder didn't finish.")
+	require.Equal(t, io.EOF, end, "Unexpected end of a complete stream.")
+	require.Len(t, all, len(boundaries), "Unexpected number of entries.")
+
+	complete := 0
+	for i := 0; i < len(stream); i++ {
+		for complete < len(boundaries) && boundaries[complete] <= i {
+			complete++
+		}
+		ents, end, ok := decodeAll(stream[:i])
+		if !assert.True(t, ok, "Decoder didn't finish a stream truncated to %v bytes.", i) {
+			continue
+		}
+		if complete == 0 {
+			assert.Empty(t, ents, "Unexpected entries in a stream truncated to %v bytes.", i)
+		} else {
+			assert.Equal(t, all[:complete], ents, "Unexpected entries in a stream truncated to %v bytes.", i)
+		}
+		if i == 0 || (complete > 0 && boundaries[complete-1] == i) {
+			assert.Equal(t, io.EOF, end, "Unexpected end of a stream truncated at an entry boundary.")
+		} else {
+			assert.Equal(t, io.ErrUnexpectedEOF, end, "Unexpected end of a stream truncated to %v bytes.", i)
+		}
+	}
+}
+
+func TestDecodeCorruptStreams(t *testing.T) {
+	stream, _ := sampleStream(t)
+	r := rand.New(rand.NewSource(1))
+	corrupt := make([]byte, len(stream))
+	for i := 0; i < 2000; i++ {
+		copy(corrupt, stream)
+		for j := r.Intn(4); j >= 0; j-- {
+			corrupt[r.Intn(len(corrupt))] = byte(r.Intn(256))
+		}
+		_, _, ok := decodeAll(corrupt)
+		assert.True(t, ok, "Decoder didn't finish a corrupt stream: %x", corrupt)
+	}
+}
+
+func TestDecodeRandomStreams(t *testing.T) {
+	finishes := func(data []byte) bool {
+		_, _, ok := decodeAll(data)
+		return ok
+	}
+	assert.NoError(t, quick.Check(finishes, &quick.Config{MaxCountScale: 10.0}), "Decoder didn't finish a random stream.")
+}
+
+func TestRoundTripQuick(t *testing.T) {
+	roundTrips := func(msg, key, s string, i int64, u uint64, f float64, b []byte) bool {
+		ents, _, _ := decodeAll(encode(t, zap.Entry{Message: msg},
+			zap.String(key, s),
+			zap.Int64(key, i),
+			zap.Uint64(key, u),
+			zap.Float64(key, f),
+			zap.Binary(key, b),
+		))
+		if len(ents) != 1 || ents[0].Message != msg || len(ents[0].Fields) != 5 {
+			return false
+		}
+		fs := ents[0].Fields
+		for _, f := range fs {
+			if f.Key != key {
+				return false
+			}
+		}
+		return string(fs[0].Bytes) == s &&
+			fs[1].Int == i &&
+			fs[2].Uint == u &&
+			math.Float64bits(fs[3].Float) == math.Float64bits(f) &&
+			bytes.Equal(fs[4].Bytes, b)
+	}
+	assert.NoError(t, quick.Check(roundTrips, nil), "Expected values to survive a round trip.")
+}
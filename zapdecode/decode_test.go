@@ -0,0 +1,394 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapdecode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+// encode writes an entry with the supplied fields using the binary encoder.
+func encode(t testing.TB, ent zap.Entry, fields ...zap.Field) []byte {
+	enc := zap.NewBinaryEncoder()
+	defer enc.Free()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, enc.WriteEntry(buf, ent), "Unexpected error encoding an entry.")
+	return buf.Bytes()
+}
+
+// decodeOne decodes a stream that should contain exactly one entry.
+func decodeOne(t testing.TB, data []byte) Entry {
+	dec := NewDecoder(bytes.NewReader(data))
+	ent, err := dec.Decode()
+	require.NoError(t, err, "Unexpected error decoding an entry.")
+	_, err = dec.Decode()
+	assert.Equal(t, io.EOF, err, "Expected the stream to end after one entry.")
+	return ent
+}
+
+func TestRoundTripEntry(t *testing.T) {
+	ts := time.Unix(1475000000, 123456789)
+	tests := []zap.Entry{
+		{Level: zap.InfoLevel, Time: ts, Message: "hello"},
+		{Level: zap.DebugLevel, Message: ""},
+		{Level: zap.TraceLevel, Time: time.Unix(-1, 0), Message: "before epoch"},
+		{Level: zap.FatalLevel, Time: ts, Message: "multi\nline", Stack: "goroutine 1 [running]:\nmain.main()"},
+		{
+			Level:   zap.ErrorLevel,
+			Time:    ts,
+			Message: "with caller",
+			Caller:  zap.EntryCaller{Defined: true, PC: 0x4a5b6c, File: "/src/main.go", Line: 42},
+		},
+	}
+
+	for _, ent := range tests {
+		got := decodeOne(t, encode(t, ent))
+		assert.Equal(t, ent.Level, got.Level, "Unexpected level for entry %q.", ent.Message)
+		assert.True(t, ent.Time.Equal(got.Time), "Unexpected time for entry %q: got %v.", ent.Message, got.Time)
+		assert.Equal(t, ent.Message, got.Message, "Unexpected message.")
+		assert.Equal(t, ent.Caller, got.Caller, "Unexpected caller for entry %q.", ent.Message)
+		assert.Equal(t, ent.Stack, got.Stack, "Unexpected stack for entry %q.", ent.Message)
+		assert.Nil(t, got.Fields, "Expected no fields for entry %q.", ent.Message)
+	}
+}
+
+func TestRoundTripFields(t *testing.T) {
+	ints := zap.Ints("ints", []int{1, -2})
+	tests := []struct {
+		field    zap.Field
+		expected Field
+	}{
+		{zap.Bool("k", true), Field{Key: "k", Type: BoolType, Bool: true}},
+		{zap.Bool("k", false), Field{Key: "k", Type: BoolType}},
+		{zap.Int("k", -42), Field{Key: "k", Type: Int64Type, Int: -42}},
+		{zap.Int64("k", math.MinInt64), Field{Key: "k", Type: Int64Type, Int: math.MinInt64}},
+		{zap.Int64("k", math.MaxInt64), Field{Key: "k", Type: Int64Type, Int: math.MaxInt64}},
+		{zap.Duration("k", time.Second), Field{Key: "k", Type: Int64Type, Int: int64(time.Second)}},
+		{zap.Uint("k", 42), Field{Key: "k", Type: Uint64Type, Uint: 42}},
+		{zap.Uint64("k", math.MaxUint64), Field{Key: "k", Type: Uint64Type, Uint: math.MaxUint64}},
+		{zap.Uintptr("k", 0xdeadbeef), Field{Key: "k", Type: UintptrType, Uint: 0xdeadbeef}},
+		{zap.Float32("k", 1.5), Field{Key: "k", Type: Float32Type, Float: 1.5}},
+		{zap.Float64("k", -math.MaxFloat64), Field{Key: "k", Type: Float64Type, Float: -math.MaxFloat64}},
+		{zap.Float64("k", math.Inf(1)), Field{Key: "k", Type: Float64Type, Float: math.Inf(1)}},
+		{zap.Time("k", time.Unix(1, 500000000)), Field{Key: "k", Type: Float64Type, Float: 1.5}},
+		{zap.String("k", "héllo\n"), Field{Key: "k", Type: StringType, Bytes: []byte("héllo\n")}},
+		{zap.String("k", ""), Field{Key: "k", Type: StringType, Bytes: []byte{}}},
+		{zap.String("", "no key"), Field{Type: StringType, Bytes: []byte("no key")}},
+		{zap.Stringer("k", zap.WarnLevel), Field{Key: "k", Type: StringType, Bytes: []byte("warn")}},
+		{zap.Error(errors.New("fail")), Field{Key: "error", Type: StringType, Bytes: []byte("fail")}},
+		{zap.ByteString("k", []byte("bytes")), Field{Key: "k", Type: ByteStringType, Bytes: []byte("bytes")}},
+		{zap.Binary("k", []byte{0, 1, 0xff}), Field{Key: "k", Type: BinaryType, Bytes: []byte{0, 1, 0xff}}},
+		{zap.RawJSON("k", []byte(`{"a":[1]}`)), Field{Key: "k", Type: RawJSONType, Bytes: []byte(`{"a":[1]}`)}},
+		{zap.Object("k", user{"jane"}), Field{Key: "k", Type: RawJSONType, Bytes: []byte(`{"name":"jane"}`)}},
+		{zap.Object("k", nil), Field{Key: "k", Type: RawJSONType, Bytes: []byte(`null`)}},
+		{
+			zap.Nest("k", zap.Int("a", 1), zap.Namespace("ns"), zap.String("b", "c")),
+			Field{Key: "k", Type: ObjectType, Fields: []Field{
+				{Key: "a", Type: Int64Type, Int: 1},
+				{Key: "ns", Type: NamespaceType, Fields: []Field{
+					{Key: "b", Type: StringType, Bytes: []byte("c")},
+				}},
+			}},
+		},
+		{zap.Nest("k"), Field{Key: "k", Type: ObjectType}},
+		{
+			ints,
+			Field{Key: "ints", Type: ArrayType, Fields: []Field{
+				{Type: Int64Type, Int: 1},
+				{Type: Int64Type, Int: -2},
+			}},
+		},
+		{
+			zap.Array("k", zap.ArrayMarshalerFunc(func(arr zap.ArrayEncoder) error {
+				arr.AppendBool(true)
+				arr.AppendByteString([]byte("b"))
+				arr.AppendFloat64(2.5)
+				arr.AppendUint64(7)
+				arr.AppendString("s")
+				arr.AppendObject(user{"joe"})
+				arr.AppendArray(zap.ArrayMarshalerFunc(func(arr zap.ArrayEncoder) error {
+					arr.AppendString("inner")
+					return nil
+				}))
+				return arr.AppendMarshaler(zap.LogMarshalerFunc(func(kv zap.KeyValue) error {
+					return kv.AddArray("nested", zap.ArrayMarshalerFunc(func(zap.ArrayEncoder) error { return nil }))
+				}))
+			})),
+			Field{Key: "k", Type: ArrayType, Fields: []Field{
+				{Type: BoolType, Bool: true},
+				{Type: ByteStringType, Bytes: []byte("b")},
+				{Type: Float64Type, Float: 2.5},
+				{Type: Uint64Type, Uint: 7},
+				{Type: StringType, Bytes: []byte("s")},
+				{Type: RawJSONType, Bytes: []byte(`{"name":"joe"}`)},
+				{Type: ArrayType, Fields: []Field{{Type: StringType, Bytes: []byte("inner")}}},
+				{Type: ObjectType, Fields: []Field{{Key: "nested", Type: ArrayType}}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		ent := decodeOne(t, encode(t, zap.Entry{Message: "fields"}, tt.field))
+		if assert.Len(t, ent.Fields, 1, "Expected one field for %+v.", tt.field) {
+			assert.Equal(t, tt.expected, ent.Fields[0], "Unexpected decoded field for %+v.", tt.field)
+		}
+	}
+}
+
+func TestRoundTripNamespaces(t *testing.T) {
+	// Namespaces opened before the entry is written stay open, so they
+	// contain all the subsequent fields, including fields added to clones.
+	enc := zap.NewBinaryEncoder()
+	defer enc.Free()
+	enc.AddString("before", "ns")
+	enc.OpenNamespace("outer")
+	enc.AddInt("a", 1)
+	clone := enc.Clone()
+	defer clone.Free()
+	clone.OpenNamespace("inner")
+	clone.AddInt("b", 2)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, clone.WriteEntry(buf, zap.Entry{Message: "namespaces"}), "Unexpected error encoding an entry.")
+	require.NoError(t, enc.WriteEntry(buf, zap.Entry{Message: "parent"}), "Unexpected error encoding an entry.")
+
+	dec := NewDecoder(buf)
+	ent, err := dec.Decode()
+	require.NoError(t, err, "Unexpected error decoding an entry.")
+	assert.Equal(t, []Field{
+		{Key: "before", Type: StringType, Bytes: []byte("ns")},
+		{Key: "outer", Type: NamespaceType, Fields: []Field{
+			{Key: "a", Type: Int64Type, Int: 1},
+			{Key: "inner", Type: NamespaceType, Fields: []Field{
+				{Key: "b", Type: Int64Type, Int: 2},
+			}},
+		}},
+	}, ent.Fields, "Unexpected fields from the clone.")
+
+	ent, err = dec.Decode()
+	require.NoError(t, err, "Unexpected error decoding an entry.")
+	assert.Equal(t, []Field{
+		{Key: "before", Type: StringType, Bytes: []byte("ns")},
+		{Key: "outer", Type: NamespaceType, Fields: []Field{
+			{Key: "a", Type: Int64Type, Int: 1},
+		}},
+	}, ent.Fields, "Expected cloning not to affect the parent.")
+}
+
+func TestRoundTripThroughJSON(t *testing.T) {
+	// Re-encoding decoded fields with AddTo should produce the same JSON as
+	// encoding the original fields.
+	fields := []zap.Field{
+		zap.Bool("bool", true),
+		zap.Int("int", -1),
+		zap.Uint64("uint64", math.MaxUint64),
+		zap.Uintptr("uintptr", 0xfeed),
+		zap.Float32("float32", 0.1),
+		zap.Float64("float64", 0.1),
+		zap.String("string", `"quoted"`),
+		zap.ByteString("bytestring", []byte("bytes")),
+		zap.Binary("binary", []byte{1, 2, 3}),
+		zap.RawJSON("raw", []byte(`[true, {"x": null}]`)),
+		zap.Object("object", map[string]int{"n": 1}),
+		zap.Strings("strings", []string{"a", "b"}),
+		zap.Array("mixed", zap.ArrayMarshalerFunc(func(arr zap.ArrayEncoder) error {
+			arr.AppendInt64(1)
+			arr.AppendObject(user{"joe"})
+			return arr.AppendMarshaler(zap.LogMarshalerFunc(func(kv zap.KeyValue) error {
+				kv.AddBool("ok", true)
+				return nil
+			}))
+		})),
+		zap.Nest("nest", zap.Int("a", 1)),
+		zap.Namespace("ns"),
+		zap.String("in", "namespace"),
+	}
+	ent := zap.Entry{Level: zap.WarnLevel, Time: time.Unix(0, 0), Message: "json"}
+
+	expected := &bytes.Buffer{}
+	enc := zap.NewJSONEncoder()
+	defer enc.Free()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	require.NoError(t, enc.WriteEntry(expected, ent), "Unexpected error writing the expected JSON.")
+
+	decoded := decodeOne(t, encode(t, ent, fields...))
+	actual := &bytes.Buffer{}
+	reenc := zap.NewJSONEncoder()
+	defer reenc.Free()
+	for _, f := range decoded.Fields {
+		assert.NoError(t, f.AddTo(reenc), "Unexpected error re-encoding field %q.", f.Key)
+	}
+	require.NoError(t, reenc.WriteEntry(actual, zap.Entry{
+		Level:   decoded.Level,
+		Time:    decoded.Time,
+		Message: decoded.Message,
+	}), "Unexpected error writing the decoded entry as JSON.")
+	assert.Equal(t, expected.String(), actual.String(), "Expected re-encoded output to match.")
+}
+
+func TestRoundTripLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := zap.New(zap.NewBinaryEncoder(), zap.Output(zap.AddSync(buf)), zap.RecordCaller(), zap.DebugLevel)
+	logger = logger.With(zap.String("service", "api"))
+	logger.Info("first", zap.Int("n", 1))
+	logger.Debug("second")
+
+	dec := NewDecoder(buf)
+	first, err := dec.Decode()
+	require.NoError(t, err, "Unexpected error decoding the first entry.")
+	assert.Equal(t, "first", first.Message, "Unexpected message.")
+	assert.Equal(t, zap.InfoLevel, first.Level, "Unexpected level.")
+	assert.True(t, first.Caller.Defined, "Expected the caller to be recorded.")
+	assert.Contains(t, first.Caller.File, "decode_test.go", "Unexpected caller.")
+	assert.Equal(t, []Field{
+		{Key: "service", Type: StringType, Bytes: []byte("api")},
+		{Key: "n", Type: Int64Type, Int: 1},
+	}, first.Fields, "Unexpected fields.")
+
+	second, err := dec.Decode()
+	require.NoError(t, err, "Unexpected error decoding the second entry.")
+	assert.Equal(t, "second", second.Message, "Unexpected message.")
+	assert.Equal(t, zap.DebugLevel, second.Level, "Unexpected level.")
+
+	_, err = dec.Decode()
+	assert.Equal(t, io.EOF, err, "Expected the stream to end.")
+}
+
+// rawField builds an encoded Field message from raw protobuf bytes.
+func rawField(contents ...byte) []byte {
+	return append([]byte{7<<3 | 2, byte(len(contents))}, contents...)
+}
+
+func frame(body []byte) []byte {
+	return append([]byte{byte(len(body))}, body...)
+}
+
+func TestUnknownFieldTypes(t *testing.T) {
+	// A field of type 99 whose value slot uses a wire type that the known
+	// types don't.
+	unknown := []byte{
+		1<<3 | 2, 1, 'u', // key "u"
+		2<<3 | 0, 99, // type 99
+		6<<3 | 2, 2, 'h', 'i', // number 6 as bytes
+	}
+	body := append(rawField(unknown...), rawField(1<<3|2, 1, 'k', 2<<3|0, byte(BoolType), 3<<3|0, 1)...)
+	// Unknown entry-level numbers are skipped.
+	body = append(body, 15<<3|5, 1, 2, 3, 4)
+
+	ent := decodeOne(t, frame(body))
+	require.Len(t, ent.Fields, 2, "Unexpected number of fields.")
+	assert.Equal(t, Field{Key: "u", Type: Type(99), Raw: unknown}, ent.Fields[0], "Expected the unknown field to be preserved.")
+	assert.Equal(t, "Type(99)", ent.Fields[0].Type.String(), "Unexpected name for an unknown type.")
+	assert.Equal(t, Field{Key: "k", Type: BoolType, Bool: true}, ent.Fields[1], "Unexpected known field.")
+
+	enc := zap.NewJSONEncoder(zap.NoTime())
+	defer enc.Free()
+	for _, f := range ent.Fields {
+		assert.NoError(t, f.AddTo(enc), "Unexpected error adding a decoded field.")
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, enc.WriteEntry(buf, zap.Entry{Level: zap.InfoLevel}), "Unexpected error writing JSON.")
+	assert.Equal(t, `{"level":"info","msg":"","u":"CgF1EGMyAmhp","k":true}`+"\n", buf.String(), "Expected unknown fields to be added as binary.")
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := encode(t, zap.Entry{Message: "ok"})
+	tests := []struct {
+		desc   string
+		stream []byte
+		err    string
+	}{
+		{"truncated prefix", []byte{0x80}, io.ErrUnexpectedEOF.Error()},
+		{"truncated body", valid[:len(valid)-1], io.ErrUnexpectedEOF.Error()},
+		{"truncated tag", frame([]byte{0x80}), "truncated message"},
+		{"truncated field", frame([]byte{7<<3 | 2, 10, 0}), "truncated message"},
+		{"truncated double", frame(rawField(2<<3|0, byte(Float64Type), 6<<3|1, 1, 2)), "truncated message"},
+		{"overflowing varint", frame([]byte{2 << 3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}), "varint overflows a 64-bit integer"},
+		{"group wire type", frame([]byte{9<<3 | 3}), "unsupported wire type 3"},
+		{"oversized entry", []byte{0x81, 0x80, 0x80, 0x20}, io.ErrUnexpectedEOF.Error()},
+	}
+
+	for _, tt := range tests {
+		_, err := NewDecoder(bytes.NewReader(tt.stream)).Decode()
+		if assert.Error(t, err, "Expected an error decoding a stream with a %s.", tt.desc) {
+			assert.Equal(t, tt.err, err.Error(), "Unexpected error decoding a stream with a %s.", tt.desc)
+		}
+	}
+}
+
+func TestDecodeKeepsGoingAfterBadEntries(t *testing.T) {
+	var stream []byte
+	stream = append(stream, frame([]byte{0x80})...)
+	// An oversized entry whose contents are present is skipped.
+	defer func(max uint64) { _maxEntrySize = max }(_maxEntrySize)
+	_maxEntrySize = 8
+	stream = append(stream, 9, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	stream = append(stream, encode(t, zap.Entry{Message: "ok"})...)
+
+	dec := NewDecoder(bytes.NewReader(stream))
+	_, err := dec.Decode()
+	assert.Error(t, err, "Expected an error decoding a malformed entry.")
+	_, err = dec.Decode()
+	if assert.Error(t, err, "Expected an error decoding an oversized entry.") {
+		assert.Contains(t, err.Error(), "entry of 9 bytes exceeds the 8 byte limit", "Unexpected error for an oversized entry.")
+	}
+	ent, err := dec.Decode()
+	require.NoError(t, err, "Expected to decode the entry after the bad ones.")
+	assert.Equal(t, "ok", ent.Message, "Unexpected message.")
+}
+
+func TestDecodeDepthLimit(t *testing.T) {
+	body := rawField(2<<3|0, byte(Int64Type))
+	for i := 0; i <= _maxDepth; i++ {
+		contents := append([]byte{2<<3 | 0, byte(ObjectType)}, body...)
+		body = append([]byte{7<<3 | 2}, appendLen(contents)...)
+	}
+	_, err := Unmarshal(body)
+	assert.Equal(t, errTooDeep, err, "Expected an error decoding deeply nested fields.")
+}
+
+func appendLen(contents []byte) []byte {
+	var prefix []byte
+	n := uint64(len(contents))
+	for n >= 0x80 {
+		prefix = append(prefix, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(append(prefix, byte(n)), contents...)
+}
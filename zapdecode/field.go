@@ -0,0 +1,300 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapdecode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/uber-go/zap"
+)
+
+// A Type identifies the kind of value a Field holds.
+type Type int
+
+// The field types written by the binary encoder. Values added with AddInt and
+// AddUint are decoded as Int64Type and Uint64Type.
+const (
+	BoolType Type = iota + 1
+	Int64Type
+	Uint64Type
+	UintptrType
+	Float32Type
+	Float64Type
+	StringType
+	ByteStringType
+	BinaryType
+	RawJSONType
+	ObjectType
+	ArrayType
+	NamespaceType
+)
+
+// String returns a lower-case name for the type.
+func (t Type) String() string {
+	switch t {
+	case BoolType:
+		return "bool"
+	case Int64Type:
+		return "int64"
+	case Uint64Type:
+		return "uint64"
+	case UintptrType:
+		return "uintptr"
+	case Float32Type:
+		return "float32"
+	case Float64Type:
+		return "float64"
+	case StringType:
+		return "string"
+	case ByteStringType:
+		return "bytestring"
+	case BinaryType:
+		return "binary"
+	case RawJSONType:
+		return "rawjson"
+	case ObjectType:
+		return "object"
+	case ArrayType:
+		return "array"
+	case NamespaceType:
+		return "namespace"
+	default:
+		return fmt.Sprintf("Type(%d)", t)
+	}
+}
+
+func (t Type) known() bool {
+	return t >= BoolType && t <= NamespaceType
+}
+
+// A Field is a decoded field. Only the value slot that matches its Type is
+// set: Bool, Int, Uint, or Float for scalars (including Uintptr and Float32
+// values), Bytes for strings, byte strings, binary data, and raw JSON, and
+// Fields for the members of objects, arrays, and namespaces. Array elements
+// have empty keys.
+type Field struct {
+	Key    string
+	Type   Type
+	Bool   bool
+	Int    int64
+	Uint   uint64
+	Float  float64
+	Bytes  []byte
+	Fields []Field
+	// Raw holds the complete encoded field if its Type isn't one of the types
+	// above, so that it can be passed along unaltered.
+	Raw []byte
+}
+
+func decodeField(data []byte, depth int) (Field, error) {
+	if depth > _maxDepth {
+		return Field{}, errTooDeep
+	}
+	key, typ, err := scanField(data)
+	if err != nil {
+		return Field{}, err
+	}
+	f := Field{Key: key, Type: typ}
+	if !typ.known() {
+		f.Raw = append([]byte(nil), data...)
+		return f, nil
+	}
+
+	msg := message{data}
+	for !msg.done() {
+		num, wire, err := msg.tag()
+		if err != nil {
+			return Field{}, err
+		}
+		switch {
+		case num == 3 && wire == _varint:
+			n, err := msg.uvarint()
+			if err != nil {
+				return Field{}, err
+			}
+			f.Bool = n != 0
+		case num == 4 && wire == _varint:
+			if f.Int, err = msg.sint(); err != nil {
+				return Field{}, err
+			}
+		case num == 5 && wire == _varint:
+			if f.Uint, err = msg.uvarint(); err != nil {
+				return Field{}, err
+			}
+		case num == 6 && wire == _fixed64:
+			if f.Float, err = msg.double(); err != nil {
+				return Field{}, err
+			}
+		case num == 7 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return Field{}, err
+			}
+			member, err := decodeField(b, depth+1)
+			if err != nil {
+				return Field{}, err
+			}
+			f.Fields = append(f.Fields, member)
+		case num == 8 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return Field{}, err
+			}
+			f.Bytes = append([]byte{}, b...)
+		default:
+			if err := msg.skip(wire); err != nil {
+				return Field{}, err
+			}
+		}
+	}
+	return f, nil
+}
+
+// scanField finds a field's key and type, skipping everything else, so that
+// fields of unknown types can be preserved without interpreting their values.
+func scanField(data []byte) (string, Type, error) {
+	var (
+		key string
+		typ Type
+	)
+	msg := message{data}
+	for !msg.done() {
+		num, wire, err := msg.tag()
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case num == 1 && wire == _bytes:
+			b, err := msg.bytes()
+			if err != nil {
+				return "", 0, err
+			}
+			key = string(b)
+		case num == 2 && wire == _varint:
+			n, err := msg.uvarint()
+			if err != nil {
+				return "", 0, err
+			}
+			typ = Type(n)
+		default:
+			if err := msg.skip(wire); err != nil {
+				return "", 0, err
+			}
+		}
+	}
+	return key, typ, nil
+}
+
+// AddTo adds the field to a zap KeyValue, which re-encodes decoded entries
+// (for example, as JSON). Fields of unknown types are added as binary data
+// holding their raw encoding. It returns the first error encountered.
+func (f Field) AddTo(kv zap.KeyValue) error {
+	switch f.Type {
+	case BoolType:
+		kv.AddBool(f.Key, f.Bool)
+	case Int64Type:
+		kv.AddInt64(f.Key, f.Int)
+	case Uint64Type:
+		kv.AddUint64(f.Key, f.Uint)
+	case UintptrType:
+		kv.AddUintptr(f.Key, uintptr(f.Uint))
+	case Float32Type:
+		kv.AddFloat32(f.Key, float32(f.Float))
+	case Float64Type:
+		kv.AddFloat64(f.Key, f.Float)
+	case StringType:
+		kv.AddString(f.Key, string(f.Bytes))
+	case ByteStringType:
+		kv.AddByteString(f.Key, f.Bytes)
+	case BinaryType:
+		kv.AddBinary(f.Key, f.Bytes)
+	case RawJSONType:
+		return kv.AddRawJSON(f.Key, f.Bytes)
+	case ObjectType:
+		return kv.AddMarshaler(f.Key, members(f.Fields))
+	case ArrayType:
+		return kv.AddArray(f.Key, elements(f.Fields))
+	case NamespaceType:
+		kv.OpenNamespace(f.Key)
+		return members(f.Fields).MarshalLog(kv)
+	default:
+		kv.AddBinary(f.Key, f.Raw)
+	}
+	return nil
+}
+
+// appendTo adds the field to a zap ArrayEncoder. Elements of types without a
+// corresponding Append method are converted to the closest type that has
+// one.
+func (f Field) appendTo(arr zap.ArrayEncoder) error {
+	switch f.Type {
+	case BoolType:
+		arr.AppendBool(f.Bool)
+	case Int64Type:
+		arr.AppendInt64(f.Int)
+	case Uint64Type, UintptrType:
+		arr.AppendUint64(f.Uint)
+	case Float32Type:
+		arr.AppendFloat32(float32(f.Float))
+	case Float64Type:
+		arr.AppendFloat64(f.Float)
+	case StringType:
+		arr.AppendString(string(f.Bytes))
+	case ByteStringType, BinaryType:
+		arr.AppendByteString(f.Bytes)
+	case RawJSONType:
+		return arr.AppendObject(json.RawMessage(f.Bytes))
+	case ObjectType, NamespaceType:
+		return arr.AppendMarshaler(members(f.Fields))
+	case ArrayType:
+		return arr.AppendArray(elements(f.Fields))
+	default:
+		arr.AppendByteString(f.Raw)
+	}
+	return nil
+}
+
+// members adapts the members of an object to zap's LogMarshaler interface.
+type members []Field
+
+func (fs members) MarshalLog(kv zap.KeyValue) error {
+	var first error
+	for _, f := range fs {
+		if err := f.AddTo(kv); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// elements adapts the elements of an array to zap's ArrayMarshaler interface.
+type elements []Field
+
+func (fs elements) MarshalLogArray(arr zap.ArrayEncoder) error {
+	var first error
+	for _, f := range fs {
+		if err := f.appendTo(arr); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
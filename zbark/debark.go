@@ -32,6 +32,11 @@ import (
 type zapperBarkFields zwrap.KeyValueMap
 
 // Debarkify wraps bark.Logger to make it compatible with zap's JSON logger
+//
+// Bark can't log at PanicLevel or FatalLevel without panicking or exiting, so
+// the returned Logger's Log method terminates at those levels too. Avoid
+// combining it with other loggers using zap.Tee, since it may terminate the
+// process before the Tee's other sub-loggers have received the message.
 func Debarkify(bl bark.Logger, lvl zap.Level) zap.Logger {
 	if wrapper, ok := bl.(*barker); ok {
 		return wrapper.zl
@@ -59,7 +64,8 @@ func (z *zapper) Log(l zap.Level, msg string, fields ...zap.Field) {
 	}
 	bl := z.bl.WithFields(zapToBark(fields))
 	switch l {
-	case zap.DebugLevel:
+	case zap.TraceLevel, zap.DebugLevel:
+		// Bark has no Trace level.
 		bl.Debug(msg)
 	case zap.InfoLevel:
 		bl.Info(msg)
@@ -90,6 +96,10 @@ func (z *zapper) Check(l zap.Level, msg string) *zap.CheckedMessage {
 	return z.Meta.Check(z, l, msg)
 }
 
+func (z *zapper) Trace(msg string, fields ...zap.Field) {
+	z.Log(zap.TraceLevel, msg, fields...)
+}
+
 func (z *zapper) Debug(msg string, fields ...zap.Field) {
 	z.Log(zap.DebugLevel, msg, fields...)
 }
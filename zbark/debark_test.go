@@ -145,9 +145,10 @@ func TestDebark_LeveledLogging(t *testing.T) {
 }
 
 func TestDebark_Methods(t *testing.T) {
-	logger, buf := newDebark(zap.DebugLevel)
+	logger, buf := newDebark(zap.TraceLevel)
 
 	funcs := []func(string, ...zap.Field){
+		logger.Trace,
 		logger.Debug,
 		logger.Info,
 		logger.Warn,
@@ -0,0 +1,321 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zkafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"github.com/uber-go/atomic"
+)
+
+const (
+	_defaultBufferSize   = 1024
+	_defaultFlushTimeout = 5 * time.Second
+)
+
+var (
+	errClosed     = errors.New("Kafka sink is closed")
+	errBufferFull = errors.New("Kafka sink's buffer is full, dropping entry")
+)
+
+// A Producer publishes messages to Kafka. Produce should block until the
+// message is delivered (or delivery fails); the Sink calls it from a single
+// background goroutine, so it needn't be safe for concurrent use. The Sink
+// doesn't retain or modify the key and value after Produce returns.
+type Producer interface {
+	Produce(topic string, key, value []byte) error
+}
+
+// A KeyFunc chooses the Kafka message key, which determines the partition,
+// for an encoded entry. Returning nil leaves the message without a key. For
+// plain writes that don't carry an entry, the entry is the zero value.
+type KeyFunc func(ent zap.Entry, value []byte) []byte
+
+// FieldKey returns a KeyFunc that uses the value of the named top-level field
+// as the message key. It expects entries encoded as JSON, and it leaves the
+// key empty if the field is missing. String values are used without their
+// quotes; other values are used as encoded.
+func FieldKey(name string) KeyFunc {
+	return func(_ zap.Entry, value []byte) []byte {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil {
+			return nil
+		}
+		raw, ok := fields[name]
+		if !ok {
+			return nil
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return []byte(s)
+		}
+		return []byte(raw)
+	}
+}
+
+// An Option configures a Sink.
+type Option interface {
+	apply(*Sink)
+}
+
+type optionFunc func(*Sink)
+
+func (f optionFunc) apply(s *Sink) {
+	f(s)
+}
+
+// LevelTopic publishes entries whose level is enabled by enab to the given
+// topic instead of the default. For example, LevelTopic(zap.ErrorLevel,
+// "logs-errors") routes errors and more severe entries to a separate topic.
+// If several LevelTopics match an entry, the first one wins.
+func LevelTopic(enab zap.LevelEnabler, topic string) Option {
+	return optionFunc(func(s *Sink) {
+		s.levelTopics = append(s.levelTopics, levelTopic{enab, topic})
+	})
+}
+
+// Key sets the function used to choose each message's key. By default,
+// messages don't have keys.
+func Key(f KeyFunc) Option {
+	return optionFunc(func(s *Sink) {
+		s.key = f
+	})
+}
+
+// BufferSize sets the number of messages the Sink buffers while waiting for
+// delivery; once the buffer is full, further entries are dropped. The default
+// is 1024.
+func BufferSize(n int) Option {
+	return optionFunc(func(s *Sink) {
+		s.bufferSize = n
+	})
+}
+
+// FlushTimeout bounds how long Sync and Close wait for buffered messages to
+// be delivered. The default is five seconds.
+func FlushTimeout(d time.Duration) Option {
+	return optionFunc(func(s *Sink) {
+		s.flushTimeout = d
+	})
+}
+
+// ErrorOutput sets the destination for reports of failed deliveries, which
+// happen in the background and so can't be returned to the logger. It must be
+// safe for concurrent use. The default is standard error.
+func ErrorOutput(ws zap.WriteSyncer) Option {
+	return optionFunc(func(s *Sink) {
+		s.errorOutput = ws
+	})
+}
+
+type levelTopic struct {
+	enab  zap.LevelEnabler
+	topic string
+}
+
+type message struct {
+	topic      string
+	key, value []byte
+	// If set, the message is a flush marker rather than an entry, and the
+	// channel is closed when it's dequeued.
+	flushed chan struct{}
+}
+
+// A Sink is a zap.WriteSyncer that asynchronously publishes each entry it's
+// given as a Kafka message. Writes never block: entries are buffered and
+// delivered by a background goroutine, and entries that arrive when the
+// buffer is full are dropped and reported as write errors. Since it
+// implements zap.EntryWriteSyncer, a Sink used as a Logger's Output can route
+// entries by level.
+//
+// Each entry is published without its trailing newline. Sinks are safe for
+// concurrent use.
+type Sink struct {
+	producer     Producer
+	topic        string
+	levelTopics  []levelTopic
+	key          KeyFunc
+	bufferSize   int
+	flushTimeout time.Duration
+	errorOutput  zap.WriteSyncer
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan message
+	done   chan struct{}
+
+	dropped *atomic.Uint64
+	failed  *atomic.Uint64
+}
+
+// New creates a Sink that publishes entries to the given topic (unless a
+// LevelTopic option routes them elsewhere) and starts its background
+// delivery goroutine. Close the Sink to stop the goroutine.
+func New(p Producer, topic string, opts ...Option) *Sink {
+	s := &Sink{
+		producer:     p,
+		topic:        topic,
+		bufferSize:   _defaultBufferSize,
+		flushTimeout: _defaultFlushTimeout,
+		errorOutput:  zap.AddSync(os.Stderr),
+		done:         make(chan struct{}),
+		dropped:      atomic.NewUint64(0),
+		failed:       atomic.NewUint64(0),
+	}
+	for _, opt := range opts {
+		opt.apply(s)
+	}
+	s.queue = make(chan message, s.bufferSize)
+	go s.run()
+	return s
+}
+
+// Write publishes an encoded entry to the default topic.
+func (s *Sink) Write(p []byte) (int, error) {
+	return s.write(zap.Entry{}, s.topic, p)
+}
+
+// WriteEntry publishes an encoded entry to the topic for its level.
+func (s *Sink) WriteEntry(ent zap.Entry, p []byte) (int, error) {
+	return s.write(ent, s.topicFor(ent.Level), p)
+}
+
+func (s *Sink) topicFor(lvl zap.Level) string {
+	for _, lt := range s.levelTopics {
+		if lt.enab.Enabled(lvl) {
+			return lt.topic
+		}
+	}
+	return s.topic
+}
+
+func (s *Sink) write(ent zap.Entry, topic string, p []byte) (int, error) {
+	// The caller may reuse p, so copy it.
+	value := p
+	if n := len(value); n > 0 && value[n-1] == '\n' {
+		value = value[:n-1]
+	}
+	value = append([]byte(nil), value...)
+	msg := message{topic: topic, value: value}
+	if s.key != nil {
+		msg.key = s.key(ent, value)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return 0, errClosed
+	}
+	select {
+	case s.queue <- msg:
+		return len(p), nil
+	default:
+		s.dropped.Inc()
+		return 0, errBufferFull
+	}
+}
+
+// Sync waits for all the entries written so far to be delivered, giving up
+// after the FlushTimeout.
+func (s *Sink) Sync() error {
+	timer := time.NewTimer(s.flushTimeout)
+	defer timer.Stop()
+
+	flushed := make(chan struct{})
+	s.mu.RLock()
+	if s.closed {
+		// The queue is closed, so wait for it to drain instead.
+		s.mu.RUnlock()
+		flushed = s.done
+	} else {
+		select {
+		case s.queue <- message{flushed: flushed}:
+		case <-timer.C:
+			s.mu.RUnlock()
+			return s.timeoutError()
+		}
+		s.mu.RUnlock()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-timer.C:
+		return s.timeoutError()
+	}
+}
+
+// Close stops accepting entries and waits for the buffered entries to be
+// delivered, giving up after the FlushTimeout. It's safe to call Close more
+// than once.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.flushTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.done:
+		return nil
+	case <-timer.C:
+		return s.timeoutError()
+	}
+}
+
+// Dropped returns the number of entries dropped because the buffer was full.
+func (s *Sink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Failed returns the number of entries that the Producer failed to deliver.
+func (s *Sink) Failed() uint64 {
+	return s.failed.Load()
+}
+
+func (s *Sink) timeoutError() error {
+	return fmt.Errorf("timed out after %v waiting for %v buffered Kafka messages", s.flushTimeout, len(s.queue))
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	for msg := range s.queue {
+		if msg.flushed != nil {
+			close(msg.flushed)
+			continue
+		}
+		if err := s.producer.Produce(msg.topic, msg.key, msg.value); err != nil {
+			s.failed.Inc()
+			fmt.Fprintf(s.errorOutput, "%v kafka error: failed to publish to topic %q: %v\n", time.Now(), msg.topic, err)
+			s.errorOutput.Sync()
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zkafka
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type produced struct {
+	topic, key, value string
+}
+
+// fakeProducer records the messages it's asked to publish. If block is set,
+// Produce waits for it to be closed first.
+type fakeProducer struct {
+	sync.Mutex
+	msgs  []produced
+	err   error
+	block chan struct{}
+}
+
+func (p *fakeProducer) Produce(topic string, key, value []byte) error {
+	if p.block != nil {
+		<-p.block
+	}
+	p.Lock()
+	defer p.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.msgs = append(p.msgs, produced{topic, string(key), string(value)})
+	return nil
+}
+
+func (p *fakeProducer) Messages() []produced {
+	p.Lock()
+	defer p.Unlock()
+	return append([]produced(nil), p.msgs...)
+}
+
+type syncBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.String()
+}
+
+func (b *syncBuffer) Sync() error {
+	return nil
+}
+
+func withSink(t testing.TB, p Producer, opts []Option, f func(zap.Logger, *Sink, *syncBuffer)) {
+	errBuf := &syncBuffer{}
+	sink := New(p, "logs", append([]Option{ErrorOutput(errBuf)}, opts...)...)
+	logger := zap.New(zap.NewJSONEncoder(zap.NoTime()), zap.Output(sink), zap.ErrorOutput(errBuf))
+	f(logger, sink, errBuf)
+	assert.NoError(t, sink.Close(), "Unexpected error closing sink.")
+}
+
+func TestSinkLevelRouting(t *testing.T) {
+	p := &fakeProducer{}
+	opts := []Option{
+		LevelTopic(zap.ErrorLevel, "logs-errors"),
+		LevelTopic(zap.WarnLevel, "logs-warnings"),
+	}
+	withSink(t, p, opts, func(logger zap.Logger, sink *Sink, errBuf *syncBuffer) {
+		logger.Info("info")
+		logger.Warn("warn")
+		logger.Error("error")
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, []produced{
+			{"logs", "", `{"level":"info","msg":"info"}`},
+			{"logs-warnings", "", `{"level":"warn","msg":"warn"}`},
+			{"logs-errors", "", `{"level":"error","msg":"error"}`},
+		}, p.Messages(), "Unexpected messages published.")
+		assert.NotContains(t, errBuf.String(), " error: ", "Unexpected errors reported.")
+	})
+}
+
+func TestSinkPlainWrites(t *testing.T) {
+	p := &fakeProducer{}
+	withSink(t, p, []Option{LevelTopic(zap.DebugLevel, "unused")}, func(_ zap.Logger, sink *Sink, _ *syncBuffer) {
+		n, err := sink.Write([]byte("plain\n"))
+		assert.NoError(t, err, "Unexpected error writing to sink.")
+		assert.Equal(t, 6, n, "Unexpected number of bytes written.")
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, []produced{{"logs", "", "plain"}}, p.Messages(), "Expected plain writes to use the default topic.")
+	})
+}
+
+func TestSinkKeys(t *testing.T) {
+	p := &fakeProducer{}
+	withSink(t, p, []Option{Key(FieldKey("user"))}, func(logger zap.Logger, sink *Sink, _ *syncBuffer) {
+		logger.With(zap.String("user", "alice")).Info("string")
+		logger.Info("number", zap.Int("user", 42))
+		logger.Info("missing")
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		msgs := p.Messages()
+		require.Equal(t, 3, len(msgs), "Unexpected number of messages published.")
+		assert.Equal(t, "alice", msgs[0].key, "Expected string fields to be unquoted.")
+		assert.Equal(t, "42", msgs[1].key, "Expected other fields to be used as encoded.")
+		assert.Equal(t, "", msgs[2].key, "Expected no key when the field is missing.")
+	})
+
+	byLevel := func(ent zap.Entry, _ []byte) []byte { return []byte(ent.Level.String()) }
+	p = &fakeProducer{}
+	withSink(t, p, []Option{Key(byLevel)}, func(logger zap.Logger, sink *Sink, _ *syncBuffer) {
+		logger.Warn("")
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, "warn", p.Messages()[0].key, "Expected KeyFuncs to receive the entry.")
+	})
+}
+
+func TestSinkFlush(t *testing.T) {
+	p := &fakeProducer{block: make(chan struct{})}
+	withSink(t, p, []Option{FlushTimeout(10 * time.Millisecond)}, func(logger zap.Logger, sink *Sink, _ *syncBuffer) {
+		logger.Info("one")
+		logger.Info("two")
+		err := sink.Sync()
+		require.Error(t, err, "Expected Sync to time out while the producer is blocked.")
+		assert.Contains(t, err.Error(), "timed out after 10ms", "Unexpected timeout error.")
+		assert.Empty(t, p.Messages(), "Expected no messages before the producer unblocks.")
+
+		close(p.block)
+		sink.flushTimeout = time.Second
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, 2, len(p.Messages()), "Expected Sync to wait for buffered messages.")
+	})
+}
+
+func TestSinkBufferFull(t *testing.T) {
+	p := &fakeProducer{block: make(chan struct{})}
+	withSink(t, p, []Option{BufferSize(1)}, func(logger zap.Logger, sink *Sink, errBuf *syncBuffer) {
+		// The first entry may be dequeued by the delivery goroutine, which then
+		// blocks; either way, the buffer fills up.
+		for i := 0; i < 5; i++ {
+			logger.Info("")
+		}
+		assert.True(t, sink.Dropped() >= 3, "Expected entries to be dropped once the buffer is full.")
+		assert.Contains(t, errBuf.String(), "buffer is full", "Expected drops to be reported.")
+		close(p.block)
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, 5, len(p.Messages())+int(sink.Dropped()), "Expected every entry to be either published or dropped.")
+	})
+}
+
+func TestSinkDeliveryFailures(t *testing.T) {
+	p := &fakeProducer{err: errors.New("broker unavailable")}
+	withSink(t, p, nil, func(logger zap.Logger, sink *Sink, errBuf *syncBuffer) {
+		logger.Info("")
+		logger.Error("")
+		require.NoError(t, sink.Sync(), "Unexpected error syncing sink.")
+		assert.Equal(t, uint64(2), sink.Failed(), "Expected failed deliveries to be counted.")
+		assert.Equal(t, 2, strings.Count(errBuf.String(), `kafka error: failed to publish to topic "logs": broker unavailable`), "Expected failed deliveries to be reported.")
+	})
+}
+
+func TestSinkClose(t *testing.T) {
+	p := &fakeProducer{}
+	sink := New(p, "logs")
+	_, err := sink.Write([]byte("before"))
+	require.NoError(t, err, "Unexpected error writing to sink.")
+	require.NoError(t, sink.Close(), "Unexpected error closing sink.")
+	assert.Equal(t, []produced{{"logs", "", "before"}}, p.Messages(), "Expected Close to flush buffered messages.")
+
+	_, err = sink.Write([]byte("after"))
+	assert.Equal(t, errClosed, err, "Expected writes after Close to fail.")
+	assert.NoError(t, sink.Sync(), "Unexpected error syncing a closed sink.")
+	assert.NoError(t, sink.Close(), "Unexpected error closing sink twice.")
+}
@@ -20,14 +20,28 @@
 
 package zwrap
 
-import "github.com/uber-go/zap"
+import (
+	"encoding/json"
+
+	"github.com/uber-go/zap"
+)
 
 // KeyValueMap implements zap.KeyValue backed by a map.
 type KeyValueMap map[string]interface{}
 
+// AddBinary adds the value under the specified key to the map. The byte slice
+// isn't copied.
+func (m KeyValueMap) AddBinary(k string, v []byte) { m[k] = v }
+
+// AddByteString adds the value under the specified key to the map as a string.
+func (m KeyValueMap) AddByteString(k string, v []byte) { m[k] = string(v) }
+
 // AddBool adds the value under the specified key to the map.
 func (m KeyValueMap) AddBool(k string, v bool) { m[k] = v }
 
+// AddFloat32 adds the value under the specified key to the map.
+func (m KeyValueMap) AddFloat32(k string, v float32) { m[k] = v }
+
 // AddFloat64 adds the value under the specified key to the map.
 func (m KeyValueMap) AddFloat64(k string, v float64) { m[k] = v }
 
@@ -55,14 +69,73 @@ func (m KeyValueMap) AddObject(k string, v interface{}) error {
 // AddString adds the value under the specified key to the map.
 func (m KeyValueMap) AddString(k string, v string) { m[k] = v }
 
+// OpenNamespace is a no-op. Since a KeyValueMap can't track which nested map
+// it's currently adding to, fields added after a namespace is opened are added
+// to the top-level map.
+func (m KeyValueMap) OpenNamespace(k string) {}
+
 // AddMarshaler adds the value under the specified key to the map.
 func (m KeyValueMap) AddMarshaler(k string, v zap.LogMarshaler) error {
 	return m.Nest(k, v.MarshalLog)
 }
 
+// AddArray adds the elements produced by the ArrayMarshaler under the
+// specified key to the map, as a []interface{}.
+func (m KeyValueMap) AddArray(k string, v zap.ArrayMarshaler) error {
+	arr := &sliceArrayEncoder{elems: make([]interface{}, 0)}
+	err := v.MarshalLogArray(arr)
+	m[k] = arr.elems
+	return err
+}
+
+// AddRawJSON adds the value under the specified key to the map as a
+// json.RawMessage. Invalid JSON is added as a string instead, and an error is
+// returned.
+func (m KeyValueMap) AddRawJSON(k string, v []byte) error {
+	var raw json.RawMessage
+	if err := json.Unmarshal(v, &raw); err != nil {
+		m[k] = string(v)
+		return err
+	}
+	m[k] = raw
+	return nil
+}
+
 // Nest builds a object and adds the value under the specified key to the map.
 func (m KeyValueMap) Nest(k string, f func(zap.KeyValue) error) error {
 	newMap := make(KeyValueMap)
 	m[k] = newMap
 	return f(newMap)
 }
+
+// sliceArrayEncoder implements zap.ArrayEncoder backed by a slice.
+type sliceArrayEncoder struct {
+	elems []interface{}
+}
+
+func (s *sliceArrayEncoder) AppendMarshaler(v zap.LogMarshaler) error {
+	m := make(KeyValueMap)
+	err := v.MarshalLog(m)
+	s.elems = append(s.elems, m)
+	return err
+}
+
+func (s *sliceArrayEncoder) AppendArray(v zap.ArrayMarshaler) error {
+	arr := &sliceArrayEncoder{elems: make([]interface{}, 0)}
+	err := v.MarshalLogArray(arr)
+	s.elems = append(s.elems, arr.elems)
+	return err
+}
+
+func (s *sliceArrayEncoder) AppendObject(v interface{}) error {
+	s.elems = append(s.elems, v)
+	return nil
+}
+
+func (s *sliceArrayEncoder) AppendBool(v bool)         { s.elems = append(s.elems, v) }
+func (s *sliceArrayEncoder) AppendByteString(v []byte) { s.elems = append(s.elems, string(v)) }
+func (s *sliceArrayEncoder) AppendFloat32(v float32)   { s.elems = append(s.elems, v) }
+func (s *sliceArrayEncoder) AppendFloat64(v float64)   { s.elems = append(s.elems, v) }
+func (s *sliceArrayEncoder) AppendInt64(v int64)       { s.elems = append(s.elems, v) }
+func (s *sliceArrayEncoder) AppendUint64(v uint64)     { s.elems = append(s.elems, v) }
+func (s *sliceArrayEncoder) AppendString(v string)     { s.elems = append(s.elems, v) }
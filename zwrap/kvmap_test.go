@@ -21,6 +21,7 @@
 package zwrap
 
 import (
+	"encoding/json"
 	"errors"
 	"math"
 	"testing"
@@ -55,6 +56,11 @@ func TestKeyValueMapAdd(t *testing.T) {
 	kv.AddInt64("i64", math.MaxInt64)
 	kv.AddUintptr("uintptr", uintptr(0xdeadbeef))
 	kv.AddString("s", "string")
+	kv.AddBinary("bin", []byte{0xff})
+	kv.AddByteString("bs", []byte("bytes"))
+	assert.NoError(t, kv.AddRawJSON("raw", []byte(`{"foo":1}`)), "AddRawJSON failed")
+	assert.Error(t, kv.AddRawJSON("badraw", []byte(`{"foo":`)), "Expected an error adding invalid raw JSON")
+	zap.Ints("ints", []int{1, 2}).AddTo(kv)
 
 	assert.NoError(t, kv.AddObject("obj", arbitraryObj), "AddObject failed")
 	assert.NoError(t, kv.AddMarshaler("m1", loggable{}), "AddMarshaler failed")
@@ -67,6 +73,11 @@ func TestKeyValueMapAdd(t *testing.T) {
 		"i64":     int64(math.MaxInt64),
 		"uintptr": uintptr(0xdeadbeef),
 		"s":       "string",
+		"bin":     []byte{0xff},
+		"bs":      "bytes",
+		"raw":     json.RawMessage(`{"foo":1}`),
+		"badraw":  `{"foo":`,
+		"ints":    []interface{}{int64(1), int64(2)},
 		"obj":     arbitraryObj,
 		"m1": KeyValueMap{
 			"loggable": "yes",
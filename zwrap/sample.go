@@ -81,7 +81,14 @@ func (c *counters) Reset(key string) {
 //
 // Per-message counts are shared between parent and child loggers, which allows
 // applications to more easily control global I/O load.
+//
+// Audit loggers (see zap.Audit) are never sampled, so Sample returns them
+// unchanged. Similarly, auditing a sampling logger audits the underlying
+// logger instead.
 func Sample(zl zap.Logger, tick time.Duration, first, thereafter int) zap.Logger {
+	if zap.IsAudit(zl) {
+		return zl
+	}
 	return &sampler{
 		Logger:     zl,
 		tick:       tick,
@@ -110,6 +117,18 @@ func (s *sampler) With(fields ...zap.Field) zap.Logger {
 	}
 }
 
+func (s *sampler) Sync() error {
+	return zap.Sync(s.Logger)
+}
+
+func (s *sampler) Context() []zap.Field {
+	return zap.Context(s.Logger)
+}
+
+func (s *sampler) Audit() zap.Logger {
+	return zap.Audit(s.Logger)
+}
+
 func (s *sampler) Check(lvl zap.Level, msg string) *zap.CheckedMessage {
 	cm := s.Logger.Check(lvl, msg)
 	switch lvl {
@@ -134,6 +153,12 @@ func (s *sampler) Log(lvl zap.Level, msg string, fields ...zap.Field) {
 	}
 }
 
+func (s *sampler) Trace(msg string, fields ...zap.Field) {
+	if s.Logger.Check(zap.TraceLevel, msg) != nil && s.sampled(msg) {
+		s.Logger.Trace(msg, fields...)
+	}
+}
+
 func (s *sampler) Debug(msg string, fields ...zap.Field) {
 	if s.Logger.Check(zap.DebugLevel, msg) != nil && s.sampled(msg) {
 		s.Logger.Debug(msg, fields...)
@@ -21,6 +21,8 @@
 package zwrap
 
 import (
+	"bytes"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -62,6 +64,11 @@ func TestSampler(t *testing.T) {
 		development bool
 		sampled     bool
 	}{
+		{
+			level:   zap.TraceLevel,
+			logFunc: func(sampler zap.Logger, n int) { WithIter(sampler, n).Trace("sample") },
+			sampled: true,
+		},
 		{
 			level:   zap.DebugLevel,
 			logFunc: func(sampler zap.Logger, n int) { WithIter(sampler, n).Debug("sample") },
@@ -103,7 +110,7 @@ func TestSampler(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		sampler, sink := fakeSampler(zap.DebugLevel, time.Minute, 2, 3, tt.development)
+		sampler, sink := fakeSampler(zap.TraceLevel, time.Minute, 2, 3, tt.development)
 		for i := 1; i < 10; i++ {
 			tt.logFunc(sampler, i)
 		}
@@ -120,7 +127,8 @@ func TestSampler(t *testing.T) {
 func TestSampledDisabledLevels(t *testing.T) {
 	sampler, sink := fakeSampler(zap.InfoLevel, time.Minute, 1, 100, false)
 
-	// Shouldn't be counted, because debug logging isn't enabled.
+	// Shouldn't be counted, because trace and debug logging aren't enabled.
+	WithIter(sampler, 1).Trace("sample")
 	WithIter(sampler, 1).Debug("sample")
 	WithIter(sampler, 2).Info("sample")
 	expected := buildExpectation(zap.InfoLevel, 2)
@@ -152,6 +160,11 @@ func TestSamplerWithSharesCounters(t *testing.T) {
 	assert.Equal(t, expected, sink.Logs(), "Expected child loggers to share counters.")
 }
 
+func TestSamplerContext(t *testing.T) {
+	sampler, _ := fakeSampler(zap.DebugLevel, time.Minute, 2, 3, false)
+	assert.Equal(t, []zap.Field{zap.Int("iter", 1)}, zap.Context(WithIter(sampler, 1)), "Expected the sampler to return the wrapped logger's context.")
+}
+
 func TestSamplerTicks(t *testing.T) {
 	// Ensure that we're resetting the sampler's counter every tick.
 	sampler, sink := fakeSampler(zap.DebugLevel, time.Millisecond, 1, 1000, false)
@@ -220,3 +233,21 @@ func TestSamplerRaces(t *testing.T) {
 	close(start)
 	wg.Wait()
 }
+
+func TestSamplerAudit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	base := zap.New(zap.NewJSONEncoder(zap.NoTime()), zap.WarnLevel, zap.Output(zap.AddSync(buf)))
+
+	// An aggressive sampler: one entry per message per hour.
+	sampledAudit := Sample(zap.Audit(base), time.Hour, 1, 1000000)
+	auditedSampler := zap.Audit(Sample(base, time.Hour, 1, 1000000))
+	for i := 0; i < 10; i++ {
+		sampledAudit.Info("login")
+		auditedSampler.Info("export")
+		auditedSampler.Check(zap.InfoLevel, "checked").Write()
+	}
+	out := buf.String()
+	assert.Equal(t, 10, strings.Count(out, `{"level":"info","msg":"login","audit":true}`), "Expected sampling an audit logger to have no effect.")
+	assert.Equal(t, 10, strings.Count(out, `{"level":"info","msg":"export","audit":true}`), "Expected auditing a sampler to bypass sampling.")
+	assert.Equal(t, 10, strings.Count(out, `{"level":"info","msg":"checked","audit":true}`), "Expected auditing a sampler to bypass sampling.")
+}
@@ -29,7 +29,7 @@ import (
 
 // ErrInvalidLevel indicates that the user chose an invalid Level when
 // constructing a StandardLogger.
-var ErrInvalidLevel = errors.New("StandardLogger's print level must be Debug, Info, Warn, or Error")
+var ErrInvalidLevel = errors.New("StandardLogger's print level must be Trace, Debug, Info, Warn, or Error")
 
 // StandardLogger is the interface exposed by the standard library's log.Logger.
 type StandardLogger interface {
@@ -48,14 +48,16 @@ type StandardLogger interface {
 
 // Standardize wraps a Logger to make it compatible with the standard library.
 // It takes the Logger itself, and the level to use for the StandardLogger's
-// Print family of methods. If the specified Level isn't Debug, Info, Warn, or
-// Error, Standardize returns ErrInvalidLevel.
+// Print family of methods. If the specified Level isn't Trace, Debug, Info,
+// Warn, or Error, Standardize returns ErrInvalidLevel.
 func Standardize(l zap.Logger, printAt zap.Level) (StandardLogger, error) {
 	s := stdLogger{
 		panic: l.Panic,
 		fatal: l.Fatal,
 	}
 	switch printAt {
+	case zap.TraceLevel:
+		s.write = l.Trace
 	case zap.DebugLevel:
 		s.write = l.Debug
 	case zap.InfoLevel:
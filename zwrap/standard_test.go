@@ -35,7 +35,7 @@ func newStd(lvl zap.Level) (StandardLogger, *bytes.Buffer, error) {
 	buf := &bytes.Buffer{}
 	logger := zap.New(
 		zap.NewJSONEncoder(),
-		zap.DebugLevel,
+		zap.TraceLevel,
 		zap.Output(zap.AddSync(buf)),
 	)
 	std, err := Standardize(logger, lvl)
@@ -50,7 +50,7 @@ func TestStandardizeInvalidLevels(t *testing.T) {
 }
 
 func TestStandardizeValidLevels(t *testing.T) {
-	for _, level := range []zap.Level{zap.DebugLevel, zap.InfoLevel, zap.WarnLevel, zap.ErrorLevel} {
+	for _, level := range []zap.Level{zap.TraceLevel, zap.DebugLevel, zap.InfoLevel, zap.WarnLevel, zap.ErrorLevel} {
 		std, buf, err := newStd(level)
 		require.NoError(t, err, "Unexpected error calling Standardize with a valid level.")
 		std.Print("foo")